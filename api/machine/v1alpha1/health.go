@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import "time"
+
+const (
+	DefaultHealthCheckInterval = 5 * time.Second
+	DefaultHealthCheckTimeout  = 3 * time.Second
+	DefaultHealthCheckRetries  = 3
+)
+
+// HealthCheck describes a probe which determines whether the machine is able
+// to serve.  Exactly one of TCP, HTTP or Serial should be set.
+type HealthCheck struct {
+	// TCP is the address, in the format [host:]port, to which a connection must
+	// be successfully established.  When no host is provided, the first IP
+	// address of the machine is used, or localhost if it has none.
+	TCP string `json:"tcp,omitempty"`
+
+	// HTTP is the URL which must respond to a GET request with a non-error
+	// status code.
+	HTTP string `json:"http,omitempty"`
+
+	// Serial is a regular expression which must match the serial output of the
+	// machine.
+	Serial string `json:"serial,omitempty"`
+
+	// Interval is the duration between two consecutive probes.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Timeout is the duration after which a single probe is considered failed.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// StartPeriod is the duration after the machine has started during which
+	// failing probes do not count towards the number of retries.
+	StartPeriod time.Duration `json:"startPeriod,omitempty"`
+
+	// Retries is the number of consecutive failing probes after which the
+	// machine is considered unhealthy.
+	Retries int `json:"retries,omitempty"`
+}
+
+// HealthStatus is the result of the health check of a machine.
+type HealthStatus string
+
+const (
+	HealthStatusNone      = HealthStatus("")
+	HealthStatusStarting  = HealthStatus("starting")
+	HealthStatusHealthy   = HealthStatus("healthy")
+	HealthStatusUnhealthy = HealthStatus("unhealthy")
+)
+
+// String implements fmt.Stringer
+func (hs HealthStatus) String() string {
+	return string(hs)
+}
@@ -90,6 +90,22 @@ type MachineSpec struct {
 
 	// Emulation indicates whether to use VMM emulation.
 	Emulation bool `json:"emulation,omitempty"`
+
+	// Snapshot is the fully-qualified path to a previously saved machine state
+	// from which the machine is restored instead of being booted.
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// GDBStub requests a GDB stub to be exposed for the machine, either on the
+	// provided TCP port of the host's loopback interface or at the provided
+	// unix socket path.
+	GDBStub string `json:"gdbStub,omitempty"`
+
+	// RestartPolicy describes whether the machine is restarted after it exits.
+	RestartPolicy RestartPolicy `json:"restartPolicy,omitempty"`
+
+	// HealthCheck describes how to determine whether the machine is able to
+	// serve.
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
 }
 
 // MachineState indicates the state of the machine.
@@ -135,12 +151,43 @@ type MachineStatus struct {
 	// ExitedAt represents when the machine fully shutdown
 	ExitedAt time.Time `json:"exitedAt,omitempty"`
 
+	// RestartCount is the number of times the machine has been restarted
+	// according to its restart policy.
+	RestartCount int `json:"restartCount,omitempty"`
+
+	// ManuallyStopped indicates that the machine was explicitly stopped, in
+	// which case it is not restarted regardless of its restart policy.
+	ManuallyStopped bool `json:"manuallyStopped,omitempty"`
+
+	// Health is the most recent result of the health check of the machine.
+	Health HealthStatus `json:"health,omitempty"`
+
 	// StateDir contains the path of the state of the machine.
 	StateDir string `json:"stateDir,omitempty"`
 
 	// LogFile is the in-host path to the log file of the machine.
 	LogFile string `json:"logFile,omitempty"`
 
+	// GDBStub is the endpoint of the GDB stub of the machine in the format
+	// which is accepted by GDB's "target remote" command.
+	GDBStub string `json:"gdbStub,omitempty"`
+
+	// ConsoleSocket is the in-host path to a unix socket which is connected to
+	// the console of the machine, if supported by the platform.
+	ConsoleSocket string `json:"consoleSocket,omitempty"`
+
+	// SnapshotPath is the in-host path to the most recently saved state of the
+	// machine.
+	SnapshotPath string `json:"snapshotPath,omitempty"`
+
+	// CrashDumpPath is the in-host path to the dump of the guest memory which
+	// was taken when the machine panicked.
+	CrashDumpPath string `json:"crashDumpPath,omitempty"`
+
+	// CrashLogPath is the in-host path to the last lines of serial output of the
+	// machine which were recorded when it panicked.
+	CrashLogPath string `json:"crashLogPath,omitempty"`
+
 	// PlatformConfig is platform-specific attributes which are populated by the
 	// underlying machine service implementation.
 	PlatformConfig interface{} `json:"platformConfig,omitempty"`
@@ -154,6 +201,7 @@ type MachineService interface {
 	Pause(context.Context, *Machine) (*Machine, error)
 	Stop(context.Context, *Machine) (*Machine, error)
 	Update(context.Context, *Machine) (*Machine, error)
+	Snapshot(context.Context, *Machine) (*Machine, error)
 	Delete(context.Context, *Machine) (*Machine, error)
 	Get(context.Context, *Machine) (*Machine, error)
 	List(context.Context, *MachineList) (*MachineList, error)
@@ -164,16 +212,17 @@ type MachineService interface {
 // MachineServiceHandler provides a Zip API Object Framework service for the
 // machine.
 type MachineServiceHandler struct {
-	create zip.MethodStrategy[*Machine, *Machine]
-	start  zip.MethodStrategy[*Machine, *Machine]
-	pause  zip.MethodStrategy[*Machine, *Machine]
-	stop   zip.MethodStrategy[*Machine, *Machine]
-	update zip.MethodStrategy[*Machine, *Machine]
-	delete zip.MethodStrategy[*Machine, *Machine]
-	get    zip.MethodStrategy[*Machine, *Machine]
-	list   zip.MethodStrategy[*MachineList, *MachineList]
-	watch  zip.StreamStrategy[*Machine, *Machine]
-	logs   zip.StreamStrategy[*Machine, string]
+	create   zip.MethodStrategy[*Machine, *Machine]
+	start    zip.MethodStrategy[*Machine, *Machine]
+	pause    zip.MethodStrategy[*Machine, *Machine]
+	stop     zip.MethodStrategy[*Machine, *Machine]
+	update   zip.MethodStrategy[*Machine, *Machine]
+	snapshot zip.MethodStrategy[*Machine, *Machine]
+	delete   zip.MethodStrategy[*Machine, *Machine]
+	get      zip.MethodStrategy[*Machine, *Machine]
+	list     zip.MethodStrategy[*MachineList, *MachineList]
+	watch    zip.StreamStrategy[*Machine, *Machine]
+	logs     zip.StreamStrategy[*Machine, string]
 }
 
 // Create implements MachineService
@@ -201,6 +250,11 @@ func (client *MachineServiceHandler) Update(ctx context.Context, req *Machine) (
 	return client.update.Do(ctx, req)
 }
 
+// Snapshot implements MachineService
+func (client *MachineServiceHandler) Snapshot(ctx context.Context, req *Machine) (*Machine, error) {
+	return client.snapshot.Do(ctx, req)
+}
+
 // Delete implements MachineService
 func (client *MachineServiceHandler) Delete(ctx context.Context, req *Machine) (*Machine, error) {
 	return client.delete.Do(ctx, req)
@@ -258,6 +312,11 @@ func NewMachineServiceHandler(ctx context.Context, impl MachineService, opts ...
 		return nil, err
 	}
 
+	snapshot, err := zip.NewMethodClient(ctx, impl.Snapshot, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	delete, err := zip.NewMethodClient(ctx, impl.Delete, opts...)
 	if err != nil {
 		return nil, err
@@ -289,6 +348,7 @@ func NewMachineServiceHandler(ctx context.Context, impl MachineService, opts ...
 		pause,
 		stop,
 		update,
+		snapshot,
 		delete,
 		get,
 		list,
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RestartPolicyName indicates when a machine should be restarted after it has
+// exited.
+type RestartPolicyName string
+
+const (
+	RestartPolicyNo        = RestartPolicyName("no")
+	RestartPolicyOnFailure = RestartPolicyName("on-failure")
+	RestartPolicyAlways    = RestartPolicyName("always")
+)
+
+// RestartPolicy describes how a machine should be restarted after it has
+// exited.
+type RestartPolicy struct {
+	// Name of the policy.
+	Name RestartPolicyName `json:"name,omitempty"`
+
+	// MaximumRetryCount is the number of times a machine which exits with a
+	// failure is restarted before giving up.  A value of zero indicates that the
+	// machine is restarted indefinitely.  Only used with the on-failure policy.
+	MaximumRetryCount int `json:"maximumRetryCount,omitempty"`
+}
+
+// ParseRestartPolicy parses a string representation of a RestartPolicy in the
+// "docker-like" syntax, i.e. "no", "on-failure[:max-retries]" or "always".
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	name, count, hasCount := strings.Cut(s, ":")
+
+	policy := RestartPolicy{
+		Name: RestartPolicyName(name),
+	}
+
+	switch policy.Name {
+	case "", RestartPolicyNo, RestartPolicyAlways:
+		if hasCount {
+			return RestartPolicy{}, fmt.Errorf("maximum retry count cannot be used with restart policy '%s'", name)
+		}
+
+	case RestartPolicyOnFailure:
+		if !hasCount {
+			break
+		}
+
+		max, err := strconv.Atoi(count)
+		if err != nil || max < 0 {
+			return RestartPolicy{}, fmt.Errorf("invalid maximum retry count: %s", count)
+		}
+
+		policy.MaximumRetryCount = max
+
+	default:
+		return RestartPolicy{}, fmt.Errorf("invalid restart policy '%s': must be one of no, on-failure[:max-retries] or always", name)
+	}
+
+	return policy, nil
+}
+
+// String implements fmt.Stringer and outputs the RestartPolicy in the same
+// format which is accepted by ParseRestartPolicy.
+func (policy RestartPolicy) String() string {
+	if policy.Name == "" {
+		return string(RestartPolicyNo)
+	}
+
+	if policy.Name == RestartPolicyOnFailure && policy.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", policy.Name, policy.MaximumRetryCount)
+	}
+
+	return string(policy.Name)
+}
+
+// ShouldRestart determines whether a machine which has reached the provided
+// state with the provided exit code should be restarted, given the number of
+// times it has already been restarted.
+func (policy RestartPolicy) ShouldRestart(state MachineState, exitCode, restartCount int) bool {
+	switch state {
+	case MachineStateExited, MachineStateFailed, MachineStateErrored:
+	default:
+		return false
+	}
+
+	switch policy.Name {
+	case RestartPolicyAlways:
+		return true
+
+	case RestartPolicyOnFailure:
+		if state == MachineStateExited && exitCode == 0 {
+			return false
+		}
+
+		return policy.MaximumRetryCount == 0 || restartCount < policy.MaximumRetryCount
+	}
+
+	return false
+}
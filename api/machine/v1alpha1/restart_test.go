@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import "testing"
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		in     string
+		expect RestartPolicy
+		err    bool
+	}{
+		{in: "", expect: RestartPolicy{}},
+		{in: "no", expect: RestartPolicy{Name: RestartPolicyNo}},
+		{in: "always", expect: RestartPolicy{Name: RestartPolicyAlways}},
+		{in: "on-failure", expect: RestartPolicy{Name: RestartPolicyOnFailure}},
+		{in: "on-failure:3", expect: RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: 3}},
+		{in: "on-failure:-1", err: true},
+		{in: "on-failure:x", err: true},
+		{in: "always:3", err: true},
+		{in: "sometimes", err: true},
+	}
+
+	for _, tt := range tests {
+		policy, err := ParseRestartPolicy(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseRestartPolicy(%q): expected an error", tt.in)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("ParseRestartPolicy(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+
+		if policy != tt.expect {
+			t.Errorf("ParseRestartPolicy(%q): expected %+v, got %+v", tt.in, tt.expect, policy)
+		}
+	}
+}
+
+func TestRestartPolicyShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy       RestartPolicy
+		state        MachineState
+		exitCode     int
+		restartCount int
+		expect       bool
+	}{
+		{policy: RestartPolicy{}, state: MachineStateFailed, exitCode: 1},
+		{policy: RestartPolicy{Name: RestartPolicyNo}, state: MachineStateFailed, exitCode: 1},
+		{policy: RestartPolicy{Name: RestartPolicyAlways}, state: MachineStateExited, expect: true},
+		{policy: RestartPolicy{Name: RestartPolicyAlways}, state: MachineStateRunning},
+		{policy: RestartPolicy{Name: RestartPolicyOnFailure}, state: MachineStateExited},
+		{policy: RestartPolicy{Name: RestartPolicyOnFailure}, state: MachineStateExited, exitCode: 1, restartCount: 10, expect: true},
+		{policy: RestartPolicy{Name: RestartPolicyOnFailure}, state: MachineStateErrored, expect: true},
+		{policy: RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: 2}, state: MachineStateFailed, exitCode: 1, restartCount: 1, expect: true},
+		{policy: RestartPolicy{Name: RestartPolicyOnFailure, MaximumRetryCount: 2}, state: MachineStateFailed, exitCode: 1, restartCount: 2},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.ShouldRestart(tt.state, tt.exitCode, tt.restartCount); got != tt.expect {
+			t.Errorf("%s.ShouldRestart(%s, %d, %d): expected %t, got %t", tt.policy, tt.state, tt.exitCode, tt.restartCount, tt.expect, got)
+		}
+	}
+}
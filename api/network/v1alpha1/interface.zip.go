@@ -6,6 +6,7 @@ package v1alpha1
 
 import (
 	zip "api.zip"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,8 +29,36 @@ type NetworkInterfaceSpec struct {
 	// IP address of a machine interface.
 	IP string `json:"ip,omitempty"`
 
+	// IPv6 address of a machine interface on dual-stack or IPv6-only networks.
+	IP6 string `json:"ip6,omitempty"`
+
 	// Hardware address of a machine interface.
 	MacAddress string `json:"mac,omitempty"`
+
+	// Aliases are additional names which resolve to the IP address of this
+	// interface on the network.
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Ports which are published on the host and forwarded to the IP address of
+	// this interface.
+	Ports []NetworkInterfacePort `json:"ports,omitempty"`
+}
+
+// NetworkInterfacePort represents a port on the host which is forwarded to a
+// port on the network interface.
+type NetworkInterfacePort struct {
+	// Address on the host which the port is bound to.  When unset, the port is
+	// published on all local addresses.
+	HostIP string `json:"hostIP,omitempty"`
+
+	// Port on the host which is forwarded.
+	HostPort int32 `json:"hostPort"`
+
+	// Port on the interface which traffic is forwarded to.
+	InterfacePort int32 `json:"interfacePort"`
+
+	// Protocol of the port.  Defaults to "TCP".
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
 }
 
 // NetworkInterfaceTemplateSpec describes the data a network interface should
@@ -59,4 +88,17 @@ func (ms NetworkInterfaceState) String() string {
 type NetworkInterfaceStatus struct {
 	// State is the current state of the network interface.
 	State NetworkInterfaceState `json:"state"`
+
+	// The name of the host interface, e.g. the tap device of the machine.
+	IfName string `json:"ifname,omitempty"`
+
+	// Statistics of the host interface.
+	RxBytes   uint64 `json:"rxBytes"`
+	RxDropped uint64 `json:"rxDropped"`
+	RxErrors  uint64 `json:"rxErrors"`
+	RxPackets uint64 `json:"rxPackets"`
+	TxBytes   uint64 `json:"txBytes"`
+	TxDropped uint64 `json:"txDropped"`
+	TxErrors  uint64 `json:"txErrors"`
+	TxPackets uint64 `json:"txPackets"`
 }
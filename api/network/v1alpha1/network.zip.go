@@ -34,6 +34,31 @@ type NetworkSpec struct {
 	// range.
 	Netmask string `json:"netmask,omitempty"`
 
+	// The IPv6 gateway address of dual-stack or IPv6-only networks.
+	Gateway6 string `json:"gateway6,omitempty"`
+
+	// The IPv6 network mask to apply over the IPv6 gateway address to gather the
+	// IPv6 subnet range, e.g. "ffff:ffff:ffff:ffff::" for a /64 prefix.
+	Netmask6 string `json:"netmask6,omitempty"`
+
+	// DHCP enables the built-in DHCP server of the network which hands out
+	// addresses to the machines attached to it.
+	DHCP bool `json:"dhcp,omitempty"`
+
+	// DNS enables the embedded DNS server of the network which resolves the
+	// names and aliases of the machines attached to it and forwards all other
+	// queries to the upstream name servers of the host.
+	DNS bool `json:"dns,omitempty"`
+
+	// DisableNAT disables the masquerading of outbound traffic of the network,
+	// which otherwise allows machines to reach external networks through the
+	// host.
+	DisableNAT bool `json:"disableNAT,omitempty"`
+
+	// Internal restricts the traffic of the network to the machines attached to
+	// it and the host, such that machines cannot reach external networks.
+	Internal bool `json:"internal,omitempty"`
+
 	// Network interfaces associated with this network.
 	Interfaces []NetworkInterfaceTemplateSpec `json:"interfaces,omitempty"`
 }
@@ -92,6 +117,10 @@ type NetworkStatus struct {
 	TxPackets         uint64 `json:"txPackets"`
 	TxWindowErrors    uint64 `json:"txWindowErrors"`
 
+	// Interfaces contains the status of the host interfaces of the machines
+	// which are attached to the network, e.g. tap devices.
+	Interfaces []NetworkInterfaceStatus `json:"interfaces,omitempty"`
+
 	// DriverConfig is driver-specific attributes which are populated by the
 	// underlying network implementation.
 	DriverConfig interface{} `json:"driverConfig,omitempty"`
@@ -107,6 +136,7 @@ type NetworkService interface {
 	Delete(context.Context, *Network) (*Network, error)
 	Get(context.Context, *Network) (*Network, error)
 	List(context.Context, *NetworkList) (*NetworkList, error)
+	Watch(context.Context, *Network) (chan *Network, chan error, error)
 }
 
 // NetworkServiceHandler provides a Zip API Object Framework service for the
@@ -119,6 +149,7 @@ type NetworkServiceHandler struct {
 	delete zip.MethodStrategy[*Network, *Network]
 	get    zip.MethodStrategy[*Network, *Network]
 	list   zip.MethodStrategy[*NetworkList, *NetworkList]
+	watch  zip.StreamStrategy[*Network, *Network]
 }
 
 // Create implements NetworkService
@@ -156,6 +187,11 @@ func (client *NetworkServiceHandler) List(ctx context.Context, req *NetworkList)
 	return client.list.Do(ctx, req)
 }
 
+// Watch implements NetworkService
+func (client *NetworkServiceHandler) Watch(ctx context.Context, req *Network) (chan *Network, chan error, error) {
+	return client.watch.Channel(ctx, req)
+}
+
 // NewNetworkServiceHandler returns a service based on an inline API
 // client which essentially wraps the specific call, enabling pre- and post-
 // call hooks.  This is useful for wrapping the command with decorators, for
@@ -198,6 +234,11 @@ func NewNetworkServiceHandler(ctx context.Context, impl NetworkService, opts ...
 		return nil, err
 	}
 
+	watch, err := zip.NewStreamClient(ctx, impl.Watch, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	return &NetworkServiceHandler{
 		create,
 		start,
@@ -206,5 +247,6 @@ func NewNetworkServiceHandler(ctx context.Context, impl NetworkService, opts ...
 		delete,
 		get,
 		list,
+		watch,
 	}, nil
 }
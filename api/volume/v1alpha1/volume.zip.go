@@ -39,6 +39,16 @@ type VolumeSpec struct {
 
 	// Mark whether the volume is readonly.
 	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// Format of the disk image of block device volumes, e.g. raw or qcow2.
+	Format string `json:"format,omitempty"`
+
+	// FsType is the filesystem of block device volumes which is mounted in the
+	// machine.
+	FsType string `json:"fsType,omitempty"`
+
+	// Size in bytes of the disk image of block device volumes.
+	Size int64 `json:"size,omitempty"`
 }
 
 // VolumeTemplateSpec describes the data a volume should have when created
@@ -75,6 +85,15 @@ type VolumeStatus struct {
 	// State is the current state of the volume.
 	State VolumeState `json:"state"`
 
+	// Managed is set when the host directory of the volume was created by the
+	// volume driver, which is the case for named volumes, such that it is
+	// removed along with the volume.
+	Managed bool `json:"managed,omitempty"`
+
+	// Machines contains the names of the machines which the volume is attached
+	// to.  A volume cannot be removed whilst it is attached to a machine.
+	Machines []string `json:"machines,omitempty"`
+
 	// DriverConfig is driver-specific attributes which are populated by the
 	// underlying volume implementation.
 	DriverConfig interface{} `json:"driverConfig,omitempty"`
@@ -84,6 +103,7 @@ type VolumeStatus struct {
 // by an implementing network driver.
 type VolumeService interface {
 	Create(context.Context, *Volume) (*Volume, error)
+	Update(context.Context, *Volume) (*Volume, error)
 	Delete(context.Context, *Volume) (*Volume, error)
 	Get(context.Context, *Volume) (*Volume, error)
 	List(context.Context, *VolumeList) (*VolumeList, error)
@@ -93,6 +113,7 @@ type VolumeService interface {
 // volume.
 type VolumeServiceHandler struct {
 	create zip.MethodStrategy[*Volume, *Volume]
+	update zip.MethodStrategy[*Volume, *Volume]
 	delete zip.MethodStrategy[*Volume, *Volume]
 	get    zip.MethodStrategy[*Volume, *Volume]
 	list   zip.MethodStrategy[*VolumeList, *VolumeList]
@@ -103,6 +124,11 @@ func (client *VolumeServiceHandler) Create(ctx context.Context, req *Volume) (*V
 	return client.create.Do(ctx, req)
 }
 
+// Update implements VolumeService
+func (client *VolumeServiceHandler) Update(ctx context.Context, req *Volume) (*Volume, error) {
+	return client.update.Do(ctx, req)
+}
+
 // Delete implements VolumeService
 func (client *VolumeServiceHandler) Delete(ctx context.Context, req *Volume) (*Volume, error) {
 	return client.delete.Do(ctx, req)
@@ -130,6 +156,11 @@ func NewVolumeServiceHandler(ctx context.Context, impl VolumeService, opts ...zi
 		return nil, err
 	}
 
+	update, err := zip.NewMethodClient(ctx, impl.Update, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	delete, err := zip.NewMethodClient(ctx, impl.Delete, opts...)
 	if err != nil {
 		return nil, err
@@ -147,6 +178,7 @@ func NewVolumeServiceHandler(ctx context.Context, impl VolumeService, opts ...zi
 
 	return &VolumeServiceHandler{
 		create,
+		update,
 		delete,
 		get,
 		list,
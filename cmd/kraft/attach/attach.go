@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package attach
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/attach"
+	"kraftkit.sh/iostreams"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type Attach struct {
+	DetachKeys string `long:"detach-keys" usage:"Override the key sequence for detaching from the console" default:"ctrl-p,ctrl-q"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Attach{}, cobra.Command{
+		Short: "Attach to the console of a running unikernel",
+		Use:   "attach [FLAGS] MACHINE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Attach the terminal's standard input and output to the console of a
+			running unikernel.
+
+			Detach from the console and leave the unikernel running by entering the
+			detach key sequence, which defaults to Ctrl-P followed by Ctrl-Q.`),
+		Example: heredoc.Doc(`
+			Attach to the console of a running unikernel:
+			$ kraft attach my-machine
+
+			Attach to the console and detach with Ctrl-A followed by d:
+			$ kraft attach --detach-keys=ctrl-a,d my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Attach) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	detachKeys, err := attach.ParseDetachKeys(opts.DetachKeys)
+	if err != nil {
+		return err
+	}
+
+	controller, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var machine *machineapi.Machine
+
+	for i, found := range machines.Items {
+		if args[0] == found.Name || args[0] == string(found.UID) {
+			machine = &machines.Items[i]
+			break
+		}
+	}
+
+	if machine == nil {
+		return fmt.Errorf("machine not found: %s", args[0])
+	}
+
+	switch machine.Status.State {
+	case machineapi.MachineStateRunning, machineapi.MachineStatePaused:
+	default:
+		return fmt.Errorf("cannot attach to machine in %s state", machine.Status.State)
+	}
+
+	if machine.Status.ConsoleSocket == "" {
+		return fmt.Errorf("machine %s does not support attaching to its console", machine.Name)
+	}
+
+	conn, err := net.Dial("unix", machine.Status.ConsoleSocket)
+	if err != nil {
+		return fmt.Errorf("could not connect to console: %w", err)
+	}
+
+	var out io.Writer = iostreams.G(ctx).Out
+
+	// Pass every key press directly to the machine, such that it is the
+	// machine which interprets special keys, e.g. Ctrl-C.
+	if iostreams.G(ctx).IsStdinTTY() {
+		fd := int(iostreams.G(ctx).In.Fd())
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("could not set terminal to raw mode: %w", err)
+		}
+
+		defer func() {
+			_ = term.Restore(fd, state)
+		}()
+
+		out = attach.NewCRLFWriter(out)
+	}
+
+	fmt.Fprintf(iostreams.G(ctx).ErrOut, "attached to %s: use %s to detach\r\n", machine.Name, opts.DetachKeys)
+
+	err = attach.Attach(ctx, conn, iostreams.G(ctx).In, out, detachKeys)
+	if errors.Is(err, attach.ErrDetached) {
+		fmt.Fprintf(iostreams.G(ctx).ErrOut, "\r\ndetached from %s\r\n", machine.Name)
+		return nil
+	}
+
+	return err
+}
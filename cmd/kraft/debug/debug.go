@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package debug
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type Debug struct {
+	GDB string `long:"gdb" usage:"Path to the GDB binary to use" default:"gdb"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Debug{}, cobra.Command{
+		Short: "Attach a debugger to a unikernel",
+		Use:   "debug [FLAGS] MACHINE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Launch GDB pre-loaded with the symbolic kernel of a unikernel and connect
+			it to the GDB stub of the unikernel.
+
+			The unikernel must have been started with the --gdb flag of kraft run,
+			which leaves it in the created state until the debugger resumes it, e.g.
+			via GDB's continue command.`),
+		Example: heredoc.Doc(`
+			Start a unikernel which waits for a debugger and attach GDB to it:
+			$ kraft run --gdb 1234 --name my-machine
+			$ kraft debug my-machine
+
+			Use an alternative GDB binary:
+			$ kraft debug --gdb gdb-multiarch my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Debug) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	controller, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var machine *machineapi.Machine
+
+	for i, found := range machines.Items {
+		if args[0] == found.Name || args[0] == string(found.UID) {
+			machine = &machines.Items[i]
+			break
+		}
+	}
+
+	if machine == nil {
+		return fmt.Errorf("machine not found: %s", args[0])
+	}
+
+	if machine.Status.GDBStub == "" {
+		return fmt.Errorf("machine %s does not expose a GDB stub: start it with kraft run --gdb", machine.Name)
+	}
+
+	switch machine.Status.State {
+	case machineapi.MachineStateExited, machineapi.MachineStateFailed, machineapi.MachineStateErrored:
+		return fmt.Errorf("cannot debug machine in %s state", machine.Status.State)
+	}
+
+	gdb := exec.CommandContext(ctx,
+		opts.GDB,
+		machine.Status.KernelPath,
+		"-ex", "target remote "+machine.Status.GDBStub,
+	)
+	gdb.Stdin = iostreams.G(ctx).In
+	gdb.Stdout = iostreams.G(ctx).Out
+	gdb.Stderr = iostreams.G(ctx).ErrOut
+
+	log.G(ctx).
+		WithField("kernel", machine.Status.KernelPath).
+		WithField("gdb", machine.Status.GDBStub).
+		Debug("launching debugger")
+
+	return gdb.Run()
+}
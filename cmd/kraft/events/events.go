@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
@@ -23,6 +24,7 @@ import (
 	"kraftkit.sh/internal/set"
 	"kraftkit.sh/internal/waitgroup"
 	"kraftkit.sh/log"
+	"kraftkit.sh/machine/health"
 	"kraftkit.sh/machine/platform"
 	mplatform "kraftkit.sh/machine/platform"
 	"kraftkit.sh/machine/qemu/qmp"
@@ -61,7 +63,24 @@ func New() *cobra.Command {
 	return cmd
 }
 
-var observations = waitgroup.WaitGroup[*machineapi.Machine]{}
+const (
+	// DefaultRestartBackoff is the initial delay before restarting a machine
+	// which has exited, which is doubled with every consecutive restart.
+	DefaultRestartBackoff = time.Second
+
+	// MaxRestartBackoff is the upper bound of the delay before restarting a
+	// machine which has exited.
+	MaxRestartBackoff = time.Minute
+
+	// RestartBackoffReset is the duration a restarted machine must be running
+	// for before the delay is reset to DefaultRestartBackoff.
+	RestartBackoffReset = 10 * time.Second
+)
+
+var (
+	observations = waitgroup.WaitGroup[*machineapi.Machine]{}
+	supervised   = sync.Map{}
+)
 
 func (opts *Events) Pre(cmd *cobra.Command, _ []string) error {
 	opts.platform = cmd.Flag("plat").Value.String()
@@ -168,7 +187,9 @@ seek:
 			return fmt.Errorf("could not list machines: %v", err)
 		}
 
-		for _, machine := range machines.Items {
+		for i := range machines.Items {
+			machine := &machines.Items[i]
+
 			if len(args) == 0 || (args[0] == string(machine.UID) || args[0] == machine.Name) {
 				switch machine.Status.State {
 				case machineapi.MachineStateFailed,
@@ -180,7 +201,22 @@ seek:
 				default:
 				}
 
-				observations.Add(&machine)
+				// Machines which have exited for good need no supervision.
+				if machine.Status.State == machineapi.MachineStateExited || machine.Status.State == machineapi.MachineStateFailed {
+					policy := machine.Spec.RestartPolicy
+					if machine.Status.ManuallyStopped || !policy.ShouldRestart(machine.Status.State, machine.Status.ExitCode, machine.Status.RestartCount) {
+						continue
+					}
+				}
+
+				// Only supervise each machine once.
+				if _, loaded := supervised.LoadOrStore(machine.UID, true); loaded {
+					continue
+				}
+
+				observations.Add(machine)
+
+				go opts.supervise(ctx, controller, machine)
 			}
 		}
 
@@ -189,45 +225,171 @@ seek:
 			break seek
 		}
 
-		for _, machine := range observations.Items() {
-			machine := machine // loop closure
+		time.Sleep(time.Second * opts.Granularity)
+	}
 
-			go func() {
-				events, errs, err := controller.Watch(ctx, machine)
-				if err != nil {
-					log.G(ctx).Debugf("could not listen for status updates for %s: %v", machine.Name, err)
-					return
-				}
+	observations.Wait()
 
-				for {
-					// Wait on either channel
-					select {
-					case machine := <-events:
-						log.G(ctx).Infof("%s : %s", machine.Name, machine.Status.State.String())
-						switch machine.Status.State {
-						case machineapi.MachineStateExited, machineapi.MachineStateFailed:
-							observations.Done(machine)
-							return
-						}
-
-					case err := <-errs:
-						if !errors.Is(err, qmp.ErrAcceptedNonEvent) {
-							log.G(ctx).Errorf("%v", err)
-						}
-						observations.Done(machine)
-
-					case <-ctx.Done():
-						observations.Done(machine)
-						return
-					}
-				}
-			}()
+	return nil
+}
+
+// supervise follows the events of the provided machine and restarts it
+// according to its restart policy whenever it exits.
+func (opts *Events) supervise(ctx context.Context, controller machineapi.MachineService, machine *machineapi.Machine) {
+	defer observations.Done(machine)
+
+	// Allow the machine to be supervised again once it is, e.g., re-created
+	// with the same UID.
+	defer supervised.Delete(machine.UID)
+
+	backoff := DefaultRestartBackoff
+
+	for {
+		switch machine.Status.State {
+		case machineapi.MachineStateExited,
+			machineapi.MachineStateFailed,
+			machineapi.MachineStateErrored:
+		default:
+			var err error
+			machine, err = opts.watch(ctx, controller, machine)
+			if err != nil {
+				log.G(ctx).Debugf("could not listen for status updates for %s: %v", machine.Name, err)
+				return
+			}
 		}
 
-		time.Sleep(time.Second * opts.Granularity)
+		if ctx.Err() != nil || machine.Status.ManuallyStopped {
+			return
+		}
+
+		policy := machine.Spec.RestartPolicy
+		if !policy.ShouldRestart(machine.Status.State, machine.Status.ExitCode, machine.Status.RestartCount) {
+			return
+		}
+
+		if mplatform.PlatformByName(machine.Spec.Platform) != mplatform.PlatformQEMU {
+			log.G(ctx).Errorf("cannot restart %s: restart policies are not supported by the %s platform driver", machine.Name, machine.Spec.Platform)
+			return
+		}
+
+		// Reset the delay if the machine was running for a while, such that only
+		// machines which repeatedly exit are restarted increasingly slowly.
+		if !machine.Status.StartedAt.IsZero() && time.Since(machine.Status.StartedAt) > RestartBackoffReset+backoff {
+			backoff = DefaultRestartBackoff
+		}
+
+		log.G(ctx).Debugf("%s : restarting in %s", machine.Name, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > MaxRestartBackoff {
+			backoff = MaxRestartBackoff
+		}
+
+		// The machine may have been stopped or removed in the meantime.
+		latest, err := lookup(ctx, controller, machine)
+		if err != nil {
+			log.G(ctx).Errorf("could not look up %s: %v", machine.Name, err)
+			return
+		} else if latest == nil || latest.Status.ManuallyStopped {
+			return
+		}
+
+		latest.Status.State = machineapi.MachineStateRestarting
+		latest.Status.RestartCount = machine.Status.RestartCount + 1
+		log.G(ctx).Infof("%s : %s (%d)", latest.Name, latest.Status.State.String(), latest.Status.RestartCount)
+
+		machine, err = controller.Start(ctx, latest)
+		if err != nil {
+			log.G(ctx).Errorf("could not restart %s: %v", latest.Name, err)
+			return
+		}
 	}
+}
 
-	observations.Wait()
+// watch follows the events of the provided machine until it exits, returning
+// its final state.
+func (opts *Events) watch(ctx context.Context, controller machineapi.MachineService, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	events, errs, err := controller.Watch(ctx, machine)
+	if err != nil {
+		return machine, err
+	}
 
-	return nil
+	// Check the health of the machine for as long as it is being watched.
+	if machine.Spec.HealthCheck != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		go checkHealth(ctx, machine)
+	}
+
+	for {
+		// Wait on either channel
+		select {
+		case update := <-events:
+			machine = update
+			log.G(ctx).Infof("%s : %s", machine.Name, machine.Status.State.String())
+			switch machine.Status.State {
+			case machineapi.MachineStateExited,
+				machineapi.MachineStateFailed,
+				machineapi.MachineStateErrored:
+				return machine, nil
+			}
+
+		case err := <-errs:
+			if !errors.Is(err, qmp.ErrAcceptedNonEvent) {
+				return machine, err
+			}
+
+		case <-ctx.Done():
+			return machine, nil
+		}
+	}
+}
+
+// checkHealth periodically probes the provided machine and records every
+// change of its health status until the context is cancelled.
+func checkHealth(ctx context.Context, machine *machineapi.Machine) {
+	checker := health.NewChecker(machine)
+	status := machineapi.HealthStatusNone
+
+	for {
+		if latest := checker.Check(ctx); latest != status {
+			status = latest
+			log.G(ctx).Infof("%s : %s", machine.Name, status.String())
+
+			if err := health.Save(machine, status); err != nil {
+				log.G(ctx).Errorf("could not save health status of %s: %v", machine.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(checker.Interval()):
+		}
+	}
+}
+
+// lookup retrieves the latest version of the provided machine from the
+// controller, returning nil if it no longer exists.
+func lookup(ctx context.Context, controller machineapi.MachineService, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, found := range machines.Items {
+		if found.UID == machine.UID {
+			return &machines.Items[i], nil
+		}
+	}
+
+	return nil, nil
 }
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/initrdref"
+	"kraftkit.sh/iostreams"
+)
+
+type Diff struct {
+	Architecture string `long:"arch" short:"m" usage:"Set the architecture of the packages to inspect"`
+	Output       string `long:"output" short:"o" usage:"Set output format (text or json)" default:"text"`
+	Platform     string `long:"plat" short:"p" usage:"Set the platform of the packages to inspect"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Diff{}, cobra.Command{
+		Short: "Show the differences between two initramfs",
+		Use:   "diff [FLAGS] FILE|DIR|MACHINE|PACKAGE FILE|DIR|MACHINE|PACKAGE",
+		Args:  cobra.ExactArgs(2),
+		Long: heredoc.Doc(`
+			Show the differences between two initramfs
+
+			Entries which were added (A), deleted (D) or modified (M) in the second
+			initramfs are listed.  Entries are modified when their type, permissions,
+			owner, size or contents differ, whereas modification times are ignored.
+			Directories are compared as they would be serialized, i.e. owned by root
+			and without the paths excluded by their .kraftignore file.`),
+		Example: heredoc.Doc(`
+			# Compare the initramfs of a running machine with the one it was built from
+			$ kraft initrd diff .unikraft/build/initramfs.cpio my-machine
+
+			# Compare a rootfs directory with the initramfs of a package
+			$ kraft initrd diff unikraft.org/nginx:latest ./rootfs`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "pkg",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Diff) Pre(cmd *cobra.Command, _ []string) error {
+	if opts.Output != "text" && opts.Output != "json" {
+		return fmt.Errorf("unsupported output format: %s", opts.Output)
+	}
+
+	return nil
+}
+
+func (opts *Diff) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	var entries [2][]initrd.Entry
+
+	for i, ref := range args {
+		source, err := initrdref.Resolve(ctx, ref, opts.Platform, opts.Architecture)
+		if err != nil {
+			return err
+		}
+
+		entries[i], err = source.Entries(initrd.WithOwner(0, 0))
+		source.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	changes := initrd.Diff(entries[0], entries[1])
+
+	if opts.Output == "json" {
+		if changes == nil {
+			changes = []initrd.Change{}
+		}
+
+		ret, err := json.Marshal(changes)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(iostreams.G(ctx).Out, "%s\n", ret)
+
+		return nil
+	}
+
+	cs := iostreams.G(ctx).ColorScheme()
+
+	for _, change := range changes {
+		line := string(change.Type) + " " + change.Path
+
+		switch change.Type {
+		case initrd.ChangeAdded:
+			line = cs.Green(line)
+		case initrd.ChangeRemoved:
+			line = cs.Red(line)
+		case initrd.ChangeModified:
+			line = cs.Yellow(line) + cs.Gray(" ("+strings.Join(modifications(change), ", ")+")")
+		}
+
+		fmt.Fprintln(iostreams.G(ctx).Out, line)
+	}
+
+	return nil
+}
+
+// modifications returns the descriptions of the attributes which differ
+// between the entries of a modified change.
+func modifications(change initrd.Change) []string {
+	var ret []string
+
+	before, after := change.Before, change.After
+
+	if before.Mode != after.Mode {
+		ret = append(ret, fmt.Sprintf("mode %s -> %s", before.Mode, after.Mode))
+	}
+	if before.UID != after.UID || before.GID != after.GID {
+		ret = append(ret, fmt.Sprintf("owner %d:%d -> %d:%d", before.UID, before.GID, after.UID, after.GID))
+	}
+	if before.Linkname != after.Linkname {
+		ret = append(ret, fmt.Sprintf("link %s -> %s", before.Linkname, after.Linkname))
+	}
+	if before.Size != after.Size {
+		ret = append(ret, fmt.Sprintf("size %d -> %d", before.Size, after.Size))
+	} else if before.Digest != after.Digest {
+		ret = append(ret, "contents")
+	}
+
+	return ret
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package extract
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/initrdref"
+	"kraftkit.sh/iostreams"
+)
+
+type Extract struct {
+	Architecture string `long:"arch" short:"m" usage:"Set the architecture of the package to inspect"`
+	Platform     string `long:"plat" short:"p" usage:"Set the platform of the package to inspect"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Extract{}, cobra.Command{
+		Short: "Extract the entries of an initramfs to a directory",
+		Use:   "extract [FLAGS] FILE|MACHINE|PACKAGE DIR",
+		Args:  cobra.ExactArgs(2),
+		Example: heredoc.Doc(`
+			# Extract a CPIO archive to a directory
+			$ kraft initrd extract .unikraft/build/initramfs.cpio ./rootfs
+
+			# Extract the initramfs of a package to a directory
+			$ kraft initrd extract unikraft.org/nginx:latest ./rootfs`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "pkg",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Extract) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	source, err := initrdref.Resolve(ctx, args[0], opts.Platform, opts.Architecture)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	if source.IsDir {
+		return fmt.Errorf("cannot extract %s: not an initramfs", args[0])
+	}
+
+	if err := os.MkdirAll(args[1], 0o755); err != nil {
+		return err
+	}
+
+	if err := initrd.Extract(ctx, source.Path, args[1]); err != nil {
+		return fmt.Errorf("could not extract initramfs: %w", err)
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, args[1])
+
+	return nil
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmd/kraft/initrd/diff"
+	"kraftkit.sh/cmd/kraft/initrd/extract"
+	"kraftkit.sh/cmd/kraft/initrd/ls"
+	"kraftkit.sh/cmdfactory"
+)
+
+type Initrd struct{}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Initrd{}, cobra.Command{
+		Short:   "Inspect initramfs CPIO archives",
+		Use:     "initrd SUBCOMMAND",
+		Aliases: []string{"initramfs"},
+		Long: heredoc.Doc(`
+			Inspect initramfs CPIO archives
+
+			Each command accepts a path to a, possibly compressed, CPIO archive or a
+			directory, the name of a machine or the name of a package.`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "pkg",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.AddCommand(diff.New())
+	cmd.AddCommand(extract.New())
+	cmd.AddCommand(ls.New())
+
+	return cmd
+}
+
+func (opts *Initrd) Run(cmd *cobra.Command, args []string) error {
+	return cmd.Help()
+}
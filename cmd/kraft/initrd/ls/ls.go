@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package ls
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/initrdref"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+)
+
+type Ls struct {
+	Architecture string `long:"arch" short:"m" usage:"Set the architecture of the package to inspect"`
+	Long         bool   `long:"long" short:"l" usage:"Show more information"`
+	Output       string `long:"output" short:"o" usage:"Set output format" default:"table"`
+	Platform     string `long:"plat" short:"p" usage:"Set the platform of the package to inspect"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Ls{}, cobra.Command{
+		Short:   "List the entries of an initramfs",
+		Use:     "ls [FLAGS] FILE|DIR|MACHINE|PACKAGE",
+		Aliases: []string{"list"},
+		Args:    cobra.ExactArgs(1),
+		Example: heredoc.Doc(`
+			# List the entries of a CPIO archive
+			$ kraft initrd ls .unikraft/build/initramfs.cpio
+
+			# List the entries of the initramfs of a running machine
+			$ kraft initrd ls -l my-machine
+
+			# List the entries of the initramfs of a package
+			$ kraft initrd ls unikraft.org/nginx:latest`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "pkg",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Ls) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	source, err := initrdref.Resolve(ctx, args[0], opts.Platform, opts.Architecture)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	entries, err := source.Entries(initrd.WithOwner(0, 0))
+	if err != nil {
+		return err
+	}
+
+	err = iostreams.G(ctx).StartPager()
+	if err != nil {
+		log.G(ctx).Errorf("error starting pager: %v", err)
+	}
+
+	defer iostreams.G(ctx).StopPager()
+
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+		tableprinter.WithOutputFormatFromString(opts.Output),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Header row
+	table.AddField("MODE", cs.Bold)
+	if opts.Long {
+		table.AddField("OWNER", cs.Bold)
+	}
+	table.AddField("SIZE", cs.Bold)
+	if opts.Long {
+		table.AddField("MODIFIED", cs.Bold)
+		table.AddField("DIGEST", cs.Bold)
+	}
+	table.AddField("PATH", cs.Bold)
+	table.EndRow()
+
+	for _, entry := range entries {
+		table.AddField(entry.Mode.String(), nil)
+		if opts.Long {
+			table.AddField(fmt.Sprintf("%d:%d", entry.UID, entry.GID), nil)
+		}
+		table.AddField(humanize.Bytes(uint64(entry.Size)), nil)
+		if opts.Long {
+			table.AddField(entry.ModTime.UTC().Format("2006-01-02 15:04:05"), nil)
+			table.AddField(entry.Digest, nil)
+		}
+
+		if entry.Linkname != "" {
+			table.AddField(entry.Path+" -> "+entry.Linkname, nil)
+		} else {
+			table.AddField(entry.Path, nil)
+		}
+
+		table.EndRow()
+	}
+
+	return table.Render(iostreams.G(ctx).Out)
+}
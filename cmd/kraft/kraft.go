@@ -20,10 +20,13 @@ import (
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/log"
 
+	"kraftkit.sh/cmd/kraft/attach"
 	"kraftkit.sh/cmd/kraft/build"
 	"kraftkit.sh/cmd/kraft/clean"
+	"kraftkit.sh/cmd/kraft/debug"
 	"kraftkit.sh/cmd/kraft/events"
 	"kraftkit.sh/cmd/kraft/fetch"
+	"kraftkit.sh/cmd/kraft/initrd"
 	"kraftkit.sh/cmd/kraft/login"
 	"kraftkit.sh/cmd/kraft/logs"
 	"kraftkit.sh/cmd/kraft/menu"
@@ -32,12 +35,15 @@ import (
 	"kraftkit.sh/cmd/kraft/prepare"
 	"kraftkit.sh/cmd/kraft/properclean"
 	"kraftkit.sh/cmd/kraft/ps"
+	"kraftkit.sh/cmd/kraft/restore"
 	"kraftkit.sh/cmd/kraft/rm"
 	"kraftkit.sh/cmd/kraft/run"
 	"kraftkit.sh/cmd/kraft/set"
+	"kraftkit.sh/cmd/kraft/snapshot"
 	"kraftkit.sh/cmd/kraft/stop"
 	"kraftkit.sh/cmd/kraft/unset"
 	"kraftkit.sh/cmd/kraft/version"
+	"kraftkit.sh/cmd/kraft/volume"
 
 	// Additional initializers
 	_ "kraftkit.sh/manifest"
@@ -77,19 +83,27 @@ func New() *cobra.Command {
 	cmd.AddCommand(unset.New())
 
 	cmd.AddGroup(&cobra.Group{ID: "pkg", Title: "PACKAGING COMMANDS"})
+	cmd.AddCommand(initrd.New())
 	cmd.AddCommand(pkg.New())
 
 	cmd.AddGroup(&cobra.Group{ID: "run", Title: "RUNTIME COMMANDS"})
+	cmd.AddCommand(attach.New())
+	cmd.AddCommand(debug.New())
 	cmd.AddCommand(events.New())
 	cmd.AddCommand(logs.New())
 	cmd.AddCommand(ps.New())
+	cmd.AddCommand(restore.New())
 	cmd.AddCommand(rm.New())
 	cmd.AddCommand(run.New())
+	cmd.AddCommand(snapshot.New())
 	cmd.AddCommand(stop.New())
 
 	cmd.AddGroup(&cobra.Group{ID: "net", Title: "LOCAL NETWORKING COMMANDS"})
 	cmd.AddCommand(net.New())
 
+	cmd.AddGroup(&cobra.Group{ID: "vol", Title: "LOCAL VOLUME COMMANDS"})
+	cmd.AddCommand(volume.New())
+
 	cmd.AddGroup(&cobra.Group{ID: "misc", Title: "MISCELLANEOUS COMMANDS"})
 	cmd.AddCommand(login.New())
 	cmd.AddCommand(version.New())
@@ -134,6 +148,8 @@ func main() {
 		ctx = iostreams.WithIOStreams(ctx, copts.IOStreams)
 	}
 
+	ctx = withHostContext(ctx)
+
 	if !config.G[config.KraftKit](ctx).NoCheckUpdates {
 		if err := kitupdate.Check(ctx); err != nil {
 			log.G(ctx).Debugf("could not check for updates: %v", err)
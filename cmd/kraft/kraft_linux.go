@@ -0,0 +1,39 @@
+//go:build linux
+// +build linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package main
+
+import (
+	"context"
+	"os"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/machine/network/bridge"
+)
+
+// withHostContext returns a new context with the host-specific settings of the
+// program.  Bridge networks run their DHCP and DNS servers as detached
+// instances of `kraft net` through the current executable, which operate on
+// the same runtime and configuration directories as this instance.
+func withHostContext(ctx context.Context) context.Context {
+	executable, err := os.Executable()
+	if err != nil {
+		return ctx
+	}
+
+	command := []string{executable, "net", "--driver", "bridge"}
+
+	cfg := config.G[config.KraftKit](ctx)
+	if cfg.RuntimeDir != "" {
+		command = append(command, "--runtime-dir="+cfg.RuntimeDir)
+	}
+	if cfg.Paths.Config != "" {
+		command = append(command, "--config-dir="+cfg.Paths.Config)
+	}
+
+	return bridge.WithDaemonCommand(ctx, command...)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package main
+
+import "context"
+
+// withHostContext returns a new context with the host-specific settings of the
+// program.
+func withHostContext(ctx context.Context) context.Context {
+	return ctx
+}
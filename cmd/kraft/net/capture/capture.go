@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/capture"
+)
+
+type Capture struct {
+	Count    int           `long:"count" short:"c" usage:"Stop after capturing the provided number of packets"`
+	Duration time.Duration `long:"duration" usage:"Stop after capturing for the provided duration"`
+	Filter   string        `long:"filter" usage:"Only capture packets matching the filter, e.g. 'tcp and port 80'"`
+	Write    string        `long:"write" short:"w" usage:"Write the capture in pcapng format to the provided file or '-' for stdout" default:"-"`
+	driver   string
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Capture{}, cobra.Command{
+		Short: "Capture the packets of a network or machine",
+		Use:   "capture [FLAGS] NETWORK|MACHINE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Capture the packets of a network or machine
+
+			Packets are captured on the host interface of the network, e.g. its
+			bridge, or on the interface of the machine with the provided name and are
+			written in the pcapng format.  A filter consists of the primitives arp, ip,
+			ip6, icmp, icmp6, tcp, udp, [src|dst] host ADDRESS and [src|dst] port
+			NUMBER, which are joined by "and" and negated by "not".
+		`),
+		Example: heredoc.Doc(`
+			Capture the packets of a network to a file:
+			$ kraft net capture kraft0 -w kraft0.pcapng
+
+			Capture ten HTTP packets of a machine and inspect them with tcpdump:
+			$ kraft net capture my-machine --filter 'tcp and port 80' -c 10 | tcpdump -r -
+
+			Capture the packets of a network for one minute:
+			$ kraft net capture kraft0 --duration 1m -w kraft0.pcapng`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Capture) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	if _, err := capture.ParseFilter(opts.Filter); err != nil {
+		return fmt.Errorf("invalid filter: %v", err)
+	}
+
+	return nil
+}
+
+// ifname returns the name of the host interface of the network or of the
+// machine interface with the provided name.
+func ifname(networks *networkapi.NetworkList, name string) (string, error) {
+	for _, network := range networks.Items {
+		if network.Name != name {
+			continue
+		}
+
+		if network.Spec.Driver == "user" {
+			return "", fmt.Errorf("cannot capture packets of user-mode network %s: it has no host interface", name)
+		}
+
+		if network.Spec.IfName != "" {
+			return network.Spec.IfName, nil
+		}
+
+		return network.Name, nil
+	}
+
+	// Interfaces are named after the machine they are attached to.
+	for _, network := range networks.Items {
+		for _, iface := range network.Spec.Interfaces {
+			if iface.Name != name {
+				continue
+			}
+
+			if network.Spec.Driver == "user" {
+				return "", fmt.Errorf("cannot capture packets of %s on user-mode network %s: it has no host interface", name, network.Name)
+			}
+
+			return iface.Spec.IfName, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find network or machine %s", name)
+}
+
+func (opts *Capture) Run(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %s", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	networks, err := controller.List(ctx, &networkapi.NetworkList{})
+	if err != nil {
+		return err
+	}
+
+	iface, err := ifname(networks, args[0])
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer
+	if opts.Write == "-" {
+		if iostreams.G(ctx).IsStdoutTTY() {
+			return fmt.Errorf("refusing to write capture to a terminal: please redirect stdout or use --write")
+		}
+
+		out = iostreams.G(ctx).Out
+
+		// Keep log messages out of the capture.
+		log.G(ctx).Out = iostreams.G(ctx).ErrOut
+	} else {
+		f, err := os.Create(opts.Write)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		out = f
+	}
+
+	if opts.Duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	// Stop capturing when asked to terminate.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	log.G(ctx).Infof("capturing packets on %s", iface)
+
+	count, err := capture.Capture(ctx, iface, out,
+		capture.WithFilter(opts.Filter),
+		capture.WithCount(opts.Count),
+	)
+	if err != nil {
+		return err
+	}
+
+	log.G(ctx).Infof("captured %d packets", count)
+
+	return nil
+}
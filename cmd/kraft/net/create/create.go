@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
 	"github.com/vishvananda/netlink"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -19,8 +20,13 @@ import (
 )
 
 type Create struct {
-	driver  string
-	Network string `long:"network" short:"n" usage:"Set the gateway IP address and the subnet of the network in CIDR format."`
+	driver   string
+	DHCP     bool     `long:"dhcp" usage:"Serve DHCP on the network to hand out addresses to machines."`
+	DNS      bool     `long:"dns" usage:"Serve DNS on the network to resolve the names of machines."`
+	Internal bool     `long:"internal" usage:"Restrict traffic to the network and the host, without access to external networks."`
+	NoNAT    bool     `long:"no-nat" usage:"Do not masquerade outbound traffic of the network."`
+	Network  []string `long:"network" short:"n" usage:"Set the gateway IP address and the subnet of the network in CIDR format, once for IPv4 and/or once for IPv6. Machines are only addressed over IPv4, an IPv6 subnet only configures the host side of the network."`
+	Parent   string   `long:"parent" usage:"Set the host link which machines are attached to (macvtap driver only)."`
 }
 
 func New() *cobra.Command {
@@ -29,6 +35,18 @@ func New() *cobra.Command {
 		Use:     "create [FLAGS] NETWORK",
 		Aliases: []string{"add"},
 		Args:    cobra.ExactArgs(1),
+		Example: heredoc.Doc(`
+			Create an IPv4 network:
+			$ kraft net create kraft0 --network 172.100.0.1/24
+
+			Create a dual-stack network, whose machines are only addressed over IPv4:
+			$ kraft net create kraft0 --network 172.100.0.1/24 --network fd00:100::1/64
+
+			Create a network whose machines cannot reach external networks:
+			$ kraft net create kraft0 --network 172.100.0.1/24 --internal
+
+			Create a network which attaches machines directly to the LAN of eth0:
+			$ kraft net create lan0 --driver macvtap --parent eth0 --network 192.168.1.1/24`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "net",
 		},
@@ -52,10 +70,26 @@ func (opts *Create) Pre(cmd *cobra.Command, _ []string) error {
 	// if opts.Subnet == "" {
 	// 	return fmt.Errorf("cannot create network without subnet")
 	// }
-	if opts.Network == "" {
+	// User-mode networks are emulated by the hypervisor and fall back to its
+	// default subnet.
+	if len(opts.Network) == 0 && opts.driver != "user" && opts.driver != "macvtap" {
 		return fmt.Errorf("cannot create network without gateway and subnet in CIDR format")
 	}
 
+	if opts.Parent == "" && opts.driver == "macvtap" {
+		return fmt.Errorf("cannot create macvtap network without parent link")
+	} else if opts.Parent != "" && opts.driver != "macvtap" {
+		return fmt.Errorf("setting the parent link is not supported by the %s network driver", opts.driver)
+	}
+
+	if opts.DNS && opts.driver != "bridge" {
+		return fmt.Errorf("DNS is not supported by the %s network driver", opts.driver)
+	}
+
+	if (opts.Internal || opts.NoNAT) && opts.driver != "bridge" {
+		return fmt.Errorf("configuring the traffic of the network is not supported by the %s network driver", opts.driver)
+	}
+
 	return nil
 }
 
@@ -74,19 +108,41 @@ func (opts *Create) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	addr, err := netlink.ParseAddr(opts.Network)
-	if err != nil {
-		return err
+	spec := networkapi.NetworkSpec{
+		IfName:     opts.Parent,
+		DHCP:       opts.DHCP,
+		DNS:        opts.DNS,
+		DisableNAT: opts.NoNAT,
+		Internal:   opts.Internal,
+	}
+	for _, cidr := range opts.Network {
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			return err
+		}
+
+		if addr.IP.To4() != nil {
+			if spec.Gateway != "" {
+				return fmt.Errorf("cannot set more than one IPv4 subnet: %s", cidr)
+			}
+
+			spec.Gateway = addr.IP.String()
+			spec.Netmask = net.IP(addr.Mask).String()
+		} else {
+			if spec.Gateway6 != "" {
+				return fmt.Errorf("cannot set more than one IPv6 subnet: %s", cidr)
+			}
+
+			spec.Gateway6 = addr.IP.String()
+			spec.Netmask6 = net.IP(addr.Mask).String()
+		}
 	}
 
 	if _, err := controller.Create(ctx, &networkapi.Network{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: args[0],
 		},
-		Spec: networkapi.NetworkSpec{
-			Gateway: addr.IP.String(),
-			Netmask: net.IP(addr.Mask).String(),
-		},
+		Spec: spec,
 	}); err != nil {
 		return err
 	}
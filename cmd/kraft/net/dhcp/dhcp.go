@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network/bridge"
+)
+
+type Dhcp struct {
+	DNS bool `long:"dns" usage:"Hand out the gateway of the network as name server"`
+
+	driver string
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Dhcp{}, cobra.Command{
+		Short:  "Serve DHCP on a network",
+		Use:    "dhcp NETWORK",
+		Args:   cobra.ExactArgs(1),
+		Hidden: true,
+		Long: heredoc.Doc(`
+			Serve DHCP on a network in the foreground.
+
+			The server is started automatically in the background for networks which
+			have been created with --dhcp when the network is brought up.`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Dhcp) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	if opts.driver != "bridge" {
+		return fmt.Errorf("DHCP is not supported by the %s network driver", opts.driver)
+	}
+
+	return nil
+}
+
+func (opts *Dhcp) Run(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	// Stop serving when the server is asked to terminate.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	log.G(ctx).Infof("serving DHCP on %s", args[0])
+
+	return bridge.ServeDHCP(ctx, args[0], opts.DNS)
+}
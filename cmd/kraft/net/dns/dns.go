@@ -0,0 +1,106 @@
+//go:build linux
+// +build linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network/bridge"
+	"kraftkit.sh/machine/network/dns"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type Dns struct {
+	Upstreams []string `long:"upstream" usage:"Forward queries to the provided name server(s) instead of those of the host"`
+
+	driver string
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Dns{}, cobra.Command{
+		Short:  "Serve DNS on a network",
+		Use:    "dns [FLAGS] NETWORK",
+		Args:   cobra.ExactArgs(1),
+		Hidden: true,
+		Long: heredoc.Doc(`
+			Serve DNS on a network in the foreground.
+
+			The server resolves the names and aliases of the machines which are
+			attached to the network and forwards all other queries upstream.  It is
+			started automatically in the background for networks which have been
+			created with --dns when the network is brought up.`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Dns) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	if opts.driver != "bridge" {
+		return fmt.Errorf("DNS is not supported by the %s network driver", opts.driver)
+	}
+
+	return nil
+}
+
+func (opts *Dns) Run(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	upstreams := make([]string, 0, len(opts.Upstreams))
+	for _, upstream := range opts.Upstreams {
+		if _, _, err := net.SplitHostPort(upstream); err != nil {
+			upstream = net.JoinHostPort(upstream, strconv.Itoa(dns.Port))
+		}
+
+		upstreams = append(upstreams, upstream)
+	}
+
+	if len(upstreams) == 0 {
+		var err error
+		upstreams, err = dns.Upstreams(dns.DefaultResolvConf)
+		if err != nil {
+			log.G(ctx).Warnf("could not determine upstream name servers: %v", err)
+		}
+	}
+
+	machines, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Stop serving when the server is asked to terminate.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	log.G(ctx).Infof("serving DNS on %s", args[0])
+
+	return bridge.ServeDNS(ctx, args[0], machines, upstreams)
+}
@@ -7,6 +7,7 @@ package list
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -77,14 +78,24 @@ func (opts *List) Run(cmd *cobra.Command, args []string) error {
 	var items []netTable
 
 	for _, network := range networks.Items {
-		addr := &net.IPNet{
-			IP:   net.ParseIP(network.Spec.Gateway),
-			Mask: net.IPMask(net.ParseIP(network.Spec.Netmask)),
+		var addrs []string
+		if network.Spec.Gateway != "" {
+			addrs = append(addrs, (&net.IPNet{
+				IP:   net.ParseIP(network.Spec.Gateway),
+				Mask: net.IPMask(net.ParseIP(network.Spec.Netmask).To4()),
+			}).String())
 		}
+		if network.Spec.Gateway6 != "" {
+			addrs = append(addrs, (&net.IPNet{
+				IP:   net.ParseIP(network.Spec.Gateway6),
+				Mask: net.IPMask(net.ParseIP(network.Spec.Netmask6)),
+			}).String())
+		}
+
 		items = append(items, netTable{
 			id:      string(network.UID),
 			name:    network.Name,
-			network: addr.String(),
+			network: strings.Join(addrs, ","),
 			driver:  opts.driver,
 			status:  network.Status.State,
 		})
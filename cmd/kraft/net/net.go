@@ -9,11 +9,15 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"kraftkit.sh/cmd/kraft/net/capture"
 	"kraftkit.sh/cmd/kraft/net/create"
 	"kraftkit.sh/cmd/kraft/net/down"
 	"kraftkit.sh/cmd/kraft/net/inspect"
 	"kraftkit.sh/cmd/kraft/net/list"
 	"kraftkit.sh/cmd/kraft/net/remove"
+	"kraftkit.sh/cmd/kraft/net/reserve"
+	"kraftkit.sh/cmd/kraft/net/stats"
+	"kraftkit.sh/cmd/kraft/net/unreserve"
 	"kraftkit.sh/cmd/kraft/net/up"
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/internal/set"
@@ -38,13 +42,21 @@ func New() *cobra.Command {
 		panic(err)
 	}
 
+	cmd.AddCommand(capture.New())
 	cmd.AddCommand(create.New())
 	cmd.AddCommand(down.New())
 	cmd.AddCommand(inspect.New())
 	cmd.AddCommand(list.New())
 	cmd.AddCommand(remove.New())
+	cmd.AddCommand(reserve.New())
+	cmd.AddCommand(stats.New())
+	cmd.AddCommand(unreserve.New())
 	cmd.AddCommand(up.New())
 
+	for _, subcmd := range hostCommands() {
+		cmd.AddCommand(subcmd)
+	}
+
 	return cmd
 }
 
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package net
+
+import (
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmd/kraft/net/dhcp"
+	"kraftkit.sh/cmd/kraft/net/dns"
+)
+
+// hostCommands returns the sub-commands which are only supported by the host,
+// i.e. those which serve bridge networks.
+func hostCommands() []*cobra.Command {
+	return []*cobra.Command{
+		dhcp.New(),
+		dns.New(),
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package net
+
+import "github.com/spf13/cobra"
+
+// hostCommands returns the sub-commands which are only supported by the host.
+func hostCommands() []*cobra.Command {
+	return nil
+}
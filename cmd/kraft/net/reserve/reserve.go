@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package reserve
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/ipam"
+)
+
+type Reserve struct {
+	MacAddress string `long:"mac" usage:"Reserve the address for the interface with this MAC address"`
+	Name       string `long:"name" usage:"Reserve the address for the machine with this name"`
+
+	driver string
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Reserve{}, cobra.Command{
+		Short: "Statically reserve an IP address of a network",
+		Use:   "reserve [FLAGS] NETWORK IP",
+		Args:  cobra.ExactArgs(2),
+		Long: heredoc.Doc(`
+			Statically reserve an IP address of a network.
+
+			The reserved address is only allocated to the machine with the given name
+			or to the interface with the given MAC address when it is attached to the
+			network.`),
+		Example: heredoc.Doc(`
+			Reserve an address for the machine named "web":
+			$ kraft net reserve --name web kraft0 172.100.0.10
+
+			Reserve an address for an interface with a specific MAC address:
+			$ kraft net reserve --mac 02:b0:b0:00:00:0a kraft0 172.100.0.11`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Reserve) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	if opts.MacAddress == "" && opts.Name == "" {
+		return fmt.Errorf("either --mac or --name must be provided")
+	}
+
+	return nil
+}
+
+func (opts *Reserve) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, err := controller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(args[1])
+	if ip == nil {
+		return fmt.Errorf("invalid IP address: %s", args[1])
+	}
+
+	// Validate the address against the subnet of the same family.
+	gateway, netmask := found.Spec.Gateway, net.IPMask(net.ParseIP(found.Spec.Netmask).To4())
+	if ip.To4() == nil {
+		gateway, netmask = found.Spec.Gateway6, net.IPMask(net.ParseIP(found.Spec.Netmask6))
+	}
+
+	if gateway == "" {
+		return fmt.Errorf("network %s has no subnet for address %s", found.Name, ip)
+	}
+
+	subnet := &net.IPNet{IP: net.ParseIP(gateway).Mask(netmask), Mask: netmask}
+	if !subnet.Contains(ip) {
+		return fmt.Errorf("address %s is not within network %s (%s)", ip, found.Name, subnet)
+	} else if ip.Equal(net.ParseIP(gateway)) {
+		return fmt.Errorf("address %s is the gateway of network %s", ip, found.Name)
+	}
+
+	manager, err := ipam.NewDefaultIPAM(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := manager.Reserve(ctx, found.Name, ipam.Reservation{
+		IP:   ip.String(),
+		MAC:  opts.MacAddress,
+		Name: opts.Name,
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, ip.String())
+
+	return nil
+}
@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/network"
+)
+
+type Stats struct {
+	Follow bool   `long:"follow" short:"f" usage:"Continuously stream the statistics"`
+	Output string `long:"output" short:"o" usage:"Set output format" default:"table"`
+	driver string
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Stats{}, cobra.Command{
+		Short: "Show the throughput of machine networks",
+		Use:   "stats [FLAGS] [NETWORK...]",
+		Args:  cobra.ArbitraryArgs,
+		Long: heredoc.Doc(`
+			Show the throughput of machine networks
+
+			The throughput is shown for the bridge of each network and for the
+			interface of every machine attached to it.  Without any arguments, all
+			networks which are up are shown.
+		`),
+		Example: heredoc.Doc(`
+			Show the throughput of all networks:
+			$ kraft net stats
+
+			Continuously show the throughput of a network:
+			$ kraft net stats -f kraft0`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Stats) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+	return nil
+}
+
+// sample is a reading of the byte counters of an interface.
+type sample struct {
+	rx, tx uint64
+	at     time.Time
+}
+
+// row contains the statistics of an interface.
+type row struct {
+	network string
+	ifname  string
+	state   string
+	rxBytes uint64
+	txBytes uint64
+	rxRate  float64
+	txRate  float64
+
+	// rated is set once two samples of the interface were taken, such that its
+	// throughput is known.
+	rated bool
+}
+
+// update is an event of one of the watched networks.
+type update struct {
+	network *networkapi.Network
+	err     error
+}
+
+func (opts *Stats) Run(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %s", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	var networks []networkapi.Network
+
+	if len(args) == 0 {
+		list, err := controller.List(ctx, &networkapi.NetworkList{})
+		if err != nil {
+			return err
+		}
+
+		for _, network := range list.Items {
+			if network.Status.State == networkapi.NetworkStateUp {
+				networks = append(networks, network)
+			}
+		}
+	} else {
+		for _, name := range args {
+			network, err := controller.Get(ctx, &networkapi.Network{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: name,
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("could not get network %s: %v", name, err)
+			}
+
+			networks = append(networks, *network)
+		}
+	}
+
+	if len(networks) == 0 {
+		return fmt.Errorf("no networks are up")
+	}
+
+	updates := make(chan update)
+
+	for i := range networks {
+		events, errs, err := controller.Watch(ctx, &networks[i])
+		if err != nil {
+			return fmt.Errorf("could not watch network %s: %v", networks[i].Name, err)
+		}
+
+		go func(events chan *networkapi.Network, errs chan error) {
+			for {
+				var next update
+
+				select {
+				case <-ctx.Done():
+					return
+				case next.network = <-events:
+				case next.err = <-errs:
+				}
+
+				select {
+				case updates <- next:
+				case <-ctx.Done():
+					return
+				}
+
+				if next.err != nil {
+					return
+				}
+			}
+		}(events, errs)
+	}
+
+	samples := make(map[string]sample)
+	latest := make(map[string][]row)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case next := <-updates:
+			if next.err != nil {
+				return next.err
+			}
+
+			latest[next.network.Name] = rows(next.network, samples, time.Now())
+
+			// Without following, wait until the throughput of every interface of
+			// every network is known and show it once.
+			if !opts.Follow && !rated(latest, len(networks)) {
+				continue
+			}
+
+			if opts.Follow {
+				iostreams.G(ctx).RefreshScreen()
+			}
+
+			if err := opts.render(ctx, latest); err != nil {
+				return err
+			}
+
+			if !opts.Follow {
+				return nil
+			}
+		}
+	}
+}
+
+// rows returns the statistics of the bridge and the attached interfaces of the
+// provided network.  The throughput is derived from the previous samples of
+// the interfaces, which are replaced by the current counters.
+func rows(network *networkapi.Network, samples map[string]sample, now time.Time) []row {
+	sampled := func(r row) row {
+		prev, ok := samples[r.ifname]
+		samples[r.ifname] = sample{rx: r.rxBytes, tx: r.txBytes, at: now}
+
+		// Counters are reset when an interface is re-created.
+		if !ok || !now.After(prev.at) || r.rxBytes < prev.rx || r.txBytes < prev.tx {
+			return r
+		}
+
+		elapsed := now.Sub(prev.at).Seconds()
+		r.rxRate = float64(r.rxBytes-prev.rx) / elapsed
+		r.txRate = float64(r.txBytes-prev.tx) / elapsed
+		r.rated = true
+
+		return r
+	}
+
+	ret := []row{sampled(row{
+		network: network.Name,
+		ifname:  network.Name,
+		state:   network.Status.State.String(),
+		rxBytes: network.Status.RxBytes,
+		txBytes: network.Status.TxBytes,
+	})}
+
+	for _, iface := range network.Status.Interfaces {
+		ret = append(ret, sampled(row{
+			network: network.Name,
+			ifname:  iface.IfName,
+			state:   iface.State.String(),
+			rxBytes: iface.RxBytes,
+			txBytes: iface.TxBytes,
+		}))
+	}
+
+	return ret
+}
+
+// rated returns whether the throughput of all interfaces of the expected
+// number of networks is known.
+func rated(latest map[string][]row, expected int) bool {
+	if len(latest) < expected {
+		return false
+	}
+
+	for _, rows := range latest {
+		for _, r := range rows {
+			if !r.rated {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// render prints the latest statistics of all networks ordered by name.
+func (opts *Stats) render(ctx context.Context, latest map[string][]row) error {
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+		tableprinter.WithOutputFormatFromString(opts.Output),
+	)
+	if err != nil {
+		return err
+	}
+
+	rate := func(r row, bytes float64) string {
+		if !r.rated {
+			return "-"
+		}
+
+		return humanize.Bytes(uint64(bytes)) + "/s"
+	}
+
+	// Header row
+	table.AddField("NETWORK", cs.Bold)
+	table.AddField("INTERFACE", cs.Bold)
+	table.AddField("STATUS", cs.Bold)
+	table.AddField("RX RATE", cs.Bold)
+	table.AddField("TX RATE", cs.Bold)
+	table.AddField("RX", cs.Bold)
+	table.AddField("TX", cs.Bold)
+	table.EndRow()
+
+	for _, name := range names {
+		for _, r := range latest[name] {
+			table.AddField(r.network, nil)
+			table.AddField(r.ifname, nil)
+			table.AddField(r.state, nil)
+			table.AddField(rate(r, r.rxRate), nil)
+			table.AddField(rate(r, r.txRate), nil)
+			table.AddField(humanize.Bytes(r.rxBytes), nil)
+			table.AddField(humanize.Bytes(r.txBytes), nil)
+			table.EndRow()
+		}
+	}
+
+	return table.Render(iostreams.G(ctx).Out)
+}
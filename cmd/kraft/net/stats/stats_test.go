@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package stats
+
+import (
+	"testing"
+	"time"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+)
+
+// counters returns a network whose bridge and single interface have the
+// provided byte counters.
+func counters(rx, tx uint64) *networkapi.Network {
+	network := &networkapi.Network{
+		Status: networkapi.NetworkStatus{
+			RxBytes: rx,
+			TxBytes: tx,
+			Interfaces: []networkapi.NetworkInterfaceStatus{{
+				IfName:  "tap0",
+				RxBytes: rx * 2,
+				TxBytes: tx * 2,
+			}},
+		},
+	}
+	network.Name = "br0"
+
+	return network
+}
+
+func TestRows(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		previous *networkapi.Network
+		elapsed  time.Duration
+		current  *networkapi.Network
+		rated    bool
+		rxRate   float64
+		txRate   float64
+	}{
+		{
+			name:    "first sample",
+			current: counters(1000, 2000),
+		},
+		{
+			name:     "rate",
+			previous: counters(1000, 2000),
+			elapsed:  2 * time.Second,
+			current:  counters(3000, 3000),
+			rated:    true,
+			rxRate:   1000,
+			txRate:   500,
+		},
+		{
+			name:     "idle",
+			previous: counters(1000, 2000),
+			elapsed:  time.Second,
+			current:  counters(1000, 2000),
+			rated:    true,
+		},
+		{
+			name:     "counter reset",
+			previous: counters(1000, 2000),
+			elapsed:  time.Second,
+			current:  counters(10, 3000),
+		},
+		{
+			name:     "same time",
+			previous: counters(1000, 2000),
+			current:  counters(3000, 3000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := map[string]sample{}
+
+			if tt.previous != nil {
+				_ = rows(tt.previous, samples, start)
+			}
+
+			got := rows(tt.current, samples, start.Add(tt.elapsed))
+			if len(got) != 2 {
+				t.Fatalf("expected rows of the bridge and the interface, got %d", len(got))
+			}
+
+			// The counters of the interface are twice those of the bridge.
+			for i, r := range got {
+				factor := float64(i + 1)
+
+				if r.rated != tt.rated {
+					t.Errorf("%s: expected rated to be %t, got %t", r.ifname, tt.rated, r.rated)
+				}
+
+				if r.rxRate != tt.rxRate*factor || r.txRate != tt.txRate*factor {
+					t.Errorf("%s: expected rates %v/%v, got %v/%v", r.ifname, tt.rxRate*factor, tt.txRate*factor, r.rxRate, r.txRate)
+				}
+
+				if sampled := samples[r.ifname]; sampled.rx != r.rxBytes || sampled.tx != r.txBytes {
+					t.Errorf("%s: expected the current counters to be sampled, got %d/%d", r.ifname, sampled.rx, sampled.tx)
+				}
+			}
+		})
+	}
+}
+
+func TestRated(t *testing.T) {
+	tests := []struct {
+		name     string
+		latest   map[string][]row
+		expected int
+		rated    bool
+	}{
+		{
+			name:     "no networks",
+			latest:   map[string][]row{},
+			expected: 1,
+		},
+		{
+			name: "missing network",
+			latest: map[string][]row{
+				"br0": {{rated: true}},
+			},
+			expected: 2,
+		},
+		{
+			name: "unrated interface",
+			latest: map[string][]row{
+				"br0": {{rated: true}, {rated: false}},
+			},
+			expected: 1,
+		},
+		{
+			name: "all rated",
+			latest: map[string][]row{
+				"br0": {{rated: true}, {rated: true}},
+				"br1": {{rated: true}},
+			},
+			expected: 2,
+			rated:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rated(tt.latest, tt.expected); got != tt.rated {
+				t.Errorf("expected %t, got %t", tt.rated, got)
+			}
+		})
+	}
+}
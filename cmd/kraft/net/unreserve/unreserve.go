@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package unreserve
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/network/ipam"
+)
+
+type Unreserve struct{}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Unreserve{}, cobra.Command{
+		Short: "Remove the static reservation of an IP address of a network",
+		Use:   "unreserve NETWORK IP",
+		Args:  cobra.ExactArgs(2),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Unreserve) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	manager, err := ipam.NewDefaultIPAM(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := manager.Unreserve(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, args[1])
+
+	return nil
+}
@@ -8,12 +8,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/mattn/go-shellwords"
 	"github.com/spf13/cobra"
 
 	"kraftkit.sh/config"
+	"kraftkit.sh/initrd"
 	"kraftkit.sh/machine/platform"
 	"kraftkit.sh/pack"
 	"kraftkit.sh/unikraft"
@@ -38,7 +41,7 @@ type Pkg struct {
 	Dbg          bool   `local:"true" long:"dbg" usage:"Package the debuggable (symbolic) kernel image instead of the stripped image"`
 	Force        bool   `local:"true" long:"force-format" usage:"Force the use of a packaging handler format"`
 	Format       string `local:"true" long:"as" short:"M" usage:"Force the packaging despite possible conflicts" default:"auto"`
-	Initrd       string `local:"true" long:"initrd" short:"i" usage:"Path to init ramdisk to bundle within the package (passing a path will automatically generate a CPIO image)"`
+	Initrd       string `local:"true" long:"initrd" short:"i" usage:"Path to init ramdisk to bundle within the package (passing a path or OCI image reference will automatically generate a CPIO image)"`
 	Kernel       string `local:"true" long:"kernel" short:"k" usage:"Override the path to the unikernel image"`
 	Kraftfile    string `long:"kraftfile" usage:"Set an alternative path of the Kraftfile"`
 	Name         string `local:"true" long:"name" short:"n" usage:"Specify the name of the package"`
@@ -46,6 +49,9 @@ type Pkg struct {
 	Platform     string `local:"true" long:"plat" short:"p" usage:"Filter the creation of the package by platform of known targets"`
 	Target       string `local:"true" long:"target" short:"t" usage:"Package a particular known target"`
 	WithKConfig  bool   `local:"true" long:"with-kconfig" usage:"Include the target .config"`
+
+	rootfsFormat      initrd.Format
+	rootfsCompression initrd.Compression
 }
 
 func New() *cobra.Command {
@@ -66,7 +72,16 @@ func New() *cobra.Command {
 		`, "`"),
 		Example: heredoc.Doc(`
 			# Package a project as an OCI archive and embed the target's KConfig.
-			$ kraft pkg --as oci --name unikraft.org/nginx:latest --with-kconfig`),
+			$ kraft pkg --as oci --name unikraft.org/nginx:latest --with-kconfig
+
+			# Package a project with a rootfs directory serialized into an ext2 filesystem image.
+			$ kraft pkg --as oci --name unikraft.org/nginx:latest --initrd ./rootfs --rootfs-format ext2
+
+			# Package a project with the flattened filesystem of an OCI image as its initramfs.
+			$ kraft pkg --as oci --name unikraft.org/nginx:latest --initrd docker://nginx:latest
+
+			# Package a project with a reproducible, zstd-compressed initramfs.
+			$ SOURCE_DATE_EPOCH=$(git log -1 --format=%ct) kraft pkg --as oci --name unikraft.org/nginx:latest --initrd ./rootfs --rootfs-compression zstd`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "pkg",
 		},
@@ -75,6 +90,18 @@ func New() *cobra.Command {
 		panic(err)
 	}
 
+	cmd.Flags().Var(
+		cmdfactory.NewEnumFlag(initrd.FormatNames(), initrd.FormatCPIO.String()),
+		"rootfs-format",
+		"Set the format of the root file system serialized from a directory passed via --initrd.",
+	)
+
+	cmd.Flags().Var(
+		cmdfactory.NewEnumFlag(initrd.CompressionNames(), initrd.CompressionNone.String()),
+		"rootfs-compression",
+		"Set the compression of the initramfs CPIO archive serialized from a directory passed via --initrd. Unikraft's CPIO loader does not decompress archives, so only compress them for unikernels which decompress the initramfs themselves.",
+	)
+
 	cmd.AddCommand(list.New())
 	cmd.AddCommand(pull.New())
 	cmd.AddCommand(push.New())
@@ -99,6 +126,8 @@ func (opts *Pkg) Pre(cmd *cobra.Command, _ []string) error {
 	cmd.SetContext(packmanager.WithPackageManager(ctx, pm))
 
 	opts.Platform = platform.PlatformByName(opts.Platform).String()
+	opts.rootfsFormat = initrd.Format(cmd.Flag("rootfs-format").Value.String())
+	opts.rootfsCompression = initrd.Compression(cmd.Flag("rootfs-compression").Value.String())
 
 	return nil
 }
@@ -134,6 +163,11 @@ func (opts *Pkg) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	initrdPath, rootfsImage, err := opts.prepareRootfs(ctx, workdir)
+	if err != nil {
+		return err
+	}
+
 	var tree []*processtree.ProcessTreeItem
 
 	parallel := !config.G[config.KraftKit](ctx).NoParallel
@@ -204,10 +238,11 @@ func (opts *Pkg) Run(cmd *cobra.Command, args []string) error {
 
 					popts := []packmanager.PackOption{
 						packmanager.PackArgs(cmdShellArgs...),
-						packmanager.PackInitrd(opts.Initrd),
+						packmanager.PackInitrd(initrdPath),
 						packmanager.PackKConfig(opts.WithKConfig),
 						packmanager.PackName(opts.Name),
 						packmanager.PackOutput(opts.Output),
+						packmanager.PackRootfsImage(rootfsImage),
 					}
 
 					if ukversion, ok := targ.KConfig().Get(unikraft.UK_FULLVERSION); ok {
@@ -256,3 +291,52 @@ func (opts *Pkg) Run(cmd *cobra.Command, args []string) error {
 
 	return model.Start()
 }
+
+// prepareRootfs serializes the directory or OCI image passed via --initrd, if
+// any, in the requested format and returns the path of the resulting initramfs
+// or the path of the resulting filesystem image.
+func (opts *Pkg) prepareRootfs(ctx context.Context, workdir string) (string, string, error) {
+	isImage := strings.HasPrefix(opts.Initrd, initrd.OCIRegistryPrefix) ||
+		strings.HasPrefix(opts.Initrd, initrd.OCILayoutPrefix)
+
+	if fi, err := os.Stat(opts.Initrd); !isImage && (err != nil || !fi.IsDir()) {
+		if opts.rootfsFormat.IsImage() {
+			return "", "", fmt.Errorf("cannot serialize %s image: --initrd must be a directory or OCI image", opts.rootfsFormat)
+		}
+
+		return opts.Initrd, "", nil
+	}
+
+	output := filepath.Join(workdir, unikraft.BuildDir, initrd.DefaultInitramfsFileName)
+	if opts.rootfsFormat.IsImage() {
+		output = filepath.Join(workdir, unikraft.BuildDir, "rootfs."+opts.rootfsFormat.String())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return "", "", err
+	}
+
+	rootfs, err := initrd.New(ctx, opts.Initrd,
+		initrd.WithFormat(opts.rootfsFormat),
+		initrd.WithCompression(opts.rootfsCompression),
+		initrd.WithArchitecture(opts.Architecture),
+		initrd.WithOutput(output),
+		// Packages are owned by root regardless of the user who built them, such
+		// that identical trees result in identical layers.
+		initrd.WithOwner(0, 0),
+	)
+	if err != nil {
+		return "", "", fmt.Errorf("could not prepare rootfs: %w", err)
+	}
+
+	path, err := rootfs.Build(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	if opts.rootfsFormat.IsImage() {
+		return "", path, nil
+	}
+
+	return path, "", nil
+}
@@ -71,11 +71,13 @@ func (opts *Ps) Run(cmd *cobra.Command, args []string) error {
 		args    string
 		created string
 		status  machineapi.MachineState
+		health  machineapi.HealthStatus
 		mem     string
 		ports   string
 		arch    string
 		plat    string
 		ips     []string
+		crash   []string
 	}
 
 	var items []psTable
@@ -123,6 +125,7 @@ func (opts *Ps) Run(cmd *cobra.Command, args []string) error {
 			args:    strings.Join(machine.Spec.ApplicationArgs, " "),
 			kernel:  machine.Spec.Kernel,
 			status:  machine.Status.State,
+			health:  machine.Status.Health,
 			mem:     fmt.Sprintf("%dM", machine.Spec.Resources.Requests.Memory().Value()/1000000),
 			created: humanize.Time(machine.ObjectMeta.CreationTimestamp.Time),
 			ports:   machine.Spec.Ports.String(),
@@ -131,6 +134,12 @@ func (opts *Ps) Run(cmd *cobra.Command, args []string) error {
 			ips:     []string{},
 		}
 
+		for _, path := range []string{machine.Status.CrashDumpPath, machine.Status.CrashLogPath} {
+			if path != "" {
+				entry.crash = append(entry.crash, path)
+			}
+		}
+
 		for _, net := range machine.Spec.Networks {
 			for _, iface := range net.Interfaces {
 				entry.ips = append(entry.ips, iface.Spec.IP)
@@ -171,6 +180,7 @@ func (opts *Ps) Run(cmd *cobra.Command, args []string) error {
 		table.AddField("PORTS", cs.Bold)
 		table.AddField("IP", cs.Bold)
 		table.AddField("ARCH", cs.Bold)
+		table.AddField("CRASH", cs.Bold)
 	}
 	table.AddField("PLAT", cs.Bold)
 	table.EndRow()
@@ -183,12 +193,17 @@ func (opts *Ps) Run(cmd *cobra.Command, args []string) error {
 		table.AddField(item.kernel, nil)
 		table.AddField(item.args, nil)
 		table.AddField(item.created, nil)
-		table.AddField(item.status.String(), nil)
+		if item.status == machineapi.MachineStateRunning && item.health != machineapi.HealthStatusNone {
+			table.AddField(fmt.Sprintf("%s (%s)", item.status, item.health), nil)
+		} else {
+			table.AddField(item.status.String(), nil)
+		}
 		table.AddField(item.mem, nil)
 		if opts.Long {
 			table.AddField(item.ports, nil)
 			table.AddField(strings.Join(item.ips, ","), nil)
 			table.AddField(item.arch, nil)
+			table.AddField(strings.Join(item.crash, ","), nil)
 			table.AddField(item.plat, nil)
 		} else {
 			table.AddField(fmt.Sprintf("%s/%s", item.plat, item.arch), nil)
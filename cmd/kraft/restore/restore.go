@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package restore
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	machinename "kraftkit.sh/machine/name"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type Restore struct {
+	Name string `long:"name" short:"n" usage:"Name of the new instance"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Restore{}, cobra.Command{
+		Short: "Start a new unikernel from a snapshot",
+		Use:   "restore [FLAGS] MACHINE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Start a new unikernel from the most recent snapshot of an existing
+			unikernel.
+
+			The new unikernel uses the same kernel, arguments and resources as the
+			unikernel the snapshot was taken from and resumes from the saved state
+			instead of booting.`),
+		Example: heredoc.Doc(`
+			Save the state of a running unikernel:
+			$ kraft snapshot my-machine
+
+			Start a new unikernel from the saved state:
+			$ kraft restore --name my-clone my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Restore) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	iterator, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machines, err := iterator.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var source *machineapi.Machine
+
+	for i, machine := range machines.Items {
+		if opts.Name != "" && opts.Name == machine.Name {
+			return fmt.Errorf("machine instance name already in use: %s", opts.Name)
+		}
+
+		if args[0] == machine.Name || args[0] == string(machine.UID) {
+			source = &machines.Items[i]
+		}
+	}
+
+	if source == nil {
+		return fmt.Errorf("machine not found: %s", args[0])
+	}
+
+	if source.Status.SnapshotPath == "" {
+		return fmt.Errorf("machine %s has no snapshot: please run 'kraft snapshot %s' first", source.Name, source.Name)
+	}
+
+	// The devices of the new machine must match those of the machine the
+	// snapshot was taken from, which would require sharing the same network
+	// interfaces.
+	if len(source.Spec.Networks) > 0 {
+		return fmt.Errorf("restoring machines which are attached to a network is not supported")
+	}
+
+	platform, ok := mplatform.PlatformsByName()[source.Spec.Platform]
+	if !ok {
+		return fmt.Errorf("unknown platform driver: %s", source.Spec.Platform)
+	}
+
+	strategy, ok := mplatform.Strategies()[platform]
+	if !ok {
+		return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+	}
+
+	controller, err := strategy.NewMachineV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = machinename.NewRandomMachineName(0)
+	}
+
+	machine := &machineapi.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: source.Spec,
+		Status: machineapi.MachineStatus{
+			KernelPath: source.Status.KernelPath,
+			InitrdPath: source.Status.InitrdPath,
+		},
+	}
+
+	machine.Spec.Snapshot = source.Status.SnapshotPath
+
+	machine, err = controller.Create(ctx, machine)
+	if err != nil {
+		return err
+	}
+
+	if _, err := controller.Start(ctx, machine); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
+
+	return nil
+}
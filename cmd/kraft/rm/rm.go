@@ -19,6 +19,7 @@ import (
 	"kraftkit.sh/log"
 	"kraftkit.sh/machine/network"
 	mplatform "kraftkit.sh/machine/platform"
+	"kraftkit.sh/machine/volume"
 )
 
 type Rm struct {
@@ -179,6 +180,10 @@ func (opts *Rm) Run(cmd *cobra.Command, args []string) error {
 		if _, err := controller.Delete(ctx, &machine); err != nil {
 			log.G(ctx).Errorf("could not delete machine %s: %v", machine.Name, err)
 		} else {
+			if err := volume.Detach(ctx, &machine); err != nil {
+				log.G(ctx).Warnf("could not detach volumes of machine %s: %v", machine.Name, err)
+			}
+
 			fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
 		}
 	}
@@ -21,35 +21,54 @@ import (
 	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	networkapi "kraftkit.sh/api/network/v1alpha1"
 	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/internal/attach"
 	"kraftkit.sh/internal/set"
 	"kraftkit.sh/iostreams"
+	"kraftkit.sh/kconfig"
 	"kraftkit.sh/log"
+	"kraftkit.sh/machine/health"
 	"kraftkit.sh/machine/network"
 	mplatform "kraftkit.sh/machine/platform"
+	"kraftkit.sh/machine/volume"
 	"kraftkit.sh/packmanager"
 )
 
 type Run struct {
-	Architecture  string   `long:"arch" short:"m" usage:"Set the architecture"`
-	Detach        bool     `long:"detach" short:"d" usage:"Run unikernel in background"`
-	DisableAccel  bool     `long:"disable-acceleration" short:"W" usage:"Disable acceleration of CPU (usually enables TCG)"`
-	InitRd        string   `long:"initrd" usage:"Use the specified initrd (readonly)" hidden:"true"`
-	IP            string   `long:"ip" usage:"Assign the provided IP address"`
-	KernelArgs    []string `long:"kernel-arg" short:"a" usage:"Set additional kernel arguments"`
-	Kraftfile     string   `long:"kraftfile" usage:"Set an alternative path of the Kraftfile"`
-	MacAddress    string   `long:"mac" usage:"Assign the provided MAC address"`
-	Memory        string   `long:"memory" short:"M" usage:"Assign MB memory to the unikernel" default:"64M"`
-	Name          string   `long:"name" short:"n" usage:"Name of the instance"`
-	Network       string   `long:"network" usage:"Attach instance to the provided network in the format <driver>:<network>, e.g. bridge:kraft0"`
-	Ports         []string `long:"port" short:"p" usage:"Publish a machine's port(s) to the host" split:"false"`
-	Remove        bool     `long:"rm" usage:"Automatically remove the unikernel when it shutsdown"`
-	Rootfs        string   `long:"rootfs" usage:"Specify a path to use as root file system (can be volume or initramfs)"`
-	RunAs         string   `long:"as" usage:"Force a specific runner"`
-	Target        string   `long:"target" short:"t" usage:"Explicitly use the defined project target"`
-	Volumes       []string `long:"volume" short:"v" usage:"Bind a volume to the instance"`
-	WithKernelDbg bool     `long:"symbolic" usage:"Use the debuggable (symbolic) unikernel"`
+	Architecture      string        `long:"arch" short:"m" usage:"Set the architecture"`
+	Detach            bool          `long:"detach" short:"d" usage:"Run unikernel in background"`
+	DisableAccel      bool          `long:"disable-acceleration" short:"W" usage:"Disable acceleration of CPU (usually enables TCG)"`
+	GDB               string        `long:"gdb" usage:"Expose a GDB stub on the provided port or unix socket path and wait for a debugger (implies --symbolic)"`
+	HealthHTTP        string        `long:"health-http" usage:"Check the health of the unikernel with an HTTP GET request to the provided URL"`
+	HealthInterval    time.Duration `long:"health-interval" usage:"Set the duration between two health checks (default 5s)"`
+	HealthRetries     int           `long:"health-retries" usage:"Set the number of consecutive failing health checks before the unikernel is unhealthy (default 3)"`
+	HealthSerial      string        `long:"health-serial" usage:"Check the health of the unikernel by matching its serial output with the provided regular expression"`
+	HealthStartPeriod time.Duration `long:"health-start-period" usage:"Set the duration after starting during which failing health checks are not counted"`
+	HealthTCP         string        `long:"health-tcp" usage:"Check the health of the unikernel by connecting to the provided [host:]port"`
+	HealthTimeout     time.Duration `long:"health-timeout" usage:"Set the duration after which a single health check fails (default 3s)"`
+	InitRd            string        `long:"initrd" usage:"Use the specified initrd (readonly)" hidden:"true"`
+	IP                string        `long:"ip" usage:"Assign the provided IP address"`
+	KernelArgs        []string      `long:"kernel-arg" short:"a" usage:"Set additional kernel arguments"`
+	Kraftfile         string        `long:"kraftfile" usage:"Set an alternative path of the Kraftfile"`
+	MacAddress        string        `long:"mac" usage:"Assign the provided MAC address"`
+	Memory            string        `long:"memory" short:"M" usage:"Assign MB memory to the unikernel" default:"64M"`
+	Name              string        `long:"name" short:"n" usage:"Name of the instance"`
+	Network           string        `long:"network" usage:"Attach instance to the provided network in the format <driver>:<network>, e.g. bridge:kraft0 or user:default"`
+	NetworkAliases    []string      `long:"network-alias" usage:"Add additional names which resolve to the instance on the network"`
+	Ports             []string      `long:"port" short:"p" usage:"Publish a machine's port(s) to the host" split:"false"`
+	Remove            bool          `long:"rm" usage:"Automatically remove the unikernel when it shutsdown"`
+	Restart           string        `long:"restart" usage:"Set the restart policy of the unikernel when it exits (no|on-failure[:max-retries]|always)" default:"no"`
+	Rootfs            string        `long:"rootfs" usage:"Specify a path to use as root file system (can be volume, initramfs, directory or OCI image reference)"`
+	RunAs             string        `long:"as" usage:"Force a specific runner"`
+	Target            string        `long:"target" short:"t" usage:"Explicitly use the defined project target"`
+	Volumes           []string      `long:"volume" short:"v" usage:"Bind a host path or named volume to the instance, e.g. ./path/to/dir:/dir or myvol:/data (existing host paths take precedence over named volumes)"`
+	WaitHealthy       bool          `long:"wait-healthy" usage:"Wait until the health check of the unikernel passes before returning"`
+	WithKernelDbg     bool          `long:"symbolic" usage:"Use the debuggable (symbolic) unikernel"`
 
 	workdir           string
+	kconfig           kconfig.KeyValueMap
+	rootfsFormat      initrd.Format
+	rootfsImage       string
 	platform          mplatform.Platform
 	networkDriver     string
 	networkName       string
@@ -83,15 +102,40 @@ func New() *cobra.Command {
 			Run a Linux userspace binary in POSIX-/binary-compatibility mode:
 			$ kraft run a.out
 
+			Run the debuggable unikernel and wait for a debugger on port 1234, then attach GDB to it:
+			$ kraft run --gdb 1234 --name my-machine
+			$ kraft debug my-machine
+
+			Restart the unikernel up to 5 times whenever it fails:
+			$ kraft run --restart on-failure:5 unikraft.org/nginx:latest
+
+			Run a unikernel in the background and wait until it serves HTTP requests:
+			$ kraft run -d -p 8080:80 --health-http http://localhost:8080/ --wait-healthy unikraft.org/nginx:latest
+
 			Supply an initramfs CPIO archive file to the unikernel for its rootfs:
 			$ kraft run --rootfs ./initramfs.cpio
 
 			Supply a path which is dynamically serialized into an initramfs CPIO archive:
 			$ kraft run --rootfs ./path/to/rootfs
 
+			Supply an OCI image from a registry which is flattened into an initramfs CPIO archive:
+			$ kraft run --rootfs docker://alpine:latest
+
+			Supply an image of a local OCI image layout directory or tarball:
+			$ kraft run --rootfs oci:./alpine.tar:latest
+
+			Supply a path which is serialized into an ext2 filesystem image which is attached as a block device at /:
+			$ kraft run --rootfs ./path/to/rootfs --rootfs-format ext2
+
 			Mount a bi-directional path from on the host to the unikernel mapped to /dir:
 			$ kraft run -v ./path/to/dir:/dir
 
+			Mount the named volume myvol at /data, creating it if it does not exist:
+			$ kraft run -v myvol:/data unikraft.org/nginx:latest
+
+			Attach a raw or qcow2 disk image as a block device and mount it at /data:
+			$ kraft run -v ./disk.img:/data unikraft.org/nginx:latest
+
 			Supply a read-only root file system at / via initramfs CPIO archive and mount a bi-directional volume at /dir:
 			$ kraft run --rootfs ./initramfs.cpio --volume ./path/to/dir:/dir
 
@@ -112,6 +156,12 @@ func New() *cobra.Command {
 		"Set the platform virtual machine monitor driver.",
 	)
 
+	cmd.Flags().Var(
+		cmdfactory.NewEnumFlag(initrd.FormatNames(), initrd.FormatCPIO.String()),
+		"rootfs-format",
+		"Set the format of the root file system serialized from a directory, where filesystem images are attached as a block device.",
+	)
+
 	return cmd
 }
 
@@ -120,10 +170,13 @@ func (opts *Run) Pre(cmd *cobra.Command, _ []string) error {
 	ctx := cmd.Context()
 
 	opts.platform = mplatform.PlatformByName(opts.platform.String())
+	opts.rootfsFormat = initrd.Format(cmd.Flag("rootfs-format").Value.String())
 
 	// Discover the network controller strategy.
 	if opts.Network == "" && opts.IP != "" {
 		return fmt.Errorf("cannot assign IP address without providing --network")
+	} else if opts.Network == "" && len(opts.NetworkAliases) > 0 {
+		return fmt.Errorf("cannot assign network aliases without providing --network")
 	} else if opts.Network != "" && !strings.Contains(opts.Network, ":") {
 		return fmt.Errorf("specifying a network must be in the format <driver>:<network> e.g. --network=bridge:kraft0")
 	}
@@ -203,6 +256,21 @@ func (opts *Run) Pre(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	if opts.GDB != "" {
+		opts.WithKernelDbg = true
+	}
+
+	if opts.Remove && opts.Restart != "" && opts.Restart != string(machineapi.RestartPolicyNo) {
+		return fmt.Errorf("cannot use --rm together with --restart")
+	}
+
+	// Exited machines are restarted by re-launching their VMM, which is only
+	// supported by the QEMU driver: Firecracker machines can only be started
+	// via the API socket of their VMM, which no longer exists once they exit.
+	if opts.Restart != "" && opts.Restart != string(machineapi.RestartPolicyNo) && opts.platform != mplatform.PlatformQEMU {
+		return fmt.Errorf("restart policies are not supported by the %s platform driver", opts.platform.String())
+	}
+
 	if opts.InitRd != "" {
 		log.G(ctx).Warn("the --initrd flag is deprecated in favour of --rootfs")
 
@@ -285,10 +353,25 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 		machine.Spec.Resources.Requests[corev1.ResourceMemory] = quantity
 	}
 
+	machine.Spec.RestartPolicy, err = machineapi.ParseRestartPolicy(opts.Restart)
+	if err != nil {
+		return err
+	}
+
 	if err := opts.parsePorts(ctx, machine); err != nil {
 		return err
 	}
 
+	if err := opts.parseHealthCheck(ctx, machine); err != nil {
+		return err
+	}
+
+	// The name is assigned before attaching to a network, since addresses can be
+	// statically reserved for a machine by its name.
+	if err := opts.assignName(ctx, machine); err != nil {
+		return err
+	}
+
 	if err := opts.parseNetworks(ctx, machine); err != nil {
 		return err
 	}
@@ -297,11 +380,11 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if err := opts.assignName(ctx, machine); err != nil {
+	if err := opts.prepareRootfs(ctx, machine); err != nil {
 		return err
 	}
 
-	if err := opts.prepareRootfs(ctx, machine); err != nil {
+	if err := opts.prepareGDB(ctx, machine); err != nil {
 		return err
 	}
 
@@ -311,6 +394,11 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	// Record the machine as a user of its named volumes.
+	if err := volume.Attach(ctx, machine); err != nil {
+		log.G(ctx).Warnf("could not attach volumes: %v", err)
+	}
+
 	var exitErr error
 	requestShutdown := false
 	logsFinished := make(chan bool, 1)
@@ -360,14 +448,47 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 		}()
 	}
 
-	// Start the machine
-	machine, err = opts.machineController.Start(ctx, machine)
-	if err != nil {
-		signals.RequestShutdown()
-		return err
+	// Start the machine, unless a debugger is expected to do so.  The machine
+	// then remains created until the debugger resumes the guest, at which point
+	// it is reported as running, or until it is started via `kraft start`.
+	if opts.GDB != "" {
+		log.G(ctx).
+			WithField("gdb", machine.Status.GDBStub).
+			Infof("waiting for debugger, run: kraft debug %s", machine.Name)
+	} else {
+		machine, err = opts.machineController.Start(ctx, machine)
+		if err != nil {
+			signals.RequestShutdown()
+			return err
+		}
+	}
+
+	if opts.WaitHealthy {
+		log.G(ctx).Info("waiting for machine to become healthy")
+
+		if err := health.Wait(ctx, machine); err != nil {
+			signals.RequestShutdown()
+			return err
+		}
 	}
 
 	if !opts.Detach {
+		raw, restore, err := makeStdinRaw(ctx)
+		if err != nil {
+			signals.RequestShutdown()
+			return err
+		}
+
+		defer restore()
+
+		// Line feeds no longer return the cursor in raw mode.
+		var out io.Writer = iostreams.G(ctx).Out
+		if raw {
+			out = attach.NewCRLFWriter(out)
+		}
+
+		go opts.forwardStdin(ctx, machine, raw)
+
 		logs, errs, err := opts.machineController.Logs(ctx, machine)
 		if err != nil {
 			signals.RequestShutdown()
@@ -387,7 +508,7 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 				}
 
 			case line = <-logs:
-				fmt.Fprint(iostreams.G(ctx).Out, line)
+				fmt.Fprint(out, line)
 
 			case err := <-errs:
 				if errors.Is(err, io.EOF) && requestShutdown {
@@ -404,6 +525,8 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 		}
 		logsFinished <- true
 
+		restore()
+
 		// Remove the instance on Ctrl+C if the --rm flag is passed
 		if opts.Remove {
 			if _, err := opts.machineController.Stop(ctx, machine); err != nil {
@@ -412,6 +535,8 @@ func (opts *Run) Run(cmd *cobra.Command, args []string) error {
 
 			if _, err := opts.machineController.Delete(ctx, machine); err != nil {
 				log.G(ctx).Errorf("could not remove: %v", err)
+			} else if err := volume.Detach(ctx, machine); err != nil {
+				log.G(ctx).Warnf("could not detach volumes: %v", err)
 			}
 		}
 	} else {
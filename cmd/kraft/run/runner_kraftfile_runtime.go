@@ -202,6 +202,7 @@ func (runner *runnerKraftfileRuntime) Prepare(ctx context.Context, opts *Run, ma
 
 	// machine.Spec.ApplicationArgs = append([]string{filepath.Base(runner.exePath)}, runner.args...)
 	machine.Status.InitrdPath = rootfsPath
+	machine.Spec.HealthCheck = healthCheckFromProject(runner.project)
 
 	// Use the symbolic debuggable kernel image?
 	if opts.WithKernelDbg {
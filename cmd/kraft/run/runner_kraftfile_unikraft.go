@@ -122,6 +122,11 @@ func (runner *runnerKraftfileUnikraft) Prepare(ctx context.Context, opts *Run, m
 	machine.Spec.Architecture = t.Architecture().Name()
 	machine.Spec.Platform = t.Platform().Name()
 	machine.Spec.ApplicationArgs = runner.args
+	machine.Spec.HealthCheck = healthCheckFromProject(runner.project)
+
+	// The KConfig of the target determines which volume drivers are supported by
+	// its kernel.
+	opts.kconfig = t.KConfig()
 
 	// Use the symbolic debuggable kernel image?
 	if opts.WithKernelDbg {
@@ -69,6 +69,12 @@ func (runner *runnerPackage) Runnable(ctx context.Context, opts *Run, args ...st
 	return false, nil
 }
 
+// rootfsImager is implemented by packages which ship the rootfs as a
+// filesystem image.
+type rootfsImager interface {
+	RootfsImage() string
+}
+
 // Prepare implements Runner.
 func (runner *runnerPackage) Prepare(ctx context.Context, opts *Run, machine *machineapi.Machine, args ...string) error {
 	// First try the local cache of the catalog
@@ -156,20 +162,34 @@ func (runner *runnerPackage) Prepare(ctx context.Context, opts *Run, machine *ma
 	machine.Spec.Kernel = fmt.Sprintf("%s://%s", runner.pm.Format(), runner.packName)
 	machine.Spec.ApplicationArgs = runner.args
 
+	// The KConfig of the target determines which volume drivers are supported by
+	// its kernel.
+	opts.kconfig = targ.KConfig()
+
 	// Set the path to the initramfs if present.
 	var ramfs initrd.Initrd
 	if opts.Rootfs == "" && targ.Initrd() != nil {
 		ramfs = targ.Initrd()
-	} else if len(opts.Rootfs) > 0 {
-		ramfs, err = initrd.New(ctx, opts.Rootfs)
+	} else if len(opts.Rootfs) > 0 && !opts.rootfsFormat.IsImage() {
+		ramfs, err = initrd.New(ctx, opts.Rootfs,
+			initrd.WithOwner(0, 0),
+		)
 		if err != nil {
 			return err
 		}
 	}
 
-	machine.Status.InitrdPath, err = ramfs.Build(ctx)
-	if err != nil {
-		return err
+	if ramfs != nil {
+		machine.Status.InitrdPath, err = ramfs.Build(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Attach the filesystem image of the rootfs which is shipped with the
+	// package unless the rootfs is overridden.
+	if image, ok := packs[0].(rootfsImager); ok && opts.Rootfs == "" {
+		opts.rootfsImage = image.RootfsImage()
 	}
 
 	// Use the symbolic debuggable kernel image?
@@ -1,14 +1,22 @@
 package run
 
 import (
+	"bytes"
 	"context"
+	"debug/elf"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/containerd/nerdctl/pkg/strutil"
 	"github.com/dustin/go-humanize"
+	"github.com/rancher/wrangler/pkg/signals"
+	"golang.org/x/term"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
@@ -16,12 +24,110 @@ import (
 	networkapi "kraftkit.sh/api/network/v1alpha1"
 	volumeapi "kraftkit.sh/api/volume/v1alpha1"
 	"kraftkit.sh/initrd"
+	"kraftkit.sh/iostreams"
 	"kraftkit.sh/log"
 	machinename "kraftkit.sh/machine/name"
 	"kraftkit.sh/machine/volume"
+	"kraftkit.sh/machine/volume/blockdev"
 	"kraftkit.sh/unikraft"
+	"kraftkit.sh/unikraft/app"
 )
 
+// Are we exposing a GDB stub? E.g. --gdb=1234 or --gdb=/tmp/gdb.sock
+func (opts *Run) prepareGDB(_ context.Context, machine *machineapi.Machine) error {
+	if opts.GDB == "" {
+		return nil
+	}
+
+	// Debugging a kernel without its symbols is of little use, so refuse to do
+	// so instead of silently continuing.
+	kernel, err := elf.Open(machine.Status.KernelPath)
+	if err != nil {
+		return fmt.Errorf("could not open kernel for debugging: %w", err)
+	}
+
+	defer kernel.Close()
+
+	if kernel.Section(".symtab") == nil {
+		return fmt.Errorf("cannot debug stripped kernel: %s", machine.Status.KernelPath)
+	}
+
+	machine.Spec.GDBStub = opts.GDB
+
+	return nil
+}
+
+// healthCheckFromProject returns the health check specified in the Kraftfile
+// of the provided project, if any.
+func healthCheckFromProject(project app.Application) *machineapi.HealthCheck {
+	check := project.HealthCheck()
+	if check == nil {
+		return nil
+	}
+
+	return &machineapi.HealthCheck{
+		TCP:         check.TCP,
+		HTTP:        check.HTTP,
+		Serial:      check.Serial,
+		Interval:    check.Interval,
+		Timeout:     check.Timeout,
+		StartPeriod: check.StartPeriod,
+		Retries:     check.Retries,
+	}
+}
+
+// Are we checking the health of the machine? E.g. --health-http=http://localhost:8080/
+func (opts *Run) parseHealthCheck(_ context.Context, machine *machineapi.Machine) error {
+	// Any probe provided via the command-line replaces the one from the
+	// Kraftfile.
+	probes := 0
+	for _, probe := range []string{opts.HealthTCP, opts.HealthHTTP, opts.HealthSerial} {
+		if probe != "" {
+			probes++
+		}
+	}
+
+	if probes > 1 {
+		return fmt.Errorf("only one of --health-tcp, --health-http or --health-serial can be provided")
+	} else if probes == 1 {
+		machine.Spec.HealthCheck = &machineapi.HealthCheck{
+			TCP:    opts.HealthTCP,
+			HTTP:   opts.HealthHTTP,
+			Serial: opts.HealthSerial,
+		}
+	}
+
+	check := machine.Spec.HealthCheck
+	if check == nil {
+		if opts.WaitHealthy {
+			return fmt.Errorf("cannot wait for the machine to become healthy without a health check")
+		}
+
+		return nil
+	}
+
+	if opts.HealthInterval > 0 {
+		check.Interval = opts.HealthInterval
+	}
+	if opts.HealthTimeout > 0 {
+		check.Timeout = opts.HealthTimeout
+	}
+	if opts.HealthStartPeriod > 0 {
+		check.StartPeriod = opts.HealthStartPeriod
+	}
+	if opts.HealthRetries > 0 {
+		check.Retries = opts.HealthRetries
+	}
+
+	if check.Serial != "" {
+		if _, err := regexp.Compile(check.Serial); err != nil {
+			return fmt.Errorf("invalid health check serial output expression: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // Are we publishing ports? E.g. -p/--ports=127.0.0.1:80:8080/tcp ...
 func (opts *Run) parsePorts(_ context.Context, machine *machineapi.Machine) error {
 	if len(opts.Ports) == 0 {
@@ -63,14 +169,26 @@ func (opts *Run) parseNetworks(ctx context.Context, machine *machineapi.Machine)
 	// following the returning from the Update operation.
 	newIface := networkapi.NetworkInterfaceTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			UID: uuid.NewUUID(),
+			UID:  uuid.NewUUID(),
+			Name: machine.Name,
 		},
 		Spec: networkapi.NetworkInterfaceSpec{
 			IP:         opts.IP,
 			MacAddress: opts.MacAddress,
+			Aliases:    opts.NetworkAliases,
 		},
 	}
 
+	// Publish any requested ports through the interface.
+	for _, port := range machine.Spec.Ports {
+		newIface.Spec.Ports = append(newIface.Spec.Ports, networkapi.NetworkInterfacePort{
+			HostIP:        port.HostIP,
+			HostPort:      port.HostPort,
+			InterfacePort: port.MachinePort,
+			Protocol:      port.Protocol,
+		})
+	}
+
 	// Update the list of interfaces
 	if found.Spec.Interfaces == nil {
 		found.Spec.Interfaces = []networkapi.NetworkInterfaceTemplateSpec{}
@@ -123,16 +241,35 @@ func (opts *Run) assignName(ctx context.Context, machine *machineapi.Machine) er
 	return nil
 }
 
-// Was a volume specified? E.g. --volume=path:path
+// Was a volume specified? E.g. --volume=path:path or --volume=name:path
 func (opts *Run) parseVolumes(ctx context.Context, machine *machineapi.Machine) error {
 	if len(opts.Volumes) == 0 {
 		return nil
 	}
 
-	var err error
 	controllers := map[string]volumeapi.VolumeService{}
 	machine.Spec.Volumes = []volumeapi.Volume{}
 
+	// controller returns the service of the provided driver, which is shared
+	// between volumes.
+	controller := func(driver string) (volumeapi.VolumeService, error) {
+		if _, ok := controllers[driver]; !ok {
+			strategy, ok := volume.Strategies()[driver]
+			if !ok {
+				return nil, fmt.Errorf("unsupported volume driver strategy: %s", driver)
+			}
+
+			service, err := strategy.NewVolumeV1alpha1(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("could not prepare %s volume service: %w", driver, err)
+			}
+
+			controllers[driver] = service
+		}
+
+		return controllers[driver], nil
+	}
+
 	for _, volLine := range opts.Volumes {
 		var hostPath, mountPath string
 		split := strings.Split(volLine, ":")
@@ -143,37 +280,43 @@ func (opts *Run) parseVolumes(ctx context.Context, machine *machineapi.Machine)
 			return fmt.Errorf("invalid syntax for --volume=%s expected --volume=<host>:<machine>", volLine)
 		}
 
-		var driver string
-
-		for sname, strategy := range volume.Strategies() {
-			if ok, _ := strategy.IsCompatible(hostPath, nil); !ok || err != nil {
+		// Named volumes are re-used when they exist.
+		if volume.IsNamed(hostPath) {
+			if vol, _, err := volume.Lookup(ctx, hostPath); err == nil {
+				vol.Spec.Destination = mountPath
+				machine.Spec.Volumes = append(machine.Spec.Volumes, *vol)
 				continue
 			}
+		}
 
-			if _, ok := controllers[sname]; !ok {
-				controllers[sname], err = strategy.NewVolumeV1alpha1(ctx)
-				if err != nil {
-					return fmt.Errorf("could not prepare %s volume service: %w", sname, err)
-				}
-			}
+		driver, err := volume.CompatibleDriver(hostPath, opts.kconfig)
+		if err != nil {
+			return err
+		}
 
-			driver = sname
+		service, err := controller(driver)
+		if err != nil {
+			return err
+		}
+
+		spec := volumeapi.VolumeSpec{
+			Driver:      driver,
+			Source:      hostPath,
+			Destination: mountPath,
+			ReadOnly:    false, // TODO(nderjung): Options are not yet supported.
 		}
 
-		if len(driver) == 0 {
-			return fmt.Errorf("could not find compatible volume driver for %s", hostPath)
+		// Named volumes which do not exist yet are created without a host path,
+		// such that the driver manages their contents.
+		if volume.IsNamed(hostPath) {
+			spec.Source = ""
 		}
 
-		vol, err := controllers[driver].Create(ctx, &volumeapi.Volume{
+		vol, err := service.Create(ctx, &volumeapi.Volume{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: hostPath,
 			},
-			Spec: volumeapi.VolumeSpec{
-				Driver:      driver,
-				Source:      hostPath,
-				Destination: mountPath,
-				ReadOnly:    false, // TODO(nderjung): Options are not yet supported.
-			},
+			Spec: spec,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create volume: %w", err)
@@ -193,11 +336,47 @@ func (opts *Run) prepareRootfs(ctx context.Context, machine *machineapi.Machine)
 	// preparation and is considered higher priority compared to what has been set
 	// prior to this point.
 	if opts.Rootfs == "" {
-		return nil
+		return opts.attachRootfsImage(ctx, machine)
+	}
+
+	// Filesystem images are attached as a block device rather than loaded into
+	// the memory of the machine.
+	if opts.rootfsFormat.IsImage() {
+		if fi, err := os.Stat(opts.Rootfs); err == nil && !fi.IsDir() {
+			return fmt.Errorf("cannot serialize %s into %s image: not a directory or OCI image", opts.Rootfs, opts.rootfsFormat)
+		}
+
+		image, err := initrd.New(ctx, opts.Rootfs,
+			initrd.WithFormat(opts.rootfsFormat),
+			initrd.WithArchitecture(machine.Spec.Architecture),
+			initrd.WithOwner(0, 0),
+			initrd.WithCacheDir(filepath.Join(opts.workdir, unikraft.BuildDir, "rootfs")),
+		)
+		if err != nil {
+			return fmt.Errorf("could not prepare %s image: %w", opts.rootfsFormat, err)
+		}
+
+		opts.rootfsImage, err = image.Build(ctx)
+		if err != nil {
+			return err
+		}
+
+		machine.Status.InitrdPath = ""
+
+		return opts.attachRootfsImage(ctx, machine)
+	}
+
+	// The runners may have set the initramfs to the supplied rootfs itself,
+	// which must not be used as the output when it is yet to be serialized.
+	output := machine.Status.InitrdPath
+	if output == opts.Rootfs {
+		output = ""
 	}
 
 	ramfs, err := initrd.New(ctx, opts.Rootfs,
-		initrd.WithOutput(machine.Status.InitrdPath),
+		initrd.WithOutput(output),
+		initrd.WithArchitecture(machine.Spec.Architecture),
+		initrd.WithOwner(0, 0),
 		initrd.WithCacheDir(filepath.Join(opts.workdir, unikraft.BuildDir, "rootfs")),
 	)
 	if err != nil {
@@ -227,3 +406,136 @@ func (opts *Run) prepareRootfs(ctx context.Context, machine *machineapi.Machine)
 
 	return nil
 }
+
+// attachRootfsImage attaches the filesystem image of the rootfs, if any, to the
+// machine as a block device which is mounted at /.
+func (opts *Run) attachRootfsImage(ctx context.Context, machine *machineapi.Machine) error {
+	if opts.rootfsImage == "" {
+		return nil
+	}
+
+	if ok, err := blockdev.IsCompatible(opts.rootfsImage, opts.kconfig); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("cannot attach rootfs image: kernel does not enable %s", blockdev.KConfigOption)
+	}
+
+	strategy, ok := volume.Strategies()[blockdev.DriverName]
+	if !ok {
+		return fmt.Errorf("unsupported volume driver strategy: %s", blockdev.DriverName)
+	}
+
+	service, err := strategy.NewVolumeV1alpha1(ctx)
+	if err != nil {
+		return fmt.Errorf("could not prepare %s volume service: %w", blockdev.DriverName, err)
+	}
+
+	vol, err := service.Create(ctx, &volumeapi.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: machine.Name + "-rootfs",
+		},
+		Spec: volumeapi.VolumeSpec{
+			Driver:      blockdev.DriverName,
+			Source:      opts.rootfsImage,
+			Destination: "/",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create rootfs volume: %w", err)
+	}
+
+	// The rootfs must be mounted before any of the other volumes.
+	machine.Spec.Volumes = append([]volumeapi.Volume{*vol}, machine.Spec.Volumes...)
+
+	return nil
+}
+
+// ctrlC is the byte which is read from a terminal in raw mode when Ctrl-C is
+// pressed.
+const ctrlC = 0x03
+
+// makeStdinRaw puts the terminal of the standard input into raw mode, if it is
+// one, such that every key press is forwarded to the machine as-is, as with
+// `kraft attach`.  The returned function restores the previous mode of the
+// terminal and can be called repeatedly.
+func makeStdinRaw(ctx context.Context) (bool, func(), error) {
+	if !iostreams.G(ctx).IsStdinTTY() {
+		return false, func() {}, nil
+	}
+
+	fd := int(iostreams.G(ctx).In.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return false, nil, fmt.Errorf("could not set terminal to raw mode: %w", err)
+	}
+
+	var once sync.Once
+
+	return true, func() {
+		once.Do(func() {
+			_ = term.Restore(fd, state)
+		})
+	}, nil
+}
+
+// forwardStdin forwards the standard input to the console of the machine such
+// that interactive unikernels can be used without having to attach to them.
+// The console is only connected to once input is available, which leaves it
+// available for `kraft attach` otherwise.  When the terminal is in raw mode,
+// Ctrl-C no longer raises an interrupt, so it is handled here such that it
+// continues to stop the machine.
+func (opts *Run) forwardStdin(ctx context.Context, machine *machineapi.Machine, raw bool) {
+	if machine.Status.ConsoleSocket == "" {
+		return
+	}
+
+	var conn net.Conn
+	buf := make([]byte, 4096)
+
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		n, err := iostreams.G(ctx).In.Read(buf)
+
+		interrupted := false
+		if raw {
+			if i := bytes.IndexByte(buf[:n], ctrlC); i >= 0 {
+				n = i
+				interrupted = true
+			}
+		}
+
+		if n > 0 {
+			if conn == nil {
+				conn, err = net.Dial("unix", machine.Status.ConsoleSocket)
+				if err != nil {
+					log.G(ctx).Warnf("could not forward input to machine: %v", err)
+					return
+				}
+
+				// The output of the console is already displayed via the logs of the
+				// machine, so discard it to prevent the socket from filling up.
+				go func() {
+					_, _ = io.Copy(io.Discard, conn)
+				}()
+			}
+
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+
+		if interrupted {
+			signals.RequestShutdown()
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
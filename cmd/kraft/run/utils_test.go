@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package run
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// writeKernel writes an ELF file with the provided, empty sections and returns
+// its path.
+func writeKernel(t *testing.T, sections ...string) string {
+	t.Helper()
+
+	// The names of the sections, prefixed by the empty name of the null section.
+	names := []byte{0}
+	offsets := []uint32{0}
+	for _, name := range append([]string{".shstrtab"}, sections...) {
+		offsets = append(offsets, uint32(len(names)))
+		names = append(append(names, name...), 0)
+	}
+
+	ehsize := binary.Size(elf.Header64{})
+	shoff := (ehsize + len(names) + 7) &^ 7
+
+	hdr := elf.Header64{
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(shoff),
+		Ehsize:    uint16(ehsize),
+		Shentsize: uint16(binary.Size(elf.Section64{})),
+		Shnum:     uint16(len(offsets)),
+		Shstrndx:  1,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	shdrs := []elf.Section64{{}, {
+		Name: offsets[1],
+		Type: uint32(elf.SHT_STRTAB),
+		Off:  uint64(ehsize),
+		Size: uint64(len(names)),
+	}}
+
+	for i, name := range sections {
+		shdr := elf.Section64{
+			Name: offsets[i+2],
+			Type: uint32(elf.SHT_PROGBITS),
+		}
+
+		if name == ".symtab" {
+			shdr.Type = uint32(elf.SHT_SYMTAB)
+			shdr.Entsize = uint64(binary.Size(elf.Sym64{}))
+		}
+
+		shdrs = append(shdrs, shdr)
+	}
+
+	var buf bytes.Buffer
+	for _, data := range []any{hdr, names, make([]byte, shoff-ehsize-len(names)), shdrs} {
+		if err := binary.Write(&buf, binary.LittleEndian, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "kernel")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestPrepareGDB(t *testing.T) {
+	notELF := filepath.Join(t.TempDir(), "kernel")
+	if err := os.WriteFile(notELF, []byte("not a kernel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		gdb    string
+		kernel string
+		stub   string
+		err    string
+	}{
+		{
+			name:   "disabled",
+			kernel: writeKernel(t, ".text"),
+		},
+		{
+			name:   "symbolic kernel",
+			gdb:    "1234",
+			kernel: writeKernel(t, ".text", ".symtab", ".strtab"),
+			stub:   "1234",
+		},
+		{
+			name:   "stripped kernel",
+			gdb:    "1234",
+			kernel: writeKernel(t, ".text"),
+			err:    "cannot debug stripped kernel",
+		},
+		{
+			name:   "not a kernel",
+			gdb:    "/tmp/gdb.sock",
+			kernel: notELF,
+			err:    "could not open kernel",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Run{GDB: tt.gdb}
+			machine := &machineapi.Machine{}
+			machine.Status.KernelPath = tt.kernel
+
+			err := opts.prepareGDB(context.Background(), machine)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if machine.Spec.GDBStub != tt.stub {
+				t.Errorf("expected GDB stub %q, got %q", tt.stub, machine.Spec.GDBStub)
+			}
+		})
+	}
+}
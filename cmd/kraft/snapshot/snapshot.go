@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package snapshot
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/set"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type Snapshot struct {
+	platform string
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Snapshot{}, cobra.Command{
+		Short: "Save the state of one or more unikernels",
+		Use:   "snapshot [FLAGS] MACHINE [MACHINE [...]]",
+		Args:  cobra.MinimumNArgs(1),
+		Long: heredoc.Doc(`
+			Save the complete state of one or more running or paused unikernels.
+
+			The unikernel is paused and its state is written to a file in its state
+			directory, after which the unikernel remains suspended.  New unikernels
+			can be started from the snapshot with 'kraft restore'.  The snapshot is
+			removed together with the unikernel.`),
+		Example: heredoc.Doc(`
+			Save the state of a running unikernel:
+			$ kraft snapshot my-machine
+
+			Start a new unikernel from the saved state:
+			$ kraft restore my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.Flags().VarP(
+		cmdfactory.NewEnumFlag(set.NewStringSet(mplatform.DriverNames()...).Add("auto").ToSlice(), "auto"),
+		"plat",
+		"p",
+		"Set the platform virtual machine monitor driver.  Set to 'auto' to detect the guest's platform and 'host' to use the host platform.",
+	)
+
+	return cmd
+}
+
+func (opts *Snapshot) Pre(cmd *cobra.Command, _ []string) error {
+	opts.platform = cmd.Flag("plat").Value.String()
+	return nil
+}
+
+func (opts *Snapshot) Run(cmd *cobra.Command, args []string) error {
+	var err error
+
+	ctx := cmd.Context()
+	platform := mplatform.PlatformUnknown
+	var controller machineapi.MachineService
+
+	if opts.platform == "auto" {
+		controller, err = mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	} else {
+		if opts.platform == "host" {
+			platform, _, err = mplatform.Detect(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			var ok bool
+			platform, ok = mplatform.PlatformsByName()[opts.platform]
+			if !ok {
+				return fmt.Errorf("unknown platform driver: %s", opts.platform)
+			}
+		}
+
+		strategy, ok := mplatform.Strategies()[platform]
+		if !ok {
+			return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		}
+
+		controller, err = strategy.NewMachineV1alpha1(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	requested := set.NewStringSet(args...)
+	var snapshot []machineapi.Machine
+
+	for _, machine := range machines.Items {
+		if requested.ContainsExactly(machine.Name) || requested.ContainsExactly(string(machine.UID)) {
+			snapshot = append(snapshot, machine)
+		}
+	}
+
+	if len(snapshot) == 0 {
+		return fmt.Errorf("machine(s) not found")
+	}
+
+	for _, machine := range snapshot {
+		if _, err := controller.Snapshot(ctx, &machine); err != nil {
+			log.G(ctx).Errorf("could not snapshot machine %s: %v", machine.Name, err)
+		} else {
+			fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package create
+
+import (
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	volumeapi "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/volume"
+	"kraftkit.sh/machine/volume/blockdev"
+)
+
+type Create struct {
+	Driver string `long:"driver" short:"d" usage:"Set the volume driver" default:"9pfs"`
+	Format string `long:"format" usage:"Set the format of the disk image of blockdev volumes (raw|qcow2)" default:"raw"`
+	FsType string `long:"fs-type" usage:"Set the filesystem of blockdev volumes which is mounted in the machine"`
+	Size   string `long:"size" usage:"Set the size of the disk image of blockdev volumes, e.g. 1Gi"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Create{}, cobra.Command{
+		Short:   "Create a named volume",
+		Use:     "create [FLAGS] NAME",
+		Aliases: []string{"add"},
+		Args:    cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Create a named volume
+
+			The contents of named volumes are stored by KraftKit and persist across
+			machines until the volume is removed.  The blockdev driver attaches an
+			empty sparse disk image of the provided size as a block device.
+		`),
+		Example: heredoc.Doc(`
+			Create a named volume and mount it at /data:
+			$ kraft volume create myvol
+			$ kraft run -v myvol:/data unikraft.org/nginx:latest
+
+			Create a 1 GiB disk image which is attached as a block device:
+			$ kraft volume create --driver blockdev --size 1Gi --fs-type ext2 mydisk`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "vol",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Create) Pre(cmd *cobra.Command, args []string) error {
+	if err := volume.ValidateName(args[0]); err != nil {
+		return err
+	}
+
+	if opts.Driver == blockdev.DriverName {
+		if len(opts.Size) == 0 {
+			return fmt.Errorf("cannot create blockdev volume without size")
+		}
+	} else if len(opts.Size) > 0 || len(opts.FsType) > 0 || cmd.Flags().Changed("format") {
+		return fmt.Errorf("setting the disk image is not supported by the %s volume driver", opts.Driver)
+	}
+
+	return nil
+}
+
+func (opts *Create) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	if _, _, err := volume.Lookup(ctx, args[0]); err == nil {
+		return fmt.Errorf("volume %s already exists", args[0])
+	}
+
+	strategy, ok := volume.Strategies()[opts.Driver]
+	if !ok {
+		return fmt.Errorf("unsupported volume driver strategy: %v (contributions welcome!)", opts.Driver)
+	}
+
+	controller, err := strategy.NewVolumeV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	spec := volumeapi.VolumeSpec{
+		Driver: opts.Driver,
+	}
+
+	if opts.Driver == blockdev.DriverName {
+		size, err := resource.ParseQuantity(opts.Size)
+		if err != nil {
+			return fmt.Errorf("invalid size: %w", err)
+		}
+
+		spec.Format = opts.Format
+		spec.FsType = opts.FsType
+		spec.Size = size.Value()
+	}
+
+	if _, err := controller.Create(ctx, &volumeapi.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+		Spec: spec,
+	}); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, args[0])
+
+	return nil
+}
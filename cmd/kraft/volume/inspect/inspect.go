@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/volume"
+)
+
+type Inspect struct{}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Inspect{}, cobra.Command{
+		Short: "Inspect a named volume",
+		Use:   "inspect NAME",
+		Args:  cobra.ExactArgs(1),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "vol",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Inspect) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	vol, _, err := volume.Lookup(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	ret, err := json.Marshal(vol)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(iostreams.G(ctx).Out, "%s\n", ret)
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package list
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	volumeapi "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/volume"
+)
+
+type List struct {
+	Long   bool   `long:"long" short:"l" usage:"Show more information"`
+	Output string `long:"output" short:"o" usage:"Set output format" default:"table"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&List{}, cobra.Command{
+		Short:   "List named volumes",
+		Use:     "ls [FLAGS]",
+		Aliases: []string{"list"},
+		Args:    cobra.NoArgs,
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "vol",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *List) Run(cmd *cobra.Command, args []string) error {
+	var err error
+
+	ctx := cmd.Context()
+
+	var items []volumeapi.Volume
+
+	for driver, strategy := range volume.Strategies() {
+		controller, err := strategy.NewVolumeV1alpha1(ctx)
+		if err != nil {
+			log.G(ctx).Warnf("could not prepare %s volume service: %v", driver, err)
+			continue
+		}
+
+		volumes, err := controller.List(ctx, &volumeapi.VolumeList{})
+		if err != nil {
+			return err
+		}
+
+		// Only named volumes are managed by KraftKit, host path volumes are
+		// listed with the machines they are bound to.
+		for _, vol := range volumes.Items {
+			if vol.Status.Managed {
+				items = append(items, vol)
+			}
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+
+	err = iostreams.G(ctx).StartPager()
+	if err != nil {
+		log.G(ctx).Errorf("error starting pager: %v", err)
+	}
+
+	defer iostreams.G(ctx).StopPager()
+
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+		tableprinter.WithOutputFormatFromString(opts.Output),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Header row
+	table.AddField("NAME", cs.Bold)
+	table.AddField("DRIVER", cs.Bold)
+	if opts.Long {
+		table.AddField("SOURCE", cs.Bold)
+	}
+	table.AddField("MACHINES", cs.Bold)
+	table.AddField("STATUS", cs.Bold)
+	table.EndRow()
+
+	for _, item := range items {
+		table.AddField(item.Name, nil)
+		table.AddField(item.Spec.Driver, nil)
+		if opts.Long {
+			table.AddField(item.Spec.Source, nil)
+		}
+		table.AddField(fmt.Sprintf("%d", len(item.Status.Machines)), nil)
+		table.AddField(item.Status.State.String(), nil)
+		table.EndRow()
+	}
+
+	return table.Render(iostreams.G(ctx).Out)
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package remove
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	mplatform "kraftkit.sh/machine/platform"
+	"kraftkit.sh/machine/volume"
+)
+
+type Rm struct {
+	Force bool `long:"force" short:"f" usage:"Remove the volume even if it is attached to machines"`
+}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Rm{}, cobra.Command{
+		Short:   "Remove named volumes",
+		Use:     "rm [FLAGS] NAME [NAME...]",
+		Aliases: []string{"remove", "delete", "del"},
+		Args:    cobra.MinimumNArgs(1),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "vol",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *Rm) Run(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	// Determine the machines which still exist, such that volumes are not kept
+	// alive by machines which were removed without detaching them.
+	existing := map[string]bool{}
+	if !opts.Force {
+		controller, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+		if err != nil {
+			return err
+		}
+
+		machines, err := controller.List(ctx, &machineapi.MachineList{})
+		if err != nil {
+			return err
+		}
+
+		for _, machine := range machines.Items {
+			existing[machine.Name] = true
+		}
+	}
+
+	var errs []error
+
+	for _, name := range args {
+		vol, _, err := volume.Lookup(ctx, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		attached := []string{}
+		for _, machine := range vol.Status.Machines {
+			if existing[machine] {
+				attached = append(attached, machine)
+			}
+		}
+
+		vol.Status.Machines = attached
+
+		strategy, ok := volume.Strategies()[vol.Spec.Driver]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unsupported volume driver strategy: %s", vol.Spec.Driver))
+			continue
+		}
+
+		controller, err := strategy.NewVolumeV1alpha1(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if _, err := controller.Delete(ctx, vol); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		fmt.Fprintln(iostreams.G(ctx).Out, name)
+	}
+
+	for _, err := range errs {
+		log.G(ctx).Error(err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("could not remove %d volume(s)", len(errs))
+	}
+
+	return nil
+}
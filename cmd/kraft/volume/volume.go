@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package volume
+
+import (
+	"github.com/spf13/cobra"
+
+	"kraftkit.sh/cmd/kraft/volume/create"
+	"kraftkit.sh/cmd/kraft/volume/inspect"
+	"kraftkit.sh/cmd/kraft/volume/list"
+	"kraftkit.sh/cmd/kraft/volume/remove"
+	"kraftkit.sh/cmdfactory"
+)
+
+type Volume struct{}
+
+func New() *cobra.Command {
+	cmd, err := cmdfactory.New(&Volume{}, cobra.Command{
+		Short:   "Manage machine volumes",
+		Use:     "volume SUBCOMMAND",
+		Aliases: []string{"vol"},
+		Hidden:  true,
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "vol",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.AddCommand(create.New())
+	cmd.AddCommand(inspect.New())
+	cmd.AddCommand(list.New())
+	cmd.AddCommand(remove.New())
+
+	return cmd
+}
+
+func (opts *Volume) Run(cmd *cobra.Command, args []string) error {
+	return cmd.Help()
+}
@@ -7,6 +7,7 @@ package exec
 import (
 	"fmt"
 	"io"
+	"os"
 )
 
 type ExecOptions struct {
@@ -18,6 +19,7 @@ type ExecOptions struct {
 	env       []string
 	callbacks []func(int)
 	detach    bool
+	files     []*os.File
 }
 
 type ExecOption func(eo *ExecOptions) error
@@ -118,3 +120,13 @@ func WithDetach(detach bool) ExecOption {
 		return nil
 	}
 }
+
+// WithExtraFiles passes the provided open files to the process, where the
+// first file becomes file descriptor 3, the second 4, and so on.  This method
+// can be called multiple times.
+func WithExtraFiles(files ...*os.File) ExecOption {
+	return func(eo *ExecOptions) error {
+		eo.files = append(eo.files, files...)
+		return nil
+	}
+}
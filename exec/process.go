@@ -104,6 +104,9 @@ func (e *Process) Start(ctx context.Context) error {
 	// Set the stdin
 	e.cmd.Stdin = e.opts.stdin
 
+	// Pass any additional open files
+	e.cmd.ExtraFiles = e.opts.files
+
 	// Add any set environmental variables including the host's
 	e.cmd.Env = append(os.Environ(), e.opts.env...)
 
@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"kraftkit.sh/internal/unpack"
+)
+
+// Compression is the algorithm with which a CPIO archive is compressed.
+type Compression string
+
+const (
+	// CompressionNone leaves the CPIO archive uncompressed.
+	CompressionNone = Compression("none")
+
+	// CompressionGzip compresses the CPIO archive with gzip.
+	CompressionGzip = Compression("gzip")
+
+	// CompressionLZ4 compresses the CPIO archive with LZ4 in the legacy frame
+	// format, which is the one understood by initramfs decompressors.
+	CompressionLZ4 = Compression("lz4")
+
+	// CompressionZstd compresses the CPIO archive with Zstandard.
+	CompressionZstd = Compression("zstd")
+)
+
+// String implements fmt.Stringer
+func (compression Compression) String() string {
+	return string(compression)
+}
+
+// Compressions returns the list of supported compression algorithms.
+func Compressions() []Compression {
+	return []Compression{
+		CompressionNone,
+		CompressionGzip,
+		CompressionLZ4,
+		CompressionZstd,
+	}
+}
+
+// CompressionNames returns the string representation of all supported
+// compression algorithms.
+func CompressionNames() []string {
+	ret := []string{}
+	for _, compression := range Compressions() {
+		ret = append(ret, compression.String())
+	}
+
+	return ret
+}
+
+// compressor returns a writer which compresses its input with the provided
+// algorithm to the provided writer.  The output only depends on the input,
+// such that archives remain reproducible.
+func compressor(w io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case "", CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case CompressionLZ4:
+		return &lz4Writer{w: w}, nil
+	case CompressionZstd:
+		return zstd.NewWriter(w,
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithEncoderLevel(zstd.SpeedBestCompression),
+		)
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %s", compression)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close implements io.Closer.
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+const (
+	// lz4LegacyMagic is the magic number of the legacy LZ4 frame format.
+	lz4LegacyMagic = 0x184c2102
+
+	// lz4LegacyBlockSize is the uncompressed size of the blocks of the legacy
+	// LZ4 frame format.
+	lz4LegacyBlockSize = 8 << 20
+
+	lz4MinMatch  = 4
+	lz4MaxOffset = 65535
+	lz4HashLog   = 16
+)
+
+// lz4Writer compresses its input into the legacy LZ4 frame format, which
+// consists of the magic number followed by blocks prefixed with their
+// compressed size.
+type lz4Writer struct {
+	w     io.Writer
+	buf   []byte
+	begun bool
+}
+
+// Write implements io.Writer.
+func (lw *lz4Writer) Write(p []byte) (int, error) {
+	n := len(p)
+
+	for len(p) > 0 {
+		if lw.buf == nil {
+			lw.buf = make([]byte, 0, lz4LegacyBlockSize)
+		}
+
+		c := copy(lw.buf[len(lw.buf):cap(lw.buf)], p)
+		lw.buf = lw.buf[:len(lw.buf)+c]
+		p = p[c:]
+
+		if len(lw.buf) == cap(lw.buf) {
+			if err := lw.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// flush writes the buffered input as a compressed block.
+func (lw *lz4Writer) flush() error {
+	if !lw.begun {
+		var magic [4]byte
+		binary.LittleEndian.PutUint32(magic[:], lz4LegacyMagic)
+		if _, err := lw.w.Write(magic[:]); err != nil {
+			return err
+		}
+
+		lw.begun = true
+	}
+
+	if len(lw.buf) == 0 {
+		return nil
+	}
+
+	block := lz4CompressBlock(lw.buf)
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(block)))
+	if _, err := lw.w.Write(size[:]); err != nil {
+		return err
+	}
+
+	if _, err := lw.w.Write(block); err != nil {
+		return err
+	}
+
+	lw.buf = lw.buf[:0]
+
+	return nil
+}
+
+// Close implements io.Closer.
+func (lw *lz4Writer) Close() error {
+	return lw.flush()
+}
+
+// lz4CompressBlock compresses the provided data into an LZ4 block by greedily
+// encoding the earliest match found through a hash table of 4-byte sequences.
+func lz4CompressBlock(src []byte) []byte {
+	dst := make([]byte, 0, len(src)/2)
+	table := make([]int32, 1<<lz4HashLog)
+
+	// The last match must start at least 12 bytes before the end of the block
+	// and the last 5 bytes are always literals.
+	limit := len(src) - 12
+	anchor := 0
+
+	for i := 0; i < limit; {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 2654435761) >> (32 - lz4HashLog)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+
+		if ref < 0 || i-ref > lz4MaxOffset || binary.LittleEndian.Uint32(src[ref:]) != seq {
+			i++
+			continue
+		}
+
+		length := lz4MinMatch
+		for i+length < len(src)-5 && src[ref+length] == src[i+length] {
+			length++
+		}
+
+		dst = lz4Sequence(dst, src[anchor:i], i-ref, length)
+
+		i += length
+		anchor = i
+	}
+
+	literals := len(src) - anchor
+	dst = append(dst, byte(lz4Min(literals, 15)<<4))
+	if literals >= 15 {
+		dst = lz4Length(dst, literals-15)
+	}
+
+	return append(dst, src[anchor:]...)
+}
+
+// lz4Sequence appends a sequence of the provided literals followed by a match
+// of the provided offset and length.
+func lz4Sequence(dst, literals []byte, offset, length int) []byte {
+	length -= lz4MinMatch
+
+	dst = append(dst, byte(lz4Min(len(literals), 15)<<4|lz4Min(length, 15)))
+	if len(literals) >= 15 {
+		dst = lz4Length(dst, len(literals)-15)
+	}
+
+	dst = append(dst, literals...)
+	dst = append(dst, byte(offset), byte(offset>>8))
+
+	if length >= 15 {
+		dst = lz4Length(dst, length-15)
+	}
+
+	return dst
+}
+
+// lz4Length appends the remainder of a length which exceeds its token.
+func lz4Length(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
+	}
+
+	return append(dst, byte(n))
+}
+
+func lz4Min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// decompressor returns a reader of the uncompressed contents of the provided
+// archive, whose compression is detected by its magic number.
+func decompressor(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// The legacy LZ4 frame format is specific to initramfs, the remaining
+	// algorithms are shared with the layers of OCI images.
+	if len(magic) == 4 && binary.LittleEndian.Uint32(magic) == lz4LegacyMagic {
+		return io.NopCloser(&lz4Reader{r: br}), nil
+	}
+
+	return unpack.Decompress(br)
+}
+
+// lz4Reader decompresses its input from the legacy LZ4 frame format.
+type lz4Reader struct {
+	r     io.Reader
+	block []byte
+	buf   []byte
+}
+
+// Read implements io.Reader.
+func (lr *lz4Reader) Read(p []byte) (int, error) {
+	for len(lr.buf) == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(lr.r, size[:]); err == io.EOF {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, err
+		}
+
+		n := binary.LittleEndian.Uint32(size[:])
+
+		// The magic number precedes the first block and that of concatenated
+		// frames.
+		if n == lz4LegacyMagic {
+			continue
+		} else if n > lz4LegacyBlockSize+lz4LegacyBlockSize/255+16 {
+			return 0, fmt.Errorf("invalid lz4 block size: %d", n)
+		}
+
+		if cap(lr.block) < int(n) {
+			lr.block = make([]byte, n)
+		}
+
+		if _, err := io.ReadFull(lr.r, lr.block[:n]); err != nil {
+			return 0, err
+		}
+
+		var err error
+		lr.buf, err = lz4DecompressBlock(lr.block[:n], lr.buf[:0])
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, lr.buf)
+	lr.buf = lr.buf[n:]
+
+	return n, nil
+}
+
+// lz4DecompressBlock appends the decompressed contents of the provided LZ4
+// block to dst.
+func lz4DecompressBlock(src, dst []byte) ([]byte, error) {
+	length := func(i, n int) (int, int, error) {
+		if n != 15 {
+			return i, n, nil
+		}
+
+		for {
+			if i >= len(src) {
+				return 0, 0, io.ErrUnexpectedEOF
+			}
+
+			b := src[i]
+			i++
+			n += int(b)
+
+			if b != 255 {
+				return i, n, nil
+			}
+		}
+	}
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		var literals int
+		var err error
+		if i, literals, err = length(i, int(token>>4)); err != nil {
+			return nil, err
+		}
+
+		if i+literals > len(src) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		// The last sequence only consists of literals.
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+
+		var match int
+		if i, match, err = length(i, int(token&0xf)); err != nil {
+			return nil, err
+		}
+
+		if offset == 0 || offset > len(dst) {
+			return nil, fmt.Errorf("invalid lz4 match offset: %d", offset)
+		}
+
+		// Matches may overlap with the bytes which they produce.
+		start := len(dst) - offset
+		for j := 0; j < match+lz4MinMatch; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+
+	return dst, nil
+}
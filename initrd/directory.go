@@ -7,18 +7,26 @@ package initrd
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/cavaliergopher/cpio"
+
+	"kraftkit.sh/log"
 )
 
 type directory struct {
 	opts  InitrdOptions
 	path  string
 	files []string
+
+	// modes override the modes of the entries of the directory, keyed by their
+	// location within the rootfs.
+	modes map[string]fs.FileMode
 }
 
 // NewFromDirectory returns an instantiated Initrd interface which is is able to
@@ -48,7 +56,7 @@ func NewFromDirectory(_ context.Context, path string, opts ...InitrdOption) (Ini
 }
 
 // Build implements Initrd.
-func (initrd *directory) Build(_ context.Context) (string, error) {
+func (initrd *directory) Build(ctx context.Context) (string, error) {
 	if initrd.opts.output == "" {
 		fi, err := os.CreateTemp("", "")
 		if err != nil {
@@ -58,78 +66,224 @@ func (initrd *directory) Build(_ context.Context) (string, error) {
 		initrd.opts.output = fi.Name()
 	}
 
-	f, err := os.OpenFile(initrd.opts.output, os.O_RDWR|os.O_CREATE, 0o644)
+	root, err := initrd.tree()
+	if err != nil {
+		return "", fmt.Errorf("could not read rootfs: %w", err)
+	}
+
+	if initrd.opts.format.IsImage() {
+		if err := initrd.buildImage(ctx, root); err != nil {
+			return "", err
+		}
+
+		return initrd.opts.output, nil
+	}
+
+	f, err := os.OpenFile(initrd.opts.output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
 	if err != nil {
 		return "", fmt.Errorf("could not open initramfs file: %w", err)
 	}
 
 	defer f.Close()
 
-	writer := cpio.NewWriter(f)
-	defer writer.Close()
+	compressed, err := compressor(f, initrd.opts.compression)
+	if err != nil {
+		return "", err
+	}
 
-	if err := filepath.WalkDir(initrd.path, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	writer := cpio.NewWriter(compressed)
 
-		internal := strings.TrimPrefix(path, initrd.path)
-		if internal == "" {
+	// Entries are written in the depth-first order of the sorted tree, such
+	// that the same tree always results in the same archive.
+	if err := root.walk(func(e *entry) error {
+		if e == root {
 			return nil
 		}
 
-		info, err := d.Info()
-		if err != nil {
-			return err
-		}
+		return writeCPIOEntry(writer, e)
+	}); err != nil {
+		return "", err
+	}
 
-		if d.Type().IsDir() {
-			if err := writer.WriteHeader(&cpio.Header{
-				Name: internal,
-				Mode: cpio.TypeDir,
-			}); err != nil {
-				return err
-			}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
 
-			return nil
+	if err := compressed.Close(); err != nil {
+		return "", err
+	}
+
+	initrd.files = root.files()
+
+	return initrd.opts.output, nil
+}
+
+// tree returns the tree of entries of the directory without the excluded
+// entries, with the modes overridden and with the owners and modification
+// times normalized as requested.
+func (initrd *directory) tree() (*entry, error) {
+	root, err := readTree(initrd.path)
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := readIgnoreFile(filepath.Join(initrd.path, IgnoreFileName))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", IgnoreFileName, err)
+	}
+
+	patterns = append([]string{IgnoreFileName}, patterns...)
+	if err := exclude(root, append(patterns, initrd.opts.excludes...)); err != nil {
+		return nil, err
+	}
+
+	mtime := initrd.opts.mtime
+	if mtime == nil {
+		if mtime, err = sourceDateEpoch(); err != nil {
+			return nil, err
 		}
+	}
 
-		initrd.files = append(initrd.files, internal)
+	_ = root.walk(func(e *entry) error {
+		if mode, ok := initrd.modes[e.internal]; ok {
+			e.info = modeInfo{e.info, mode}
+		}
 
-		var data []byte
-		targetLink := ""
-		if info, err := d.Info(); err == nil && info.Mode()&os.ModeSymlink != 0 {
-			targetLink, err = os.Readlink(path)
-			if err != nil {
-				return err
-			}
-			data = []byte(targetLink)
-		} else if d.Type().IsRegular() {
-			data, err = os.ReadFile(path)
-			if err != nil {
-				return err
-			}
+		if initrd.opts.owner {
+			e.uid = initrd.opts.uid
+			e.gid = initrd.opts.gid
 		}
 
-		if err := writer.WriteHeader(&cpio.Header{
-			Name:     internal,
-			Linkname: targetLink,
-			Mode:     cpio.FileMode(d.Type().Perm()),
-			Size:     info.Size(),
-		}); err != nil {
-			return err
+		if mtime != nil && e.info.ModTime().After(*mtime) {
+			e.info = modTimeInfo{e.info, *mtime}
 		}
 
-		if _, err := writer.Write(data); err != nil {
+		return nil
+	})
+
+	return root, nil
+}
+
+// sourceDateEpoch returns the time of the SOURCE_DATE_EPOCH environmental
+// variable, if set, which is the conventional way of requesting reproducible
+// timestamps.
+func sourceDateEpoch() (*time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return nil, nil
+	}
+
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH: %w", err)
+	}
+
+	mtime := time.Unix(seconds, 0).UTC()
+
+	return &mtime, nil
+}
+
+// writeCPIOEntry writes the header and the contents of the entry to the CPIO
+// archive.
+func writeCPIOEntry(writer *cpio.Writer, e *entry) error {
+	mode := cpio.FileMode(e.info.Mode().Perm())
+	if e.info.Mode()&fs.ModeSetuid != 0 {
+		mode |= cpio.ModeSetuid
+	}
+	if e.info.Mode()&fs.ModeSetgid != 0 {
+		mode |= cpio.ModeSetgid
+	}
+	if e.info.Mode()&fs.ModeSticky != 0 {
+		mode |= cpio.ModeSticky
+	}
+
+	hdr := &cpio.Header{
+		Name:    e.internal,
+		Mode:    mode,
+		Uid:     e.uid,
+		Guid:    e.gid,
+		Links:   1,
+		ModTime: e.info.ModTime(),
+	}
+
+	switch {
+	case e.isDir():
+		hdr.Mode |= cpio.TypeDir
+		hdr.Links = 2
+
+		return writer.WriteHeader(hdr)
+
+	case e.isSymlink():
+		hdr.Mode |= cpio.TypeSymlink
+		hdr.Linkname = e.link
+		hdr.Size = int64(len(e.link))
+
+		if err := writer.WriteHeader(hdr); err != nil {
 			return err
 		}
 
-		return nil
-	}); err != nil {
-		return "", err
+		_, err := writer.Write([]byte(e.link))
+		return err
 	}
 
-	return initrd.opts.output, nil
+	hdr.Mode |= cpio.TypeReg
+	hdr.Size = e.info.Size()
+
+	if err := writer.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	f, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	_, err = io.Copy(writer, f)
+	return err
+}
+
+// buildImage serializes the tree of the directory into a filesystem image.
+func (initrd *directory) buildImage(ctx context.Context, root *entry) error {
+	if initrd.opts.format == FormatFAT32 {
+		_ = root.walk(func(e *entry) error {
+			children := []*entry{}
+			for _, child := range e.children {
+				if child.isSymlink() {
+					log.G(ctx).Warnf("skipping symbolic link %s: not supported by FAT32", child.internal)
+					continue
+				}
+
+				children = append(children, child)
+			}
+
+			e.children = children
+
+			return nil
+		})
+	}
+
+	f, err := os.Create(initrd.opts.output)
+	if err != nil {
+		return fmt.Errorf("could not open %s image: %w", initrd.opts.format, err)
+	}
+
+	defer f.Close()
+
+	switch initrd.opts.format {
+	case FormatExt2:
+		err = writeExt2(f, root)
+	case FormatFAT32:
+		err = writeFAT32(f, root)
+	}
+	if err != nil {
+		return fmt.Errorf("could not build %s image: %w", initrd.opts.format, err)
+	}
+
+	initrd.files = root.files()
+
+	return nil
 }
 
 // Files implements Initrd.
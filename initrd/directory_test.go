@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cavaliergopher/cpio"
+)
+
+func TestNewFromDirectoryImage(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "etc", "nginx"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range map[string]string{
+		"etc/hostname":         "unikraft\n",
+		"etc/nginx/nginx.conf": "worker_processes 1;\n",
+		"index.html":           "<html></html>\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		format Format
+		magic  func([]byte) bool
+	}{
+		{
+			format: FormatExt2,
+			magic: func(b []byte) bool {
+				return binary.LittleEndian.Uint16(b[1080:1082]) == ext2Magic
+			},
+		},
+		{
+			format: FormatFAT32,
+			magic: func(b []byte) bool {
+				return bytes.Equal(b[82:90], []byte("FAT32   ")) && b[510] == 0x55 && b[511] == 0xaa
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.String(), func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "rootfs."+tt.format.String())
+
+			rootfs, err := NewFromDirectory(context.Background(), dir,
+				WithFormat(tt.format),
+				WithOutput(output),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := rootfs.Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if path != output {
+				t.Errorf("expected image at %s, got %s", output, path)
+			}
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.magic(b) {
+				t.Errorf("image is not a valid %s image", tt.format)
+			}
+
+			expected := []string{"/etc/hostname", "/etc/nginx/nginx.conf", "/index.html"}
+			if files := rootfs.Files(); !reflect.DeepEqual(files, expected) {
+				t.Errorf("expected files %v, got %v", expected, files)
+			}
+
+			for _, contents := range []string{"unikraft\n", "worker_processes 1;\n", "<html></html>\n"} {
+				if !bytes.Contains(b, []byte(contents)) {
+					t.Errorf("image does not contain %q", contents)
+				}
+			}
+		})
+	}
+}
+
+func TestWithFormat(t *testing.T) {
+	if _, err := NewFromDirectory(context.Background(), t.TempDir(), WithFormat("iso9660")); err == nil {
+		t.Error("expected unsupported format to fail")
+	}
+}
+
+func TestFatShortNames(t *testing.T) {
+	dir := &entry{}
+	for _, name := range []string{
+		"README",
+		"index.html",
+		"Makefile",
+		"makefile.am",
+		"nginx.conf.default",
+		"nginx.conf.example",
+		".hidden",
+	} {
+		dir.children = append(dir.children, &entry{name: name})
+	}
+
+	w := fatWriter{
+		names: map[*entry][11]byte{},
+		lfn:   map[*entry]bool{},
+	}
+
+	w.shortNames(dir)
+
+	expected := map[string]struct {
+		short string
+		lfn   bool
+	}{
+		"README":             {"README     ", false},
+		"index.html":         {"INDEX~1 HTM", true},
+		"Makefile":           {"MAKEFILE   ", true},
+		"makefile.am":        {"MAKEFILEAM ", true},
+		"nginx.conf.default": {"NGINXC~1DEF", true},
+		"nginx.conf.example": {"NGINXC~1EXA", true},
+		".hidden":            {"HIDDEN~1   ", true},
+	}
+
+	for _, child := range dir.children {
+		name := w.names[child]
+		if string(name[:]) != expected[child.name].short {
+			t.Errorf("expected short name %q for %s, got %q", expected[child.name].short, child.name, string(name[:]))
+		}
+
+		if w.lfn[child] != expected[child.name].lfn {
+			t.Errorf("expected long file name of %s to be %t", child.name, expected[child.name].lfn)
+		}
+	}
+}
+
+func TestNewFromDirectoryReproducible(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "etc", "hostname"), []byte("unikraft\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	epoch := time.Unix(1700000000, 0)
+
+	for _, compression := range Compressions() {
+		t.Run(compression.String(), func(t *testing.T) {
+			var digests [][]byte
+
+			for i := 0; i < 2; i++ {
+				now := time.Now().Add(time.Duration(i) * time.Hour)
+				for _, path := range []string{dir, filepath.Join(dir, "etc"), filepath.Join(dir, "etc", "hostname")} {
+					if err := os.Chtimes(path, now, now); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				rootfs, err := NewFromDirectory(context.Background(), dir,
+					WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+					WithCompression(compression),
+					WithModTime(epoch),
+					WithOwner(0, 0),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				path, err := rootfs.Build(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				digests = append(digests, b)
+			}
+
+			if !bytes.Equal(digests[0], digests[1]) {
+				t.Error("expected builds of the same tree to be identical")
+			}
+		})
+	}
+}
+
+func TestNewFromDirectoryExcludes(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, contents := range map[string]string{
+		IgnoreFileName:     "# build artifacts\n*.log\n/cache\n!cache/keep\n",
+		"app.log":          "log",
+		"bin/app":          "app",
+		"cache/keep":       "keep",
+		"cache/tmp":        "tmp",
+		"tmp/scratch.conf": "scratch",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rootfs, err := NewFromDirectory(context.Background(), dir,
+		WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+		WithExcludes("tmp"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rootfs.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"/bin/app", "/cache/keep"}
+	if files := rootfs.Files(); !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+}
+
+func TestDirectoryModes(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"hostname", "shadow"} {
+		if err := os.WriteFile(filepath.Join(dir, "etc", file), []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rootfs := directory{
+		opts: InitrdOptions{output: filepath.Join(t.TempDir(), "initramfs.cpio")},
+		path: dir,
+		modes: map[string]fs.FileMode{
+			"/etc":        fs.ModeDir | 0o555,
+			"/etc/shadow": 0o000,
+		},
+	}
+
+	path, err := rootfs.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	found := map[string]cpio.FileMode{}
+	reader := cpio.NewReader(f)
+
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		found[hdr.Name] = hdr.Mode
+	}
+
+	expected := map[string]cpio.FileMode{
+		"/etc":          cpio.TypeDir | 0o555,
+		"/etc/hostname": cpio.TypeReg | 0o644,
+		"/etc/shadow":   cpio.TypeReg | 0o000,
+	}
+
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected modes %v, got %v", expected, found)
+	}
+}
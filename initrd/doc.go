@@ -5,4 +5,17 @@
 package initrd
 
 // Package initrd is a package that is used for the dynamic construction of CPIO
-// archives which are used as initramfs for a unikernel instance.
+// archives which are used as initramfs for a unikernel instance.  Directories
+// can alternatively be serialized into ext2 or FAT32 filesystem images, which
+// are written in pure Go such that neither root privileges, mkfs(8) nor loop
+// devices are required, and are attached to a unikernel as a block device.
+//
+// Root filesystems can also be sourced from OCI images, referenced either with
+// a docker:// prefix or an oci: prefix for local image layouts, whose layers
+// are flattened without the need for BuildKit.
+//
+// Entries are always serialized in sorted order and can be made reproducible
+// by clamping their modification times, e.g. via SOURCE_DATE_EPOCH, and
+// normalizing their owners.  Paths listed in a .kraftignore file at the root
+// of a directory are excluded and CPIO archives can be compressed with gzip,
+// LZ4 or Zstandard.
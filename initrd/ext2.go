@@ -0,0 +1,698 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+)
+
+// The layout of the ext2 filesystem images which are written is fixed to
+// revision 1 with 4 KiB blocks, 128 byte inodes and a copy of the superblock
+// and group descriptors at the start of every block group.  See:
+// https://www.nongnu.org/ext2-doc/ext2.html
+const (
+	ext2BlockSize        = 4096
+	ext2InodeSize        = 128
+	ext2BlocksPerGroup   = 8 * ext2BlockSize
+	ext2MaxInodesPerGrp  = 8 * ext2BlockSize
+	ext2InodesPerBlock   = ext2BlockSize / ext2InodeSize
+	ext2PointersPerBlock = ext2BlockSize / 4
+	ext2GroupDescSize    = 32
+	ext2BytesPerInode    = 16384
+	ext2RootIno          = 2
+	ext2FirstIno         = 11
+	ext2DirectBlocks     = 12
+	ext2Magic            = 0xef53
+
+	ext2FeatureIncompatFiletype  = 0x0002
+	ext2FeatureRoCompatLargeFile = 0x0002
+
+	ext2FileTypeRegular = 1
+	ext2FileTypeDir     = 2
+	ext2FileTypeSymlink = 7
+
+	ext2ModeRegular = 0x8000
+	ext2ModeDir     = 0x4000
+	ext2ModeSymlink = 0xa000
+)
+
+// ext2Writer serializes a tree of entries into an ext2 filesystem image.
+type ext2Writer struct {
+	f            *os.File
+	blocks       uint32
+	groups       uint32
+	inodesPerGrp uint32
+	gdtBlocks    uint32
+	itableBlocks uint32
+	blockBitmaps [][]byte
+	inodeBitmaps [][]byte
+	usedDirs     []uint16
+	inodes       map[*entry]uint32
+	next         uint32
+	largeFile    bool
+}
+
+// writeExt2 serializes the provided tree into an ext2 filesystem image which is
+// written to the provided file.
+func writeExt2(f *os.File, root *entry) error {
+	w := ext2Writer{
+		f:      f,
+		inodes: map[*entry]uint32{},
+	}
+
+	// Determine the number of blocks and inodes which are required to store the
+	// tree.
+	var dataBlocks, inodes uint64
+	if err := root.walk(func(e *entry) error {
+		n, err := ext2ContentBlocks(e)
+		if err != nil {
+			return err
+		}
+
+		dataBlocks += n + ext2IndirectBlocks(n)
+		inodes++
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := w.layout(dataBlocks, inodes); err != nil {
+		return err
+	}
+
+	if err := f.Truncate(int64(w.blocks) * ext2BlockSize); err != nil {
+		return fmt.Errorf("could not allocate ext2 image: %w", err)
+	}
+
+	// Assign the inodes in depth-first order such that the inodes of a
+	// directory are known before its entries are written.
+	ino := uint32(ext2FirstIno)
+	_ = root.walk(func(e *entry) error {
+		if e == root {
+			w.inodes[e] = ext2RootIno
+		} else {
+			w.inodes[e] = ino
+			ino++
+		}
+
+		return nil
+	})
+
+	// Reserve the inodes preceding the first non-reserved inode.
+	for i := uint32(1); i < ext2FirstIno; i++ {
+		w.markInode(i, false)
+	}
+
+	if err := w.writeEntry(root, ext2RootIno); err != nil {
+		return err
+	}
+
+	return w.writeMetadata(root)
+}
+
+// ext2ContentBlocks returns the number of blocks which hold the contents of the
+// provided entry, excluding indirect blocks.
+func ext2ContentBlocks(e *entry) (uint64, error) {
+	switch {
+	case e.isDir():
+		b, err := ext2DirEntries(e, 0, 0, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		return uint64(len(b)) / ext2BlockSize, nil
+	case e.isSymlink():
+		// Short targets are stored within the inode.
+		if len(e.link) < 60 {
+			return 0, nil
+		}
+
+		return ext2Blocks(uint64(len(e.link))), nil
+	default:
+		return ext2Blocks(uint64(e.info.Size())), nil
+	}
+}
+
+// ext2Blocks returns the number of blocks which hold the provided number of
+// bytes.
+func ext2Blocks(size uint64) uint64 {
+	return (size + ext2BlockSize - 1) / ext2BlockSize
+}
+
+// ext2IndirectBlocks returns the number of indirect blocks which are required to
+// map the provided number of blocks of an inode.
+func ext2IndirectBlocks(n uint64) uint64 {
+	const p = uint64(ext2PointersPerBlock)
+
+	if n <= ext2DirectBlocks {
+		return 0
+	}
+
+	n -= ext2DirectBlocks
+	if n <= p {
+		return 1
+	}
+
+	n -= p
+	if n <= p*p {
+		return 2 + (n+p-1)/p
+	}
+
+	n -= p * p
+
+	return 3 + p + (n+p*p-1)/(p*p) + (n+p-1)/p
+}
+
+// layout determines the number of blocks, block groups and inodes of the image
+// such that the provided number of data blocks and inodes fit, with some
+// headroom for writes within the machine.
+func (w *ext2Writer) layout(dataBlocks, inodes uint64) error {
+	need := dataBlocks + dataBlocks/10 + 16
+	minInodes := inodes + ext2FirstIno + 16
+	blocks := need + 64
+
+	for {
+		if blocks > math.MaxUint32 {
+			return fmt.Errorf("rootfs is too large for an ext2 image")
+		}
+
+		groups := (blocks + ext2BlocksPerGroup - 1) / ext2BlocksPerGroup
+		gdtBlocks := (groups*ext2GroupDescSize + ext2BlockSize - 1) / ext2BlockSize
+
+		total := blocks * ext2BlockSize / ext2BytesPerInode
+		if total < minInodes {
+			total = minInodes
+		}
+
+		inodesPerGrp := (total + groups - 1) / groups
+		inodesPerGrp = (inodesPerGrp + ext2InodesPerBlock - 1) / ext2InodesPerBlock * ext2InodesPerBlock
+		if inodesPerGrp > ext2MaxInodesPerGrp {
+			inodesPerGrp = ext2MaxInodesPerGrp
+			if inodesPerGrp*groups < minInodes {
+				blocks = (groups + 1) * ext2BlocksPerGroup
+				continue
+			}
+		}
+
+		itableBlocks := inodesPerGrp * ext2InodeSize / ext2BlockSize
+		overhead := 3 + gdtBlocks + itableBlocks
+
+		// The last block group must be large enough to hold its metadata.
+		if last := blocks - (groups-1)*ext2BlocksPerGroup; last < overhead+1 {
+			blocks += overhead + 1 - last
+			continue
+		}
+
+		if free := blocks - groups*overhead; free < need {
+			blocks += need - free
+			continue
+		}
+
+		w.blocks = uint32(blocks)
+		w.groups = uint32(groups)
+		w.inodesPerGrp = uint32(inodesPerGrp)
+		w.gdtBlocks = uint32(gdtBlocks)
+		w.itableBlocks = uint32(itableBlocks)
+
+		break
+	}
+
+	w.blockBitmaps = make([][]byte, w.groups)
+	w.inodeBitmaps = make([][]byte, w.groups)
+	w.usedDirs = make([]uint16, w.groups)
+
+	for g := uint32(0); g < w.groups; g++ {
+		w.blockBitmaps[g] = make([]byte, ext2BlockSize)
+		w.inodeBitmaps[g] = make([]byte, ext2BlockSize)
+
+		// Mark the metadata of the group as used.
+		start := g * ext2BlocksPerGroup
+		for b := uint32(0); b < 3+w.gdtBlocks+w.itableBlocks; b++ {
+			w.markBlock(start + b)
+		}
+
+		// Mark the blocks beyond the end of the last group as used.
+		for b := w.groupBlocks(g); b < ext2BlocksPerGroup; b++ {
+			w.blockBitmaps[g][b/8] |= 1 << (b % 8)
+		}
+
+		// Mark the inodes beyond the inodes of the group as used.
+		for i := w.inodesPerGrp; i < ext2MaxInodesPerGrp; i++ {
+			w.inodeBitmaps[g][i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return nil
+}
+
+// groupBlocks returns the number of blocks of the provided block group.
+func (w *ext2Writer) groupBlocks(g uint32) uint32 {
+	if g == w.groups-1 {
+		return w.blocks - g*ext2BlocksPerGroup
+	}
+
+	return ext2BlocksPerGroup
+}
+
+// blockBitmap returns the location of the block bitmap of the provided group.
+func (w *ext2Writer) blockBitmap(g uint32) uint32 {
+	return g*ext2BlocksPerGroup + 1 + w.gdtBlocks
+}
+
+// inodeBitmap returns the location of the inode bitmap of the provided group.
+func (w *ext2Writer) inodeBitmap(g uint32) uint32 {
+	return w.blockBitmap(g) + 1
+}
+
+// inodeTable returns the location of the inode table of the provided group.
+func (w *ext2Writer) inodeTable(g uint32) uint32 {
+	return w.blockBitmap(g) + 2
+}
+
+// markBlock marks the provided block as used.
+func (w *ext2Writer) markBlock(b uint32) {
+	g, i := b/ext2BlocksPerGroup, b%ext2BlocksPerGroup
+	w.blockBitmaps[g][i/8] |= 1 << (i % 8)
+}
+
+// markInode marks the provided inode as used.
+func (w *ext2Writer) markInode(ino uint32, dir bool) {
+	g, i := (ino-1)/w.inodesPerGrp, (ino-1)%w.inodesPerGrp
+	w.inodeBitmaps[g][i/8] |= 1 << (i % 8)
+
+	if dir {
+		w.usedDirs[g]++
+	}
+}
+
+// alloc returns the next free block and marks it as used.
+func (w *ext2Writer) alloc() (uint32, error) {
+	for ; w.next < w.blocks; w.next++ {
+		g, i := w.next/ext2BlocksPerGroup, w.next%ext2BlocksPerGroup
+		if w.blockBitmaps[g][i/8]&(1<<(i%8)) == 0 {
+			w.markBlock(w.next)
+			w.next++
+			return w.next - 1, nil
+		}
+	}
+
+	return 0, fmt.Errorf("ext2 image is out of space")
+}
+
+// writeBlocks stores the provided contents in newly allocated blocks and
+// returns their locations.
+func (w *ext2Writer) writeBlocks(r io.Reader, n uint64) ([]uint32, error) {
+	blocks := make([]uint32, 0, n)
+	buf := make([]byte, ext2BlockSize)
+	zero := make([]byte, ext2BlockSize)
+
+	for i := uint64(0); i < n; i++ {
+		// Contents which have shrunk since the image was laid out are padded
+		// with zeros.
+		m, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		copy(buf[m:], zero)
+
+		b, err := w.alloc()
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep the image sparse.
+		if !bytes.Equal(buf, zero) {
+			if _, err := w.f.WriteAt(buf, int64(b)*ext2BlockSize); err != nil {
+				return nil, err
+			}
+		}
+
+		blocks = append(blocks, b)
+	}
+
+	return blocks, nil
+}
+
+// mapBlocks returns the block map of an inode whose contents are stored in the
+// provided blocks, allocating and writing indirect blocks as required, and the
+// number of allocated indirect blocks.
+func (w *ext2Writer) mapBlocks(blocks []uint32) ([15]uint32, uint32, error) {
+	var iblock [15]uint32
+	var indirect uint32
+
+	for i := 0; i < ext2DirectBlocks && len(blocks) > 0; i++ {
+		iblock[i] = blocks[0]
+		blocks = blocks[1:]
+	}
+
+	for level := 1; level <= 3 && len(blocks) > 0; level++ {
+		var n uint32
+		var err error
+
+		iblock[ext2DirectBlocks+level-1], blocks, n, err = w.writeIndirect(level, blocks)
+		if err != nil {
+			return iblock, 0, err
+		}
+
+		indirect += n
+	}
+
+	if len(blocks) > 0 {
+		return iblock, 0, fmt.Errorf("file is too large for an ext2 image")
+	}
+
+	return iblock, indirect, nil
+}
+
+// writeIndirect writes an indirect block of the provided level which maps as
+// many of the provided blocks as possible and returns its location, the
+// remaining blocks and the number of allocated indirect blocks.
+func (w *ext2Writer) writeIndirect(level int, blocks []uint32) (uint32, []uint32, uint32, error) {
+	b, err := w.alloc()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	allocated := uint32(1)
+	buf := make([]byte, ext2BlockSize)
+
+	for i := 0; i < ext2PointersPerBlock && len(blocks) > 0; i++ {
+		var ptr, n uint32
+
+		if level == 1 {
+			ptr = blocks[0]
+			blocks = blocks[1:]
+		} else if ptr, blocks, n, err = w.writeIndirect(level-1, blocks); err != nil {
+			return 0, nil, 0, err
+		}
+
+		allocated += n
+		binary.LittleEndian.PutUint32(buf[i*4:], ptr)
+	}
+
+	if _, err := w.f.WriteAt(buf, int64(b)*ext2BlockSize); err != nil {
+		return 0, nil, 0, err
+	}
+
+	return b, blocks, allocated, nil
+}
+
+// writeEntry writes the contents and inode of the provided entry and its
+// descendants.
+func (w *ext2Writer) writeEntry(e *entry, parent uint32) error {
+	ino := w.inodes[e]
+
+	var mode uint16
+	var size uint64
+	var inline []byte
+	var blocks []uint32
+	var err error
+
+	links := uint16(1)
+
+	switch {
+	case e.isDir():
+		mode = ext2ModeDir
+		links = 2
+
+		for _, child := range e.children {
+			if child.isDir() {
+				links++
+			}
+		}
+
+		data, err := ext2DirEntries(e, ino, parent, w.inodes)
+		if err != nil {
+			return err
+		}
+
+		size = uint64(len(data))
+		blocks, err = w.writeBlocks(bytes.NewReader(data), size/ext2BlockSize)
+		if err != nil {
+			return err
+		}
+
+	case e.isSymlink():
+		mode = ext2ModeSymlink
+		size = uint64(len(e.link))
+
+		if len(e.link) < 60 {
+			inline = []byte(e.link)
+		} else if blocks, err = w.writeBlocks(bytes.NewReader([]byte(e.link)), ext2Blocks(size)); err != nil {
+			return err
+		}
+
+	default:
+		mode = ext2ModeRegular
+		size = uint64(e.info.Size())
+
+		f, err := os.Open(e.path)
+		if err != nil {
+			return err
+		}
+
+		blocks, err = w.writeBlocks(io.LimitReader(f, e.info.Size()), ext2Blocks(size))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("could not write %s: %w", e.internal, err)
+		}
+
+		if size > math.MaxInt32 {
+			w.largeFile = true
+		}
+	}
+
+	iblock, indirect, err := w.mapBlocks(blocks)
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", e.internal, err)
+	}
+
+	inode := make([]byte, ext2InodeSize)
+	mtime := uint32(e.info.ModTime().Unix())
+
+	binary.LittleEndian.PutUint16(inode[0:], mode|ext2Perm(e.info.Mode()))
+	binary.LittleEndian.PutUint16(inode[2:], uint16(e.uid))
+	binary.LittleEndian.PutUint32(inode[4:], uint32(size))
+	binary.LittleEndian.PutUint32(inode[8:], mtime)
+	binary.LittleEndian.PutUint32(inode[12:], mtime)
+	binary.LittleEndian.PutUint32(inode[16:], mtime)
+	binary.LittleEndian.PutUint16(inode[24:], uint16(e.gid))
+	binary.LittleEndian.PutUint16(inode[26:], links)
+	binary.LittleEndian.PutUint32(inode[28:], (uint32(len(blocks))+indirect)*(ext2BlockSize/512))
+
+	if inline != nil {
+		copy(inode[40:100], inline)
+	} else {
+		for i, b := range iblock {
+			binary.LittleEndian.PutUint32(inode[40+i*4:], b)
+		}
+	}
+
+	if mode == ext2ModeRegular {
+		binary.LittleEndian.PutUint32(inode[108:], uint32(size>>32))
+	}
+
+	// The high 16 bits of the owner are stored in the OS-dependent area.
+	binary.LittleEndian.PutUint16(inode[120:], uint16(e.uid>>16))
+	binary.LittleEndian.PutUint16(inode[122:], uint16(e.gid>>16))
+
+	g, i := (ino-1)/w.inodesPerGrp, (ino-1)%w.inodesPerGrp
+	if _, err := w.f.WriteAt(inode, int64(w.inodeTable(g))*ext2BlockSize+int64(i)*ext2InodeSize); err != nil {
+		return err
+	}
+
+	w.markInode(ino, e.isDir())
+
+	for _, child := range e.children {
+		if err := w.writeEntry(child, ino); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ext2Perm returns the permission bits of an inode for the provided mode.
+func ext2Perm(mode fs.FileMode) uint16 {
+	perm := uint16(mode.Perm())
+
+	if mode&fs.ModeSetuid != 0 {
+		perm |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		perm |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+
+	return perm
+}
+
+// ext2DirEntries returns the blocks which hold the entries of the provided
+// directory.
+func ext2DirEntries(e *entry, ino, parent uint32, inodes map[*entry]uint32) ([]byte, error) {
+	type dirent struct {
+		ino  uint32
+		typ  uint8
+		name string
+	}
+
+	dirents := []dirent{
+		{ino, ext2FileTypeDir, "."},
+		{parent, ext2FileTypeDir, ".."},
+	}
+
+	for _, child := range e.children {
+		typ := uint8(ext2FileTypeRegular)
+		if child.isDir() {
+			typ = ext2FileTypeDir
+		} else if child.isSymlink() {
+			typ = ext2FileTypeSymlink
+		}
+
+		dirents = append(dirents, dirent{inodes[child], typ, child.name})
+	}
+
+	var buf []byte
+	off, last := ext2BlockSize, 0
+
+	for _, d := range dirents {
+		if len(d.name) > 255 {
+			return nil, fmt.Errorf("file name is too long for an ext2 image: %s", d.name)
+		}
+
+		size := (8 + len(d.name) + 3) &^ 3
+
+		// Entries must not span blocks, such that the last entry of a block is
+		// extended to its end.
+		if off+size > ext2BlockSize {
+			if len(buf) > 0 {
+				binary.LittleEndian.PutUint16(buf[last+4:], uint16(len(buf)-last))
+			}
+
+			buf = append(buf, make([]byte, ext2BlockSize)...)
+			off = 0
+		}
+
+		start := len(buf) - ext2BlockSize + off
+		binary.LittleEndian.PutUint32(buf[start:], d.ino)
+		binary.LittleEndian.PutUint16(buf[start+4:], uint16(size))
+		buf[start+6] = uint8(len(d.name))
+		buf[start+7] = d.typ
+		copy(buf[start+8:], d.name)
+
+		last = start
+		off += size
+	}
+
+	binary.LittleEndian.PutUint16(buf[last+4:], uint16(len(buf)-last))
+
+	return buf, nil
+}
+
+// writeMetadata writes the bitmaps, group descriptors and superblocks of the
+// image.
+func (w *ext2Writer) writeMetadata(root *entry) error {
+	gdt := make([]byte, w.gdtBlocks*ext2BlockSize)
+
+	var freeBlocks, freeInodes uint32
+
+	for g := uint32(0); g < w.groups; g++ {
+		var groupFreeBlocks, groupFreeInodes uint32
+
+		for b := uint32(0); b < w.groupBlocks(g); b++ {
+			if w.blockBitmaps[g][b/8]&(1<<(b%8)) == 0 {
+				groupFreeBlocks++
+			}
+		}
+
+		for i := uint32(0); i < w.inodesPerGrp; i++ {
+			if w.inodeBitmaps[g][i/8]&(1<<(i%8)) == 0 {
+				groupFreeInodes++
+			}
+		}
+
+		freeBlocks += groupFreeBlocks
+		freeInodes += groupFreeInodes
+
+		desc := gdt[g*ext2GroupDescSize:]
+		binary.LittleEndian.PutUint32(desc[0:], w.blockBitmap(g))
+		binary.LittleEndian.PutUint32(desc[4:], w.inodeBitmap(g))
+		binary.LittleEndian.PutUint32(desc[8:], w.inodeTable(g))
+		binary.LittleEndian.PutUint16(desc[12:], uint16(groupFreeBlocks))
+		binary.LittleEndian.PutUint16(desc[14:], uint16(groupFreeInodes))
+		binary.LittleEndian.PutUint16(desc[16:], w.usedDirs[g])
+
+		if _, err := w.f.WriteAt(w.blockBitmaps[g], int64(w.blockBitmap(g))*ext2BlockSize); err != nil {
+			return err
+		}
+
+		if _, err := w.f.WriteAt(w.inodeBitmaps[g], int64(w.inodeBitmap(g))*ext2BlockSize); err != nil {
+			return err
+		}
+	}
+
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return err
+	}
+
+	mtime := uint32(root.info.ModTime().Unix())
+
+	sb := make([]byte, 1024)
+	binary.LittleEndian.PutUint32(sb[0:], w.inodesPerGrp*w.groups)
+	binary.LittleEndian.PutUint32(sb[4:], w.blocks)
+	binary.LittleEndian.PutUint32(sb[12:], freeBlocks)
+	binary.LittleEndian.PutUint32(sb[16:], freeInodes)
+	binary.LittleEndian.PutUint32(sb[24:], 2) // log2(block size) - 10
+	binary.LittleEndian.PutUint32(sb[28:], 2)
+	binary.LittleEndian.PutUint32(sb[32:], ext2BlocksPerGroup)
+	binary.LittleEndian.PutUint32(sb[36:], ext2BlocksPerGroup)
+	binary.LittleEndian.PutUint32(sb[40:], w.inodesPerGrp)
+	binary.LittleEndian.PutUint32(sb[48:], mtime)
+	binary.LittleEndian.PutUint16(sb[54:], 0xffff) // disable mount count checks
+	binary.LittleEndian.PutUint16(sb[56:], ext2Magic)
+	binary.LittleEndian.PutUint16(sb[58:], 1) // clean
+	binary.LittleEndian.PutUint16(sb[60:], 1) // continue on errors
+	binary.LittleEndian.PutUint32(sb[64:], mtime)
+	binary.LittleEndian.PutUint32(sb[76:], 1) // dynamic revision
+	binary.LittleEndian.PutUint32(sb[84:], ext2FirstIno)
+	binary.LittleEndian.PutUint16(sb[88:], ext2InodeSize)
+	binary.LittleEndian.PutUint32(sb[96:], ext2FeatureIncompatFiletype)
+	if w.largeFile {
+		binary.LittleEndian.PutUint32(sb[100:], ext2FeatureRoCompatLargeFile)
+	}
+	copy(sb[104:], uuid)
+
+	for g := uint32(0); g < w.groups; g++ {
+		binary.LittleEndian.PutUint16(sb[90:], uint16(g))
+
+		// The primary superblock is located after the boot sector.
+		off := int64(g) * ext2BlocksPerGroup * ext2BlockSize
+		if g == 0 {
+			off = 1024
+		}
+
+		if _, err := w.f.WriteAt(sb, off); err != nil {
+			return err
+		}
+
+		if _, err := w.f.WriteAt(gdt, (int64(g)*ext2BlocksPerGroup+1)*ext2BlockSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,568 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// The layout of the FAT32 filesystem images which are written follows the
+// Microsoft FAT specification with 512 byte sectors, two FATs and the cluster
+// size recommended for the size of the image.
+const (
+	fatSectorSize      = 512
+	fatReservedSectors = 32
+	fatCount           = 2
+	fatDirEntrySize    = 32
+	fatLFNChars        = 13
+	fatRootCluster     = 2
+	fatEndOfChain      = 0x0fffffff
+
+	// FAT32 volumes must have at least 65525 clusters, otherwise they are
+	// considered to be FAT12 or FAT16 volumes.
+	fatMinClusters = 65525
+	fatMaxClusters = 0x0ffffff5 - 2
+
+	fatAttrDirectory = 0x10
+	fatAttrArchive   = 0x20
+	fatAttrLFN       = 0x0f
+)
+
+// fatClusterSizes maps the number of sectors per cluster to the maximum number
+// of sectors of images which use it.
+var fatClusterSizes = []struct {
+	sectorsPerCluster uint32
+	maxSectors        uint64
+}{
+	{1, 532480},
+	{8, 16777216},
+	{16, 33554432},
+	{32, 67108864},
+	{64, math.MaxUint32},
+}
+
+// fatWriter serializes a tree of entries into a FAT32 filesystem image.
+type fatWriter struct {
+	f                 *os.File
+	sectorsPerCluster uint32
+	fatSectors        uint32
+	clusters          uint32
+	names             map[*entry][11]byte
+	lfn               map[*entry]bool
+	first             map[*entry]uint32
+	fat               []uint32
+}
+
+// writeFAT32 serializes the provided tree into a FAT32 filesystem image which is
+// written to the provided file.  FAT filesystems cannot represent symbolic
+// links, such that these must be pruned from the tree beforehand.
+func writeFAT32(f *os.File, root *entry) error {
+	w := fatWriter{
+		f:     f,
+		names: map[*entry][11]byte{},
+		lfn:   map[*entry]bool{},
+		first: map[*entry]uint32{},
+	}
+
+	_ = root.walk(func(e *entry) error {
+		if e.isDir() {
+			w.shortNames(e)
+		}
+
+		return nil
+	})
+
+	if err := w.layout(root); err != nil {
+		return err
+	}
+
+	total := fatReservedSectors + fatCount*w.fatSectors + w.clusters*w.sectorsPerCluster
+	if err := f.Truncate(int64(total) * fatSectorSize); err != nil {
+		return fmt.Errorf("could not allocate FAT32 image: %w", err)
+	}
+
+	// Allocate the clusters of all entries in depth-first order, such that the
+	// first cluster of the entries of a directory are known before it is
+	// written.  The root directory is located at the first cluster.
+	w.fat = []uint32{0x0ffffff8, fatEndOfChain}
+	if err := root.walk(func(e *entry) error {
+		n, err := w.entryClusters(e)
+		if err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return nil
+		}
+
+		w.first[e] = uint32(len(w.fat))
+		for i := uint64(1); i < n; i++ {
+			w.fat = append(w.fat, uint32(len(w.fat))+1)
+		}
+
+		w.fat = append(w.fat, fatEndOfChain)
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := w.writeEntry(root, nil); err != nil {
+		return err
+	}
+
+	return w.writeMetadata(total)
+}
+
+// clusterSize returns the number of bytes of a cluster.
+func (w *fatWriter) clusterSize() uint64 {
+	return uint64(w.sectorsPerCluster) * fatSectorSize
+}
+
+// entryClusters returns the number of clusters which hold the contents of the
+// provided entry.
+func (w *fatWriter) entryClusters(e *entry) (uint64, error) {
+	if !e.isDir() {
+		if e.info.Size() > math.MaxUint32 {
+			return 0, fmt.Errorf("file is too large for a FAT32 image: %s", e.internal)
+		}
+
+		return (uint64(e.info.Size()) + w.clusterSize() - 1) / w.clusterSize(), nil
+	}
+
+	entries := uint64(0)
+	if e.internal != "/" {
+		entries = 2
+	}
+
+	for _, child := range e.children {
+		entries += uint64(w.childEntries(child))
+	}
+
+	// Directories consist of at least one cluster.
+	n := (entries*fatDirEntrySize + w.clusterSize() - 1) / w.clusterSize()
+	if n == 0 {
+		n = 1
+	}
+
+	return n, nil
+}
+
+// layout determines the cluster size and the number of clusters of the image
+// such that all entries fit, with some headroom for writes within the machine.
+func (w *fatWriter) layout(root *entry) error {
+	for _, size := range fatClusterSizes {
+		w.sectorsPerCluster = size.sectorsPerCluster
+
+		var need uint64
+		if err := root.walk(func(e *entry) error {
+			n, err := w.entryClusters(e)
+			need += n
+			return err
+		}); err != nil {
+			return err
+		}
+
+		need += need/10 + 16
+		if need < fatMinClusters {
+			need = fatMinClusters
+		}
+
+		if need > fatMaxClusters {
+			continue
+		}
+
+		fatSectors := ((need+2)*4 + fatSectorSize - 1) / fatSectorSize
+		total := fatReservedSectors + fatCount*fatSectors + need*uint64(size.sectorsPerCluster)
+		if total > size.maxSectors {
+			continue
+		}
+
+		w.clusters = uint32(need)
+		w.fatSectors = uint32(fatSectors)
+
+		return nil
+	}
+
+	return fmt.Errorf("rootfs is too large for a FAT32 image")
+}
+
+// shortNames assigns the unique 8.3 names of the children of the provided
+// directory.  Children whose name is not a valid 8.3 name are additionally
+// given a long file name.
+func (w *fatWriter) shortNames(dir *entry) {
+	used := map[[11]byte]bool{}
+
+	// Names which are valid 8.3 names are preferred over generated names.
+	for _, child := range dir.children {
+		if name, ok := fatValidShortName(child.name); ok && !used[name] {
+			w.names[child] = name
+			used[name] = true
+		}
+	}
+
+	for _, child := range dir.children {
+		if _, ok := w.names[child]; ok {
+			continue
+		}
+
+		w.lfn[child] = true
+
+		// Names which only differ from a valid 8.3 name by their case are kept
+		// without a numeric tail.
+		if name, ok := fatValidShortName(strings.ToUpper(child.name)); ok && !used[name] {
+			w.names[child] = name
+			used[name] = true
+			continue
+		}
+
+		base, ext := fatBasisName(child.name)
+		for i := 1; ; i++ {
+			tail := "~" + strconv.Itoa(i)
+
+			b := base
+			if len(b)+len(tail) > 8 {
+				b = b[:8-len(tail)]
+			}
+
+			name := fatPadName(b+tail, ext)
+			if !used[name] {
+				w.names[child] = name
+				used[name] = true
+				break
+			}
+		}
+	}
+}
+
+// fatShortNameChars are the characters which are allowed in 8.3 names besides
+// upper case letters and digits.
+const fatShortNameChars = "$%'-_@~`!(){}^#&"
+
+// fatValidShortName returns the 8.3 name of the provided name if it is a valid
+// 8.3 name as is.
+func fatValidShortName(name string) ([11]byte, bool) {
+	base, ext, _ := strings.Cut(name, ".")
+	if len(base) == 0 || len(base) > 8 || len(ext) > 3 || strings.Contains(ext, ".") ||
+		(strings.HasSuffix(name, ".") && len(ext) == 0) {
+		return [11]byte{}, false
+	}
+
+	for _, c := range base + ext {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && !strings.ContainsRune(fatShortNameChars, c) {
+			return [11]byte{}, false
+		}
+	}
+
+	return fatPadName(base, ext), true
+}
+
+// fatBasisName returns the base name and extension from which a unique 8.3 name
+// is generated for the provided long name.
+func fatBasisName(name string) (string, string) {
+	sanitize := func(s string, max int) string {
+		var b strings.Builder
+
+		for _, c := range strings.ToUpper(s) {
+			if c == ' ' || c == '.' {
+				continue
+			}
+
+			if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && !strings.ContainsRune(fatShortNameChars, c) {
+				c = '_'
+			}
+
+			b.WriteRune(c)
+			if b.Len() == max {
+				break
+			}
+		}
+
+		return b.String()
+	}
+
+	name = strings.TrimLeft(name, ".")
+
+	var base, ext string
+	if i := strings.LastIndex(name, "."); i > 0 {
+		base, ext = sanitize(name[:i], 8), sanitize(name[i+1:], 3)
+	} else {
+		base = sanitize(name, 8)
+	}
+
+	if len(base) == 0 {
+		base = "_"
+	}
+
+	return base, ext
+}
+
+// fatPadName returns the on-disk representation of the 8.3 name of the
+// provided base name and extension.
+func fatPadName(base, ext string) [11]byte {
+	var name [11]byte
+
+	copy(name[:], fmt.Sprintf("%-8s%-3s", base, ext))
+
+	// The first byte 0xe5 marks a deleted entry and is escaped.
+	if name[0] == 0xe5 {
+		name[0] = 0x05
+	}
+
+	return name
+}
+
+// fatChecksum returns the checksum of the provided 8.3 name which is stored in
+// its long file name entries.
+func fatChecksum(name [11]byte) byte {
+	var sum byte
+
+	for _, c := range name {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+
+	return sum
+}
+
+// childEntries returns the number of directory entries of the provided child.
+func (w *fatWriter) childEntries(child *entry) int {
+	if !w.lfn[child] {
+		return 1
+	}
+
+	return 1 + (len(utf16.Encode([]rune(child.name)))+fatLFNChars-1)/fatLFNChars
+}
+
+// fatDirEntry returns the 8.3 directory entry with the provided attributes.
+func fatDirEntry(name [11]byte, attr byte, cluster uint32, size uint32, mtime time.Time) []byte {
+	b := make([]byte, fatDirEntrySize)
+
+	mtime = mtime.UTC()
+	if mtime.Year() < 1980 {
+		mtime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if mtime.Year() > 2107 {
+		mtime = time.Date(2107, 12, 31, 23, 59, 58, 0, time.UTC)
+	}
+
+	date := uint16(mtime.Year()-1980)<<9 | uint16(mtime.Month())<<5 | uint16(mtime.Day())
+	tod := uint16(mtime.Hour())<<11 | uint16(mtime.Minute())<<5 | uint16(mtime.Second()/2)
+
+	copy(b[0:11], name[:])
+	b[11] = attr
+	binary.LittleEndian.PutUint16(b[14:], tod)
+	binary.LittleEndian.PutUint16(b[16:], date)
+	binary.LittleEndian.PutUint16(b[18:], date)
+	binary.LittleEndian.PutUint16(b[20:], uint16(cluster>>16))
+	binary.LittleEndian.PutUint16(b[22:], tod)
+	binary.LittleEndian.PutUint16(b[24:], date)
+	binary.LittleEndian.PutUint16(b[26:], uint16(cluster))
+	binary.LittleEndian.PutUint32(b[28:], size)
+
+	return b
+}
+
+// fatLFNEntries returns the long file name entries of the provided name in
+// on-disk order.
+func fatLFNEntries(name string, checksum byte) []byte {
+	chars := utf16.Encode([]rune(name))
+	n := (len(chars) + fatLFNChars - 1) / fatLFNChars
+
+	// Names are terminated and padded unless they fill the last entry.
+	if len(chars)%fatLFNChars != 0 {
+		chars = append(chars, 0)
+	}
+
+	for len(chars) < n*fatLFNChars {
+		chars = append(chars, 0xffff)
+	}
+
+	offsets := []int{1, 3, 5, 7, 9, 14, 16, 18, 20, 22, 24, 28, 30}
+	b := make([]byte, n*fatDirEntrySize)
+
+	for i := 0; i < n; i++ {
+		e := b[(n-1-i)*fatDirEntrySize:]
+
+		e[0] = byte(i + 1)
+		if i == n-1 {
+			e[0] |= 0x40
+		}
+
+		e[11] = fatAttrLFN
+		e[13] = checksum
+
+		for j, off := range offsets {
+			binary.LittleEndian.PutUint16(e[off:], chars[i*fatLFNChars+j])
+		}
+	}
+
+	return b
+}
+
+// clusterOffset returns the location of the provided cluster in the image.
+func (w *fatWriter) clusterOffset(cluster uint32) int64 {
+	sector := fatReservedSectors + fatCount*w.fatSectors + (cluster-2)*w.sectorsPerCluster
+	return int64(sector) * fatSectorSize
+}
+
+// writeContents writes the provided contents to the clusters starting at the
+// provided cluster.
+func (w *fatWriter) writeContents(r io.Reader, cluster uint32) error {
+	buf := make([]byte, w.clusterSize())
+	zero := make([]byte, w.clusterSize())
+
+	for cluster >= fatRootCluster && cluster < uint32(len(w.fat)) {
+		// Contents which have shrunk since the image was laid out are padded
+		// with zeros.
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		copy(buf[n:], zero)
+
+		// Keep the image sparse.
+		if !bytes.Equal(buf, zero) {
+			if _, err := w.f.WriteAt(buf, w.clusterOffset(cluster)); err != nil {
+				return err
+			}
+		}
+
+		cluster = w.fat[cluster]
+	}
+
+	return nil
+}
+
+// writeEntry writes the contents of the provided entry and its descendants.
+func (w *fatWriter) writeEntry(e, parent *entry) error {
+	if !e.isDir() {
+		f, err := os.Open(e.path)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		if err := w.writeContents(io.LimitReader(f, e.info.Size()), w.first[e]); err != nil {
+			return fmt.Errorf("could not write %s: %w", e.internal, err)
+		}
+
+		return nil
+	}
+
+	var buf []byte
+
+	if parent != nil {
+		// The parent of the children of the root directory is referred to by
+		// cluster 0.
+		var up uint32
+		if parent.internal != "/" {
+			up = w.first[parent]
+		}
+
+		buf = append(buf, fatDirEntry(fatPadName(".", ""), fatAttrDirectory, w.first[e], 0, e.info.ModTime())...)
+		buf = append(buf, fatDirEntry(fatPadName("..", ""), fatAttrDirectory, up, 0, parent.info.ModTime())...)
+	}
+
+	for _, child := range e.children {
+		name := w.names[child]
+
+		if w.lfn[child] {
+			buf = append(buf, fatLFNEntries(child.name, fatChecksum(name))...)
+		}
+
+		attr := byte(fatAttrArchive)
+		size := uint32(child.info.Size())
+		if child.isDir() {
+			attr = fatAttrDirectory
+			size = 0
+		}
+
+		buf = append(buf, fatDirEntry(name, attr, w.first[child], size, child.info.ModTime())...)
+	}
+
+	if err := w.writeContents(bytes.NewReader(buf), w.first[e]); err != nil {
+		return err
+	}
+
+	for _, child := range e.children {
+		if err := w.writeEntry(child, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeMetadata writes the boot sectors, the FSInfo sectors and the FATs of the
+// image with the provided number of sectors.
+func (w *fatWriter) writeMetadata(total uint32) error {
+	serial := make([]byte, 4)
+	if _, err := rand.Read(serial); err != nil {
+		return err
+	}
+
+	boot := make([]byte, fatSectorSize)
+	copy(boot[0:], []byte{0xeb, 0x58, 0x90})
+	copy(boot[3:], "KRAFTKIT")
+	binary.LittleEndian.PutUint16(boot[11:], fatSectorSize)
+	boot[13] = byte(w.sectorsPerCluster)
+	binary.LittleEndian.PutUint16(boot[14:], fatReservedSectors)
+	boot[16] = fatCount
+	boot[21] = 0xf8 // fixed media
+	binary.LittleEndian.PutUint16(boot[24:], 32)
+	binary.LittleEndian.PutUint16(boot[26:], 64)
+	binary.LittleEndian.PutUint32(boot[32:], total)
+	binary.LittleEndian.PutUint32(boot[36:], w.fatSectors)
+	binary.LittleEndian.PutUint32(boot[44:], fatRootCluster)
+	binary.LittleEndian.PutUint16(boot[48:], 1) // FSInfo sector
+	binary.LittleEndian.PutUint16(boot[50:], 6) // backup boot sector
+	boot[64] = 0x80
+	boot[66] = 0x29
+	copy(boot[67:], serial)
+	copy(boot[71:], "NO NAME    ")
+	copy(boot[82:], "FAT32   ")
+	boot[510], boot[511] = 0x55, 0xaa
+
+	fsinfo := make([]byte, fatSectorSize)
+	binary.LittleEndian.PutUint32(fsinfo[0:], 0x41615252)
+	binary.LittleEndian.PutUint32(fsinfo[484:], 0x61417272)
+	binary.LittleEndian.PutUint32(fsinfo[488:], w.clusters+2-uint32(len(w.fat)))
+	binary.LittleEndian.PutUint32(fsinfo[492:], uint32(len(w.fat)))
+	binary.LittleEndian.PutUint32(fsinfo[508:], 0xaa550000)
+
+	for _, sector := range []int64{0, 6} {
+		if _, err := w.f.WriteAt(boot, sector*fatSectorSize); err != nil {
+			return err
+		}
+
+		if _, err := w.f.WriteAt(fsinfo, (sector+1)*fatSectorSize); err != nil {
+			return err
+		}
+	}
+
+	fat := make([]byte, len(w.fat)*4)
+	for i, next := range w.fat {
+		binary.LittleEndian.PutUint32(fat[i*4:], next)
+	}
+
+	for i := uint32(0); i < fatCount; i++ {
+		if _, err := w.f.WriteAt(fat, int64(fatReservedSectors+i*w.fatSectors)*fatSectorSize); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
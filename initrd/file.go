@@ -18,7 +18,8 @@ type file struct {
 	files []string
 }
 
-// NewFromFile accepts an input file which already represents a CPIO archive and
+// NewFromFile accepts an input file which already represents a CPIO archive,
+// optionally compressed with one of the supported compression algorithms, and
 // is provided as a mechanism for satisfying the Initrd interface.
 func NewFromFile(_ context.Context, path string, opts ...InitrdOption) (Initrd, error) {
 	fi, err := os.Open(path)
@@ -39,7 +40,15 @@ func NewFromFile(_ context.Context, path string, opts ...InitrdOption) (Initrd,
 		}
 	}
 
-	reader := cpio.NewReader(fi)
+	// Archives may have been compressed, e.g. via WithCompression.
+	dr, err := decompressor(fi)
+	if err != nil {
+		return nil, err
+	}
+
+	defer dr.Close()
+
+	reader := cpio.NewReader(dr)
 
 	// Iterate through the files in the archive.
 	for {
@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewFromCompressedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "etc", "hostname"), []byte("unikraft\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compression := range Compressions() {
+		t.Run(compression.String(), func(t *testing.T) {
+			rootfs, err := NewFromDirectory(context.Background(), dir,
+				WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+				WithCompression(compression),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			archive, err := rootfs.Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			initrd, err := New(context.Background(), archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if expected, files := []string{"/etc", "/etc/hostname"}, initrd.Files(); !reflect.DeepEqual(files, expected) {
+				t.Errorf("expected files %v, got %v", expected, files)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+// Format is the format in which a rootfs is serialized.
+type Format string
+
+const (
+	// FormatCPIO serializes the rootfs into a CPIO archive which is loaded into
+	// the memory of the machine as its initramfs.
+	FormatCPIO = Format("cpio")
+
+	// FormatExt2 serializes the rootfs into an ext2 filesystem image which is
+	// attached to the machine as a block device.
+	FormatExt2 = Format("ext2")
+
+	// FormatFAT32 serializes the rootfs into a FAT32 filesystem image which is
+	// attached to the machine as a block device.
+	FormatFAT32 = Format("fat32")
+)
+
+// String implements fmt.Stringer
+func (format Format) String() string {
+	return string(format)
+}
+
+// IsImage returns whether the format is a filesystem image rather than an
+// archive.
+func (format Format) IsImage() bool {
+	return format == FormatExt2 || format == FormatFAT32
+}
+
+// Formats returns the list of supported formats.
+func Formats() []Format {
+	return []Format{
+		FormatCPIO,
+		FormatExt2,
+		FormatFAT32,
+	}
+}
+
+// FormatNames returns the string representation of all supported formats.
+func FormatNames() []string {
+	ret := []string{}
+	for _, format := range Formats() {
+		ret = append(ret, format.String())
+	}
+
+	return ret
+}
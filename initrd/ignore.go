@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/patternmatcher"
+)
+
+// IgnoreFileName is the name of the file at the root of a rootfs directory
+// which lists the patterns of paths excluded from the rootfs.
+const IgnoreFileName = ".kraftignore"
+
+// readIgnoreFile returns the patterns of the ignore file at the provided
+// path, if any.  Empty lines and lines starting with # are skipped.
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var patterns []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, scanner.Err()
+}
+
+// exclude removes the entries of the tree matching the provided patterns.
+// Directories which are excluded are retained when any of their descendants
+// are re-included by a pattern prefixed with !.
+func exclude(root *entry, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	// Patterns are relative to the root of the rootfs, such that leading
+	// separators are insignificant.
+	cleaned := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = filepath.Clean(filepath.FromSlash(strings.TrimPrefix(pattern, "!")))
+		pattern = strings.TrimPrefix(pattern, string(filepath.Separator))
+		if negate {
+			pattern = "!" + pattern
+		}
+
+		cleaned[i] = pattern
+	}
+
+	pm, err := patternmatcher.New(cleaned)
+	if err != nil {
+		return fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+
+	var prune func(e *entry) error
+	prune = func(e *entry) error {
+		children := []*entry{}
+
+		for _, child := range e.children {
+			excluded, err := pm.MatchesOrParentMatches(strings.TrimPrefix(child.internal, "/"))
+			if err != nil {
+				return err
+			}
+
+			if child.isDir() && (!excluded || pm.Exclusions()) {
+				if err := prune(child); err != nil {
+					return err
+				}
+
+				if excluded && len(child.children) == 0 {
+					continue
+				}
+			} else if excluded {
+				continue
+			}
+
+			children = append(children, child)
+		}
+
+		e.children = children
+
+		return nil
+	}
+
+	return prune(root)
+}
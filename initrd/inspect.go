@@ -0,0 +1,352 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cavaliergopher/cpio"
+
+	"kraftkit.sh/internal/unpack"
+	"kraftkit.sh/log"
+)
+
+// Entry is a file, directory or symbolic link within an initramfs.
+type Entry struct {
+	// Path is the absolute location of the entry within the initramfs.
+	Path string `json:"path"`
+
+	// Mode is the type and permissions of the entry.
+	Mode fs.FileMode `json:"mode"`
+
+	// Size is the size of the contents of the entry in bytes.
+	Size int64 `json:"size"`
+
+	// UID and GID are the user and group IDs of the owner of the entry.
+	UID int `json:"uid"`
+	GID int `json:"gid"`
+
+	// ModTime is the modification time of the entry.
+	ModTime time.Time `json:"mtime"`
+
+	// Linkname is the target of symbolic links.
+	Linkname string `json:"linkname,omitempty"`
+
+	// Digest is the SHA-256 digest of the contents of regular files.
+	Digest string `json:"digest,omitempty"`
+}
+
+// entryPath returns the absolute location within the initramfs of the
+// provided name of an entry, which cannot escape the root.
+func entryPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+// Walk calls the provided function for each entry of the CPIO archive at the
+// provided path, which may be compressed, in the order in which they are
+// stored, along with a reader of the contents of the entry.
+func Walk(archive string, fn func(Entry, io.Reader) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	r, err := decompressor(f)
+	if err != nil {
+		return fmt.Errorf("could not decompress initramfs: %w", err)
+	}
+
+	defer r.Close()
+
+	reader := cpio.NewReader(r)
+
+	for {
+		hdr, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("could not read initramfs: %w", err)
+		}
+
+		entry := Entry{
+			Path:     entryPath(hdr.Name),
+			Mode:     hdr.FileInfo().Mode(),
+			Size:     hdr.Size,
+			UID:      hdr.Uid,
+			GID:      hdr.Guid,
+			ModTime:  hdr.ModTime,
+			Linkname: hdr.Linkname,
+		}
+
+		// Symbolic links store their target as their contents.
+		if entry.Mode&fs.ModeSymlink != 0 && entry.Linkname == "" {
+			b, err := io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+
+			entry.Linkname = string(b)
+		}
+
+		if entry.Mode&fs.ModeSymlink != 0 {
+			entry.Size = int64(len(entry.Linkname))
+		}
+
+		if entry.Path == "/" {
+			continue
+		}
+
+		if err := fn(entry, reader); err != nil {
+			return err
+		}
+	}
+}
+
+// List returns the entries of the CPIO archive at the provided path, which
+// may be compressed, sorted by their path.
+func List(archive string) ([]Entry, error) {
+	var entries []Entry
+
+	if err := Walk(archive, func(entry Entry, r io.Reader) error {
+		if entry.Mode.IsRegular() {
+			h := sha256.New()
+			if _, err := io.Copy(h, r); err != nil {
+				return err
+			}
+
+			entry.Digest = hex.EncodeToString(h.Sum(nil))
+		}
+
+		entries = append(entries, entry)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries, nil
+}
+
+// ListDirectory returns the entries of the initramfs which the directory at
+// the provided path is serialized into, sorted by their path, such that the
+// directory can be compared with an existing initramfs.
+func ListDirectory(dir string, opts ...InitrdOption) ([]Entry, error) {
+	initrd := directory{
+		opts: InitrdOptions{},
+		path: dir,
+	}
+
+	for _, opt := range opts {
+		if err := opt(&initrd.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := initrd.tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+
+	if err := root.walk(func(e *entry) error {
+		if e == root {
+			return nil
+		}
+
+		entry := Entry{
+			Path:     e.internal,
+			Mode:     e.info.Mode(),
+			UID:      e.uid,
+			GID:      e.gid,
+			ModTime:  e.info.ModTime(),
+			Linkname: e.link,
+		}
+
+		switch {
+		case e.isSymlink():
+			entry.Size = int64(len(e.link))
+
+		case !e.isDir():
+			entry.Size = e.info.Size()
+
+			f, err := os.Open(e.path)
+			if err != nil {
+				return err
+			}
+
+			h := sha256.New()
+			_, err = io.Copy(h, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+			entry.Digest = hex.EncodeToString(h.Sum(nil))
+		}
+
+		entries = append(entries, entry)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+
+	return entries, nil
+}
+
+// Extract extracts the entries of the CPIO archive at the provided path, which
+// may be compressed, to the provided destination directory.  Device files
+// cannot be created without privileges and are skipped.
+func Extract(ctx context.Context, archive, dest string) error {
+	// The modes of directories are only set after extraction such that
+	// read-only directories can be populated.
+	var dirs unpack.Dirs
+
+	if err := Walk(archive, func(entry Entry, r io.Reader) error {
+		if err := unpack.CheckParents(dest, path.Dir(entry.Path)); err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(entry.Path))
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		if fi, err := os.Lstat(target); err == nil && !(fi.IsDir() && entry.Mode.IsDir()) {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case entry.Mode.IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+
+			dirs.Add(target, entry.Mode, entry.ModTime)
+
+			return nil
+
+		case entry.Mode&fs.ModeSymlink != 0:
+			return os.Symlink(entry.Linkname, target)
+
+		case entry.Mode.IsRegular():
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(f, r)
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+		default:
+			log.G(ctx).
+				WithField("path", entry.Path).
+				Debugf("skipping unsupported entry type %s", entry.Mode.Type())
+
+			return nil
+		}
+
+		return unpack.SetAttributes(target, entry.Mode, entry.ModTime)
+	}); err != nil {
+		return err
+	}
+
+	return dirs.Restore()
+}
+
+// ChangeType is the type of a difference between two initramfs.
+type ChangeType string
+
+const (
+	// ChangeAdded marks entries which only exist in the second initramfs.
+	ChangeAdded = ChangeType("A")
+
+	// ChangeRemoved marks entries which only exist in the first initramfs.
+	ChangeRemoved = ChangeType("D")
+
+	// ChangeModified marks entries whose type, permissions, owner or contents
+	// differ between the initramfs.
+	ChangeModified = ChangeType("M")
+)
+
+// Change is a difference of an entry between two initramfs.
+type Change struct {
+	Type ChangeType `json:"type"`
+	Path string     `json:"path"`
+
+	// Before and After are the entry in the first and second initramfs.
+	Before *Entry `json:"before,omitempty"`
+	After  *Entry `json:"after,omitempty"`
+}
+
+// Diff returns the differences between the two provided lists of entries,
+// sorted by their path.  Modification times are not compared, since they
+// rarely matter to the unikernel and differ between most builds.
+func Diff(before, after []Entry) []Change {
+	entries := map[string]*Entry{}
+	for i := range before {
+		entries[before[i].Path] = &before[i]
+	}
+
+	var changes []Change
+
+	for i := range after {
+		a := &after[i]
+
+		b, ok := entries[a.Path]
+		if !ok {
+			changes = append(changes, Change{Type: ChangeAdded, Path: a.Path, After: a})
+			continue
+		}
+
+		delete(entries, a.Path)
+
+		if a.Mode != b.Mode ||
+			a.Size != b.Size ||
+			a.UID != b.UID ||
+			a.GID != b.GID ||
+			a.Linkname != b.Linkname ||
+			a.Digest != b.Digest {
+			changes = append(changes, Change{Type: ChangeModified, Path: a.Path, Before: b, After: a})
+		}
+	}
+
+	for _, b := range entries {
+		changes = append(changes, Change{Type: ChangeRemoved, Path: b.Path, Before: b})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range map[string]string{
+		"etc/hostname": "unikraft\n",
+		"index.html":   strings.Repeat("<p>unikraft</p>\n", 1024),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink("etc/hostname", filepath.Join(dir, "hostname")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ListDirectory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compression := range Compressions() {
+		t.Run(compression.String(), func(t *testing.T) {
+			rootfs, err := NewFromDirectory(context.Background(), dir,
+				WithOutput(filepath.Join(t.TempDir(), "initramfs.cpio")),
+				WithCompression(compression),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			archive, err := rootfs.Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := List(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changes := Diff(expected, entries); len(changes) > 0 {
+				t.Errorf("expected no changes, got %v", changes)
+			}
+
+			dest := t.TempDir()
+			if err := Extract(context.Background(), archive, dest); err != nil {
+				t.Fatal(err)
+			}
+
+			extracted, err := ListDirectory(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changes := Diff(expected, extracted); len(changes) > 0 {
+				t.Errorf("expected no changes after extraction, got %v", changes)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := []Entry{
+		{Path: "/etc", Mode: os.ModeDir | 0o755},
+		{Path: "/etc/hostname", Mode: 0o644, Size: 9, Digest: "a"},
+		{Path: "/etc/passwd", Mode: 0o644, Size: 5, Digest: "b"},
+	}
+
+	after := []Entry{
+		{Path: "/etc", Mode: os.ModeDir | 0o755},
+		{Path: "/etc/hostname", Mode: 0o644, Size: 9, Digest: "c"},
+		{Path: "/etc/hosts", Mode: 0o644, Size: 5, Digest: "d"},
+	}
+
+	var changes []string
+	for _, change := range Diff(before, after) {
+		changes = append(changes, string(change.Type)+" "+change.Path)
+	}
+
+	expected := []string{"M /etc/hostname", "A /etc/hosts", "D /etc/passwd"}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %v, got %v", expected, changes)
+	}
+}
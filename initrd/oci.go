@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"kraftkit.sh/archive"
+	"kraftkit.sh/config"
+	"kraftkit.sh/log"
+	"kraftkit.sh/oci/handler"
+)
+
+const (
+	// OCIRegistryPrefix is the prefix of references to images which are fetched
+	// from a remote registry, e.g. docker://alpine:latest.
+	OCIRegistryPrefix = "docker://"
+
+	// OCILayoutPrefix is the prefix of references to images stored in a local
+	// OCI image layout directory or tarball, optionally followed by the tag of
+	// the image within the layout, e.g. oci:./alpine.tar:latest.
+	OCILayoutPrefix = "oci:"
+)
+
+type ociImage struct {
+	opts   InitrdOptions
+	ref    string
+	layout string
+	tag    string
+	files  []string
+}
+
+// NewFromOCIImage accepts a reference to an OCI image, which is either prefixed
+// with docker:// and fetched from a registry or prefixed with oci: and read
+// from a local OCI image layout, whose layers are flattened into a rootfs
+// without the need for BuildKit.
+func NewFromOCIImage(_ context.Context, path string, opts ...InitrdOption) (Initrd, error) {
+	initrd := ociImage{
+		opts: InitrdOptions{},
+	}
+
+	switch {
+	case strings.HasPrefix(path, OCIRegistryPrefix):
+		initrd.ref = strings.TrimPrefix(path, OCIRegistryPrefix)
+		if _, err := name.ParseReference(initrd.ref); err != nil {
+			return nil, fmt.Errorf("invalid image reference: %w", err)
+		}
+
+	case strings.HasPrefix(path, OCILayoutPrefix):
+		initrd.layout, initrd.tag = splitLayoutReference(strings.TrimPrefix(path, OCILayoutPrefix))
+		if _, err := os.Stat(initrd.layout); err != nil {
+			return nil, fmt.Errorf("could not access OCI image layout: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("path is not an OCI image reference: %s", path)
+	}
+
+	for _, opt := range opts {
+		if err := opt(&initrd.opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return &initrd, nil
+}
+
+// splitLayoutReference splits the location of an OCI image layout from the
+// optional tag of the image within it.
+func splitLayoutReference(ref string) (string, string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.ContainsAny(ref[i+1:], `/\`) {
+		return ref, ""
+	}
+
+	// Do not mistake the drive letter of Windows paths for a tag.
+	if _, err := os.Stat(ref); err == nil {
+		return ref, ""
+	}
+
+	return ref[:i], ref[i+1:]
+}
+
+// architecture returns the OCI platform architecture of the image to use.
+func (initrd *ociImage) architecture() string {
+	switch initrd.opts.arch {
+	case "":
+		return runtime.GOARCH
+	case "x86_64":
+		return "amd64"
+	case "arm64", "aarch64":
+		return "arm64"
+	}
+
+	return initrd.opts.arch
+}
+
+// Build implements Initrd.
+func (initrd *ociImage) Build(ctx context.Context) (string, error) {
+	rootfs, err := os.MkdirTemp("", "kraftkit-rootfs-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.RemoveAll(rootfs)
+
+	// The extracted entries remain accessible on the host whilst the modes of
+	// the image are retained within the rootfs.
+	modes := handler.FileModes{}
+	ctx = handler.WithFileModes(ctx, modes)
+
+	if initrd.ref != "" {
+		err = initrd.unpackRemote(ctx, rootfs)
+	} else {
+		err = initrd.unpackLayout(ctx, rootfs)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not unpack image: %w", err)
+	}
+
+	dir := directory{
+		opts:  initrd.opts,
+		path:  rootfs,
+		modes: modes,
+	}
+
+	output, err := dir.Build(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	initrd.files = dir.Files()
+
+	return output, nil
+}
+
+// unpackRemote fetches the image from its registry into the local OCI cache
+// and unpacks its layers to the provided directory.
+func (initrd *ociImage) unpackRemote(ctx context.Context, dest string) error {
+	handle, err := handler.NewDirectoryHandler(
+		filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, "oci"),
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	log.G(ctx).
+		WithField("ref", initrd.ref).
+		Debug("fetching image")
+
+	if err := handle.FetchImage(ctx, initrd.ref, "linux/"+initrd.architecture(), nil); err != nil {
+		return err
+	}
+
+	return handle.UnpackImage(ctx, initrd.ref, dest)
+}
+
+// unpackLayout unpacks the layers of the image of the local OCI image layout
+// to the provided directory.
+func (initrd *ociImage) unpackLayout(ctx context.Context, dest string) error {
+	path := initrd.layout
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	// Image layouts are commonly distributed as tarballs, e.g. by
+	// `docker save` or `buildctl --output type=oci`.
+	if !fi.IsDir() {
+		path, err = os.MkdirTemp("", "kraftkit-oci-*")
+		if err != nil {
+			return err
+		}
+
+		defer os.RemoveAll(path)
+
+		if strings.HasSuffix(initrd.layout, ".gz") || strings.HasSuffix(initrd.layout, ".tgz") {
+			err = archive.UntarGz(initrd.layout, path)
+		} else {
+			var f *os.File
+			if f, err = os.Open(initrd.layout); err != nil {
+				return err
+			}
+
+			err = archive.Untar(f, path)
+			f.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("could not extract OCI image layout: %w", err)
+		}
+	}
+
+	index, err := layout.ImageIndexFromPath(path)
+	if err != nil {
+		return fmt.Errorf("could not read OCI image layout: %w", err)
+	}
+
+	img, err := selectImage(index, initrd.tag, initrd.architecture())
+	if err != nil {
+		return err
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range layers {
+		reader, err := layer.Compressed()
+		if err != nil {
+			return err
+		}
+
+		err = handler.ApplyLayer(ctx, reader, dest)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("could not apply layer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// selectImage returns the image of the index with the provided tag, if any,
+// whose platform matches the provided architecture.  Nested indexes, e.g. of
+// multi-platform images, are searched recursively.
+func selectImage(index v1.ImageIndex, tag, arch string) (v1.Image, error) {
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, desc := range manifest.Manifests {
+		if tag != "" && desc.Annotations[ocispec.AnnotationRefName] != tag {
+			continue
+		}
+
+		if desc.Platform != nil && desc.Platform.Architecture != "" && desc.Platform.Architecture != arch {
+			continue
+		}
+
+		switch {
+		case desc.MediaType.IsIndex():
+			child, err := index.ImageIndex(desc.Digest)
+			if err != nil {
+				return nil, err
+			}
+
+			if img, err := selectImage(child, "", arch); err == nil {
+				return img, nil
+			}
+
+		case desc.MediaType.IsImage():
+			return index.Image(desc.Digest)
+		}
+	}
+
+	if tag != "" {
+		return nil, fmt.Errorf("could not find image tagged %s for %s", tag, arch)
+	}
+
+	return nil, fmt.Errorf("could not find image for %s", arch)
+}
+
+// Files implements Initrd.
+func (initrd *ociImage) Files() []string {
+	return initrd.files
+}
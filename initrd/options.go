@@ -4,9 +4,22 @@
 // You may not use this file except in compliance with the License.
 package initrd
 
+import (
+	"fmt"
+	"time"
+)
+
 type InitrdOptions struct {
-	output   string
-	cacheDir string
+	output      string
+	cacheDir    string
+	format      Format
+	arch        string
+	compression Compression
+	mtime       *time.Time
+	owner       bool
+	uid         int
+	gid         int
+	excludes    []string
 }
 
 type InitrdOption func(*InitrdOptions) error
@@ -29,3 +42,78 @@ func WithCacheDir(dir string) InitrdOption {
 		return nil
 	}
 }
+
+// WithFormat sets the format in which a directory is serialized, which is a
+// CPIO archive by default.
+func WithFormat(format Format) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		for _, f := range Formats() {
+			if f == format {
+				opts.format = format
+				return nil
+			}
+		}
+
+		return fmt.Errorf("unsupported rootfs format: %s", format)
+	}
+}
+
+// WithArchitecture sets the architecture of the unikernel which the rootfs is
+// built for, which selects the platform of multi-platform OCI images.
+func WithArchitecture(arch string) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.arch = arch
+		return nil
+	}
+}
+
+// WithCompression sets the algorithm with which the CPIO archive is
+// compressed, which is uncompressed by default.
+func WithCompression(compression Compression) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		for _, c := range Compressions() {
+			if c == compression {
+				opts.compression = compression
+				return nil
+			}
+		}
+
+		return fmt.Errorf("unsupported compression: %s", compression)
+	}
+}
+
+// WithModTime clamps the modification times of the entries of the rootfs
+// which are later than the provided time to it.  When unset, the time of the
+// SOURCE_DATE_EPOCH environmental variable is used, if any.
+func WithModTime(mtime time.Time) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.mtime = &mtime
+		return nil
+	}
+}
+
+// WithOwner sets the user and group IDs of the owner of all entries of the
+// rootfs, which otherwise retain their owner on the host.
+func WithOwner(uid, gid int) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		if uid < 0 || gid < 0 {
+			return fmt.Errorf("invalid owner: %d:%d", uid, gid)
+		}
+
+		opts.owner = true
+		opts.uid = uid
+		opts.gid = gid
+		return nil
+	}
+}
+
+// WithExcludes sets patterns of paths within the rootfs which are excluded
+// from it, in addition to those of the .kraftignore file at its root.  The
+// patterns follow the syntax of .dockerignore files, where patterns prefixed
+// with ! re-include paths.
+func WithExcludes(patterns ...string) InitrdOption {
+	return func(opts *InitrdOptions) error {
+		opts.excludes = append(opts.excludes, patterns...)
+		return nil
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package initrd
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// entry is a directory, regular file or symbolic link of a rootfs which is
+// serialized into a filesystem image.
+type entry struct {
+	// name is the base name of the entry.
+	name string
+
+	// path is the location of the entry on the host.
+	path string
+
+	// internal is the location of the entry within the rootfs.
+	internal string
+
+	// info is the result of lstat(2) of the entry.
+	info fs.FileInfo
+
+	// link is the target of symbolic links.
+	link string
+
+	// uid and gid are the user and group IDs of the owner of the entry.
+	uid int
+	gid int
+
+	// children are the entries of directories, sorted by name.
+	children []*entry
+}
+
+// readTree returns the tree of entries of the directory at the provided path.
+// Special files, e.g. devices, sockets and named pipes, cannot be represented
+// in every filesystem and are skipped.
+func readTree(path string) (*entry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &entry{
+		path:     path,
+		internal: "/",
+		info:     info,
+	}
+
+	root.uid, root.gid = fileOwner(info)
+
+	return root, root.readChildren()
+}
+
+// readChildren populates the children of the directory entry recursively.
+func (e *entry) readChildren() error {
+	dirents, err := os.ReadDir(e.path)
+	if err != nil {
+		return err
+	}
+
+	for _, dirent := range dirents {
+		info, err := dirent.Info()
+		if err != nil {
+			return err
+		}
+
+		child := &entry{
+			name:     dirent.Name(),
+			path:     filepath.Join(e.path, dirent.Name()),
+			internal: strings.TrimSuffix(e.internal, "/") + "/" + dirent.Name(),
+			info:     info,
+		}
+
+		child.uid, child.gid = fileOwner(info)
+
+		switch {
+		case info.IsDir():
+			if err := child.readChildren(); err != nil {
+				return err
+			}
+		case info.Mode()&fs.ModeSymlink != 0:
+			if child.link, err = os.Readlink(child.path); err != nil {
+				return err
+			}
+		case !info.Mode().IsRegular():
+			continue
+		}
+
+		e.children = append(e.children, child)
+	}
+
+	return nil
+}
+
+// isDir returns whether the entry is a directory.
+func (e *entry) isDir() bool {
+	return e.info.IsDir()
+}
+
+// isSymlink returns whether the entry is a symbolic link.
+func (e *entry) isSymlink() bool {
+	return e.info.Mode()&fs.ModeSymlink != 0
+}
+
+// walk calls the provided function for the entry and all of its descendants
+// in depth-first order.
+func (e *entry) walk(fn func(*entry) error) error {
+	if err := fn(e); err != nil {
+		return err
+	}
+
+	for _, child := range e.children {
+		if err := child.walk(fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// files returns the locations within the rootfs of all files of the tree.
+func (e *entry) files() []string {
+	var files []string
+
+	_ = e.walk(func(e *entry) error {
+		if !e.isDir() {
+			files = append(files, e.internal)
+		}
+
+		return nil
+	})
+
+	return files
+}
+
+// modTimeInfo overrides the modification time of a file.
+type modTimeInfo struct {
+	fs.FileInfo
+	mtime time.Time
+}
+
+// ModTime implements fs.FileInfo.
+func (info modTimeInfo) ModTime() time.Time {
+	return info.mtime
+}
+
+// modeInfo overrides the permissions, including the setuid, setgid and sticky
+// bits, of a file.
+type modeInfo struct {
+	fs.FileInfo
+	mode fs.FileMode
+}
+
+// Mode implements fs.FileInfo.
+func (info modeInfo) Mode() fs.FileMode {
+	const perm = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+	return info.FileInfo.Mode()&^perm | info.mode&perm
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package attach connects local standard streams to the console of a machine.
+package attach
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultDetachKeys is the key sequence which is used to detach from a console
+// when none has been provided.
+const DefaultDetachKeys = "ctrl-p,ctrl-q"
+
+// ErrDetached is returned when the detach key sequence has been entered.
+var ErrDetached = errors.New("detached")
+
+// ParseDetachKeys converts a comma-separated list of keys into the sequence of
+// bytes which they produce.  Keys are either a single character, e.g. "a", or
+// a control character, e.g. "ctrl-p".
+func ParseDetachKeys(keys string) ([]byte, error) {
+	var seq []byte
+
+	for _, key := range strings.Split(keys, ",") {
+		key = strings.TrimSpace(key)
+
+		switch {
+		case len(key) == 1:
+			seq = append(seq, key[0])
+
+		case strings.HasPrefix(key, "ctrl-") && len(key) == 6:
+			c := key[5]
+			switch {
+			case c >= 'a' && c <= 'z':
+				seq = append(seq, c-'a'+1)
+			case c == '@':
+				seq = append(seq, 0)
+			case c >= '[' && c <= '_':
+				seq = append(seq, c-'['+27)
+			default:
+				return nil, fmt.Errorf("invalid detach key: %s", key)
+			}
+
+		default:
+			return nil, fmt.Errorf("invalid detach key: %s", key)
+		}
+	}
+
+	if len(seq) == 0 {
+		return nil, fmt.Errorf("empty detach key sequence")
+	}
+
+	return seq, nil
+}
+
+// detachReader passes through everything read from the underlying reader
+// until the detach key sequence has been read.  Bytes which form a partial
+// match of the sequence are withheld until it is clear they do not complete it.
+type detachReader struct {
+	r       io.Reader
+	keys    []byte
+	matched int
+	pending []byte
+	err     error
+}
+
+// NewDetachReader returns a reader which returns ErrDetached once the provided
+// key sequence has been read from r.
+func NewDetachReader(r io.Reader, keys []byte) io.Reader {
+	return &detachReader{
+		r:    r,
+		keys: keys,
+	}
+}
+
+// Read implements io.Reader
+func (dr *detachReader) Read(p []byte) (int, error) {
+	if len(dr.pending) > 0 {
+		n := copy(p, dr.pending)
+		dr.pending = dr.pending[n:]
+		return n, nil
+	} else if dr.err != nil {
+		return 0, dr.err
+	}
+
+	buf := make([]byte, len(p))
+	n, err := dr.r.Read(buf)
+
+	var out []byte
+	for _, b := range buf[:n] {
+		if b == dr.keys[dr.matched] {
+			dr.matched++
+			if dr.matched == len(dr.keys) {
+				dr.matched = 0
+				err = ErrDetached
+				break
+			}
+			continue
+		}
+
+		// Release the withheld partial match, since it was not the sequence.
+		out = append(out, dr.keys[:dr.matched]...)
+		dr.matched = 0
+
+		if b == dr.keys[0] {
+			dr.matched++
+			continue
+		}
+
+		out = append(out, b)
+	}
+
+	// Release any withheld partial match if the input has ended.
+	if err != nil && err != ErrDetached && dr.matched > 0 {
+		out = append(out, dr.keys[:dr.matched]...)
+		dr.matched = 0
+	}
+
+	written := copy(p, out)
+	if written < len(out) {
+		// Defer any error until the remainder has been read.
+		dr.pending = append(dr.pending, out[written:]...)
+		dr.err = err
+		return written, nil
+	}
+
+	return written, err
+}
+
+// Attach copies everything read from in to the console conn and everything
+// read from the console to out.  It returns ErrDetached when the detach key
+// sequence was read from in and nil when the console was closed.  The console
+// is always closed upon returning.
+func Attach(ctx context.Context, conn io.ReadWriteCloser, in io.Reader, out io.Writer, detachKeys []byte) error {
+	defer conn.Close()
+
+	inErr := make(chan error, 1)
+	outErr := make(chan error, 1)
+
+	go func() {
+		_, err := io.Copy(conn, NewDetachReader(in, detachKeys))
+		inErr <- err
+	}()
+
+	go func() {
+		_, err := io.Copy(out, conn)
+		outErr <- err
+	}()
+
+	for {
+		select {
+		case err := <-inErr:
+			// The input may legitimately end, e.g. when it is not a terminal, in
+			// which case the output of the console continues to be relayed.
+			if err != nil {
+				return err
+			}
+
+		case err := <-outErr:
+			return err
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// crlfWriter translates line feeds into carriage return and line feed pairs.
+type crlfWriter struct {
+	w    io.Writer
+	last byte
+}
+
+// NewCRLFWriter returns a writer which translates each line feed which is not
+// already preceded by a carriage return into a carriage return and line feed
+// pair.  This is necessary when writing to a terminal in raw mode.
+func NewCRLFWriter(w io.Writer) io.Writer {
+	return &crlfWriter{w: w}
+}
+
+// Write implements io.Writer
+func (cw *crlfWriter) Write(p []byte) (int, error) {
+	out := make([]byte, 0, len(p))
+
+	for _, b := range p {
+		if b == '\n' && cw.last != '\r' {
+			out = append(out, '\r')
+		}
+
+		out = append(out, b)
+		cw.last = b
+	}
+
+	if _, err := cw.w.Write(out); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
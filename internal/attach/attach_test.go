@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseDetachKeys(t *testing.T) {
+	tests := []struct {
+		keys   string
+		expect []byte
+	}{
+		{keys: "ctrl-p,ctrl-q", expect: []byte{16, 17}},
+		{keys: "ctrl-a, d", expect: []byte{1, 'd'}},
+		{keys: "ctrl-[", expect: []byte{27}},
+		{keys: "ctrl-@", expect: []byte{0}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDetachKeys(test.keys)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %q: %v", test.keys, err)
+			continue
+		}
+		if !bytes.Equal(test.expect, got) {
+			t.Errorf("Unexpected sequence for %q. Expected %v, got %v", test.keys, test.expect, got)
+		}
+	}
+
+	for _, keys := range []string{"", "ctrl-", "ctrl-1", "ab", "alt-x"} {
+		if _, err := ParseDetachKeys(keys); err == nil {
+			t.Errorf("Expected an error parsing %q", keys)
+		}
+	}
+}
+
+func TestDetachReader(t *testing.T) {
+	keys := []byte{16, 17}
+
+	tests := []struct {
+		name     string
+		in       []byte
+		expect   []byte
+		detached bool
+	}{
+		{
+			name:   "no sequence",
+			in:     []byte("hello\n"),
+			expect: []byte("hello\n"),
+		},
+		{
+			name:     "sequence",
+			in:       []byte{'a', 16, 17, 'b'},
+			expect:   []byte{'a'},
+			detached: true,
+		},
+		{
+			name:   "partial sequence",
+			in:     []byte{'a', 16, 'b', 17},
+			expect: []byte{'a', 16, 'b', 17},
+		},
+		{
+			name:     "repeated first key",
+			in:       []byte{16, 16, 17},
+			expect:   []byte{16},
+			detached: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Read a single byte at a time to exercise sequences spanning reads.
+			r := NewDetachReader(iotest.OneByteReader(bytes.NewReader(test.in)), keys)
+			got, err := io.ReadAll(r)
+
+			if test.detached && !errors.Is(err, ErrDetached) {
+				t.Errorf("Expected ErrDetached, got %v", err)
+			} else if !test.detached && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if !bytes.Equal(test.expect, got) {
+				t.Errorf("Unexpected output. Expected %v, got %v", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestCRLFWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCRLFWriter(&buf)
+
+	for _, s := range []string{"hello\n", "world\r\n", "split\r", "\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal("Unexpected error:", err)
+		}
+	}
+
+	if expect, got := "hello\r\nworld\r\nsplit\r\n", buf.String(); expect != got {
+		t.Errorf("Unexpected output. Expected %q, got %q", expect, got)
+	}
+}
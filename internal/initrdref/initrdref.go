@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package initrdref resolves the references accepted by the commands which
+// inspect an initramfs, i.e. a path, the name of a machine or a package, to
+// the location of the initramfs.
+package initrdref
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/initrd"
+	"kraftkit.sh/log"
+	mplatform "kraftkit.sh/machine/platform"
+	"kraftkit.sh/pack"
+	"kraftkit.sh/packmanager"
+	"kraftkit.sh/unikraft"
+	"kraftkit.sh/unikraft/target"
+)
+
+// Source is a resolved initramfs or rootfs directory.
+type Source struct {
+	// Path is the location of the CPIO archive or the directory on the host.
+	Path string
+
+	// IsDir is set when the source is a directory rather than an archive.
+	IsDir bool
+
+	// workdir is the temporary location a package was pulled to.
+	workdir string
+}
+
+// Close removes any temporary files of the source.
+func (source *Source) Close() error {
+	if source.workdir == "" {
+		return nil
+	}
+
+	return os.RemoveAll(source.workdir)
+}
+
+// Entries returns the entries of the initramfs, or those which the directory
+// is serialized into.  Directories are serialized with the provided options.
+func (source *Source) Entries(opts ...initrd.InitrdOption) ([]initrd.Entry, error) {
+	if source.IsDir {
+		return initrd.ListDirectory(source.Path, opts...)
+	}
+
+	return initrd.List(source.Path)
+}
+
+// Resolve returns the initramfs of the provided reference, which is either a
+// path to a CPIO archive or a directory, the name or UID of a machine or the
+// name of a package.  Packages are pulled for the provided platform and
+// architecture, which default to those of the host.
+func Resolve(ctx context.Context, ref, plat, arch string) (*Source, error) {
+	if fi, err := os.Stat(ref); err == nil {
+		return &Source{
+			Path:  ref,
+			IsDir: fi.IsDir(),
+		}, nil
+	}
+
+	if source, err := resolveMachine(ctx, ref); err != nil {
+		return nil, err
+	} else if source != nil {
+		return source, nil
+	}
+
+	return resolvePackage(ctx, ref, plat, arch)
+}
+
+// resolveMachine returns the initramfs of the machine with the provided name
+// or UID, if any.
+func resolveMachine(ctx context.Context, ref string) (*Source, error) {
+	controller, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		log.G(ctx).Debugf("could not prepare machine service: %v", err)
+		return nil, nil
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		log.G(ctx).Debugf("could not list machines: %v", err)
+		return nil, nil
+	}
+
+	for _, machine := range machines.Items {
+		if ref != machine.Name && ref != string(machine.UID) {
+			continue
+		}
+
+		if machine.Status.InitrdPath == "" {
+			return nil, fmt.Errorf("machine %s has no initramfs", machine.Name)
+		}
+
+		fi, err := os.Stat(machine.Status.InitrdPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not access initramfs of machine %s: %w", machine.Name, err)
+		}
+
+		return &Source{
+			Path:  machine.Status.InitrdPath,
+			IsDir: fi.IsDir(),
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// resolvePackage pulls the package with the provided name to a temporary
+// location and returns its initramfs.
+func resolvePackage(ctx context.Context, ref, plat, arch string) (*Source, error) {
+	pm, err := packmanager.NewUmbrellaManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if plat == "" {
+		platform, _, err := mplatform.Detect(ctx)
+		if err != nil {
+			platform = mplatform.PlatformQEMU
+		}
+
+		plat = platform.String()
+	} else {
+		plat = mplatform.PlatformByName(plat).String()
+	}
+
+	// First try the local cache of the catalog before accessing the remote one.
+	var packs []pack.Package
+	for _, cache := range []bool{true, false} {
+		packs, err = pm.Catalog(ctx,
+			packmanager.WithTypes(unikraft.ComponentTypeApp),
+			packmanager.WithName(ref),
+			packmanager.WithCache(cache),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(packs) > 0 {
+			break
+		}
+	}
+
+	if len(packs) == 0 {
+		return nil, fmt.Errorf("no such file, machine or package: %s", ref)
+	} else if len(packs) > 1 {
+		return nil, fmt.Errorf("could not determine which package to inspect: too many options for %s", ref)
+	}
+
+	workdir, err := os.MkdirTemp("", "kraftkit-initrd-*")
+	if err != nil {
+		return nil, err
+	}
+
+	source := &Source{workdir: workdir}
+
+	if err := packs[0].Pull(ctx,
+		pack.WithPullWorkdir(workdir),
+		pack.WithPullPlatform(plat),
+		pack.WithPullArchitecture(arch),
+	); err != nil {
+		source.Close()
+		return nil, fmt.Errorf("could not pull %s: %w", ref, err)
+	}
+
+	targ, ok := packs[0].(target.Target)
+	if !ok || targ.Initrd() == nil {
+		source.Close()
+		return nil, fmt.Errorf("package %s has no initramfs", ref)
+	}
+
+	source.Path, err = targ.Initrd().Build(ctx)
+	if err != nil {
+		source.Close()
+		return nil, err
+	}
+
+	return source, nil
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package unpack provides the helpers which are shared by the extraction of
+// archives onto the host, i.e. of the layers of OCI images and of initramfs
+// CPIO archives.
+package unpack
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Decompress returns a reader of the uncompressed contents of the provided
+// archive, which is either uncompressed or gzip or zstd compressed.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return zr.IOReadCloser(), nil
+	}
+
+	return io.NopCloser(br), nil
+}
+
+// CheckParents returns an error if any of the parents of the provided relative
+// directory within the destination is a symbolic link, such that entries
+// cannot be extracted outside of the destination.
+func CheckParents(dest, dir string) error {
+	current := dest
+
+	for _, part := range strings.Split(path.Clean(dir), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+
+		current = filepath.Join(current, part)
+
+		fi, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("refusing to extract through symbolic link: %s", current)
+		}
+	}
+
+	return nil
+}
+
+// SetAttributes sets the permissions, including the setuid, setgid and sticky
+// bits, and the modification time of the extracted entry at the provided path.
+func SetAttributes(target string, mode fs.FileMode, mtime time.Time) error {
+	if err := os.Chmod(target, mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+
+	_ = os.Chtimes(target, mtime, mtime)
+
+	return nil
+}
+
+type dirent struct {
+	path  string
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+// Dirs collects the attributes of extracted directories, which are only set
+// after extraction such that read-only directories can be populated.
+type Dirs struct {
+	dirs []dirent
+}
+
+// Add records the attributes of the extracted directory at the provided path.
+func (d *Dirs) Add(target string, mode fs.FileMode, mtime time.Time) {
+	d.dirs = append(d.dirs, dirent{target, mode, mtime})
+}
+
+// Restore sets the attributes of the recorded directories in reverse order
+// such that children are handled before their parents.
+func (d *Dirs) Restore() error {
+	for i := len(d.dirs) - 1; i >= 0; i-- {
+		if err := SetAttributes(d.dirs[i].path, d.dirs[i].mode, d.dirs[i].mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
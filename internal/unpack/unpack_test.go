@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package unpack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckParents(t *testing.T) {
+	dest := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dest, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(t.TempDir(), filepath.Join(dest, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir string
+		err bool
+	}{
+		{dir: "."},
+		{dir: "etc"},
+		{dir: "etc/missing/nested"},
+		{dir: "escape", err: true},
+		{dir: "escape/nested", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			if err := CheckParents(dest, tt.dir); (err != nil) != tt.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -30,14 +30,18 @@ import (
 	"kraftkit.sh/exec"
 	"kraftkit.sh/internal/logtail"
 	"kraftkit.sh/log"
+	"kraftkit.sh/machine/health"
 	"kraftkit.sh/machine/network/macaddr"
+	"kraftkit.sh/machine/volume/blockdev"
 	"kraftkit.sh/unikraft/export/v0/ukargparse"
 	"kraftkit.sh/unikraft/export/v0/uknetdev"
+	"kraftkit.sh/unikraft/export/v0/vfscore"
 )
 
 const (
-	FirecrackerBin      = "firecracker"
-	DefaultClientTimout = time.Second * 5
+	FirecrackerBin       = "firecracker"
+	DefaultClientTimout  = time.Second * 5
+	DefaultWatchInterval = time.Second
 )
 
 // machineV1alpha1Service ...
@@ -71,10 +75,26 @@ func NewMachineV1alpha1Service(ctx context.Context, opts ...any) (machinev1alpha
 // Create implements kraftkit.sh/api/machine/v1alpha1.MachineService.Create
 func (service *machineV1alpha1Service) Create(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	// Start with fail-safe checks for unsupported specification declarations.
-	if len(machine.Spec.Ports) > 0 {
+	if len(machine.Spec.Ports) > 0 && len(machine.Spec.Networks) == 0 {
 		return machine, fmt.Errorf("kraftkit does not yet support port forwarding to firecracker (contributions welcome): please use a network instead")
 	}
 
+	for _, network := range machine.Spec.Networks {
+		if network.Driver == "user" {
+			return machine, fmt.Errorf("firecracker does not support user-mode networks: please use a bridge network instead")
+		} else if network.Driver == "macvtap" {
+			return machine, fmt.Errorf("firecracker does not support macvtap networks: please use a bridge network instead")
+		}
+	}
+
+	for _, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver != blockdev.DriverName {
+			return machine, fmt.Errorf("firecracker does not support %s volumes: please use a blockdev volume instead", vol.Spec.Driver)
+		} else if vol.Spec.Format != blockdev.FormatRaw {
+			return machine, fmt.Errorf("firecracker does not support %s disk images: please use a raw disk image instead", vol.Spec.Format)
+		}
+	}
+
 	if machine.Status.KernelPath == "" {
 		return machine, fmt.Errorf("cannot create firecracker instance without kernel")
 	}
@@ -83,6 +103,20 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("cannot create firecracker instance with emulation")
 	}
 
+	if len(machine.Spec.GDBStub) > 0 {
+		return machine, fmt.Errorf("firecracker does not support exposing a GDB stub: please use the qemu platform instead")
+	}
+
+	if len(machine.Spec.Snapshot) > 0 {
+		if _, err := os.Stat(machine.Spec.Snapshot); err != nil {
+			return machine, fmt.Errorf("could not access snapshot: %w", err)
+		}
+
+		if _, err := os.Stat(snapshotMemoryPath(machine.Spec.Snapshot)); err != nil {
+			return machine, fmt.Errorf("could not access snapshot memory: %w", err)
+		}
+	}
+
 	if machine.ObjectMeta.UID == "" {
 		machine.ObjectMeta.UID = uuid.NewUUID()
 	}
@@ -214,6 +248,23 @@ watch:
 
 	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
 
+	// Restoring from a snapshot must be the first request made to the API socket
+	// and replaces the configuration of the machine entirely.  The machine
+	// remains paused until it is started.
+	if len(machine.Spec.Snapshot) > 0 {
+		if _, err = client.LoadSnapshot(ctx, &models.SnapshotLoadParams{
+			SnapshotPath: firecracker.String(machine.Spec.Snapshot),
+			MemFilePath:  firecracker.String(snapshotMemoryPath(machine.Spec.Snapshot)),
+		}); err != nil {
+			return machine, fmt.Errorf("could not load snapshot: %w", err)
+		}
+
+		machine.Status.Pid = int32(pid)
+		machine.Status.State = machinev1alpha1.MachineStateCreated
+
+		return machine, nil
+	}
+
 	kernelArgs, err := ukargparse.Parse(machine.Spec.KernelArgs...)
 	if err != nil {
 		return machine, err
@@ -253,7 +304,7 @@ watch:
 
 				// Assign the first interface statically via command-line arguments, also
 				// checking if the built-in arguments for
-				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && i == 0 {
+				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && i == 0 && iface.Spec.IP != "" {
 					kernelArgs = append(kernelArgs,
 						uknetdev.ParamIpv4Addr.WithValue(iface.Spec.IP),
 						uknetdev.ParamIpv4GwAddr.WithValue(network.Gateway),
@@ -261,12 +312,46 @@ watch:
 					)
 				}
 
+				// Point the first interface at the embedded DNS server of the network.
+				if !kernelArgs.Contains(uknetdev.ParamIpv4Dns0) && i == 0 && network.DNS && network.Gateway != "" {
+					kernelArgs = append(kernelArgs,
+						uknetdev.ParamIpv4Dns0.WithValue(network.Gateway),
+					)
+				}
+
 				// Increment the host network ID for additional interfaces.
 				i++
 			}
 		}
 	}
 
+	// Attach each volume as a drive, which Firecracker exposes as virtio-blk
+	// devices in the order in which they were added.
+	var fstab []string
+
+	for i, vol := range machine.Spec.Volumes {
+		if _, err := client.PutGuestDriveByID(ctx, blockdev.DeviceName(i), &models.Drive{
+			DriveID:      firecracker.String(blockdev.DeviceName(i)),
+			PathOnHost:   firecracker.String(vol.Spec.Source),
+			IsRootDevice: firecracker.Bool(false),
+			IsReadOnly:   firecracker.Bool(vol.Spec.ReadOnly),
+		}); err != nil {
+			return machine, fmt.Errorf("could not attach volume %s: %w", vol.Name, err)
+		}
+
+		if entry, ok := blockdev.FstabEntry(i, vol); ok {
+			fstab = append(fstab, entry)
+		} else {
+			log.G(ctx).Warnf("attaching %s without mounting it: unknown filesystem", vol.Spec.Source)
+		}
+	}
+
+	if len(fstab) > 0 {
+		kernelArgs = append(kernelArgs,
+			vfscore.ParamVfsFstab.WithValue(fstab),
+		)
+	}
+
 	// TODO(nderjung): This is standard "Unikraft" positional argument syntax
 	// (kernel args and application arguments separated with "--").  The resulting
 	// string should be standardized through a central function.
@@ -324,9 +409,82 @@ func getFirecrackerConfigFromPlatformConfig(platformConfig interface{}) (*Firecr
 	return nil, fmt.Errorf("could not cast firecracker platform config from store")
 }
 
-// Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
+// snapshotMemoryPath returns the path of the guest memory file which
+// accompanies the provided snapshot.
+func snapshotMemoryPath(snapshotPath string) string {
+	return snapshotPath + ".mem"
+}
+
+// Update implements kraftkit.sh/api/machine/v1alpha1.MachineService.
+// Firecracker does not support changing the resources of a machine after it
+// has been configured, so only requests which leave the resources of the
+// machine unchanged succeed.
 func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	panic("not implemented: kraftkit.sh/machine/firecracker.machineV1alpha1Service.Update")
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	config, err := client.GetMachineConfiguration()
+	if err != nil {
+		return machine, fmt.Errorf("could not query machine configuration via API socket: %v", err)
+	}
+
+	if cpus := machine.Spec.Resources.Requests.Cpu().Value(); cpus > 0 && config.Payload.VcpuCount != nil && cpus != *config.Payload.VcpuCount {
+		return machine, fmt.Errorf("cannot change the number of vCPUs of a firecracker machine: please re-create it instead")
+	}
+
+	if memory := machine.Spec.Resources.Requests.Memory().Value() / 1000000; memory > 0 && config.Payload.MemSizeMib != nil && memory != *config.Payload.MemSizeMib {
+		return machine, fmt.Errorf("cannot change the memory of a firecracker machine: please re-create it instead")
+	}
+
+	return machine, nil
+}
+
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService.  The
+// machine is paused and both its state and its guest memory are saved to files
+// within its state directory, after which the machine remains suspended.
+func (service *machineV1alpha1Service) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	switch machine.Status.State {
+	case machinev1alpha1.MachineStateRunning, machinev1alpha1.MachineStatePaused:
+	default:
+		return machine, fmt.Errorf("cannot snapshot machine in %s state", machine.Status.State)
+	}
+
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	// Firecracker only permits creating snapshots of paused machines.
+	if machine.Status.State == machinev1alpha1.MachineStateRunning {
+		if _, err := client.PatchVM(ctx, &models.VM{
+			State: firecracker.String(models.VMStatePaused),
+		}); err != nil {
+			return machine, fmt.Errorf("could not pause machine: %w", err)
+		}
+
+		machine.Status.State = machinev1alpha1.MachineStatePaused
+	}
+
+	snapshotPath := filepath.Join(machine.Status.StateDir, "machine.snapshot")
+
+	if _, err := client.CreateSnapshot(ctx, &models.SnapshotCreateParams{
+		SnapshotPath: firecracker.String(snapshotPath),
+		MemFilePath:  firecracker.String(snapshotMemoryPath(snapshotPath)),
+		SnapshotType: models.SnapshotCreateParamsSnapshotTypeFull,
+	}); err != nil {
+		return machine, fmt.Errorf("could not create snapshot: %w", err)
+	}
+
+	machine.Status.SnapshotPath = snapshotPath
+	machine.Status.State = machinev1alpha1.MachineStateSuspended
+
+	return machine, nil
 }
 
 // Watch implements kraftkit.sh/api/machine/v1alpha1.MachineService
@@ -339,31 +497,37 @@ func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machi
 	return events, errs, nil
 }
 
+// watch periodically queries the state of the machine and emits an event
+// each time it changes until the machine has exited.  The firecracker process
+// is not necessarily a child of this process, so it cannot be waited upon.
 func (service *machineV1alpha1Service) watch(ctx context.Context, machine *machinev1alpha1.Machine, events *chan *machinev1alpha1.Machine, errs *chan error) {
+	ticker := time.NewTicker(DefaultWatchInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.G(ctx).Info("context cancelled (watch)")
 			*errs <- ctx.Err()
 			return
-		default:
-			process, err := os.FindProcess(int(machine.Status.Pid))
-			if err != nil {
-				return
-			}
 
-			state, err := process.Wait()
-			if err != nil {
+		case <-ticker.C:
+			state := machine.Status.State
+
+			if _, err := service.Get(ctx, machine); err != nil {
 				*errs <- err
 				return
 			}
 
-			if state.Exited() {
-				machine.Status.State = machinev1alpha1.MachineStateExited
-				machine.Status.ExitCode = state.ExitCode()
+			if machine.Status.State == state {
+				continue
 			}
 
 			*events <- machine
+
+			if machine.Status.State == machinev1alpha1.MachineStateExited {
+				return
+			}
 		}
 	}
 }
@@ -376,29 +540,111 @@ func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machi
 	}
 
 	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
-	action := models.InstanceActionInfoActionTypeInstanceStart
-	info := models.InstanceActionInfo{
-		ActionType: &action,
+
+	info, err := client.GetInstanceInfo(ctx)
+	if err != nil {
+		return machine, fmt.Errorf("could not query machine status via API socket: %v", err)
 	}
 
-	if _, err := client.CreateSyncAction(ctx, &info); err != nil {
-		return machine, err
+	// A machine which has already been started, either by booting it or by
+	// restoring it from a snapshot, is resumed instead.
+	if *info.Payload.State == models.InstanceInfoStatePaused {
+		if _, err := client.PatchVM(ctx, &models.VM{
+			State: firecracker.String(models.VMStateResumed),
+		}); err != nil {
+			return machine, fmt.Errorf("could not resume machine: %w", err)
+		}
+	} else if *info.Payload.State == models.InstanceInfoStateNotStarted {
+		action := models.InstanceActionInfoActionTypeInstanceStart
+		if _, err := client.CreateSyncAction(ctx, &models.InstanceActionInfo{
+			ActionType: &action,
+		}); err != nil {
+			return machine, err
+		}
 	}
 
 	machine.Status.State = machinev1alpha1.MachineStateRunning
-	machine.Status.StartedAt = time.Now()
+	machine.Status.ManuallyStopped = false
+	if machine.Status.StartedAt.IsZero() {
+		machine.Status.StartedAt = time.Now()
+	}
 
 	return machine, nil
 }
 
 // Pause implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	panic("not implemented: kraftkit.sh/machine/firecracker.machineV1alpha1Service.Pause")
+	if machine.Status.State != machinev1alpha1.MachineStateRunning {
+		return machine, fmt.Errorf("cannot pause machine in %s state", machine.Status.State)
+	}
+
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	if _, err := client.PatchVM(ctx, &models.VM{
+		State: firecracker.String(models.VMStatePaused),
+	}); err != nil {
+		return machine, fmt.Errorf("could not pause machine: %w", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
 }
 
-// Logs implements kraftkit.sh/api/machine/v1alpha1.MachineService
+// Logs implements kraftkit.sh/api/machine/v1alpha1.MachineService.  The
+// standard output of firecracker contains both the serial console of the guest
+// and, unless a logger has been configured, the log messages of firecracker
+// itself.  Only the output of the guest is returned.
 func (service *machineV1alpha1Service) Logs(ctx context.Context, machine *machinev1alpha1.Machine) (chan string, chan error, error) {
-	return logtail.NewLogTail(ctx, machine.Status.LogFile)
+	if _, err := os.Stat(machine.Status.LogFile); err != nil {
+		return nil, nil, fmt.Errorf("could not access machine logs: %w", err)
+	}
+
+	in, errIn, err := logtail.NewLogTail(ctx, machine.Status.LogFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan string)
+	errOut := make(chan error)
+
+	// Messages from firecracker are prefixed with the ID of the instance, which
+	// is the UID of the machine.
+	prefix := "[" + string(machine.UID) + ":"
+
+	go func() {
+		for {
+			select {
+			case line := <-in:
+				if strings.Contains(line, prefix) || strings.Contains(line, "[anonymous-instance:") {
+					continue
+				}
+
+				select {
+				case out <- line:
+				case <-ctx.Done():
+					return
+				}
+
+			case err := <-errIn:
+				select {
+				case errOut <- err:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, errOut, nil
 }
 
 // Get implements kraftkit.sh/api/machine/v1alpha1/MachineService.Get
@@ -411,6 +657,8 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		return machine, err
 	}
 
+	machine.Status.Health = health.Load(machine)
+
 	// Check if the process is alive, which ultimately indicates to us whether we
 	// able to speak to the exposed QMP socket
 	activeProcess := false
@@ -482,7 +730,13 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		exitCode = -1
 
 	case models.InstanceInfoStatePaused:
-		state = machinev1alpha1.MachineStatePaused
+		// A snapshot can only be taken of a paused machine, so retain the
+		// suspended state until the machine is resumed.
+		if savedState == machinev1alpha1.MachineStateSuspended {
+			state = machinev1alpha1.MachineStateSuspended
+		} else {
+			state = machinev1alpha1.MachineStatePaused
+		}
 		exitCode = -1
 	}
 
@@ -527,6 +781,7 @@ func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machin
 
 	machine.Status.State = machinev1alpha1.MachineStateExited
 	machine.Status.ExitedAt = time.Now()
+	machine.Status.ManuallyStopped = true
 
 	return machine, nil
 }
@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package firecracker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// fakeFirecracker serves the subset of the Firecracker API which is used by
+// the machine service over a unix socket.
+type fakeFirecracker struct {
+	mu        sync.Mutex
+	state     string
+	vcpus     int64
+	memory    int64
+	snapshots []models.SnapshotCreateParams
+}
+
+func newFakeFirecracker(t *testing.T, state string) (*fakeFirecracker, string) {
+	t.Helper()
+
+	fake := &fakeFirecracker{
+		state:  state,
+		vcpus:  1,
+		memory: 64,
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "firecracker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal("Failed to listen on socket:", err)
+	}
+
+	server := &http.Server{Handler: fake}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		server.Close()
+	})
+
+	return fake, socketPath
+}
+
+// ServeHTTP implements http.Handler
+func (fake *fakeFirecracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /":
+		fake.reply(w, http.StatusOK, models.InstanceInfo{
+			AppName:    stringPtr("Firecracker"),
+			ID:         stringPtr("fake"),
+			State:      stringPtr(fake.state),
+			VmmVersion: stringPtr("1.1.0"),
+		})
+
+	case "GET /machine-config":
+		fake.reply(w, http.StatusOK, models.MachineConfiguration{
+			VcpuCount:  &fake.vcpus,
+			MemSizeMib: &fake.memory,
+		})
+
+	case "PUT /actions":
+		var action models.InstanceActionInfo
+		if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+			fake.fault(w, err.Error())
+			return
+		}
+
+		if fake.state != models.InstanceInfoStateNotStarted {
+			fake.fault(w, "The requested operation is not supported after starting the microVM.")
+			return
+		}
+
+		fake.state = models.InstanceInfoStateRunning
+		w.WriteHeader(http.StatusNoContent)
+
+	case "PATCH /vm":
+		var vm models.VM
+		if err := json.NewDecoder(r.Body).Decode(&vm); err != nil {
+			fake.fault(w, err.Error())
+			return
+		}
+
+		if fake.state == models.InstanceInfoStateNotStarted {
+			fake.fault(w, "The requested operation is not supported before starting the microVM.")
+			return
+		}
+
+		switch *vm.State {
+		case models.VMStatePaused:
+			fake.state = models.InstanceInfoStatePaused
+		case models.VMStateResumed:
+			fake.state = models.InstanceInfoStateRunning
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case "PUT /snapshot/create":
+		var params models.SnapshotCreateParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			fake.fault(w, err.Error())
+			return
+		}
+
+		if fake.state != models.InstanceInfoStatePaused {
+			fake.fault(w, "Cannot create snapshot while the microVM is running.")
+			return
+		}
+
+		fake.snapshots = append(fake.snapshots, params)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		fake.fault(w, "Invalid request method and/or path: "+r.Method+" "+r.URL.Path)
+	}
+}
+
+func (fake *fakeFirecracker) reply(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (fake *fakeFirecracker) fault(w http.ResponseWriter, message string) {
+	fake.reply(w, http.StatusBadRequest, models.Error{
+		FaultMessage: message,
+	})
+}
+
+func (fake *fakeFirecracker) State() string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	return fake.state
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func newFakeMachine(t *testing.T, socketPath string, state machinev1alpha1.MachineState) *machinev1alpha1.Machine {
+	t.Helper()
+
+	return &machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1"),
+					corev1.ResourceMemory: resource.MustParse("64M"),
+				},
+			},
+		},
+		Status: machinev1alpha1.MachineStatus{
+			State: state,
+			// The liveness of the machine is determined by its PID, so use one which
+			// is guaranteed to be alive.
+			Pid:      int32(os.Getpid()),
+			StateDir: t.TempDir(),
+			PlatformConfig: &FirecrackerConfig{
+				SocketPath: socketPath,
+			},
+		},
+	}
+}
+
+func newService(t *testing.T) machinev1alpha1.MachineService {
+	t.Helper()
+
+	service, err := NewMachineV1alpha1Service(context.Background())
+	if err != nil {
+		t.Fatal("Failed to create service:", err)
+	}
+
+	return service
+}
+
+func TestPauseAndResume(t *testing.T) {
+	ctx := context.Background()
+	fake, socketPath := newFakeFirecracker(t, models.InstanceInfoStateRunning)
+	machine := newFakeMachine(t, socketPath, machinev1alpha1.MachineStateRunning)
+	service := newService(t)
+
+	machine, err := service.Pause(ctx, machine)
+	if err != nil {
+		t.Fatal("Failed to pause machine:", err)
+	}
+	if expect, got := machinev1alpha1.MachineStatePaused, machine.Status.State; expect != got {
+		t.Errorf("Unexpected machine state after pausing. Expected %q, got %q", expect, got)
+	}
+	if expect, got := models.InstanceInfoStatePaused, fake.State(); expect != got {
+		t.Errorf("Unexpected firecracker state after pausing. Expected %q, got %q", expect, got)
+	}
+
+	machine, err = service.Get(ctx, machine)
+	if err != nil {
+		t.Fatal("Failed to get machine:", err)
+	}
+	if expect, got := machinev1alpha1.MachineStatePaused, machine.Status.State; expect != got {
+		t.Errorf("Unexpected machine state of paused machine. Expected %q, got %q", expect, got)
+	}
+
+	machine, err = service.Start(ctx, machine)
+	if err != nil {
+		t.Fatal("Failed to resume machine:", err)
+	}
+	if expect, got := machinev1alpha1.MachineStateRunning, machine.Status.State; expect != got {
+		t.Errorf("Unexpected machine state after resuming. Expected %q, got %q", expect, got)
+	}
+	if expect, got := models.InstanceInfoStateRunning, fake.State(); expect != got {
+		t.Errorf("Unexpected firecracker state after resuming. Expected %q, got %q", expect, got)
+	}
+}
+
+func TestPauseNotRunning(t *testing.T) {
+	_, socketPath := newFakeFirecracker(t, models.InstanceInfoStateNotStarted)
+	machine := newFakeMachine(t, socketPath, machinev1alpha1.MachineStateCreated)
+
+	if _, err := newService(t).Pause(context.Background(), machine); err == nil {
+		t.Error("Expected an error when pausing a machine which is not running")
+	}
+}
+
+func TestStartNotStarted(t *testing.T) {
+	fake, socketPath := newFakeFirecracker(t, models.InstanceInfoStateNotStarted)
+	machine := newFakeMachine(t, socketPath, machinev1alpha1.MachineStateCreated)
+
+	machine, err := newService(t).Start(context.Background(), machine)
+	if err != nil {
+		t.Fatal("Failed to start machine:", err)
+	}
+	if expect, got := machinev1alpha1.MachineStateRunning, machine.Status.State; expect != got {
+		t.Errorf("Unexpected machine state after starting. Expected %q, got %q", expect, got)
+	}
+	if expect, got := models.InstanceInfoStateRunning, fake.State(); expect != got {
+		t.Errorf("Unexpected firecracker state after starting. Expected %q, got %q", expect, got)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fake, socketPath := newFakeFirecracker(t, models.InstanceInfoStateRunning)
+	machine := newFakeMachine(t, socketPath, machinev1alpha1.MachineStateRunning)
+	service := newService(t)
+
+	machine, err := service.Snapshot(ctx, machine)
+	if err != nil {
+		t.Fatal("Failed to snapshot machine:", err)
+	}
+	if expect, got := machinev1alpha1.MachineStateSuspended, machine.Status.State; expect != got {
+		t.Errorf("Unexpected machine state after snapshot. Expected %q, got %q", expect, got)
+	}
+
+	if len(fake.snapshots) != 1 {
+		t.Fatalf("Unexpected number of snapshots. Expected 1, got %d", len(fake.snapshots))
+	}
+	if expect, got := machine.Status.SnapshotPath, *fake.snapshots[0].SnapshotPath; expect != got {
+		t.Errorf("Unexpected snapshot path. Expected %q, got %q", expect, got)
+	}
+	if expect, got := snapshotMemoryPath(machine.Status.SnapshotPath), *fake.snapshots[0].MemFilePath; expect != got {
+		t.Errorf("Unexpected snapshot memory path. Expected %q, got %q", expect, got)
+	}
+	if filepath.Dir(machine.Status.SnapshotPath) != machine.Status.StateDir {
+		t.Errorf("Expected snapshot to be located in the state directory %q, got %q", machine.Status.StateDir, machine.Status.SnapshotPath)
+	}
+
+	machine, err = service.Get(ctx, machine)
+	if err != nil {
+		t.Fatal("Failed to get machine:", err)
+	}
+	if expect, got := machinev1alpha1.MachineStateSuspended, machine.Status.State; expect != got {
+		t.Errorf("Unexpected machine state of suspended machine. Expected %q, got %q", expect, got)
+	}
+
+	machine, err = service.Start(ctx, machine)
+	if err != nil {
+		t.Fatal("Failed to resume machine:", err)
+	}
+	if expect, got := models.InstanceInfoStateRunning, fake.State(); expect != got {
+		t.Errorf("Unexpected firecracker state after resuming. Expected %q, got %q", expect, got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	_, socketPath := newFakeFirecracker(t, models.InstanceInfoStateRunning)
+	machine := newFakeMachine(t, socketPath, machinev1alpha1.MachineStateRunning)
+	service := newService(t)
+
+	if _, err := service.Update(ctx, machine); err != nil {
+		t.Error("Unexpected error when updating machine without changes:", err)
+	}
+
+	machine.Spec.Resources.Requests[corev1.ResourceCPU] = resource.MustParse("2")
+	if _, err := service.Update(ctx, machine); err == nil {
+		t.Error("Expected an error when changing the number of vCPUs")
+	}
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package health probes machines according to their health check and records
+// the result in their state directory, such that it is shared between the
+// events monitor, which continuously checks machines, and the commands which
+// display their status.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// StatusFile is the name of the file in the state directory of a machine
+// which holds the result of its most recent health check.
+const StatusFile = "health"
+
+// Load returns the most recently saved result of the health check of the
+// provided machine.
+func Load(machine *machinev1alpha1.Machine) machinev1alpha1.HealthStatus {
+	if machine.Spec.HealthCheck == nil || machine.Status.StateDir == "" {
+		return machinev1alpha1.HealthStatusNone
+	}
+
+	b, err := os.ReadFile(filepath.Join(machine.Status.StateDir, StatusFile))
+	if err != nil {
+		return machinev1alpha1.HealthStatusStarting
+	}
+
+	return machinev1alpha1.HealthStatus(strings.TrimSpace(string(b)))
+}
+
+// Save records the result of the health check of the provided machine.
+func Save(machine *machinev1alpha1.Machine, status machinev1alpha1.HealthStatus) error {
+	// Write to a temporary file first such that concurrent readers never see a
+	// partially written status.
+	path := filepath.Join(machine.Status.StateDir, StatusFile)
+	if err := os.WriteFile(path+".tmp", []byte(status.String()), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// Probe performs a single probe of the health check of the provided machine.
+func Probe(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	check := machine.Spec.HealthCheck
+	if check == nil {
+		return fmt.Errorf("machine has no health check")
+	}
+
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = machinev1alpha1.DefaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case check.TCP != "":
+		return probeTCP(ctx, tcpAddress(machine, check.TCP))
+	case check.HTTP != "":
+		return probeHTTP(ctx, check.HTTP)
+	case check.Serial != "":
+		return probeSerial(machine.Status.LogFile, check.Serial)
+	}
+
+	return fmt.Errorf("health check has no probe")
+}
+
+// tcpAddress completes the address of a TCP probe with the address of the
+// machine if it only consists of a port.  Machines without an address on a
+// network are probed via the host port which the port is published on.
+func tcpAddress(machine *machinev1alpha1.Machine, addr string) string {
+	if strings.Contains(addr, ":") {
+		return addr
+	}
+
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			if iface.Spec.IP != "" {
+				return net.JoinHostPort(iface.Spec.IP, addr)
+			}
+		}
+	}
+
+	for _, port := range machine.Spec.Ports {
+		if port.HostPort == 0 || strconv.Itoa(int(port.MachinePort)) != addr {
+			continue
+		}
+
+		if port.Protocol != "" && !strings.EqualFold(string(port.Protocol), string(corev1.ProtocolTCP)) {
+			continue
+		}
+
+		host := port.HostIP
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			host = "localhost"
+		}
+
+		return net.JoinHostPort(host, strconv.Itoa(int(port.HostPort)))
+	}
+
+	return net.JoinHostPort("localhost", addr)
+}
+
+func probeTCP(ctx context.Context, addr string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func probeHTTP(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func probeSerial(logFile, expr string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("invalid serial output expression: %w", err)
+	}
+
+	b, err := os.ReadFile(logFile)
+	if err != nil {
+		return err
+	}
+
+	if !re.Match(b) {
+		return fmt.Errorf("serial output does not match %q", expr)
+	}
+
+	return nil
+}
+
+// Checker accumulates the results of consecutive probes of a machine into its
+// health status.
+type Checker struct {
+	machine  *machinev1alpha1.Machine
+	failures int
+	status   machinev1alpha1.HealthStatus
+}
+
+// NewChecker prepares a Checker for the provided machine.
+func NewChecker(machine *machinev1alpha1.Machine) *Checker {
+	return &Checker{
+		machine: machine,
+		status:  machinev1alpha1.HealthStatusStarting,
+	}
+}
+
+// Interval returns the duration between two consecutive probes.
+func (checker *Checker) Interval() time.Duration {
+	if interval := checker.machine.Spec.HealthCheck.Interval; interval > 0 {
+		return interval
+	}
+
+	return machinev1alpha1.DefaultHealthCheckInterval
+}
+
+// Check probes the machine once and returns its resulting health status.
+func (checker *Checker) Check(ctx context.Context) machinev1alpha1.HealthStatus {
+	if err := Probe(ctx, checker.machine); err == nil {
+		checker.failures = 0
+		checker.status = machinev1alpha1.HealthStatusHealthy
+		return checker.status
+	}
+
+	// Failures during the start period do not count towards the number of
+	// retries, unless the machine has already been healthy.
+	check := checker.machine.Spec.HealthCheck
+	if checker.status == machinev1alpha1.HealthStatusStarting && time.Since(checker.machine.Status.StartedAt) < check.StartPeriod {
+		return checker.status
+	}
+
+	retries := check.Retries
+	if retries <= 0 {
+		retries = machinev1alpha1.DefaultHealthCheckRetries
+	}
+
+	checker.failures++
+	if checker.failures >= retries {
+		checker.status = machinev1alpha1.HealthStatusUnhealthy
+	}
+
+	return checker.status
+}
+
+// Wait probes the machine until it is either healthy or unhealthy, saving
+// every change of its health status.
+func Wait(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	checker := NewChecker(machine)
+	status := machinev1alpha1.HealthStatusNone
+
+	for {
+		if latest := checker.Check(ctx); latest != status {
+			status = latest
+			if err := Save(machine, status); err != nil {
+				return fmt.Errorf("could not save health status: %w", err)
+			}
+		}
+
+		switch status {
+		case machinev1alpha1.HealthStatusHealthy:
+			return nil
+		case machinev1alpha1.HealthStatusUnhealthy:
+			return fmt.Errorf("machine %s is unhealthy", machine.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(checker.Interval()):
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+func newMachine(t *testing.T, check machinev1alpha1.HealthCheck) *machinev1alpha1.Machine {
+	t.Helper()
+
+	stateDir := t.TempDir()
+
+	return &machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			HealthCheck: &check,
+		},
+		Status: machinev1alpha1.MachineStatus{
+			StateDir:  stateDir,
+			LogFile:   filepath.Join(stateDir, "machine.log"),
+			StartedAt: time.Now(),
+		},
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+
+	machine := newMachine(t, machinev1alpha1.HealthCheck{TCP: listener.Addr().String()})
+
+	if err := Probe(context.Background(), machine); err != nil {
+		t.Error("Expected TCP probe to pass:", err)
+	}
+
+	listener.Close()
+
+	if err := Probe(context.Background(), machine); err == nil {
+		t.Error("Expected TCP probe to fail after closing the listener")
+	}
+}
+
+func TestTCPAddress(t *testing.T) {
+	machine := &machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			Ports: machinev1alpha1.MachinePorts{
+				{HostIP: "0.0.0.0", HostPort: 8080, MachinePort: 80, Protocol: "tcp"},
+				{HostIP: "127.0.0.2", HostPort: 8443, MachinePort: 443, Protocol: "tcp"},
+				{HostPort: 5353, MachinePort: 53, Protocol: "udp"},
+			},
+		},
+	}
+
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"10.0.0.2:80", "10.0.0.2:80"},
+		{"80", "localhost:8080"},
+		{"443", "127.0.0.2:8443"},
+		{"53", "localhost:53"},
+	}
+
+	for _, tt := range tests {
+		if got := tcpAddress(machine, tt.addr); got != tt.expected {
+			t.Errorf("tcpAddress(%q) = %q, want %q", tt.addr, got, tt.expected)
+		}
+	}
+}
+
+func TestProbeHTTP(t *testing.T) {
+	code := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer server.Close()
+
+	machine := newMachine(t, machinev1alpha1.HealthCheck{HTTP: server.URL})
+
+	if err := Probe(context.Background(), machine); err != nil {
+		t.Error("Expected HTTP probe to pass:", err)
+	}
+
+	code = http.StatusServiceUnavailable
+
+	if err := Probe(context.Background(), machine); err == nil {
+		t.Error("Expected HTTP probe to fail on error status")
+	}
+}
+
+func TestProbeSerial(t *testing.T) {
+	machine := newMachine(t, machinev1alpha1.HealthCheck{Serial: `Listening on port \d+`})
+
+	if err := os.WriteFile(machine.Status.LogFile, []byte("Booting...\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Probe(context.Background(), machine); err == nil {
+		t.Error("Expected serial probe to fail before the output matches")
+	}
+
+	if err := os.WriteFile(machine.Status.LogFile, []byte("Booting...\nListening on port 80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Probe(context.Background(), machine); err != nil {
+		t.Error("Expected serial probe to pass:", err)
+	}
+}
+
+func TestChecker(t *testing.T) {
+	machine := newMachine(t, machinev1alpha1.HealthCheck{
+		Serial:  "ready",
+		Retries: 2,
+	})
+
+	checker := NewChecker(machine)
+
+	for i, expect := range []machinev1alpha1.HealthStatus{
+		machinev1alpha1.HealthStatusStarting,
+		machinev1alpha1.HealthStatusUnhealthy,
+	} {
+		if got := checker.Check(context.Background()); got != expect {
+			t.Errorf("Unexpected status after %d failing probes. Expected %q, got %q", i+1, expect, got)
+		}
+	}
+
+	if err := os.WriteFile(machine.Status.LogFile, []byte("ready\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect, got := machinev1alpha1.HealthStatusHealthy, checker.Check(context.Background()); got != expect {
+		t.Errorf("Unexpected status after passing probe. Expected %q, got %q", expect, got)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	machine := newMachine(t, machinev1alpha1.HealthCheck{TCP: "80"})
+
+	if expect, got := machinev1alpha1.HealthStatusStarting, Load(machine); got != expect {
+		t.Errorf("Unexpected status before saving. Expected %q, got %q", expect, got)
+	}
+
+	if err := Save(machine, machinev1alpha1.HealthStatusHealthy); err != nil {
+		t.Fatal("Failed to save status:", err)
+	}
+
+	if expect, got := machinev1alpha1.HealthStatusHealthy, Load(machine); got != expect {
+		t.Errorf("Unexpected status after saving. Expected %q, got %q", expect, got)
+	}
+}
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/config"
+)
+
+// Services which are run in the background for a network.  Each service is
+// run by a detached instance of the daemon command which is provided via the
+// context, e.g. `kraft net --driver bridge dhcp NETWORK`.
+const (
+	daemonDHCP = "dhcp"
+	daemonDNS  = "dns"
+)
+
+// daemonCommandKey is used to retrieve the daemon command from the context.
+type daemonCommandKey struct{}
+
+// WithDaemonCommand returns a new context with the command which serves the
+// background services of bridge networks.  The name of the service, i.e. "dhcp"
+// or "dns", its arguments and the name of the network are appended to the
+// command.  Networks which enable DHCP or DNS cannot be started without it.
+func WithDaemonCommand(ctx context.Context, command ...string) context.Context {
+	return context.WithValue(ctx, daemonCommandKey{}, command)
+}
+
+// daemonCommand returns the daemon command in the context, if any.
+func daemonCommand(ctx context.Context) []string {
+	command, _ := ctx.Value(daemonCommandKey{}).([]string)
+	return command
+}
+
+// daemonPath returns the path of the runtime file of the provided service of
+// the network with the given extension.
+func daemonPath(ctx context.Context, network *networkv1alpha1.Network, daemon, ext string) string {
+	return filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, daemon, network.Name+ext)
+}
+
+// daemonPid returns the PID of the provided running service of the network, or
+// 0 if it is not running.  The daemon inherits an exclusive lock on its pid
+// file, such that the PID is only trusted whilst the lock is held and a
+// recycled PID is never mistaken for the daemon.
+func daemonPid(ctx context.Context, network *networkv1alpha1.Network, daemon string) int {
+	f, err := os.Open(daemonPath(ctx, network, daemon, ".pid"))
+	if err != nil {
+		return 0
+	}
+
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err == nil {
+		// Nobody holds the lock, so the daemon has exited.
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return 0
+	} else if !errors.Is(err, syscall.EWOULDBLOCK) {
+		return 0
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+
+	return pid
+}
+
+// startDaemon starts the provided service of the network in the background,
+// unless it is already running.  Additional arguments are passed to the
+// sub-command before the name of the network.
+func (service *v1alpha1Network) startDaemon(ctx context.Context, network *networkv1alpha1.Network, daemon string, args ...string) error {
+	command := daemonCommand(ctx)
+	if len(command) == 0 {
+		return fmt.Errorf("cannot start %s server of %s: no daemon command provided", daemon, network.Name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(daemonPath(ctx, network, daemon, ".pid")), 0o755); err != nil {
+		return err
+	}
+
+	pidFile, err := os.OpenFile(daemonPath(ctx, network, daemon, ".pid"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer pidFile.Close()
+
+	// The lock is held by a running daemon.
+	if err := syscall.Flock(int(pidFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); errors.Is(err, syscall.EWOULDBLOCK) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not lock pid file of %s server: %v", daemon, err)
+	}
+
+	logFile, err := os.Create(daemonPath(ctx, network, daemon, ".log"))
+	if err != nil {
+		return err
+	}
+
+	defer logFile.Close()
+
+	argv := append(append(append([]string{}, command[1:]...), daemon), args...)
+	cmd := exec.Command(command[0], append(argv, network.Name)...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+
+	// The daemon inherits the locked pid file and thereby holds the lock until
+	// it exits.
+	cmd.ExtraFiles = []*os.File{pidFile}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start %s server of %s: %v", daemon, network.Name, err)
+	}
+
+	if err := pidFile.Truncate(0); err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("could not write pid file of %s server: %v", daemon, err)
+	}
+
+	if _, err := pidFile.WriteAt([]byte(strconv.Itoa(cmd.Process.Pid)), 0); err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("could not write pid file of %s server: %v", daemon, err)
+	}
+
+	return cmd.Process.Release()
+}
+
+// stopDaemon stops the provided service of the network if it is running.
+func (service *v1alpha1Network) stopDaemon(ctx context.Context, network *networkv1alpha1.Network, daemon string) error {
+	if pid := daemonPid(ctx, network, daemon); pid > 0 {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			return fmt.Errorf("could not stop %s server of %s: %v", daemon, network.Name, err)
+		}
+	}
+
+	if err := os.Remove(daemonPath(ctx, network, daemon, ".pid")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// startDaemons starts the services which are enabled for the network.
+func (service *v1alpha1Network) startDaemons(ctx context.Context, network *networkv1alpha1.Network) error {
+	if network.Spec.DHCP {
+		var args []string
+		if network.Spec.DNS {
+			args = append(args, "--dns")
+		}
+
+		if err := service.startDaemon(ctx, network, daemonDHCP, args...); err != nil {
+			return err
+		}
+	}
+
+	if network.Spec.DNS {
+		if err := service.startDaemon(ctx, network, daemonDNS); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopDaemons stops all services of the network.
+func (service *v1alpha1Network) stopDaemons(ctx context.Context, network *networkv1alpha1.Network) error {
+	for _, daemon := range []string{daemonDHCP, daemonDNS} {
+		if err := service.stopDaemon(ctx, network, daemon); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/config"
+)
+
+func TestDaemonPid(t *testing.T) {
+	cfgm, err := config.NewConfigManager(&config.KraftKit{RuntimeDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := config.WithConfigManager(context.Background(), cfgm)
+	network := &networkv1alpha1.Network{}
+	network.Name = "kraft0"
+
+	if pid := daemonPid(ctx, network, daemonDHCP); pid != 0 {
+		t.Errorf("Expected no PID without pid file, got %d", pid)
+	}
+
+	path := daemonPath(ctx, network, daemonDHCP, ".pid")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("1234"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stale pid file, whose PID may have been recycled, is not trusted.
+	if pid := daemonPid(ctx, network, daemonDHCP); pid != 0 {
+		t.Errorf("Expected no PID of unlocked pid file, got %d", pid)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+
+	if expect, got := 1234, daemonPid(ctx, network, daemonDHCP); expect != got {
+		t.Errorf("Unexpected PID of locked pid file. Expected %d, got %d", expect, got)
+	}
+}
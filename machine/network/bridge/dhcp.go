@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+
+	"kraftkit.sh/machine/network/dhcp"
+	"kraftkit.sh/machine/network/ipam"
+)
+
+// dhcpLeaser implements dhcp.Leaser by handing out the addresses which have
+// been allocated to the interfaces of the network and allocating new addresses
+// for unknown clients through the IPAM.  The addresses of unknown clients
+// expire after the lease time unless the client renews its lease, such that
+// offers which are never requested do not exhaust the subnet.
+type dhcpLeaser struct {
+	network string
+	subnet  *net.IPNet
+	gateway net.IP
+	ttl     time.Duration
+	ipam    *ipam.IPAM
+}
+
+// dhcpLeaseID returns the IPAM lease identifier of a DHCP client.
+func dhcpLeaseID(mac net.HardwareAddr) string {
+	return "dhcp:" + mac.String()
+}
+
+// Lease implements dhcp.Leaser
+func (leaser *dhcpLeaser) Lease(ctx context.Context, mac net.HardwareAddr, requested net.IP) (net.IP, error) {
+	leases, err := leaser.ipam.Leases(ctx, leaser.network)
+	if err != nil {
+		return nil, err
+	}
+
+	// Machines attached by KraftKit already hold a lease for the hardware
+	// address of their interface, whereas the leases of unknown clients are
+	// renewed through the IPAM below.
+	for _, lease := range leases {
+		if lease.ID != dhcpLeaseID(mac) && strings.EqualFold(lease.MAC, mac.String()) && leaser.subnet.Contains(net.ParseIP(lease.IP)) {
+			return net.ParseIP(lease.IP), nil
+		}
+	}
+
+	req := ipam.Request{
+		ID:  dhcpLeaseID(mac),
+		MAC: mac.String(),
+		TTL: leaser.ttl,
+	}
+
+	if requested != nil {
+		req.IP = requested.String()
+		if ip, err := leaser.ipam.Allocate(ctx, leaser.network, leaser.subnet, []net.IP{leaser.gateway}, req); err == nil {
+			return ip, nil
+		}
+
+		// Fall back to any free address.
+		req.IP = ""
+	}
+
+	return leaser.ipam.Allocate(ctx, leaser.network, leaser.subnet, []net.IP{leaser.gateway}, req)
+}
+
+// Release implements dhcp.Leaser
+func (leaser *dhcpLeaser) Release(ctx context.Context, mac net.HardwareAddr) error {
+	return leaser.ipam.Release(ctx, leaser.network, dhcpLeaseID(mac))
+}
+
+// ServeDHCP serves DHCP on the bridge of the network with the provided name
+// until the context is cancelled.  When dns is set, the gateway of the network,
+// which runs the embedded DNS server, is handed out as name server.
+func ServeDHCP(ctx context.Context, name string, dns bool) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("could not get bridge link: %v", err)
+	}
+
+	addrs, err := netlink.AddrList(link, nl.FAMILY_V4)
+	if err != nil {
+		return err
+	} else if len(addrs) == 0 {
+		return fmt.Errorf("bridge %s has no address", name)
+	}
+
+	gateway := addrs[0].IP.To4()
+	subnet := &net.IPNet{
+		IP:   gateway.Mask(addrs[0].Mask),
+		Mask: addrs[0].Mask,
+	}
+
+	manager, err := ipam.NewDefaultIPAM(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dhcp.Listen(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	server := dhcp.Server{
+		ServerIP: gateway,
+		Subnet:   subnet,
+		Router:   gateway,
+		Leaser: &dhcpLeaser{
+			network: name,
+			subnet:  subnet,
+			gateway: gateway,
+			ttl:     dhcp.DefaultLeaseTime,
+			ipam:    manager,
+		},
+	}
+
+	if dns {
+		server.DNS = []net.IP{gateway}
+	}
+
+	return server.Serve(ctx, conn)
+}
@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sync/errgroup"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/machine/network/dns"
+)
+
+// dnsCacheTTL is the duration for which the names of the machines on a network
+// are cached before the stores are consulted again.
+const dnsCacheTTL = 2 * time.Second
+
+// dnsResolver implements dns.Resolver by resolving the names and aliases of
+// the running machines which are attached to the network to the IPv4 and IPv6
+// addresses of their interfaces.
+type dnsResolver struct {
+	network  string
+	networks networkv1alpha1.NetworkService
+	machines machinev1alpha1.MachineService
+
+	mu      sync.Mutex
+	names   map[string][]net.IP
+	updated time.Time
+}
+
+// Resolve implements dns.Resolver
+func (resolver *dnsResolver) Resolve(ctx context.Context, name string) ([]net.IP, error) {
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	if time.Since(resolver.updated) > dnsCacheTTL {
+		names, err := resolver.lookup(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resolver.names = names
+		resolver.updated = time.Now()
+	}
+
+	return resolver.names[name], nil
+}
+
+// lookup returns the addresses of the running machines on the network indexed
+// by their lower-case names and aliases.  The addresses are those which were
+// allocated to the interfaces of the machines when they were attached to the
+// network, as recorded in the specification of the machines.  Only interfaces
+// whose link the network service reports as attached to the network are
+// resolved, such that stale machine records are never answered.
+func (resolver *dnsResolver) lookup(ctx context.Context) (map[string][]net.IP, error) {
+	network, err := resolver.networks.Get(ctx, &networkv1alpha1.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: resolver.network,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attached := make(map[string]bool, len(network.Status.Interfaces))
+	for _, iface := range network.Status.Interfaces {
+		attached[iface.IfName] = true
+	}
+
+	machines, err := resolver.machines.List(ctx, &machinev1alpha1.MachineList{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string][]net.IP)
+	for _, machine := range machines.Items {
+		if machine.Status.State != machinev1alpha1.MachineStateRunning {
+			continue
+		}
+
+		for _, spec := range machine.Spec.Networks {
+			if spec.IfName != resolver.network {
+				continue
+			}
+
+			for _, iface := range spec.Interfaces {
+				if !attached[iface.Spec.IfName] {
+					continue
+				}
+
+				var ips []net.IP
+				for _, addr := range []string{iface.Spec.IP, iface.Spec.IP6} {
+					if ip := net.ParseIP(addr); ip != nil {
+						ips = append(ips, ip)
+					}
+				}
+
+				for _, name := range append([]string{machine.Name}, iface.Spec.Aliases...) {
+					name = strings.ToLower(name)
+					names[name] = append(names[name], ips...)
+				}
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// ServeDNS serves DNS on the gateway addresses of the bridge of the network
+// with the provided name, i.e. on its IPv4 and, for dual-stack networks, its
+// IPv6 gateway, until the context is cancelled.  The names of the machines are
+// looked up through the provided machine service and all other queries are
+// forwarded to the provided upstream name servers.
+func ServeDNS(ctx context.Context, name string, machines machinev1alpha1.MachineService, upstreams []string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("could not get bridge link: %v", err)
+	}
+
+	addr4, addr6, err := bridgeAddrs(link)
+	if err != nil {
+		return err
+	} else if addr4 == nil && addr6 == nil {
+		return fmt.Errorf("bridge %s has no address", name)
+	}
+
+	networks, err := NewNetworkServiceV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	server := dns.Server{
+		Domain: name,
+		Resolver: &dnsResolver{
+			network:  name,
+			networks: networks,
+			machines: machines,
+		},
+		Upstreams: upstreams,
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	for _, listen := range []struct {
+		network string
+		addr    *netlink.Addr
+	}{
+		{"udp4", addr4},
+		{"udp6", addr6},
+	} {
+		if listen.addr == nil {
+			continue
+		}
+
+		conn, err := net.ListenPacket(listen.network, net.JoinHostPort(listen.addr.IP.String(), fmt.Sprint(dns.Port)))
+		if err != nil {
+			return fmt.Errorf("could not listen for DNS queries on %s: %v", listen.addr.IP, err)
+		}
+
+		eg.Go(func() error {
+			return server.Serve(ctx, conn)
+		})
+	}
+
+	return eg.Wait()
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+// fakeMachines implements the listing of machinev1alpha1.MachineService.
+type fakeMachines struct {
+	machinev1alpha1.MachineService
+	machines []machinev1alpha1.Machine
+}
+
+func (fake *fakeMachines) List(_ context.Context, list *machinev1alpha1.MachineList) (*machinev1alpha1.MachineList, error) {
+	list.Items = fake.machines
+	return list, nil
+}
+
+// fakeNetworks implements the retrieval of networkv1alpha1.NetworkService.
+type fakeNetworks struct {
+	networkv1alpha1.NetworkService
+	ifnames []string
+}
+
+func (fake *fakeNetworks) Get(_ context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	for _, ifname := range fake.ifnames {
+		network.Status.Interfaces = append(network.Status.Interfaces, networkv1alpha1.NetworkInterfaceStatus{
+			IfName: ifname,
+		})
+	}
+
+	return network, nil
+}
+
+func newFakeMachine(name, network, ip string, state machinev1alpha1.MachineState, aliases ...string) machinev1alpha1.Machine {
+	machine := machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			Networks: []networkv1alpha1.NetworkSpec{{
+				IfName: network,
+				Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{{
+					Spec: networkv1alpha1.NetworkInterfaceSpec{
+						IfName:  name + "0",
+						IP:      ip,
+						Aliases: aliases,
+					},
+				}},
+			}},
+		},
+		Status: machinev1alpha1.MachineStatus{
+			State: state,
+		},
+	}
+	machine.Name = name
+
+	return machine
+}
+
+func TestDNSResolver(t *testing.T) {
+	resolver := &dnsResolver{
+		network: "kraft0",
+		networks: &fakeNetworks{
+			ifnames: []string{"nginx0", "redis0", "stopped0"},
+		},
+		machines: &fakeMachines{
+			machines: []machinev1alpha1.Machine{
+				newFakeMachine("nginx", "kraft0", "172.100.0.2", machinev1alpha1.MachineStateRunning, "Web"),
+				newFakeMachine("redis", "kraft1", "172.101.0.2", machinev1alpha1.MachineStateRunning),
+				newFakeMachine("stopped", "kraft0", "172.100.0.3", machinev1alpha1.MachineStateExited),
+				newFakeMachine("detached", "kraft0", "172.100.0.4", machinev1alpha1.MachineStateRunning),
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		expect string
+	}{
+		{name: "nginx", expect: "172.100.0.2"},
+		{name: "web", expect: "172.100.0.2"},
+		{name: "redis"},
+		{name: "stopped"},
+		{name: "detached"},
+	}
+
+	for _, tt := range tests {
+		ips, err := resolver.Resolve(context.Background(), tt.name)
+		if err != nil {
+			t.Fatal("Failed to resolve:", err)
+		}
+
+		if tt.expect == "" {
+			if len(ips) > 0 {
+				t.Errorf("Expected %s not to resolve, got %v", tt.name, ips)
+			}
+			continue
+		}
+
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP(tt.expect)) {
+			t.Errorf("Expected %s to resolve to %s, got %v", tt.name, tt.expect, ips)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// NftTable is the name of the nftables table which holds all rules managed
+	// by KraftKit.
+	NftTable = "kraftkit"
+
+	// NftBridgeSet is the name of the set within the inet table of KraftKit
+	// which contains the names of all bridges managed by KraftKit.
+	NftBridgeSet = "bridges"
+
+	// nftCommentPrefix prefixes the comment attached to every rule which is
+	// installed for an interface such that the rules can be identified later.
+	nftCommentPrefix = "kraftkit:"
+
+	// nftNetworkPrefix prefixes the identifier of the rules which are installed
+	// for a network as a whole.
+	nftNetworkPrefix = "network:"
+
+	// Address families of the nftables tables of KraftKit.  Published ports
+	// only apply to IPv4, whilst the rules of networks apply to both families.
+	nftFamilyIP   = "ip"
+	nftFamilyInet = "inet"
+)
+
+// PortRule represents a single port on the host which is forwarded to an
+// interface attached to a bridge network.
+type PortRule struct {
+	// Bridge is the name of the bridge which the interface is attached to.
+	Bridge string
+
+	// HostIP is the address on the host which the port is published on.  When
+	// empty, the port is published on all local addresses.
+	HostIP string
+
+	// HostPort is the port on the host.
+	HostPort int32
+
+	// DestIP is the address of the interface.
+	DestIP string
+
+	// DestPort is the port on the interface.
+	DestPort int32
+
+	// Protocol is either "tcp" or "udp".
+	Protocol string
+}
+
+// NetworkRule represents the rules which apply to all traffic of a bridge
+// network.  Traffic between the bridges of separate networks is always
+// dropped.
+type NetworkRule struct {
+	// Bridge is the name of the bridge of the network.
+	Bridge string
+
+	// Subnets are the IPv4 and/or IPv6 subnets of the network in CIDR format.
+	Subnets []string
+
+	// Masquerade enables source NAT of traffic from the subnets of the network
+	// which leaves the host through another interface.
+	Masquerade bool
+
+	// Internal drops all traffic between the network and any other interface,
+	// such that machines can only reach each other and the host.
+	Internal bool
+}
+
+// Firewall manages the host rules which publish the ports of interfaces
+// attached to a bridge network and which control the traffic of the network.
+// Rules are grouped by an identifier which uniquely represents the interface.
+type Firewall interface {
+	// Publish installs the provided rules for the interface with the given
+	// identifier, replacing any rules which were previously installed for it.
+	Publish(ctx context.Context, id string, rules []PortRule) error
+
+	// Unpublish removes all rules installed for the interface with the given
+	// identifier.
+	Unpublish(ctx context.Context, id string) error
+
+	// Configure installs the provided rules of a network, replacing any rules
+	// which were previously installed for its bridge.
+	Configure(ctx context.Context, rule NetworkRule) error
+
+	// Deconfigure removes all rules installed for the network with the given
+	// bridge.
+	Deconfigure(ctx context.Context, bridge string) error
+}
+
+// nftRunner executes the nft(8) program with the provided arguments, feeding
+// stdin to it, and returns its standard output.
+type nftRunner func(ctx context.Context, stdin string, args ...string) (string, error)
+
+// sysctlWriter sets the kernel parameter with the provided name, e.g.
+// "net.ipv4.ip_forward", to the given value.
+type sysctlWriter func(name, value string) error
+
+// nftFirewall implements Firewall by using nft(8) to manage DNAT, forward and
+// masquerade rules in dedicated tables.
+type nftFirewall struct {
+	run    nftRunner
+	sysctl sysctlWriter
+}
+
+// NewNftFirewall returns a Firewall which uses nftables to publish ports.
+func NewNftFirewall() Firewall {
+	return &nftFirewall{run: runNft, sysctl: writeSysctl}
+}
+
+// writeSysctl is the default sysctlWriter which writes to procfs.
+func writeSysctl(name, value string) error {
+	return os.WriteFile(filepath.Join("/proc/sys", strings.ReplaceAll(name, ".", "/")), []byte(value), 0o644)
+}
+
+// runNft is the default nftRunner which invokes the nft binary on the host.
+func runNft(ctx context.Context, stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "nft", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("nft %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// Publish implements Firewall
+func (fw *nftFirewall) Publish(ctx context.Context, id string, rules []PortRule) error {
+	add, err := NftRuleset(id, rules)
+	if err != nil {
+		return err
+	}
+
+	// Allow traffic from the loopback interface to be routed to the bridges.
+	for _, rule := range rules {
+		if rule.Bridge == "" {
+			continue
+		}
+
+		if err := fw.sysctl("net.ipv4.conf."+rule.Bridge+".route_localnet", "1"); err != nil {
+			return fmt.Errorf("could not enable routing of loopback traffic: %v", err)
+		}
+	}
+
+	handles, err := fw.handles(ctx, nftFamilyIP, id)
+	if err != nil {
+		return err
+	}
+
+	// Remove the old rules and add the new ones in the same transaction such
+	// that a port is never left unpublished when it is re-published.
+	_, err = fw.run(ctx, nftDeletes(nftFamilyIP, handles)+add, "-f", "-")
+	return err
+}
+
+// Unpublish implements Firewall
+func (fw *nftFirewall) Unpublish(ctx context.Context, id string) error {
+	handles, err := fw.handles(ctx, nftFamilyIP, id)
+	if err != nil {
+		return err
+	}
+
+	if len(handles) == 0 {
+		return nil
+	}
+
+	_, err = fw.run(ctx, nftDeletes(nftFamilyIP, handles), "-f", "-")
+	return err
+}
+
+// Configure implements Firewall
+func (fw *nftFirewall) Configure(ctx context.Context, rule NetworkRule) error {
+	add, err := NftNetworkRuleset(rule)
+	if err != nil {
+		return err
+	}
+
+	// Masqueraded traffic is routed by the host.
+	if rule.Masquerade && !rule.Internal {
+		for _, subnet := range rule.Subnets {
+			name := "net.ipv4.ip_forward"
+			if !strings.Contains(subnet, ".") {
+				name = "net.ipv6.conf.all.forwarding"
+			}
+
+			if err := fw.sysctl(name, "1"); err != nil {
+				return fmt.Errorf("could not enable forwarding: %v", err)
+			}
+		}
+	}
+
+	handles, err := fw.handles(ctx, nftFamilyInet, nftNetworkPrefix+rule.Bridge)
+	if err != nil {
+		return err
+	}
+
+	_, err = fw.run(ctx, nftDeletes(nftFamilyInet, handles)+add, "-f", "-")
+	return err
+}
+
+// Deconfigure implements Firewall
+func (fw *nftFirewall) Deconfigure(ctx context.Context, bridge string) error {
+	listing, err := fw.list(ctx, nftFamilyInet)
+	if err != nil {
+		return err
+	}
+
+	script := nftDeletes(nftFamilyInet, nftParseHandles(listing, nftNetworkPrefix+bridge))
+	if nftSetContains(listing, NftBridgeSet, bridge) {
+		script += fmt.Sprintf("delete element %s %s %s { \"%s\" }\n", nftFamilyInet, NftTable, NftBridgeSet, bridge)
+	}
+
+	if script == "" {
+		return nil
+	}
+
+	_, err = fw.run(ctx, script, "-f", "-")
+	return err
+}
+
+// list returns the listing of the table of KraftKit with the provided family,
+// which is empty when the table does not exist.
+func (fw *nftFirewall) list(ctx context.Context, family string) (string, error) {
+	listing, err := fw.run(ctx, "", "-a", "list", "table", family, NftTable)
+	if err != nil {
+		// The table is only created once the first rule is installed.
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return listing, nil
+}
+
+// handles returns the handles of all rules in the table with the provided
+// family which have previously been installed for the given identifier.
+func (fw *nftFirewall) handles(ctx context.Context, family, id string) ([]nftHandle, error) {
+	listing, err := fw.list(ctx, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return nftParseHandles(listing, id), nil
+}
+
+// NftRuleset generates the nft(8) script which installs the DNAT and forward
+// rules for the provided port rules of the interface with the given
+// identifier.  The script creates the table and its chains if they do not
+// already exist.
+func NftRuleset(id string, rules []PortRule) (string, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "table ip %s {\n", NftTable)
+	fmt.Fprintf(&b, "\tchain prerouting {\n\t\ttype nat hook prerouting priority -100; policy accept;\n\t}\n")
+	fmt.Fprintf(&b, "\tchain output {\n\t\ttype nat hook output priority -100; policy accept;\n\t}\n")
+	fmt.Fprintf(&b, "\tchain forward {\n\t\ttype filter hook forward priority 0; policy accept;\n\t}\n")
+	fmt.Fprintf(&b, "\tchain postrouting {\n\t\ttype nat hook postrouting priority 100; policy accept;\n\t}\n")
+	fmt.Fprintf(&b, "}\n")
+
+	comment := fmt.Sprintf("comment \"%s%s\"", nftCommentPrefix, id)
+
+	for _, rule := range rules {
+		proto := strings.ToLower(rule.Protocol)
+		if proto == "" {
+			proto = "tcp"
+		}
+		if proto != "tcp" && proto != "udp" {
+			return "", fmt.Errorf("unsupported protocol for port %d: %s", rule.HostPort, rule.Protocol)
+		}
+
+		dest := net.ParseIP(rule.DestIP)
+		if dest == nil || dest.To4() == nil {
+			return "", fmt.Errorf("invalid destination address for port %d: %q", rule.HostPort, rule.DestIP)
+		}
+
+		if rule.HostPort <= 0 || rule.HostPort > 65535 || rule.DestPort <= 0 || rule.DestPort > 65535 {
+			return "", fmt.Errorf("invalid port mapping: %d->%d", rule.HostPort, rule.DestPort)
+		}
+
+		// Match traffic destined to any local address unless the port is bound to
+		// a specific host address.
+		match := "fib daddr type local"
+		if rule.HostIP != "" {
+			host := net.ParseIP(rule.HostIP)
+			if host == nil || host.To4() == nil {
+				return "", fmt.Errorf("invalid host address for port %d: %q", rule.HostPort, rule.HostIP)
+			}
+			if !host.IsUnspecified() {
+				match = "ip daddr " + host.String()
+			}
+		}
+
+		dnat := fmt.Sprintf("%s dport %d dnat to %s:%d %s", proto, rule.HostPort, dest, rule.DestPort, comment)
+
+		fmt.Fprintf(&b, "add rule ip %s prerouting %s %s\n", NftTable, match, dnat)
+
+		// Locally generated traffic does not traverse the prerouting chain.
+		fmt.Fprintf(&b, "add rule ip %s output %s %s\n", NftTable, match, dnat)
+
+		if rule.Bridge != "" {
+			fmt.Fprintf(&b, "add rule ip %s forward oifname \"%s\" ip daddr %s %s dport %d accept %s\n", NftTable, rule.Bridge, dest, proto, rule.DestPort, comment)
+		} else {
+			fmt.Fprintf(&b, "add rule ip %s forward ip daddr %s %s dport %d accept %s\n", NftTable, dest, proto, rule.DestPort, comment)
+		}
+
+		// Traffic from the loopback interface, e.g. to localhost, is routed to
+		// the bridge with route_localnet enabled and must carry the address of
+		// the bridge since the machine cannot reply to a loopback address.
+		fmt.Fprintf(&b, "add rule ip %s postrouting ip saddr 127.0.0.0/8 ip daddr %s %s dport %d masquerade %s\n", NftTable, dest, proto, rule.DestPort, comment)
+	}
+
+	return b.String(), nil
+}
+
+// NftNetworkRuleset generates the nft(8) script which installs the rules of the
+// provided network.  The script creates the inet table, its chains and the set
+// of bridges if they do not already exist.
+func NftNetworkRuleset(rule NetworkRule) (string, error) {
+	if rule.Bridge == "" {
+		return "", fmt.Errorf("cannot configure network without bridge")
+	}
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "table %s %s {\n", nftFamilyInet, NftTable)
+	fmt.Fprintf(&b, "\tset %s {\n\t\ttype ifname;\n\t}\n", NftBridgeSet)
+	fmt.Fprintf(&b, "\tchain forward {\n\t\ttype filter hook forward priority 0; policy accept;\n\t}\n")
+	fmt.Fprintf(&b, "\tchain postrouting {\n\t\ttype nat hook postrouting priority 100; policy accept;\n\t}\n")
+	fmt.Fprintf(&b, "}\n")
+
+	comment := fmt.Sprintf("comment \"%s%s%s\"", nftCommentPrefix, nftNetworkPrefix, rule.Bridge)
+	prefix := fmt.Sprintf("add rule %s %s", nftFamilyInet, NftTable)
+
+	fmt.Fprintf(&b, "add element %s %s %s { \"%s\" }\n", nftFamilyInet, NftTable, NftBridgeSet, rule.Bridge)
+
+	// Isolate the network from the bridges of all other networks.
+	fmt.Fprintf(&b, "%s forward iifname \"%s\" oifname != \"%s\" oifname @%s drop %s\n", prefix, rule.Bridge, rule.Bridge, NftBridgeSet, comment)
+
+	if rule.Internal {
+		fmt.Fprintf(&b, "%s forward iifname \"%s\" oifname != \"%s\" drop %s\n", prefix, rule.Bridge, rule.Bridge, comment)
+		fmt.Fprintf(&b, "%s forward oifname \"%s\" iifname != \"%s\" drop %s\n", prefix, rule.Bridge, rule.Bridge, comment)
+
+		return b.String(), nil
+	}
+
+	if !rule.Masquerade {
+		return b.String(), nil
+	}
+
+	for _, subnet := range rule.Subnets {
+		ip, ipnet, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return "", fmt.Errorf("invalid subnet of network %s: %v", rule.Bridge, err)
+		}
+
+		family := "ip"
+		if ip.To4() == nil {
+			family = "ip6"
+		}
+
+		fmt.Fprintf(&b, "%s postrouting %s saddr %s oifname != \"%s\" masquerade %s\n", prefix, family, ipnet, rule.Bridge, comment)
+	}
+
+	return b.String(), nil
+}
+
+// nftSetContains returns whether the set with the provided name in the
+// listing of a table contains the given interface name.
+func nftSetContains(listing, set, name string) bool {
+	var inSet bool
+
+	scanner := bufio.NewScanner(strings.NewReader(listing))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "set "+set+" {"):
+			inSet = true
+		case inSet && line == "}":
+			return false
+		case inSet && strings.Contains(line, "\""+name+"\""):
+			return true
+		}
+	}
+
+	return false
+}
+
+// nftHandle references a single rule within a chain of the KraftKit table.
+type nftHandle struct {
+	chain  string
+	handle int
+}
+
+var (
+	nftChainRegex  = regexp.MustCompile(`^\s*chain (\S+) \{`)
+	nftHandleRegex = regexp.MustCompile(`# handle (\d+)\s*$`)
+)
+
+// nftParseHandles parses the output of `nft -a list table` and returns the
+// handles of all rules which carry the comment of the provided identifier.
+func nftParseHandles(listing, id string) []nftHandle {
+	var handles []nftHandle
+	var chain string
+
+	comment := fmt.Sprintf("comment \"%s%s\"", nftCommentPrefix, id)
+	scanner := bufio.NewScanner(strings.NewReader(listing))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if matches := nftChainRegex.FindStringSubmatch(line); matches != nil {
+			chain = matches[1]
+			continue
+		}
+
+		if chain == "" || !strings.Contains(line, comment) {
+			continue
+		}
+
+		matches := nftHandleRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		handle, err := strconv.Atoi(matches[1])
+		if err != nil {
+			continue
+		}
+
+		handles = append(handles, nftHandle{chain: chain, handle: handle})
+	}
+
+	return handles
+}
+
+// nftDeletes generates the nft(8) script which deletes the provided rules of
+// the table with the given family.
+func nftDeletes(family string, handles []nftHandle) string {
+	var b strings.Builder
+
+	for _, h := range handles {
+		fmt.Fprintf(&b, "delete rule %s %s %s handle %d\n", family, NftTable, h.chain, h.handle)
+	}
+
+	return b.String()
+}
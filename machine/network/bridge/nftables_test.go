@@ -0,0 +1,385 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testListing = `table ip kraftkit { # handle 7
+	chain prerouting { # handle 1
+		type nat hook prerouting priority dstnat; policy accept;
+		fib daddr type local tcp dport 8080 dnat to 10.0.0.2:80 comment "kraftkit:net:a" # handle 4
+		fib daddr type local tcp dport 9090 dnat to 10.0.0.3:90 comment "kraftkit:net:b" # handle 5
+	}
+
+	chain output { # handle 2
+		type nat hook output priority -100; policy accept;
+		fib daddr type local tcp dport 8080 dnat to 10.0.0.2:80 comment "kraftkit:net:a" # handle 6
+	}
+
+	chain forward { # handle 3
+		type filter hook forward priority filter; policy accept;
+		oifname "kraft0" ip daddr 10.0.0.2 tcp dport 80 accept comment "kraftkit:net:a" # handle 8
+	}
+}
+`
+
+func TestNftRuleset(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []PortRule
+		expect []string
+		err    bool
+	}{
+		{
+			name: "all local addresses",
+			rules: []PortRule{
+				{Bridge: "kraft0", HostPort: 8080, DestIP: "10.0.0.2", DestPort: 80, Protocol: "TCP"},
+			},
+			expect: []string{
+				`add rule ip kraftkit prerouting fib daddr type local tcp dport 8080 dnat to 10.0.0.2:80 comment "kraftkit:id"`,
+				`add rule ip kraftkit output fib daddr type local tcp dport 8080 dnat to 10.0.0.2:80 comment "kraftkit:id"`,
+				`add rule ip kraftkit forward oifname "kraft0" ip daddr 10.0.0.2 tcp dport 80 accept comment "kraftkit:id"`,
+				`add rule ip kraftkit postrouting ip saddr 127.0.0.0/8 ip daddr 10.0.0.2 tcp dport 80 masquerade comment "kraftkit:id"`,
+			},
+		},
+		{
+			name: "specific host address",
+			rules: []PortRule{
+				{Bridge: "kraft0", HostIP: "192.168.1.10", HostPort: 53, DestIP: "10.0.0.2", DestPort: 5353, Protocol: "udp"},
+			},
+			expect: []string{
+				`add rule ip kraftkit prerouting ip daddr 192.168.1.10 udp dport 53 dnat to 10.0.0.2:5353 comment "kraftkit:id"`,
+				`add rule ip kraftkit output ip daddr 192.168.1.10 udp dport 53 dnat to 10.0.0.2:5353 comment "kraftkit:id"`,
+				`add rule ip kraftkit forward oifname "kraft0" ip daddr 10.0.0.2 udp dport 5353 accept comment "kraftkit:id"`,
+			},
+		},
+		{
+			name: "unspecified host address",
+			rules: []PortRule{
+				{HostIP: "0.0.0.0", HostPort: 80, DestIP: "10.0.0.2", DestPort: 80},
+			},
+			expect: []string{
+				`add rule ip kraftkit prerouting fib daddr type local tcp dport 80 dnat to 10.0.0.2:80 comment "kraftkit:id"`,
+				`add rule ip kraftkit forward ip daddr 10.0.0.2 tcp dport 80 accept comment "kraftkit:id"`,
+			},
+		},
+		{
+			name:  "unsupported protocol",
+			rules: []PortRule{{HostPort: 80, DestIP: "10.0.0.2", DestPort: 80, Protocol: "sctp"}},
+			err:   true,
+		},
+		{
+			name:  "missing destination address",
+			rules: []PortRule{{HostPort: 80, DestPort: 80}},
+			err:   true,
+		},
+		{
+			name:  "invalid port",
+			rules: []PortRule{{HostPort: 0, DestIP: "10.0.0.2", DestPort: 80}},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset, err := NftRuleset("id", tt.rules)
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			if !strings.HasPrefix(ruleset, "table ip kraftkit {\n") {
+				t.Errorf("Expected ruleset to declare the table, got:\n%s", ruleset)
+			}
+
+			for _, rule := range tt.expect {
+				if !strings.Contains(ruleset, rule+"\n") {
+					t.Errorf("Expected ruleset to contain %q, got:\n%s", rule, ruleset)
+				}
+			}
+		})
+	}
+}
+
+func TestNftParseHandles(t *testing.T) {
+	handles := nftParseHandles(testListing, "net:a")
+	expect := []nftHandle{
+		{chain: "prerouting", handle: 4},
+		{chain: "output", handle: 6},
+		{chain: "forward", handle: 8},
+	}
+
+	if len(handles) != len(expect) {
+		t.Fatalf("Unexpected number of handles. Expected %d, got %d", len(expect), len(handles))
+	}
+
+	for i := range expect {
+		if expect[i] != handles[i] {
+			t.Errorf("Unexpected handle at index %d. Expected %v, got %v", i, expect[i], handles[i])
+		}
+	}
+
+	if handles := nftParseHandles(testListing, "net:c"); len(handles) != 0 {
+		t.Errorf("Expected no handles for unknown interface, got %v", handles)
+	}
+}
+
+// fakeNft records the scripts which are passed to nft and replies to listings
+// with the configured output.
+type fakeNft struct {
+	listing string
+	scripts []string
+}
+
+func (fake *fakeNft) run(_ context.Context, stdin string, args ...string) (string, error) {
+	if len(args) > 0 && args[0] == "-a" {
+		if fake.listing == "" {
+			return "", errors.New("Error: No such file or directory")
+		}
+		return fake.listing, nil
+	}
+
+	fake.scripts = append(fake.scripts, stdin)
+	return "", nil
+}
+
+func TestNftFirewallPublish(t *testing.T) {
+	fake := &fakeNft{listing: testListing}
+	sysctls := map[string]string{}
+	fw := &nftFirewall{
+		run: fake.run,
+		sysctl: func(name, value string) error {
+			sysctls[name] = value
+			return nil
+		},
+	}
+
+	err := fw.Publish(context.Background(), "net:a", []PortRule{
+		{Bridge: "kraft0", HostPort: 8081, DestIP: "10.0.0.2", DestPort: 80},
+	})
+	if err != nil {
+		t.Fatal("Failed to publish:", err)
+	}
+
+	if len(fake.scripts) != 1 {
+		t.Fatalf("Expected ports to be published in a single transaction, got %d", len(fake.scripts))
+	}
+
+	script := fake.scripts[0]
+	for _, line := range []string{
+		"delete rule ip kraftkit prerouting handle 4\n",
+		"delete rule ip kraftkit output handle 6\n",
+		"delete rule ip kraftkit forward handle 8\n",
+		"tcp dport 8081 dnat to 10.0.0.2:80",
+	} {
+		if !strings.Contains(script, line) {
+			t.Errorf("Expected script to contain %q, got:\n%s", line, script)
+		}
+	}
+
+	if strings.Contains(script, "handle 5") {
+		t.Errorf("Expected rules of other interfaces to be kept, got:\n%s", script)
+	}
+
+	if sysctls["net.ipv4.conf.kraft0.route_localnet"] != "1" {
+		t.Errorf("Expected routing of loopback traffic to be enabled, got %v", sysctls)
+	}
+}
+
+func TestNftFirewallUnpublish(t *testing.T) {
+	fake := &fakeNft{}
+	fw := &nftFirewall{run: fake.run}
+
+	if err := fw.Unpublish(context.Background(), "net:a"); err != nil {
+		t.Fatal("Failed to unpublish without table:", err)
+	}
+	if len(fake.scripts) != 0 {
+		t.Errorf("Expected nothing to be removed without table, got %v", fake.scripts)
+	}
+
+	fake.listing = testListing
+	if err := fw.Unpublish(context.Background(), "net:b"); err != nil {
+		t.Fatal("Failed to unpublish:", err)
+	}
+	if expect := "delete rule ip kraftkit prerouting handle 5\n"; len(fake.scripts) != 1 || fake.scripts[0] != expect {
+		t.Errorf("Unexpected script. Expected %q, got %v", expect, fake.scripts)
+	}
+}
+
+const testInetListing = `table inet kraftkit { # handle 9
+	set bridges { # handle 1
+		type ifname
+		elements = { "kraft0",
+			     "kraft1" }
+	}
+
+	chain forward { # handle 2
+		type filter hook forward priority filter; policy accept;
+		iifname "kraft0" oifname != "kraft0" oifname @bridges drop comment "kraftkit:network:kraft0" # handle 4
+		iifname "kraft1" oifname != "kraft1" oifname @bridges drop comment "kraftkit:network:kraft1" # handle 5
+	}
+
+	chain postrouting { # handle 3
+		type nat hook postrouting priority srcnat; policy accept;
+		ip saddr 172.100.0.0/24 oifname != "kraft0" masquerade comment "kraftkit:network:kraft0" # handle 6
+	}
+}
+`
+
+func TestNftNetworkRuleset(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   NetworkRule
+		expect []string
+		reject []string
+		err    bool
+	}{
+		{
+			name: "masquerade",
+			rule: NetworkRule{Bridge: "kraft0", Subnets: []string{"172.100.0.0/24", "fd00:100::/64"}, Masquerade: true},
+			expect: []string{
+				`add element inet kraftkit bridges { "kraft0" }`,
+				`add rule inet kraftkit forward iifname "kraft0" oifname != "kraft0" oifname @bridges drop comment "kraftkit:network:kraft0"`,
+				`add rule inet kraftkit postrouting ip saddr 172.100.0.0/24 oifname != "kraft0" masquerade comment "kraftkit:network:kraft0"`,
+				`add rule inet kraftkit postrouting ip6 saddr fd00:100::/64 oifname != "kraft0" masquerade comment "kraftkit:network:kraft0"`,
+			},
+		},
+		{
+			name: "no masquerade",
+			rule: NetworkRule{Bridge: "kraft0", Subnets: []string{"172.100.0.0/24"}},
+			expect: []string{
+				`add rule inet kraftkit forward iifname "kraft0" oifname != "kraft0" oifname @bridges drop comment "kraftkit:network:kraft0"`,
+			},
+			reject: []string{"masquerade"},
+		},
+		{
+			name: "internal",
+			rule: NetworkRule{Bridge: "kraft0", Subnets: []string{"172.100.0.0/24"}, Masquerade: true, Internal: true},
+			expect: []string{
+				`add rule inet kraftkit forward iifname "kraft0" oifname != "kraft0" drop comment "kraftkit:network:kraft0"`,
+				`add rule inet kraftkit forward oifname "kraft0" iifname != "kraft0" drop comment "kraftkit:network:kraft0"`,
+			},
+			reject: []string{"masquerade"},
+		},
+		{
+			name: "invalid subnet",
+			rule: NetworkRule{Bridge: "kraft0", Subnets: []string{"172.100.0.0"}, Masquerade: true},
+			err:  true,
+		},
+		{
+			name: "no bridge",
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := NftNetworkRuleset(tt.rule)
+			if tt.err {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			for _, line := range tt.expect {
+				if !strings.Contains(script, line+"\n") {
+					t.Errorf("Expected script to contain %q, got:\n%s", line, script)
+				}
+			}
+			for _, s := range tt.reject {
+				if strings.Contains(script, s) {
+					t.Errorf("Expected script not to contain %q, got:\n%s", s, script)
+				}
+			}
+		})
+	}
+}
+
+func TestNftFirewallConfigure(t *testing.T) {
+	fake := &fakeNft{listing: testInetListing}
+	sysctls := map[string]string{}
+	fw := &nftFirewall{
+		run: fake.run,
+		sysctl: func(name, value string) error {
+			sysctls[name] = value
+			return nil
+		},
+	}
+
+	if err := fw.Configure(context.Background(), NetworkRule{
+		Bridge:     "kraft0",
+		Subnets:    []string{"172.100.0.0/24", "fd00:100::/64"},
+		Masquerade: true,
+	}); err != nil {
+		t.Fatal("Failed to configure network:", err)
+	}
+
+	if len(fake.scripts) != 1 {
+		t.Fatalf("Expected network to be configured in a single transaction, got %d", len(fake.scripts))
+	}
+
+	script := fake.scripts[0]
+	for _, line := range []string{
+		"delete rule inet kraftkit forward handle 4\n",
+		"delete rule inet kraftkit postrouting handle 6\n",
+		"ip6 saddr fd00:100::/64",
+	} {
+		if !strings.Contains(script, line) {
+			t.Errorf("Expected script to contain %q, got:\n%s", line, script)
+		}
+	}
+	if strings.Contains(script, "handle 5") {
+		t.Errorf("Expected rules of other networks to be kept, got:\n%s", script)
+	}
+
+	for _, name := range []string{"net.ipv4.ip_forward", "net.ipv6.conf.all.forwarding"} {
+		if sysctls[name] != "1" {
+			t.Errorf("Expected %s to be enabled, got %v", name, sysctls)
+		}
+	}
+}
+
+func TestNftFirewallDeconfigure(t *testing.T) {
+	fake := &fakeNft{}
+	fw := &nftFirewall{run: fake.run}
+
+	if err := fw.Deconfigure(context.Background(), "kraft0"); err != nil {
+		t.Fatal("Failed to deconfigure without table:", err)
+	}
+	if len(fake.scripts) != 0 {
+		t.Errorf("Expected nothing to be removed without table, got %v", fake.scripts)
+	}
+
+	fake.listing = testInetListing
+	if err := fw.Deconfigure(context.Background(), "kraft1"); err != nil {
+		t.Fatal("Failed to deconfigure:", err)
+	}
+
+	expect := "delete rule inet kraftkit forward handle 5\n" +
+		"delete element inet kraftkit bridges { \"kraft1\" }\n"
+	if len(fake.scripts) != 1 || fake.scripts[0] != expect {
+		t.Errorf("Unexpected script. Expected %q, got %v", expect, fake.scripts)
+	}
+
+	fake.scripts = nil
+	if err := fw.Deconfigure(context.Background(), "kraft2"); err != nil {
+		t.Fatal("Failed to deconfigure unknown network:", err)
+	}
+	if len(fake.scripts) != 0 {
+		t.Errorf("Expected nothing to be removed for unknown network, got %v", fake.scripts)
+	}
+}
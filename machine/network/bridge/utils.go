@@ -1,16 +1,18 @@
 package bridge
 
 import (
-	"context"
 	"encoding/binary"
 	"fmt"
 	"math/big"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/erikh/ping"
 	"github.com/vishvananda/netlink"
-	"kraftkit.sh/internal/set"
+	"github.com/vishvananda/netlink/nl"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
 )
 
 // IpToBigInt converts a 4 bytes IP into a 128 bit integer.
@@ -49,17 +51,18 @@ func IsUnicastIP(ip net.IP, mask net.IPMask) bool {
 	return ip.IsGlobalUnicast()
 }
 
-// BridgeIPs returns all the IPs attached to the provided bridge
-func BridgeIPs(bridge *netlink.Bridge) ([]string, error) {
+// BridgeIPs returns all the IPs of the provided address family attached to the
+// provided bridge
+func BridgeIPs(bridge *netlink.Bridge, family int) ([]string, error) {
 	// get the neighbors
 	var (
 		list []netlink.Neigh
 		err  error
 	)
 
-	list, err = netlink.NeighList(bridge.Index, netlink.FAMILY_V4)
+	list, err = netlink.NeighList(bridge.Index, family)
 	if err != nil {
-		return nil, fmt.Errorf("cannot retrieve IPv4 neighbor information for interface %s: %v", bridge.Name, err)
+		return nil, fmt.Errorf("cannot retrieve neighbor information for interface %s: %v", bridge.Name, err)
 	}
 
 	ips := make([]string, len(list))
@@ -70,66 +73,148 @@ func BridgeIPs(bridge *netlink.Bridge) ([]string, error) {
 	return ips, nil
 }
 
-// For a given IP network, bridge (and its interface), allocate a free IP
-// address.
-func AllocateIP(ctx context.Context, ipnet *net.IPNet, iface *net.Interface, bridge *netlink.Bridge) (net.IP, error) {
-	bridgeAddrs, err := iface.Addrs()
+// inUse returns a probe which determines whether an address of the provided
+// family is in use by a host attached to the provided bridge which is not
+// known to the IPAM.
+func inUse(bridge *netlink.Bridge, family int) (func(net.IP) bool, error) {
+	neighbours, err := BridgeIPs(bridge, family)
 	if err != nil {
 		return nil, err
 	}
 
-	allocatedIps, err := BridgeIPs(bridge)
+	allocated := neighbourIPs(neighbours)
+
+	return func(ip net.IP) bool {
+		if allocated[ip.String()] {
+			return true
+		}
+
+		// Use ICMP to check if the IP is in use as a final sanity check.
+		return ping.Ping(&net.IPAddr{IP: ip, Zone: ""}, 150*time.Millisecond)
+	}, nil
+}
+
+// neighbourIPs returns the set of addresses of the provided neighbours, which
+// are represented as "IP MAC" by BridgeIPs.
+func neighbourIPs(neighbours []string) map[string]bool {
+	ips := make(map[string]bool, len(neighbours))
+	for _, neighbour := range neighbours {
+		if fields := strings.Fields(neighbour); len(fields) > 0 {
+			ips[fields[0]] = true
+		}
+	}
+
+	return ips
+}
+
+// parseGateway returns the gateway address and the network mask of the
+// provided address family, or nil if neither is set.
+func parseGateway(gateway, netmask string, ipv6 bool) (*net.IPNet, error) {
+	if gateway == "" && netmask == "" {
+		return nil, nil
+	}
+
+	ip := net.ParseIP(gateway)
+	if ip == nil || (ip.To4() == nil) != ipv6 {
+		return nil, fmt.Errorf("invalid gateway address: %q", gateway)
+	}
+
+	mask := net.ParseIP(netmask)
+	if mask == nil || (mask.To4() == nil) != ipv6 {
+		return nil, fmt.Errorf("invalid netmask: %q", netmask)
+	}
+
+	addr := &net.IPNet{
+		IP:   ip.To16(),
+		Mask: net.IPMask(mask.To16()),
+	}
+	if !ipv6 {
+		addr.IP = ip.To4()
+		addr.Mask = net.IPMask(mask.To4())
+	}
+
+	if ones, bits := addr.Mask.Size(); ones == 0 && bits == 0 {
+		return nil, fmt.Errorf("invalid netmask: %q", netmask)
+	}
+
+	return addr, nil
+}
+
+// gateways returns the IPv4 and IPv6 gateway addresses of the network together
+// with their network masks.  Either is nil when the network has no address of
+// the respective family, but at least one must be set.
+func gateways(network *networkv1alpha1.Network) (*net.IPNet, *net.IPNet, error) {
+	gateway4, err := parseGateway(network.Spec.Gateway, network.Spec.Netmask, false)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	gateway6, err := parseGateway(network.Spec.Gateway6, network.Spec.Netmask6, true)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	allocatedSet := set.NewStringSet(allocatedIps...)
-	ip := ipnet.IP
+	if gateway4 == nil && gateway6 == nil {
+		return nil, nil, fmt.Errorf("gateway cannot be empty")
+	}
 
-search:
-	for {
-		ip = IncreaseIP(ip)
+	return gateway4, gateway6, nil
+}
 
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("context cancelled")
-		default:
-		}
+// subnetOf returns the subnet of the provided gateway address.
+func subnetOf(gateway *net.IPNet) *net.IPNet {
+	return &net.IPNet{
+		IP:   gateway.IP.Mask(gateway.Mask),
+		Mask: gateway.Mask,
+	}
+}
 
+// bridgeAddrs returns the IPv4 and IPv6 addresses of the provided bridge.
+// Link-local IPv6 addresses, which are assigned automatically, are ignored.
+func bridgeAddrs(link netlink.Link) (addr4, addr6 *netlink.Addr, err error) {
+	addrs, err := netlink.AddrList(link, nl.FAMILY_ALL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, addr := range addrs {
 		switch {
-		// If the IP is not within the provided network, it is not possible to
-		// increment the IP so return with an error.
-		case !ipnet.Contains(ip):
-			return nil, fmt.Errorf("could not allocate IP address in %v", ipnet.String())
-
-		// Skip the Bridge IP.
-		case func() bool {
-			for _, addr := range bridgeAddrs {
-				itfIP, _, _ := net.ParseCIDR(addr.String())
-				if ip.Equal(itfIP) {
-					return true
-				}
+		case addr.IP.To4() != nil:
+			if addr4 == nil {
+				addr4 = &addrs[i]
+			}
+		case !addr.IP.IsLinkLocalUnicast():
+			if addr6 == nil {
+				addr6 = &addrs[i]
 			}
-			return false
-		}():
-			continue
+		}
+	}
 
-		// Skip the broadcast IP address.
-		case !IsUnicastIP(ip, ipnet.Mask):
-			continue
+	return addr4, addr6, nil
+}
 
-		// Skip allocated IP addresses.
-		case allocatedSet.Contains(ip.String()):
-			continue
+// setGateways sets the gateway addresses and network masks of the network spec
+// from the provided addresses of its bridge.
+func setGateways(spec *networkv1alpha1.NetworkSpec, addr4, addr6 *netlink.Addr) {
+	spec.Gateway, spec.Netmask = "", ""
+	if addr4 != nil {
+		spec.Gateway = addr4.IP.String()
+		spec.Netmask = net.IP(addr4.Mask).String()
+	}
 
-		// Use ICMP to check if the IP is in use as a final sanity check.
-		case ping.Ping(&net.IPAddr{IP: ip, Zone: ""}, 150*time.Millisecond):
-			continue
+	spec.Gateway6, spec.Netmask6 = "", ""
+	if addr6 != nil {
+		spec.Gateway6 = addr6.IP.String()
+		spec.Netmask6 = net.IP(addr6.Mask).String()
+	}
+}
 
-		default:
-			break search
-		}
+// ifaceAddr returns the address of the interface which is used to check
+// whether it is still in use, preferring its IPv4 address.
+func ifaceAddr(iface networkv1alpha1.NetworkInterfaceTemplateSpec) net.IP {
+	if ip := net.ParseIP(iface.Spec.IP); ip != nil {
+		return ip
 	}
 
-	return ip, nil
+	return net.ParseIP(iface.Spec.IP6)
 }
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"net"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+func TestGateways(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    networkv1alpha1.NetworkSpec
+		expect4 string
+		expect6 string
+		err     bool
+	}{
+		{
+			name:    "ipv4",
+			spec:    networkv1alpha1.NetworkSpec{Gateway: "172.100.0.1", Netmask: "255.255.0.0"},
+			expect4: "172.100.0.1/16",
+		},
+		{
+			name:    "ipv6",
+			spec:    networkv1alpha1.NetworkSpec{Gateway6: "fd00:100::1", Netmask6: "ffff:ffff:ffff:ffff::"},
+			expect6: "fd00:100::1/64",
+		},
+		{
+			name: "dual-stack",
+			spec: networkv1alpha1.NetworkSpec{
+				Gateway:  "172.100.0.1",
+				Netmask:  "255.255.255.0",
+				Gateway6: "fd00:100::1",
+				Netmask6: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:0",
+			},
+			expect4: "172.100.0.1/24",
+			expect6: "fd00:100::1/112",
+		},
+		{
+			name: "no gateway",
+			err:  true,
+		},
+		{
+			name: "ipv6 gateway as ipv4",
+			spec: networkv1alpha1.NetworkSpec{Gateway: "fd00:100::1", Netmask: "255.255.255.0"},
+			err:  true,
+		},
+		{
+			name: "non-canonical netmask",
+			spec: networkv1alpha1.NetworkSpec{Gateway: "172.100.0.1", Netmask: "255.0.255.0"},
+			err:  true,
+		},
+		{
+			name: "missing netmask",
+			spec: networkv1alpha1.NetworkSpec{Gateway6: "fd00:100::1"},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway4, gateway6, err := gateways(&networkv1alpha1.Network{Spec: tt.spec})
+			if tt.err {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			if got := gateway4.String(); (gateway4 != nil || tt.expect4 != "") && tt.expect4 != got {
+				t.Errorf("Unexpected IPv4 gateway. Expected %s, got %s", tt.expect4, got)
+			}
+			if got := gateway6.String(); (gateway6 != nil || tt.expect6 != "") && tt.expect6 != got {
+				t.Errorf("Unexpected IPv6 gateway. Expected %s, got %s", tt.expect6, got)
+			}
+		})
+	}
+}
+
+func TestNeighbourIPs(t *testing.T) {
+	mac, _ := net.ParseMAC("02:b0:b0:00:00:01")
+
+	neighbours := []string{
+		(&netlink.Neigh{IP: net.ParseIP("172.100.0.2"), HardwareAddr: mac}).String(),
+		(&netlink.Neigh{IP: net.ParseIP("fd00:100::2"), HardwareAddr: mac}).String(),
+		"",
+	}
+
+	ips := neighbourIPs(neighbours)
+
+	for _, ip := range []string{"172.100.0.2", "fd00:100::2"} {
+		if !ips[net.ParseIP(ip).String()] {
+			t.Errorf("Expected %s to be in use, got %v", ip, ips)
+		}
+	}
+
+	if ips["172.100.0.3"] {
+		t.Errorf("Expected 172.100.0.3 not to be in use, got %v", ips)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 	"unicode"
 
@@ -20,13 +21,94 @@ import (
 	"k8s.io/apimachinery/pkg/util/uuid"
 
 	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network/ipam"
 	"kraftkit.sh/machine/network/macaddr"
 )
 
-type v1alpha1Network struct{}
+// WatchInterval is the interval at which the statistics of a watched network
+// are refreshed in the absence of link updates.
+const WatchInterval = time.Second
+
+type v1alpha1Network struct {
+	firewall Firewall
+	ipam     *ipam.IPAM
+}
 
 func NewNetworkServiceV1alpha1(ctx context.Context, opts ...any) (networkv1alpha1.NetworkService, error) {
-	return &v1alpha1Network{}, nil
+	service := v1alpha1Network{
+		firewall: NewNftFirewall(),
+	}
+
+	for _, opt := range opts {
+		bopt, ok := opt.(NetworkServiceV1alpha1Option)
+		if !ok {
+			panic("cannot apply non-NetworkServiceV1alpha1Option type methods")
+		}
+
+		if err := bopt(&service); err != nil {
+			return nil, err
+		}
+	}
+
+	if service.ipam == nil {
+		var err error
+		service.ipam, err = ipam.NewDefaultIPAM(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &service, nil
+}
+
+// interfaceAlias returns the unique combination of the network and the
+// interface which is used to reference the interface's tap link and its
+// published ports.
+func interfaceAlias(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec) string {
+	return fmt.Sprintf("%s:%s", network.ObjectMeta.UID, iface.ObjectMeta.UID)
+}
+
+// networkRule returns the rule which controls the traffic of the provided
+// network.
+func networkRule(network *networkv1alpha1.Network) (NetworkRule, error) {
+	gateway4, gateway6, err := gateways(network)
+	if err != nil {
+		return NetworkRule{}, err
+	}
+
+	rule := NetworkRule{
+		Bridge:     network.Name,
+		Masquerade: !network.Spec.DisableNAT,
+		Internal:   network.Spec.Internal,
+	}
+
+	for _, gateway := range []*net.IPNet{gateway4, gateway6} {
+		if gateway != nil {
+			rule.Subnets = append(rule.Subnets, subnetOf(gateway).String())
+		}
+	}
+
+	return rule, nil
+}
+
+// portRules converts the ports of the provided interface to the rules which
+// publish them on the host.
+func portRules(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec) []PortRule {
+	rules := make([]PortRule, len(iface.Spec.Ports))
+
+	for i, port := range iface.Spec.Ports {
+		rules[i] = PortRule{
+			Bridge:   network.Name,
+			HostIP:   port.HostIP,
+			HostPort: port.HostPort,
+			DestIP:   iface.Spec.IP,
+			DestPort: port.InterfacePort,
+			Protocol: string(port.Protocol),
+		}
+	}
+
+	return rules
 }
 
 // Create implements kraftkit.sh/api/network/v1alpha1.Create
@@ -47,11 +129,9 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 	network.Status.State = networkv1alpha1.NetworkStateUnknown
 
 	// Validate the options.
-	if len(network.Spec.Gateway) == 0 {
-		return network, fmt.Errorf("gateway cannot be empty")
-	}
-	if len(network.Spec.Netmask) == 0 {
-		return network, fmt.Errorf("netmask cannot be empty")
+	gateway4, gateway6, err := gateways(network)
+	if err != nil {
+		return network, err
 	}
 
 	bridge := &netlink.Bridge{
@@ -60,7 +140,7 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 
 	bridge.LinkAttrs.MTU = DefaultMTU
 
-	_, err := net.InterfaceByName(network.Name)
+	_, err = net.InterfaceByName(network.Name)
 	if err == nil {
 		// Bridge already exists, return early.
 		return network, fmt.Errorf("network already exists: %s", network.Name)
@@ -79,15 +159,21 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 
 	// br.Promisc = 1 // TODO(nderjung): Should the bridge be promiscuous?
 
-	// Setup IP address for bridge.
-	addr := &netlink.Addr{
-		IPNet: &net.IPNet{
-			IP:   net.ParseIP(network.Spec.Gateway),
-			Mask: net.ParseIP(network.Spec.Netmask).DefaultMask(),
-		},
+	// Setup IP addresses for bridge.
+	var addrs []*netlink.Addr
+	if gateway4 != nil {
+		addrs = append(addrs, &netlink.Addr{IPNet: gateway4})
 	}
-	if err := netlink.AddrAdd(br, addr); err != nil {
-		return network, fmt.Errorf("adding address %s to bridge %s failed: %v", addr.String(), network.Name, err)
+	if gateway6 != nil {
+		// Skip duplicate address detection such that the gateway is immediately
+		// usable.
+		addrs = append(addrs, &netlink.Addr{IPNet: gateway6, Flags: syscall.IFA_F_NODAD})
+	}
+
+	for _, addr := range addrs {
+		if err := netlink.AddrAdd(br, addr); err != nil {
+			return network, fmt.Errorf("adding address %s to bridge %s failed: %v", addr.String(), network.Name, err)
+		}
 	}
 
 	// Bring the bridge up.
@@ -113,6 +199,19 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 		network.Status.State = networkv1alpha1.NetworkStateDown
 	}
 
+	rule, err := networkRule(network)
+	if err != nil {
+		return network, err
+	}
+
+	if err := service.firewall.Configure(ctx, rule); err != nil {
+		return network, fmt.Errorf("could not configure firewall of %s: %v", network.Name, err)
+	}
+
+	if err := service.startDaemons(ctx, network); err != nil {
+		return network, err
+	}
+
 	// Add any interfaces
 	for i, iface := range network.Spec.Interfaces {
 		if iface.Spec.IfName == "" {
@@ -143,7 +242,7 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 			return network, err
 		}
 
-		if err := netlink.LinkSetAlias(tap, fmt.Sprintf("%s:%s", network.ObjectMeta.UID, iface.ObjectMeta.UID)); err != nil {
+		if err := netlink.LinkSetAlias(tap, interfaceAlias(network, iface)); err != nil {
 			return network, err
 		}
 
@@ -178,6 +277,29 @@ func (service *v1alpha1Network) Start(ctx context.Context, network *networkv1alp
 		return network, fmt.Errorf("could not bring %s link up: %v", network.Name, err)
 	}
 
+	// Determine the subnets of the network from the bridge if they are unknown.
+	if network.Spec.Gateway == "" && network.Spec.Gateway6 == "" {
+		addr4, addr6, err := bridgeAddrs(link)
+		if err != nil {
+			return network, err
+		}
+
+		setGateways(&network.Spec, addr4, addr6)
+	}
+
+	rule, err := networkRule(network)
+	if err != nil {
+		return network, err
+	}
+
+	if err := service.firewall.Configure(ctx, rule); err != nil {
+		return network, fmt.Errorf("could not configure firewall of %s: %v", network.Name, err)
+	}
+
+	if err := service.startDaemons(ctx, network); err != nil {
+		return network, err
+	}
+
 	network.Status.State = networkv1alpha1.NetworkStateUp
 
 	return network, nil
@@ -192,8 +314,8 @@ func (service *v1alpha1Network) Stop(ctx context.Context, network *networkv1alph
 			return network, fmt.Errorf("getting link %s failed: %v", iface.Spec.IfName, err)
 		}
 
-		if ping.Ping(&net.IPAddr{IP: net.ParseIP(iface.Spec.IP), Zone: ""}, 150*time.Millisecond) {
-			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, iface.Spec.IP)
+		if ping.Ping(&net.IPAddr{IP: ifaceAddr(iface), Zone: ""}, 150*time.Millisecond) {
+			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, ifaceAddr(iface))
 		}
 
 		if err := netlink.LinkSetDown(link); err != nil {
@@ -207,6 +329,14 @@ func (service *v1alpha1Network) Stop(ctx context.Context, network *networkv1alph
 		return network, fmt.Errorf("getting bridge %s failed: %v", network.Name, err)
 	}
 
+	if err := service.stopDaemons(ctx, network); err != nil {
+		return network, err
+	}
+
+	if err := service.firewall.Deconfigure(ctx, network.Name); err != nil {
+		return network, fmt.Errorf("could not deconfigure firewall of %s: %v", network.Name, err)
+	}
+
 	// Bring down the bridge link
 	if err := netlink.LinkSetDown(link); err != nil {
 		return network, fmt.Errorf("could not bring %s bridge down: %v", network.Name, err)
@@ -230,14 +360,16 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 		return network, fmt.Errorf("network link is not bridge")
 	}
 
-	bridgeface, err := net.InterfaceByName(bridge.Name)
+	gateway4, _, err := gateways(network)
 	if err != nil {
-		return nil, fmt.Errorf("could not get bridge interface: %v", err)
+		return network, err
 	}
 
-	ipnet := &net.IPNet{
-		IP:   net.ParseIP(network.Spec.Gateway),
-		Mask: net.ParseIP(network.Spec.Netmask).DefaultMask(),
+	var probe4 func(net.IP) bool
+	if gateway4 != nil {
+		if probe4, err = inUse(bridge, nl.FAMILY_V4); err != nil {
+			return network, err
+		}
 	}
 
 	// Start MAC addresses iteratively.
@@ -279,15 +411,30 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 			iface.Spec.MacAddress = mac.String()
 		}
 
-		if iface.Spec.IP == "" {
-			ip, err := AllocateIP(ctx, ipnet, bridgeface, bridge)
+		// Allocate an address for the interface, or record the one which has been
+		// requested, such that it is not handed out to another interface.
+		if gateway4 != nil {
+			ip, err := service.ipam.Allocate(ctx, network.Name, subnetOf(gateway4), []net.IP{gateway4.IP}, ipam.Request{
+				ID:    string(iface.ObjectMeta.UID),
+				MAC:   iface.Spec.MacAddress,
+				Name:  iface.ObjectMeta.Name,
+				IP:    iface.Spec.IP,
+				InUse: probe4,
+			})
 			if err != nil {
 				return network, fmt.Errorf("could not allocate interface IP for %s: %v", iface.Spec.IfName, err)
 			}
 
 			iface.Spec.IP = ip.String()
+		} else if iface.Spec.IP != "" {
+			return network, fmt.Errorf("cannot assign IPv4 address to %s: network %s has no IPv4 subnet", iface.Spec.IfName, network.Name)
 		}
 
+		// Guests are only addressed over IPv4: Unikraft's netdev parameters cannot
+		// configure IPv6 addresses and the network serves neither DHCPv6 nor router
+		// advertisements, so no IPv6 address is allocated to the interface.
+		iface.Spec.IP6 = ""
+
 		tap := &netlink.Tuntap{
 			LinkAttrs: netlink.NewLinkAttrs(),
 			Mode:      netlink.TUNTAP_MODE_TAP,
@@ -313,7 +460,7 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 
 		// Set the alias such that it can be referenced later as the unique
 		// combination of the network and this interface.
-		alias := fmt.Sprintf("%s:%s", network.ObjectMeta.UID, iface.ObjectMeta.UID)
+		alias := interfaceAlias(network, iface)
 		if err := netlink.LinkSetAlias(tap, alias); err != nil {
 			return network, fmt.Errorf("could not set link alias: %v", err)
 		}
@@ -322,6 +469,16 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 			return network, fmt.Errorf("could not bring %s link up: %v", iface.Spec.IfName, err)
 		}
 
+		// Publish any ports to the allocated IP address of the interface.  Ports
+		// are only published over IPv4.
+		if len(iface.Spec.Ports) > 0 && iface.Spec.IP == "" {
+			return network, fmt.Errorf("cannot publish ports of %s without IPv4 address", iface.Spec.IfName)
+		} else if len(iface.Spec.Ports) > 0 {
+			if err := service.firewall.Publish(ctx, alias, portRules(network, iface)); err != nil {
+				return network, fmt.Errorf("could not publish ports of %s: %v", iface.Spec.IfName, err)
+			}
+		}
+
 		inuse[alias] = true
 		network.Spec.Interfaces[i] = iface
 	}
@@ -342,6 +499,21 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 			continue // Skip in-use interfaces
 		}
 
+		// Only remove interfaces which belong to this network.
+		if !strings.HasPrefix(tap.Alias, string(network.ObjectMeta.UID)+":") {
+			continue
+		}
+
+		if err := service.firewall.Unpublish(ctx, tap.Alias); err != nil {
+			return network, fmt.Errorf("could not unpublish ports of %s: %v", tap.Name, err)
+		}
+
+		// Release the address of the interface.
+		ifaceUID := strings.TrimPrefix(tap.Alias, string(network.ObjectMeta.UID)+":")
+		if err := service.ipam.Release(ctx, network.Name, ifaceUID); err != nil {
+			return network, fmt.Errorf("could not release address of %s: %v", tap.Name, err)
+		}
+
 		if err = netlink.LinkSetDown(tap); err != nil {
 			return network, fmt.Errorf("could not bring %s link down: %v", tap.Name, err)
 		}
@@ -364,8 +536,13 @@ func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1al
 			return network, fmt.Errorf("could not get %s link: %v", iface.Spec.IfName, err)
 		}
 
-		if ping.Ping(&net.IPAddr{IP: net.ParseIP(iface.Spec.IP), Zone: ""}, 150*time.Millisecond) {
-			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, iface.Spec.IP)
+		if ping.Ping(&net.IPAddr{IP: ifaceAddr(iface), Zone: ""}, 150*time.Millisecond) {
+			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, ifaceAddr(iface))
+		}
+
+		// Remove any published ports.
+		if err := service.firewall.Unpublish(ctx, interfaceAlias(network, iface)); err != nil {
+			return network, fmt.Errorf("could not unpublish ports of %s: %v", iface.Spec.IfName, err)
 		}
 
 		// Bring down the bridge link
@@ -379,6 +556,14 @@ func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1al
 		}
 	}
 
+	if err := service.stopDaemons(ctx, network); err != nil {
+		return network, err
+	}
+
+	if err := service.firewall.Deconfigure(ctx, network.Name); err != nil {
+		return network, fmt.Errorf("could not deconfigure firewall of %s: %v", network.Name, err)
+	}
+
 	// Get the bridge link.
 	link, err := netlink.LinkByName(network.Name)
 	if err != nil {
@@ -395,6 +580,11 @@ func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1al
 		return network, fmt.Errorf("could not delete %s link: %v", network.Name, err)
 	}
 
+	// Forget all allocations and reservations of the network.
+	if err := service.ipam.Purge(ctx, network.Name); err != nil {
+		return network, fmt.Errorf("could not release addresses of %s: %v", network.Name, err)
+	}
+
 	return nil, nil
 }
 
@@ -425,6 +615,54 @@ func mapBridgeStatistics(network *networkv1alpha1.Network, bridge *netlink.Bridg
 	network.Status.TxWindowErrors = bridge.Statistics.TxWindowErrors
 }
 
+// mapInterfaceStatistics embeds the state and statistics of the links which
+// are enslaved to the provided bridge, i.e. the tap devices of the attached
+// machines, to the provided network's status.
+func mapInterfaceStatistics(network *networkv1alpha1.Network, bridge *netlink.Bridge) error {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return fmt.Errorf("could not gather list of existing links: %v", err)
+	}
+
+	network.Status.Interfaces = nil
+
+	for _, link := range links {
+		attrs := link.Attrs()
+		if attrs.MasterIndex != bridge.Index {
+			continue
+		}
+
+		status := networkv1alpha1.NetworkInterfaceStatus{
+			State:  networkv1alpha1.NetworkInterfaceStateDisconnected,
+			IfName: attrs.Name,
+		}
+
+		// The carrier of a tap device is only up whilst a machine holds it open.
+		if attrs.OperState == netlink.OperUp {
+			status.State = networkv1alpha1.NetworkInterfaceStateConnected
+		}
+
+		if attrs.Statistics != nil {
+			status.RxBytes = attrs.Statistics.RxBytes
+			status.RxDropped = attrs.Statistics.RxDropped
+			status.RxErrors = attrs.Statistics.RxErrors
+			status.RxPackets = attrs.Statistics.RxPackets
+			status.TxBytes = attrs.Statistics.TxBytes
+			status.TxDropped = attrs.Statistics.TxDropped
+			status.TxErrors = attrs.Statistics.TxErrors
+			status.TxPackets = attrs.Statistics.TxPackets
+		}
+
+		network.Status.Interfaces = append(network.Status.Interfaces, status)
+	}
+
+	sort.Slice(network.Status.Interfaces, func(i, j int) bool {
+		return network.Status.Interfaces[i].IfName < network.Status.Interfaces[j].IfName
+	})
+
+	return nil
+}
+
 // Get implements kraftkit.sh/api/network/v1alpha1.Get
 func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
 	link, err := netlink.LinkByName(network.Name)
@@ -441,14 +679,13 @@ func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha
 		return network, fmt.Errorf("network link is not bridge")
 	}
 
-	addrs, err := netlink.AddrList(bridge, nl.FAMILY_V4)
+	addr4, addr6, err := bridgeAddrs(bridge)
 	if err != nil {
 		return network, err
 	}
 
 	network.Spec.Driver = "bridge"
-	network.Spec.Gateway = addrs[0].IP.String()
-	network.Spec.Netmask = net.IP(addrs[0].Mask).String()
+	setGateways(&network.Spec, addr4, addr6)
 
 	// Use the internal network bridge networking system to determine
 	// whether the identified network is online.
@@ -463,6 +700,10 @@ func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha
 
 	mapBridgeStatistics(network, bridge)
 
+	if err := mapInterfaceStatistics(network, bridge); err != nil {
+		return network, err
+	}
+
 	return network, nil
 }
 
@@ -508,7 +749,7 @@ func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alp
 
 	// Discover new bridges.
 	for _, bridge := range bridges {
-		addrs, err := netlink.AddrList(bridge, nl.FAMILY_V4)
+		addr4, addr6, err := bridgeAddrs(bridge)
 		if err != nil {
 			continue // TODO(nderjung): error groups
 		}
@@ -520,16 +761,13 @@ func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alp
 			},
 		}
 
-		if len(addrs) != 1 {
+		if addr4 == nil && addr6 == nil {
 			network.Status.State = networkv1alpha1.NetworkStateDown
 			networks.Items = append(networks.Items, network)
 			continue // TODO(nderjung): error groups
 		}
 
-		network.Spec = networkv1alpha1.NetworkSpec{
-			Gateway: addrs[0].IP.String(),
-			Netmask: net.IP(addrs[0].Mask).String(),
-		}
+		setGateways(&network.Spec, addr4, addr6)
 
 		// Use the internal network bridge networking system to determine
 		// whether the identified network is online.
@@ -584,6 +822,84 @@ func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alp
 }
 
 // Watch implements kraftkit.sh/api/network/v1alpha1.Watch
-func (service *v1alpha1Network) Watch(context.Context, *networkv1alpha1.Network) (chan *networkv1alpha1.Network, chan error, error) {
-	panic("not implemented: kraftkit.sh/machine/network/bridge.v1alpha1Network.Watch")
+func (service *v1alpha1Network) Watch(ctx context.Context, network *networkv1alpha1.Network) (chan *networkv1alpha1.Network, chan error, error) {
+	link, err := netlink.LinkByName(network.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not get link %s: %v", network.Name, err)
+	}
+
+	index := link.Attrs().Index
+	updates := make(chan netlink.LinkUpdate)
+	done := make(chan struct{})
+
+	if err := netlink.LinkSubscribeWithOptions(updates, done, netlink.LinkSubscribeOptions{
+		ErrorCallback: func(err error) {
+			log.G(ctx).Debugf("could not receive link update: %v", err)
+		},
+	}); err != nil {
+		return nil, nil, fmt.Errorf("could not subscribe to link updates: %v", err)
+	}
+
+	events := make(chan *networkv1alpha1.Network)
+	errs := make(chan error)
+
+	go func() {
+		ticker := time.NewTicker(WatchInterval)
+
+		defer func() {
+			ticker.Stop()
+			close(done)
+
+			// Drain any pending updates such that the subscription can terminate.
+			for range updates {
+			}
+		}()
+
+		for {
+			// Copy the network such that previously sent events are not mutated.
+			current := *network
+			current.Status = networkv1alpha1.NetworkStatus{}
+
+			if _, err := service.Get(ctx, &current); err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			select {
+			case events <- &current:
+			case <-ctx.Done():
+				return
+			}
+
+		wait:
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case <-ticker.C:
+					break wait
+
+				case update, ok := <-updates:
+					if !ok {
+						select {
+						case errs <- fmt.Errorf("link subscription of %s closed", network.Name):
+						case <-ctx.Done():
+						}
+						return
+					}
+
+					// Only changes to the bridge or its enslaved links are relevant.
+					if attrs := update.Attrs(); attrs.Index == index || attrs.MasterIndex == index {
+						break wait
+					}
+				}
+			}
+		}
+	}()
+
+	return events, errs, nil
 }
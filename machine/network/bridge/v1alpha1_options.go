@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import "kraftkit.sh/machine/network/ipam"
+
+// NetworkServiceV1alpha1Option represents an option-method handler for the
+// networkv1alpha1 bridge service.
+type NetworkServiceV1alpha1Option func(*v1alpha1Network) error
+
+// WithFirewall sets the firewall which is used to publish the ports of
+// interfaces attached to the bridge and to masquerade and isolate the traffic
+// of the network.  By default, nftables is used.
+func WithFirewall(firewall Firewall) NetworkServiceV1alpha1Option {
+	return func(service *v1alpha1Network) error {
+		service.firewall = firewall
+		return nil
+	}
+}
+
+// WithIPAM sets the IP address manager which allocates the addresses of
+// interfaces attached to the bridge.  By default, the IPAM located in
+// KraftKit's runtime directory is used.
+func WithIPAM(ipam *ipam.IPAM) NetworkServiceV1alpha1Option {
+	return func(service *v1alpha1Network) error {
+		service.ipam = ipam
+		return nil
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+// CaptureOptions contains the configuration of a capture.
+type CaptureOptions struct {
+	filter  *Filter
+	count   int
+	snaplen int
+}
+
+// CaptureOption is a method which configures a capture.
+type CaptureOption func(*CaptureOptions) error
+
+// WithFilter only captures the frames which match the provided filter
+// expression.
+func WithFilter(expr string) CaptureOption {
+	return func(opts *CaptureOptions) error {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			return err
+		}
+
+		opts.filter = filter
+		return nil
+	}
+}
+
+// WithCount stops the capture after the provided number of frames have been
+// captured.
+func WithCount(count int) CaptureOption {
+	return func(opts *CaptureOptions) error {
+		opts.count = count
+		return nil
+	}
+}
+
+// WithSnapLen sets the maximum number of bytes which are captured of each
+// frame.  Defaults to DefaultSnapLen.
+func WithSnapLen(snaplen int) CaptureOption {
+	return func(opts *CaptureOptions) error {
+		opts.snaplen = snaplen
+		return nil
+	}
+}
+
+// newCaptureOptions applies the provided options over the defaults.
+func newCaptureOptions(copts ...CaptureOption) (*CaptureOptions, error) {
+	opts := &CaptureOptions{
+		snaplen: DefaultSnapLen,
+	}
+
+	for _, o := range copts {
+		if err := o(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,17 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Capture writes the frames of the interface with the provided name to the
+// provided writer in the pcapng format until the context is cancelled.
+func Capture(ctx context.Context, ifname string, w io.Writer, copts ...CaptureOption) (int, error) {
+	return 0, errors.New("packet capture is not supported on MacOS")
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// pollInterval is the interval at which a capture checks whether it has been
+// cancelled whilst no frames are received.
+const pollInterval = 250 * time.Millisecond
+
+// htons converts the provided short from host to network byte order.
+func htons(i uint16) uint16 {
+	return i<<8 | i>>8
+}
+
+// Capture writes the frames of the interface with the provided name to the
+// provided writer in the pcapng format until the context is cancelled or the
+// requested number of frames have been captured.  It returns the number of
+// captured frames.  Frames are received from a raw packet socket, which
+// requires the CAP_NET_RAW capability.
+func Capture(ctx context.Context, ifname string, w io.Writer, copts ...CaptureOption) (int, error) {
+	opts, err := newCaptureOptions(copts...)
+	if err != nil {
+		return 0, err
+	}
+
+	iface, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return 0, fmt.Errorf("could not get interface %s: %v", ifname, err)
+	}
+
+	protocol := htons(syscall.ETH_P_ALL)
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, int(protocol))
+	if err != nil {
+		return 0, fmt.Errorf("could not open packet socket: %v", err)
+	}
+
+	defer syscall.Close(fd)
+
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{
+		Protocol: protocol,
+		Ifindex:  iface.Index,
+	}); err != nil {
+		return 0, fmt.Errorf("could not bind packet socket to %s: %v", ifname, err)
+	}
+
+	// Time out receiving such that cancellation is noticed.
+	tv := syscall.NsecToTimeval(pollInterval.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return 0, fmt.Errorf("could not set receive timeout: %v", err)
+	}
+
+	writer, err := NewWriter(w, ifname, opts.snaplen)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, opts.snaplen)
+	count := 0
+
+	for ctx.Err() == nil && (opts.count <= 0 || count < opts.count) {
+		// With MSG_TRUNC the original length of truncated frames is returned.
+		n, _, err := syscall.Recvfrom(fd, buf, syscall.MSG_TRUNC)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return count, fmt.Errorf("could not receive frame: %v", err)
+		}
+
+		// Frames which are longer than the snapshot length are truncated to the
+		// buffer, whilst their original length is recorded.
+		length := n
+		if n > len(buf) {
+			n = len(buf)
+		}
+
+		frame := buf[:n]
+		if !opts.filter.Match(frame) {
+			continue
+		}
+
+		if err := writer.WritePacket(time.Now(), frame, length); err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, nil
+}
@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	etherTypeIPv4 = 0x0800
+	etherTypeARP  = 0x0806
+	etherTypeVLAN = 0x8100
+	etherTypeIPv6 = 0x86dd
+
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMPv6 = 58
+)
+
+// packet contains the decoded headers of an Ethernet frame which filters can
+// match against.
+type packet struct {
+	etherType uint16
+	protocol  uint8
+	src       net.IP
+	dst       net.IP
+	sport     uint16
+	dport     uint16
+	ports     bool
+}
+
+// decode returns the headers of the provided Ethernet frame.  Only the headers
+// which are present and complete are populated.
+func decode(frame []byte) packet {
+	var p packet
+
+	if len(frame) < 14 {
+		return p
+	}
+
+	p.etherType = binary.BigEndian.Uint16(frame[12:14])
+	payload := frame[14:]
+
+	if p.etherType == etherTypeVLAN && len(payload) >= 4 {
+		p.etherType = binary.BigEndian.Uint16(payload[2:4])
+		payload = payload[4:]
+	}
+
+	var transport []byte
+
+	switch p.etherType {
+	case etherTypeIPv4:
+		if len(payload) < 20 {
+			return p
+		}
+
+		ihl := int(payload[0]&0xf) * 4
+		p.protocol = payload[9]
+		p.src = net.IP(payload[12:16])
+		p.dst = net.IP(payload[16:20])
+
+		// Only the first fragment carries the transport header.
+		if binary.BigEndian.Uint16(payload[6:8])&0x1fff == 0 && len(payload) >= ihl {
+			transport = payload[ihl:]
+		}
+
+	case etherTypeIPv6:
+		if len(payload) < 40 {
+			return p
+		}
+
+		p.protocol = payload[6]
+		p.src = net.IP(payload[8:24])
+		p.dst = net.IP(payload[24:40])
+		transport = payload[40:]
+
+	case etherTypeARP:
+		// Sender and target protocol addresses of IPv4 over Ethernet.
+		if len(payload) >= 28 {
+			p.src = net.IP(payload[14:18])
+			p.dst = net.IP(payload[24:28])
+		}
+	}
+
+	if (p.protocol == protocolTCP || p.protocol == protocolUDP) && len(transport) >= 4 {
+		p.sport = binary.BigEndian.Uint16(transport[0:2])
+		p.dport = binary.BigEndian.Uint16(transport[2:4])
+		p.ports = true
+	}
+
+	return p
+}
+
+// Filter matches Ethernet frames against a simple expression which consists of
+// primitives that are joined by "and" and which are optionally negated by
+// "not".  The supported primitives are:
+//
+//	arp, ip, ip6, icmp, icmp6, tcp, udp
+//	[src|dst] host ADDRESS
+//	[src|dst] port NUMBER
+//
+// For example: "tcp and port 80 and not host 172.100.0.2".
+type Filter struct {
+	primitives []primitive
+}
+
+// primitive matches a single attribute of a packet.
+type primitive struct {
+	negate bool
+	match  func(p *packet) bool
+}
+
+// ParseFilter returns the filter for the provided expression.  An empty
+// expression matches all frames.
+func ParseFilter(expr string) (*Filter, error) {
+	filter := &Filter{}
+	tokens := strings.Fields(strings.ToLower(expr))
+
+	for len(tokens) > 0 {
+		if len(filter.primitives) > 0 {
+			if tokens[0] != "and" {
+				return nil, fmt.Errorf("expected 'and' before '%s'", tokens[0])
+			}
+
+			tokens = tokens[1:]
+		}
+
+		var prim primitive
+		for len(tokens) > 0 && tokens[0] == "not" {
+			prim.negate = !prim.negate
+			tokens = tokens[1:]
+		}
+
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("unexpected end of filter")
+		}
+
+		n, match, err := parsePrimitive(tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		prim.match = match
+		filter.primitives = append(filter.primitives, prim)
+		tokens = tokens[n:]
+	}
+
+	return filter, nil
+}
+
+// parsePrimitive parses the primitive at the start of the provided tokens and
+// returns the number of tokens it consumed.
+func parsePrimitive(tokens []string) (int, func(p *packet) bool, error) {
+	switch tokens[0] {
+	case "arp":
+		return 1, func(p *packet) bool { return p.etherType == etherTypeARP }, nil
+	case "ip":
+		return 1, func(p *packet) bool { return p.etherType == etherTypeIPv4 }, nil
+	case "ip6":
+		return 1, func(p *packet) bool { return p.etherType == etherTypeIPv6 }, nil
+	case "icmp":
+		return 1, func(p *packet) bool { return p.etherType == etherTypeIPv4 && p.protocol == protocolICMP }, nil
+	case "icmp6":
+		return 1, func(p *packet) bool { return p.etherType == etherTypeIPv6 && p.protocol == protocolICMPv6 }, nil
+	case "tcp":
+		return 1, func(p *packet) bool { return p.protocol == protocolTCP }, nil
+	case "udp":
+		return 1, func(p *packet) bool { return p.protocol == protocolUDP }, nil
+	}
+
+	src, dst, n := true, true, 0
+	switch tokens[0] {
+	case "src":
+		dst, n = false, 1
+	case "dst":
+		src, n = false, 1
+	}
+
+	if len(tokens) < n+2 {
+		return 0, nil, fmt.Errorf("unknown or incomplete filter primitive: %s", strings.Join(tokens, " "))
+	}
+
+	switch tokens[n] {
+	case "host":
+		ip := net.ParseIP(tokens[n+1])
+		if ip == nil {
+			return 0, nil, fmt.Errorf("invalid host address: %s", tokens[n+1])
+		}
+
+		return n + 2, func(p *packet) bool {
+			return (src && ip.Equal(p.src)) || (dst && ip.Equal(p.dst))
+		}, nil
+
+	case "port":
+		port, err := strconv.ParseUint(tokens[n+1], 10, 16)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid port: %s", tokens[n+1])
+		}
+
+		return n + 2, func(p *packet) bool {
+			return p.ports && ((src && p.sport == uint16(port)) || (dst && p.dport == uint16(port)))
+		}, nil
+	}
+
+	return 0, nil, fmt.Errorf("unknown filter primitive: %s", tokens[n])
+}
+
+// Match returns whether the provided Ethernet frame matches the filter.
+func (filter *Filter) Match(frame []byte) bool {
+	if filter == nil || len(filter.primitives) == 0 {
+		return true
+	}
+
+	p := decode(frame)
+	for _, prim := range filter.primitives {
+		if prim.match(&p) == prim.negate {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// newFrame returns an Ethernet frame carrying an IPv4 packet of the provided
+// protocol between the provided addresses and ports.
+func newFrame(protocol uint8, src, dst string, sport, dport uint16) []byte {
+	frame := make([]byte, 14+20+8)
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeIPv4)
+
+	ip := frame[14:]
+	ip[0] = 0x45
+	ip[9] = protocol
+	copy(ip[12:16], net.ParseIP(src).To4())
+	copy(ip[16:20], net.ParseIP(dst).To4())
+
+	binary.BigEndian.PutUint16(ip[20:22], sport)
+	binary.BigEndian.PutUint16(ip[22:24], dport)
+
+	return frame
+}
+
+func TestFilter(t *testing.T) {
+	http := newFrame(protocolTCP, "172.100.0.2", "172.100.0.1", 80, 43210)
+	dns := newFrame(protocolUDP, "172.100.0.3", "172.100.0.1", 5353, 53)
+
+	tests := []struct {
+		expr string
+		http bool
+		dns  bool
+	}{
+		{"", true, true},
+		{"ip", true, true},
+		{"ip6", false, false},
+		{"tcp", true, false},
+		{"udp and port 53", false, true},
+		{"src port 80", true, false},
+		{"dst port 80", false, false},
+		{"host 172.100.0.1", true, true},
+		{"src host 172.100.0.1", false, false},
+		{"not host 172.100.0.2", false, true},
+		{"not not tcp", true, false},
+		{"tcp and not port 22", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatal("Failed to parse filter:", err)
+			}
+
+			if got := filter.Match(http); got != tt.http {
+				t.Errorf("Unexpected match of HTTP frame. Expected %v, got %v", tt.http, got)
+			}
+			if got := filter.Match(dns); got != tt.dns {
+				t.Errorf("Unexpected match of DNS frame. Expected %v, got %v", tt.dns, got)
+			}
+		})
+	}
+
+	for _, expr := range []string{"port", "host invalid", "port 70000", "tcp udp", "tcp or udp", "not", "and tcp"} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("Expected an error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package capture captures the Ethernet frames of a host interface, e.g. the
+// bridge of a network or the tap device of a machine, and writes them in the
+// pcapng format which is understood by tools such as Wireshark and tcpdump.
+package capture
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	// DefaultSnapLen is the default maximum number of bytes which are captured
+	// of each frame.
+	DefaultSnapLen = 262144
+
+	// linkTypeEthernet is the link type of Ethernet frames.
+	linkTypeEthernet = 1
+
+	blockTypeSectionHeader       = 0x0a0d0d0a
+	blockTypeInterfaceDescriptor = 0x00000001
+	blockTypeEnhancedPacket      = 0x00000006
+
+	byteOrderMagic = 0x1a2b3c4d
+
+	optionEndOfOpt = 0
+	optionIfName   = 2
+)
+
+// Writer writes captured Ethernet frames of a single interface in the pcapng
+// format.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter writes the section header and the description of the interface
+// with the provided name and snapshot length to the provided writer and
+// returns a Writer for its packets.
+func NewWriter(w io.Writer, ifname string, snaplen int) (*Writer, error) {
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], byteOrderMagic)
+	binary.LittleEndian.PutUint16(shb[4:6], 1) // Major version
+	binary.LittleEndian.PutUint16(shb[6:8], 0) // Minor version
+	binary.LittleEndian.PutUint64(shb[8:16], 0xffffffffffffffff)
+
+	if err := writeBlock(w, blockTypeSectionHeader, shb); err != nil {
+		return nil, err
+	}
+
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], linkTypeEthernet)
+	binary.LittleEndian.PutUint32(idb[4:8], uint32(snaplen))
+
+	if ifname != "" {
+		idb = appendOption(idb, optionIfName, []byte(ifname))
+	}
+	idb = appendOption(idb, optionEndOfOpt, nil)
+
+	if err := writeBlock(w, blockTypeInterfaceDescriptor, idb); err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w}, nil
+}
+
+// WritePacket writes the provided frame which was captured at the provided
+// time.  The length is the original length of the frame, which is larger than
+// the frame when it was truncated to the snapshot length.
+func (w *Writer) WritePacket(ts time.Time, frame []byte, length int) error {
+	// Timestamps are in microseconds, the default resolution.
+	micros := uint64(ts.UnixMicro())
+
+	epb := make([]byte, 20, 20+pad(len(frame)))
+	binary.LittleEndian.PutUint32(epb[0:4], 0) // Interface ID
+	binary.LittleEndian.PutUint32(epb[4:8], uint32(micros>>32))
+	binary.LittleEndian.PutUint32(epb[8:12], uint32(micros))
+	binary.LittleEndian.PutUint32(epb[12:16], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(epb[16:20], uint32(length))
+	epb = append(epb, frame...)
+	epb = append(epb, make([]byte, pad(len(frame))-len(frame))...)
+
+	return writeBlock(w.w, blockTypeEnhancedPacket, epb)
+}
+
+// pad returns the provided length rounded up to a multiple of 32 bits.
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
+
+// appendOption appends the option with the provided code and value to the
+// provided block body.
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	return append(b, make([]byte, pad(len(value))-len(value))...)
+}
+
+// writeBlock writes a block of the provided type with the provided body, which
+// must be padded to 32 bits, in a single write.
+func writeBlock(w io.Writer, blockType uint32, body []byte) error {
+	length := uint32(12 + len(body))
+
+	b := make([]byte, 0, length)
+	b = binary.LittleEndian.AppendUint32(b, blockType)
+	b = binary.LittleEndian.AppendUint32(b, length)
+	b = append(b, body...)
+	b = binary.LittleEndian.AppendUint32(b, length)
+
+	_, err := w.Write(b)
+	return err
+}
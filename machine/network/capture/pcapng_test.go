@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := NewWriter(&buf, "kraft0", 64)
+	if err != nil {
+		t.Fatal("Failed to create writer:", err)
+	}
+
+	ts := time.UnixMicro(1700000000123456)
+	if err := w.WritePacket(ts, []byte{1, 2, 3, 4, 5}, 100); err != nil {
+		t.Fatal("Failed to write packet:", err)
+	}
+
+	b := buf.Bytes()
+
+	var types []uint32
+	for i := 0; i < len(b); {
+		if i+12 > len(b) {
+			t.Fatal("Truncated block")
+		}
+
+		blockType := binary.LittleEndian.Uint32(b[i : i+4])
+		length := int(binary.LittleEndian.Uint32(b[i+4 : i+8]))
+		if length%4 != 0 || i+length > len(b) {
+			t.Fatalf("Invalid length of block %x: %d", blockType, length)
+		}
+		if trailer := int(binary.LittleEndian.Uint32(b[i+length-4 : i+length])); trailer != length {
+			t.Fatalf("Mismatching trailing length of block %x: %d != %d", blockType, trailer, length)
+		}
+
+		if blockType == blockTypeEnhancedPacket {
+			body := b[i+8 : i+length-4]
+			micros := uint64(binary.LittleEndian.Uint32(body[4:8]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:12]))
+			if micros != uint64(ts.UnixMicro()) {
+				t.Errorf("Unexpected timestamp: %d", micros)
+			}
+			if captured, original := binary.LittleEndian.Uint32(body[12:16]), binary.LittleEndian.Uint32(body[16:20]); captured != 5 || original != 100 {
+				t.Errorf("Unexpected lengths: %d/%d", captured, original)
+			}
+			if !bytes.Equal(body[20:25], []byte{1, 2, 3, 4, 5}) {
+				t.Errorf("Unexpected frame: %v", body[20:25])
+			}
+		}
+
+		types = append(types, blockType)
+		i += length
+	}
+
+	expect := []uint32{blockTypeSectionHeader, blockTypeInterfaceDescriptor, blockTypeEnhancedPacket}
+	if len(types) != len(expect) {
+		t.Fatalf("Unexpected blocks. Expected %x, got %x", expect, types)
+	}
+	for i := range expect {
+		if types[i] != expect[i] {
+			t.Errorf("Unexpected block %d. Expected %x, got %x", i, expect[i], types[i])
+		}
+	}
+}
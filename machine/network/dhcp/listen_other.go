@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// Listen returns a connection which receives the DHCP messages arriving at the
+// provided interface and is able to broadcast replies on it.
+func Listen(ctx context.Context, ifname string) (net.PacketConn, error) {
+	return nil, errors.New("serving DHCP is only supported on Linux")
+}
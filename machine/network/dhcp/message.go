@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dhcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// OpCode is the type of a BOOTP message.
+type OpCode byte
+
+const (
+	OpCodeBootRequest = OpCode(1)
+	OpCodeBootReply   = OpCode(2)
+)
+
+// MessageType is the type of a DHCP message, as carried by the option
+// OptionMessageType.
+type MessageType byte
+
+const (
+	MessageTypeDiscover = MessageType(1)
+	MessageTypeOffer    = MessageType(2)
+	MessageTypeRequest  = MessageType(3)
+	MessageTypeDecline  = MessageType(4)
+	MessageTypeAck      = MessageType(5)
+	MessageTypeNak      = MessageType(6)
+	MessageTypeRelease  = MessageType(7)
+	MessageTypeInform   = MessageType(8)
+)
+
+// String implements fmt.Stringer
+func (mt MessageType) String() string {
+	switch mt {
+	case MessageTypeDiscover:
+		return "DISCOVER"
+	case MessageTypeOffer:
+		return "OFFER"
+	case MessageTypeRequest:
+		return "REQUEST"
+	case MessageTypeDecline:
+		return "DECLINE"
+	case MessageTypeAck:
+		return "ACK"
+	case MessageTypeNak:
+		return "NAK"
+	case MessageTypeRelease:
+		return "RELEASE"
+	case MessageTypeInform:
+		return "INFORM"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", mt)
+	}
+}
+
+// Option is the code of a DHCP option as defined in RFC 2132.
+type Option byte
+
+const (
+	OptionPad              = Option(0)
+	OptionSubnetMask       = Option(1)
+	OptionRouter           = Option(3)
+	OptionDomainNameServer = Option(6)
+	OptionHostName         = Option(12)
+	OptionDomainName       = Option(15)
+	OptionBroadcastAddress = Option(28)
+	OptionRequestedIP      = Option(50)
+	OptionLeaseTime        = Option(51)
+	OptionMessageType      = Option(53)
+	OptionServerIdentifier = Option(54)
+	OptionRenewalTime      = Option(58)
+	OptionRebindingTime    = Option(59)
+	OptionClientIdentifier = Option(61)
+	OptionEnd              = Option(255)
+)
+
+const (
+	// headerLen is the length of the fixed BOOTP header, excluding options.
+	headerLen = 236
+
+	// flagBroadcast is set by clients which cannot receive unicast replies
+	// before their address is configured.
+	flagBroadcast = 0x8000
+)
+
+// magicCookie precedes the options of every DHCP message.
+var magicCookie = []byte{99, 130, 83, 99}
+
+// Message represents a DHCPv4 message as defined in RFC 2131.
+type Message struct {
+	OpCode OpCode
+	HType  byte
+	Hops   byte
+	XID    uint32
+	Secs   uint16
+	Flags  uint16
+
+	// ClientIP (ciaddr) is the current address of the client.
+	ClientIP net.IP
+
+	// YourIP (yiaddr) is the address offered to the client.
+	YourIP net.IP
+
+	// ServerIP (siaddr) is the address of the next server.
+	ServerIP net.IP
+
+	// GatewayIP (giaddr) is the address of the relay agent.
+	GatewayIP net.IP
+
+	// ClientHWAddr (chaddr) is the hardware address of the client.
+	ClientHWAddr net.HardwareAddr
+
+	// Options of the message, indexed by their code.
+	Options map[Option][]byte
+}
+
+// Type returns the DHCP message type, or 0 when the message is a plain BOOTP
+// message.
+func (m *Message) Type() MessageType {
+	if v, ok := m.Options[OptionMessageType]; ok && len(v) == 1 {
+		return MessageType(v[0])
+	}
+
+	return 0
+}
+
+// Broadcast returns whether the client requested the reply to be broadcast.
+func (m *Message) Broadcast() bool {
+	return m.Flags&flagBroadcast != 0
+}
+
+// IPOption returns the address carried by the provided option, if any.
+func (m *Message) IPOption(opt Option) net.IP {
+	if v, ok := m.Options[opt]; ok && len(v) == net.IPv4len {
+		return net.IP(v)
+	}
+
+	return nil
+}
+
+// SetIPOption sets the provided option to the list of addresses.
+func (m *Message) SetIPOption(opt Option, ips ...net.IP) {
+	var v []byte
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			v = append(v, ip4...)
+		}
+	}
+
+	if len(v) > 0 {
+		m.Options[opt] = v
+	}
+}
+
+// SetUint32Option sets the provided option to a 32-bit value.
+func (m *Message) SetUint32Option(opt Option, value uint32) {
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, value)
+	m.Options[opt] = v
+}
+
+// ParseMessage decodes a DHCPv4 message.
+func ParseMessage(b []byte) (*Message, error) {
+	if len(b) < headerLen+len(magicCookie) {
+		return nil, errors.New("message too short")
+	}
+
+	hlen := int(b[2])
+	if hlen > 16 {
+		return nil, fmt.Errorf("invalid hardware address length: %d", hlen)
+	}
+
+	m := &Message{
+		OpCode:       OpCode(b[0]),
+		HType:        b[1],
+		Hops:         b[3],
+		XID:          binary.BigEndian.Uint32(b[4:8]),
+		Secs:         binary.BigEndian.Uint16(b[8:10]),
+		Flags:        binary.BigEndian.Uint16(b[10:12]),
+		ClientIP:     net.IP(append([]byte{}, b[12:16]...)),
+		YourIP:       net.IP(append([]byte{}, b[16:20]...)),
+		ServerIP:     net.IP(append([]byte{}, b[20:24]...)),
+		GatewayIP:    net.IP(append([]byte{}, b[24:28]...)),
+		ClientHWAddr: net.HardwareAddr(append([]byte{}, b[28:28+hlen]...)),
+		Options:      make(map[Option][]byte),
+	}
+
+	if string(b[headerLen:headerLen+len(magicCookie)]) != string(magicCookie) {
+		return nil, errors.New("invalid magic cookie")
+	}
+
+	opts := b[headerLen+len(magicCookie):]
+	for i := 0; i < len(opts); {
+		opt := Option(opts[i])
+		switch opt {
+		case OptionPad:
+			i++
+			continue
+		case OptionEnd:
+			return m, nil
+		}
+
+		if i+1 >= len(opts) {
+			return nil, fmt.Errorf("truncated option %d", opt)
+		}
+
+		length := int(opts[i+1])
+		if i+2+length > len(opts) {
+			return nil, fmt.Errorf("truncated option %d", opt)
+		}
+
+		// Options which occur multiple times are concatenated (RFC 3396).
+		m.Options[opt] = append(m.Options[opt], opts[i+2:i+2+length]...)
+		i += 2 + length
+	}
+
+	return m, nil
+}
+
+// Marshal encodes the message.
+func (m *Message) Marshal() []byte {
+	b := make([]byte, headerLen, headerLen+len(magicCookie)+64)
+
+	b[0] = byte(m.OpCode)
+	b[1] = m.HType
+	b[2] = byte(len(m.ClientHWAddr))
+	b[3] = m.Hops
+	binary.BigEndian.PutUint32(b[4:8], m.XID)
+	binary.BigEndian.PutUint16(b[8:10], m.Secs)
+	binary.BigEndian.PutUint16(b[10:12], m.Flags)
+	copy(b[12:16], m.ClientIP.To4())
+	copy(b[16:20], m.YourIP.To4())
+	copy(b[20:24], m.ServerIP.To4())
+	copy(b[24:28], m.GatewayIP.To4())
+	copy(b[28:44], m.ClientHWAddr)
+
+	b = append(b, magicCookie...)
+
+	// Emit the message type first, followed by all other options in order, such
+	// that the encoding is deterministic.
+	codes := make([]int, 0, len(m.Options))
+	for opt := range m.Options {
+		if opt != OptionMessageType {
+			codes = append(codes, int(opt))
+		}
+	}
+	sort.Ints(codes)
+
+	if _, ok := m.Options[OptionMessageType]; ok {
+		codes = append([]int{int(OptionMessageType)}, codes...)
+	}
+
+	for _, code := range codes {
+		v := m.Options[Option(code)]
+
+		// Split values which exceed the maximum length of a single option.
+		for len(v) > 255 {
+			b = append(b, byte(code), 255)
+			b = append(b, v[:255]...)
+			v = v[255:]
+		}
+
+		b = append(b, byte(code), byte(len(v)))
+		b = append(b, v...)
+	}
+
+	b = append(b, byte(OptionEnd))
+
+	// Pad to the minimum BOOTP message length which some clients expect.
+	for len(b) < 300 {
+		b = append(b, byte(OptionPad))
+	}
+
+	return b
+}
+
+// reply returns a reply to the message with the provided type.
+func (m *Message) reply(mt MessageType, serverID net.IP) *Message {
+	r := &Message{
+		OpCode:       OpCodeBootReply,
+		HType:        m.HType,
+		XID:          m.XID,
+		Flags:        m.Flags,
+		ClientIP:     net.IPv4zero,
+		YourIP:       net.IPv4zero,
+		ServerIP:     net.IPv4zero,
+		GatewayIP:    m.GatewayIP,
+		ClientHWAddr: m.ClientHWAddr,
+		Options: map[Option][]byte{
+			OptionMessageType: {byte(mt)},
+		},
+	}
+
+	r.SetIPOption(OptionServerIdentifier, serverID)
+
+	return r
+}
@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package dhcp implements a minimal DHCPv4 server which hands out addresses
+// to the machines attached to a network such that unikernels do not need to be
+// booted with static network parameters.
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"kraftkit.sh/log"
+)
+
+const (
+	// ServerPort is the UDP port the server listens on.
+	ServerPort = 67
+
+	// ClientPort is the UDP port which replies are sent to.
+	ClientPort = 68
+
+	// DefaultLeaseTime is the default duration of a lease.
+	DefaultLeaseTime = time.Hour
+)
+
+// Leaser decides which address is handed out to a client.
+type Leaser interface {
+	// Lease returns the address of the client with the provided hardware
+	// address.  The requested address, which may be nil, should be preferred
+	// if it is available.
+	Lease(ctx context.Context, mac net.HardwareAddr, requested net.IP) (net.IP, error)
+
+	// Release releases the address of the client with the provided hardware
+	// address.
+	Release(ctx context.Context, mac net.HardwareAddr) error
+}
+
+// Server is a DHCPv4 server for a single subnet.
+type Server struct {
+	// ServerIP is the address of the server on the network, which is used as
+	// the server identifier.
+	ServerIP net.IP
+
+	// Subnet is the subnet of the network.
+	Subnet *net.IPNet
+
+	// Router is the default gateway handed out to clients.
+	Router net.IP
+
+	// DNS is the list of name servers handed out to clients.
+	DNS []net.IP
+
+	// LeaseTime is the duration of leases.  Defaults to DefaultLeaseTime.
+	LeaseTime time.Duration
+
+	// Leaser decides which address is handed out to a client.
+	Leaser Leaser
+}
+
+// Serve answers the DHCP messages received on the provided connection until
+// the context is cancelled.
+func (server *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	if server.Leaser == nil {
+		return errors.New("cannot serve DHCP without leaser")
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 1500)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("could not read DHCP message: %v", err)
+		}
+
+		msg, err := ParseMessage(buf[:n])
+		if err != nil {
+			log.G(ctx).Debugf("ignoring malformed DHCP message from %s: %v", addr, err)
+			continue
+		}
+
+		reply, err := server.Handle(ctx, msg)
+		if err != nil {
+			log.G(ctx).Warnf("could not handle DHCP %s from %s: %v", msg.Type(), msg.ClientHWAddr, err)
+			continue
+		}
+
+		if reply == nil {
+			continue
+		}
+
+		if _, err := conn.WriteTo(reply.Marshal(), server.destination(msg, reply)); err != nil {
+			log.G(ctx).Warnf("could not send DHCP %s to %s: %v", reply.Type(), msg.ClientHWAddr, err)
+		}
+	}
+}
+
+// destination returns the address which the reply to the provided message is
+// sent to.  Clients without an address only receive broadcasts.
+func (server *Server) destination(msg, reply *Message) net.Addr {
+	if msg.GatewayIP != nil && !msg.GatewayIP.IsUnspecified() {
+		return &net.UDPAddr{IP: msg.GatewayIP, Port: ServerPort}
+	}
+
+	if reply.Type() != MessageTypeNak && !msg.Broadcast() && msg.ClientIP != nil && !msg.ClientIP.IsUnspecified() {
+		return &net.UDPAddr{IP: msg.ClientIP, Port: ClientPort}
+	}
+
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+}
+
+// Handle returns the reply to the provided message, which is nil when the
+// message does not require a reply.
+func (server *Server) Handle(ctx context.Context, msg *Message) (*Message, error) {
+	if msg.OpCode != OpCodeBootRequest {
+		return nil, nil
+	}
+
+	// Ignore messages which are directed at other servers.
+	if id := msg.IPOption(OptionServerIdentifier); id != nil && !id.Equal(server.ServerIP) {
+		return nil, nil
+	}
+
+	switch msg.Type() {
+	case MessageTypeDiscover:
+		ip, err := server.Leaser.Lease(ctx, msg.ClientHWAddr, msg.IPOption(OptionRequestedIP))
+		if err != nil {
+			return nil, err
+		}
+
+		log.G(ctx).Debugf("offering %s to %s", ip, msg.ClientHWAddr)
+
+		return server.lease(msg, MessageTypeOffer, ip), nil
+
+	case MessageTypeRequest:
+		requested := msg.IPOption(OptionRequestedIP)
+		if requested == nil && !msg.ClientIP.IsUnspecified() {
+			requested = msg.ClientIP
+		}
+
+		ip, err := server.Leaser.Lease(ctx, msg.ClientHWAddr, requested)
+		if err != nil || (requested != nil && !requested.Equal(ip)) {
+			log.G(ctx).Debugf("refusing %s to %s", requested, msg.ClientHWAddr)
+			return msg.reply(MessageTypeNak, server.ServerIP), nil
+		}
+
+		log.G(ctx).Infof("leased %s to %s", ip, msg.ClientHWAddr)
+
+		return server.lease(msg, MessageTypeAck, ip), nil
+
+	case MessageTypeInform:
+		reply := server.lease(msg, MessageTypeAck, nil)
+		reply.ClientIP = msg.ClientIP
+		delete(reply.Options, OptionLeaseTime)
+		delete(reply.Options, OptionRenewalTime)
+		delete(reply.Options, OptionRebindingTime)
+
+		return reply, nil
+
+	case MessageTypeRelease:
+		log.G(ctx).Infof("releasing address of %s", msg.ClientHWAddr)
+		return nil, server.Leaser.Release(ctx, msg.ClientHWAddr)
+
+	case MessageTypeDecline:
+		log.G(ctx).Warnf("%s declined address %s", msg.ClientHWAddr, msg.IPOption(OptionRequestedIP))
+		return nil, nil
+	}
+
+	return nil, nil
+}
+
+// lease returns a reply which hands out the provided address together with the
+// configuration of the network.
+func (server *Server) lease(msg *Message, mt MessageType, ip net.IP) *Message {
+	reply := msg.reply(mt, server.ServerIP)
+
+	if ip != nil {
+		reply.YourIP = ip
+	}
+
+	leaseTime := server.LeaseTime
+	if leaseTime == 0 {
+		leaseTime = DefaultLeaseTime
+	}
+
+	reply.SetUint32Option(OptionLeaseTime, uint32(leaseTime/time.Second))
+	reply.SetUint32Option(OptionRenewalTime, uint32(leaseTime/2/time.Second))
+	reply.SetUint32Option(OptionRebindingTime, uint32(leaseTime*7/8/time.Second))
+
+	if server.Subnet != nil {
+		reply.SetIPOption(OptionSubnetMask, net.IP(server.Subnet.Mask))
+
+		broadcast := make(net.IP, net.IPv4len)
+		for i := range broadcast {
+			broadcast[i] = server.Subnet.IP.To4()[i] | ^server.Subnet.Mask[len(server.Subnet.Mask)-net.IPv4len+i]
+		}
+		reply.SetIPOption(OptionBroadcastAddress, broadcast)
+	}
+
+	if server.Router != nil {
+		reply.SetIPOption(OptionRouter, server.Router)
+	}
+
+	if len(server.DNS) > 0 {
+		reply.SetIPOption(OptionDomainNameServer, server.DNS...)
+	}
+
+	return reply
+}
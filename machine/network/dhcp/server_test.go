@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dhcp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeLeaser hands out addresses from a static table.
+type fakeLeaser struct {
+	leases   map[string]net.IP
+	released []string
+}
+
+func (leaser *fakeLeaser) Lease(_ context.Context, mac net.HardwareAddr, _ net.IP) (net.IP, error) {
+	return leaser.leases[mac.String()], nil
+}
+
+func (leaser *fakeLeaser) Release(_ context.Context, mac net.HardwareAddr) error {
+	leaser.released = append(leaser.released, mac.String())
+	return nil
+}
+
+var (
+	testMAC, _       = net.ParseMAC("02:b0:b0:00:00:01")
+	testServerIP     = net.ParseIP("172.100.0.1").To4()
+	_, testSubnet, _ = net.ParseCIDR("172.100.0.0/24")
+)
+
+func newTestServer() (*Server, *fakeLeaser) {
+	leaser := &fakeLeaser{
+		leases: map[string]net.IP{
+			testMAC.String(): net.ParseIP("172.100.0.2").To4(),
+		},
+	}
+
+	return &Server{
+		ServerIP:  testServerIP,
+		Subnet:    testSubnet,
+		Router:    testServerIP,
+		DNS:       []net.IP{testServerIP},
+		LeaseTime: 10 * time.Minute,
+		Leaser:    leaser,
+	}, leaser
+}
+
+func newRequest(mt MessageType) *Message {
+	return &Message{
+		OpCode:       OpCodeBootRequest,
+		HType:        1,
+		XID:          0xdeadbeef,
+		Flags:        flagBroadcast,
+		ClientIP:     net.IPv4zero,
+		YourIP:       net.IPv4zero,
+		ServerIP:     net.IPv4zero,
+		GatewayIP:    net.IPv4zero,
+		ClientHWAddr: testMAC,
+		Options: map[Option][]byte{
+			OptionMessageType: {byte(mt)},
+		},
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	msg := newRequest(MessageTypeRequest)
+	msg.SetIPOption(OptionRequestedIP, net.ParseIP("172.100.0.2"))
+	msg.Options[OptionHostName] = []byte("unikraft")
+
+	parsed, err := ParseMessage(msg.Marshal())
+	if err != nil {
+		t.Fatal("Failed to parse message:", err)
+	}
+
+	if expect, got := MessageTypeRequest, parsed.Type(); expect != got {
+		t.Errorf("Unexpected message type. Expected %s, got %s", expect, got)
+	}
+	if expect, got := msg.XID, parsed.XID; expect != got {
+		t.Errorf("Unexpected transaction ID. Expected %x, got %x", expect, got)
+	}
+	if expect, got := testMAC.String(), parsed.ClientHWAddr.String(); expect != got {
+		t.Errorf("Unexpected hardware address. Expected %s, got %s", expect, got)
+	}
+	if !parsed.Broadcast() {
+		t.Error("Expected broadcast flag to be set")
+	}
+	if expect, got := "172.100.0.2", parsed.IPOption(OptionRequestedIP).String(); expect != got {
+		t.Errorf("Unexpected requested address. Expected %s, got %s", expect, got)
+	}
+	if expect, got := "unikraft", string(parsed.Options[OptionHostName]); expect != got {
+		t.Errorf("Unexpected host name. Expected %s, got %s", expect, got)
+	}
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	if _, err := ParseMessage([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected an error for a truncated message")
+	}
+
+	b := newRequest(MessageTypeDiscover).Marshal()
+	b[headerLen] = 0
+	if _, err := ParseMessage(b); err == nil {
+		t.Error("Expected an error for an invalid magic cookie")
+	}
+}
+
+func TestHandleDiscoverAndRequest(t *testing.T) {
+	ctx := context.Background()
+	server, _ := newTestServer()
+
+	offer, err := server.Handle(ctx, newRequest(MessageTypeDiscover))
+	if err != nil {
+		t.Fatal("Failed to handle DISCOVER:", err)
+	}
+
+	if expect, got := MessageTypeOffer, offer.Type(); expect != got {
+		t.Fatalf("Unexpected reply. Expected %s, got %s", expect, got)
+	}
+	if expect, got := "172.100.0.2", offer.YourIP.String(); expect != got {
+		t.Errorf("Unexpected offered address. Expected %s, got %s", expect, got)
+	}
+	for opt, expect := range map[Option]string{
+		OptionServerIdentifier: "172.100.0.1",
+		OptionRouter:           "172.100.0.1",
+		OptionSubnetMask:       "255.255.255.0",
+		OptionBroadcastAddress: "172.100.0.255",
+		OptionDomainNameServer: "172.100.0.1",
+	} {
+		if got := offer.IPOption(opt).String(); expect != got {
+			t.Errorf("Unexpected option %d. Expected %s, got %s", opt, expect, got)
+		}
+	}
+	if expect, got := []byte{0, 0, 2, 88}, offer.Options[OptionLeaseTime]; string(expect) != string(got) {
+		t.Errorf("Unexpected lease time. Expected %v, got %v", expect, got)
+	}
+
+	req := newRequest(MessageTypeRequest)
+	req.SetIPOption(OptionServerIdentifier, testServerIP)
+	req.SetIPOption(OptionRequestedIP, offer.YourIP)
+
+	ack, err := server.Handle(ctx, req)
+	if err != nil {
+		t.Fatal("Failed to handle REQUEST:", err)
+	}
+	if expect, got := MessageTypeAck, ack.Type(); expect != got {
+		t.Fatalf("Unexpected reply. Expected %s, got %s", expect, got)
+	}
+	if expect, got := "172.100.0.2", ack.YourIP.String(); expect != got {
+		t.Errorf("Unexpected acknowledged address. Expected %s, got %s", expect, got)
+	}
+	if expect, got := "255.255.255.255:68", server.destination(req, ack).String(); expect != got {
+		t.Errorf("Unexpected destination. Expected %s, got %s", expect, got)
+	}
+}
+
+func TestHandleRequestOtherAddress(t *testing.T) {
+	server, _ := newTestServer()
+
+	req := newRequest(MessageTypeRequest)
+	req.SetIPOption(OptionRequestedIP, net.ParseIP("172.100.0.99"))
+
+	nak, err := server.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal("Failed to handle REQUEST:", err)
+	}
+	if expect, got := MessageTypeNak, nak.Type(); expect != got {
+		t.Errorf("Unexpected reply. Expected %s, got %s", expect, got)
+	}
+}
+
+func TestHandleOtherServer(t *testing.T) {
+	server, _ := newTestServer()
+
+	req := newRequest(MessageTypeRequest)
+	req.SetIPOption(OptionServerIdentifier, net.ParseIP("10.0.0.1"))
+
+	reply, err := server.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatal("Failed to handle REQUEST:", err)
+	}
+	if reply != nil {
+		t.Errorf("Expected no reply to a REQUEST for another server, got %s", reply.Type())
+	}
+}
+
+func TestHandleRelease(t *testing.T) {
+	server, leaser := newTestServer()
+
+	req := newRequest(MessageTypeRelease)
+	req.ClientIP = net.ParseIP("172.100.0.2")
+
+	if _, err := server.Handle(context.Background(), req); err != nil {
+		t.Fatal("Failed to handle RELEASE:", err)
+	}
+	if len(leaser.released) != 1 || leaser.released[0] != testMAC.String() {
+		t.Errorf("Expected address of %s to be released, got %v", testMAC, leaser.released)
+	}
+}
+
+func TestServe(t *testing.T) {
+	server, _ := newTestServer()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, conn)
+	}()
+
+	client, err := net.Dial("udp4", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal("Failed to dial:", err)
+	}
+	defer client.Close()
+
+	// Malformed messages are ignored.
+	if _, err := client.Write([]byte("garbage")); err != nil {
+		t.Fatal("Failed to send message:", err)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("Unexpected error when stopping server:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Server did not stop after the context was cancelled")
+	}
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Type is the type of a resource record as defined in RFC 1035.
+type Type uint16
+
+const (
+	TypeA    = Type(1)
+	TypeAAAA = Type(28)
+)
+
+// ClassINET is the Internet class of resource records.
+const ClassINET = 1
+
+// RCode is the response code of a DNS message.
+type RCode byte
+
+const (
+	RCodeSuccess        = RCode(0)
+	RCodeFormatError    = RCode(1)
+	RCodeServerFailure  = RCode(2)
+	RCodeNameError      = RCode(3)
+	RCodeNotImplemented = RCode(4)
+	RCodeRefused        = RCode(5)
+)
+
+const (
+	// headerLen is the length of the fixed DNS header.
+	headerLen = 12
+
+	flagResponse           = 0x8000
+	flagRecursionDesired   = 0x0100
+	flagRecursionAvailable = 0x0080
+)
+
+// Query represents a DNS query with a single question.
+type Query struct {
+	// ID is the transaction identifier of the query.
+	ID uint16
+
+	// OpCode is the kind of the query.  Only standard queries (0) are
+	// supported.
+	OpCode byte
+
+	// RecursionDesired is set when the client asks for recursive resolution.
+	RecursionDesired bool
+
+	// Name is the queried domain name without its trailing dot.
+	Name string
+
+	// Type is the queried record type.
+	Type Type
+
+	// Class is the queried record class.
+	Class uint16
+
+	// question is the raw question section, which is echoed in replies.
+	question []byte
+}
+
+// ParseQuery decodes a DNS query.  Queries must carry exactly one question,
+// which is the only form used in practice.
+func ParseQuery(b []byte) (*Query, error) {
+	if len(b) < headerLen {
+		return nil, errors.New("message too short")
+	}
+
+	flags := binary.BigEndian.Uint16(b[2:4])
+	if flags&flagResponse != 0 {
+		return nil, errors.New("message is not a query")
+	}
+
+	if qdcount := binary.BigEndian.Uint16(b[4:6]); qdcount != 1 {
+		return nil, fmt.Errorf("unsupported number of questions: %d", qdcount)
+	}
+
+	q := &Query{
+		ID:               binary.BigEndian.Uint16(b[0:2]),
+		OpCode:           byte(flags>>11) & 0xf,
+		RecursionDesired: flags&flagRecursionDesired != 0,
+	}
+
+	var labels []string
+	i := headerLen
+	for {
+		if i >= len(b) {
+			return nil, errors.New("truncated question")
+		}
+
+		length := int(b[i])
+		if length == 0 {
+			i++
+			break
+		} else if length&0xc0 != 0 {
+			return nil, errors.New("compressed names are not supported in questions")
+		} else if i+1+length > len(b) {
+			return nil, errors.New("truncated label")
+		}
+
+		labels = append(labels, string(b[i+1:i+1+length]))
+		i += 1 + length
+	}
+
+	if i+4 > len(b) {
+		return nil, errors.New("truncated question")
+	}
+
+	q.Name = strings.Join(labels, ".")
+	q.Type = Type(binary.BigEndian.Uint16(b[i : i+2]))
+	q.Class = binary.BigEndian.Uint16(b[i+2 : i+4])
+	q.question = append([]byte{}, b[headerLen:i+4]...)
+
+	return q, nil
+}
+
+// Reply encodes a reply to the query with the provided response code which
+// answers the question with the provided addresses.  IPv4 addresses are
+// encoded as A records and IPv6 addresses as AAAA records.
+func (q *Query) Reply(rcode RCode, ips []net.IP, ttl time.Duration) []byte {
+	var answers [][]byte
+	for _, ip := range ips {
+		rtype, rdata := TypeA, ip.To4()
+		if rdata == nil {
+			rtype, rdata = TypeAAAA, ip.To16()
+		}
+		if rdata == nil {
+			continue
+		}
+
+		// Refer to the name in the question (at offset 12) rather than repeating
+		// it.
+		rr := []byte{0xc0, headerLen}
+		rr = binary.BigEndian.AppendUint16(rr, uint16(rtype))
+		rr = binary.BigEndian.AppendUint16(rr, ClassINET)
+		rr = binary.BigEndian.AppendUint32(rr, uint32(ttl/time.Second))
+		rr = binary.BigEndian.AppendUint16(rr, uint16(len(rdata)))
+		rr = append(rr, rdata...)
+
+		answers = append(answers, rr)
+	}
+
+	flags := uint16(flagResponse|flagRecursionAvailable) | uint16(q.OpCode&0xf)<<11 | uint16(rcode&0xf)
+	if q.RecursionDesired {
+		flags |= flagRecursionDesired
+	}
+
+	b := make([]byte, headerLen, headerLen+len(q.question)+len(answers)*28)
+	binary.BigEndian.PutUint16(b[0:2], q.ID)
+	binary.BigEndian.PutUint16(b[2:4], flags)
+	binary.BigEndian.PutUint16(b[4:6], 1)
+	binary.BigEndian.PutUint16(b[6:8], uint16(len(answers)))
+
+	b = append(b, q.question...)
+	for _, rr := range answers {
+		b = append(b, rr...)
+	}
+
+	return b
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package dns implements a minimal DNS server which resolves the names of the
+// machines attached to a network and forwards all other queries to upstream
+// name servers.
+package dns
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"kraftkit.sh/log"
+)
+
+const (
+	// Port is the UDP port the server listens on.
+	Port = 53
+
+	// DefaultTTL is the default time-to-live of answers for machine names.
+	DefaultTTL = 5 * time.Second
+
+	// DefaultTimeout is the default duration after which a forwarded query is
+	// abandoned.
+	DefaultTimeout = 2 * time.Second
+
+	// DefaultResolvConf is the path of the resolver configuration of the host,
+	// which lists the upstream name servers.
+	DefaultResolvConf = "/etc/resolv.conf"
+)
+
+// Resolver resolves the names of machines.
+type Resolver interface {
+	// Resolve returns the addresses of the machine with the provided name,
+	// which is lower-case and unqualified.  No addresses are returned when the
+	// name is unknown.
+	Resolve(ctx context.Context, name string) ([]net.IP, error)
+}
+
+// Server is a DNS server for a single network.
+type Server struct {
+	// Domain is the name of the network.  Machine names may be qualified with
+	// the domain, e.g. "web.kraft0", and unknown names within the domain are
+	// not forwarded.
+	Domain string
+
+	// Resolver resolves the names of machines.
+	Resolver Resolver
+
+	// Upstreams is the list of name servers in the format host:port which
+	// queries for all other names are forwarded to.
+	Upstreams []string
+
+	// TTL is the time-to-live of answers for machine names.  Defaults to
+	// DefaultTTL.
+	TTL time.Duration
+
+	// Timeout is the duration after which a forwarded query is abandoned.
+	// Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Upstreams returns the name servers in the format host:port which are listed
+// in the resolver configuration at the provided path.
+func Upstreams(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	var upstreams []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		if ip := net.ParseIP(fields[1]); ip != nil {
+			upstreams = append(upstreams, net.JoinHostPort(ip.String(), fmt.Sprint(Port)))
+		}
+	}
+
+	return upstreams, scanner.Err()
+}
+
+// Serve answers the DNS queries received on the provided connection until the
+// context is cancelled.
+func (server *Server) Serve(ctx context.Context, conn net.PacketConn) error {
+	if server.Resolver == nil {
+		return errors.New("cannot serve DNS without resolver")
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 4096)
+
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return fmt.Errorf("could not read DNS message: %v", err)
+		}
+
+		msg := append([]byte{}, buf[:n]...)
+
+		// Answer concurrently such that slow upstreams do not block the
+		// resolution of machine names.
+		go func() {
+			reply, err := server.Handle(ctx, msg)
+			if err != nil {
+				log.G(ctx).Debugf("ignoring DNS message from %s: %v", addr, err)
+				return
+			}
+
+			if _, err := conn.WriteTo(reply, addr); err != nil && ctx.Err() == nil {
+				log.G(ctx).Warnf("could not send DNS reply to %s: %v", addr, err)
+			}
+		}()
+	}
+}
+
+// Handle returns the encoded reply to the provided encoded query.
+func (server *Server) Handle(ctx context.Context, msg []byte) ([]byte, error) {
+	q, err := ParseQuery(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.OpCode != 0 {
+		return q.Reply(RCodeNotImplemented, nil, 0), nil
+	}
+
+	ttl := server.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	name, local := server.unqualify(q.Name)
+
+	ips, err := server.Resolver.Resolve(ctx, name)
+	if err != nil {
+		log.G(ctx).Warnf("could not resolve %s: %v", name, err)
+		return q.Reply(RCodeServerFailure, nil, 0), nil
+	}
+
+	if len(ips) > 0 {
+		// Only answer with the addresses of the queried family.  All other types
+		// are answered without records, which tells clients that the name exists.
+		var answers []net.IP
+		for _, ip := range ips {
+			if q.Class != ClassINET {
+				break
+			}
+
+			if (q.Type == TypeA && ip.To4() != nil) || (q.Type == TypeAAAA && ip.To4() == nil) {
+				answers = append(answers, ip)
+			}
+		}
+
+		log.G(ctx).Debugf("resolved %s to %v", q.Name, answers)
+
+		return q.Reply(RCodeSuccess, answers, ttl), nil
+	}
+
+	if local {
+		return q.Reply(RCodeNameError, nil, 0), nil
+	}
+
+	return server.forward(ctx, q, msg), nil
+}
+
+// unqualify returns the lower-case name without the domain of the network and
+// whether the name was qualified with the domain.
+func (server *Server) unqualify(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+
+	if server.Domain != "" {
+		if suffix := "." + strings.ToLower(server.Domain); strings.HasSuffix(name, suffix) {
+			return strings.TrimSuffix(name, suffix), true
+		}
+	}
+
+	return name, false
+}
+
+// forward relays the query to the upstream name servers in order and returns
+// the first reply.
+func (server *Server) forward(ctx context.Context, q *Query, msg []byte) []byte {
+	timeout := server.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	buf := make([]byte, 4096)
+
+	for _, upstream := range server.Upstreams {
+		reply, err := func() ([]byte, error) {
+			conn, err := net.DialTimeout("udp", upstream, timeout)
+			if err != nil {
+				return nil, err
+			}
+
+			defer conn.Close()
+
+			if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+				return nil, err
+			}
+
+			if _, err := conn.Write(msg); err != nil {
+				return nil, err
+			}
+
+			n, err := conn.Read(buf)
+			if err != nil {
+				return nil, err
+			}
+
+			return append([]byte{}, buf[:n]...), nil
+		}()
+		if err != nil {
+			log.G(ctx).Debugf("could not forward query for %s to %s: %v", q.Name, upstream, err)
+			continue
+		}
+
+		return reply
+	}
+
+	return q.Reply(RCodeServerFailure, nil, 0)
+}
@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver resolves names from a static table.
+type fakeResolver map[string][]net.IP
+
+func (resolver fakeResolver) Resolve(_ context.Context, name string) ([]net.IP, error) {
+	return resolver[name], nil
+}
+
+// newQuery encodes a recursive query for the provided name and type.
+func newQuery(id uint16, name string, qtype Type) []byte {
+	b := make([]byte, headerLen)
+	binary.BigEndian.PutUint16(b[0:2], id)
+	binary.BigEndian.PutUint16(b[2:4], flagRecursionDesired)
+	binary.BigEndian.PutUint16(b[4:6], 1)
+
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint16(b, uint16(qtype))
+	b = binary.BigEndian.AppendUint16(b, ClassINET)
+
+	return b
+}
+
+// parseReply returns the response code and the addresses of the A and AAAA
+// records of an encoded reply.
+func parseReply(t *testing.T, b []byte) (RCode, []string) {
+	t.Helper()
+
+	q, err := ParseQuery(append(append([]byte{}, b[:2]...), append([]byte{0, 0}, b[4:]...)...))
+	if err != nil {
+		t.Fatal("Failed to parse reply:", err)
+	}
+
+	var ips []string
+	i := headerLen + len(q.question)
+	for n := binary.BigEndian.Uint16(b[6:8]); n > 0; n-- {
+		if i+12 > len(b) {
+			t.Fatal("Truncated answer")
+		}
+		rdlen := int(binary.BigEndian.Uint16(b[i+10 : i+12]))
+		if i+12+rdlen > len(b) {
+			t.Fatal("Truncated answer")
+		}
+		ips = append(ips, net.IP(b[i+12:i+12+rdlen]).String())
+		i += 12 + rdlen
+	}
+
+	return RCode(b[3] & 0xf), ips
+}
+
+func newTestServer(upstreams ...string) *Server {
+	return &Server{
+		Domain: "kraft0",
+		Resolver: fakeResolver{
+			"web": {net.ParseIP("172.100.0.2"), net.ParseIP("fd00:100::2")},
+			"db":  {net.ParseIP("172.100.0.3")},
+		},
+		Upstreams: upstreams,
+		Timeout:   time.Second,
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := ParseQuery(newQuery(0x1234, "Web.kraft0.", TypeAAAA))
+	if err != nil {
+		t.Fatal("Failed to parse query:", err)
+	}
+
+	if expect, got := uint16(0x1234), q.ID; expect != got {
+		t.Errorf("Unexpected ID. Expected %x, got %x", expect, got)
+	}
+	if expect, got := "Web.kraft0", q.Name; expect != got {
+		t.Errorf("Unexpected name. Expected %s, got %s", expect, got)
+	}
+	if expect, got := TypeAAAA, q.Type; expect != got {
+		t.Errorf("Unexpected type. Expected %d, got %d", expect, got)
+	}
+	if !q.RecursionDesired {
+		t.Error("Expected recursion desired flag to be set")
+	}
+
+	for _, b := range [][]byte{
+		{1, 2, 3},
+		newQuery(1, "web", TypeA)[:15],
+	} {
+		if _, err := ParseQuery(b); err == nil {
+			t.Errorf("Expected an error for %v", b)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	server := newTestServer()
+
+	tests := []struct {
+		name  string
+		qtype Type
+		rcode RCode
+		ips   []string
+	}{
+		{"web", TypeA, RCodeSuccess, []string{"172.100.0.2"}},
+		{"WEB.kraft0.", TypeA, RCodeSuccess, []string{"172.100.0.2"}},
+		{"db.kraft0", TypeA, RCodeSuccess, []string{"172.100.0.3"}},
+		{"web", TypeAAAA, RCodeSuccess, []string{"fd00:100::2"}},
+		{"db", TypeAAAA, RCodeSuccess, nil},
+		{"cache.kraft0", TypeA, RCodeNameError, nil},
+		// Without upstreams, other names cannot be resolved.
+		{"unikraft.org", TypeA, RCodeServerFailure, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := server.Handle(context.Background(), newQuery(42, tt.name, tt.qtype))
+			if err != nil {
+				t.Fatal("Failed to handle query:", err)
+			}
+
+			if expect, got := uint16(42), binary.BigEndian.Uint16(reply[0:2]); expect != got {
+				t.Errorf("Unexpected ID. Expected %d, got %d", expect, got)
+			}
+
+			rcode, ips := parseReply(t, reply)
+			if tt.rcode != rcode {
+				t.Errorf("Unexpected response code. Expected %d, got %d", tt.rcode, rcode)
+			}
+			if strings.Join(tt.ips, ",") != strings.Join(ips, ",") {
+				t.Errorf("Unexpected answers. Expected %v, got %v", tt.ips, ips)
+			}
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	upstream, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	defer upstream.Close()
+
+	// Answer every query with a fixed address.
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := upstream.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			q, err := ParseQuery(buf[:n])
+			if err != nil {
+				continue
+			}
+
+			_, _ = upstream.WriteTo(q.Reply(RCodeSuccess, []net.IP{net.ParseIP("10.0.0.1")}, time.Minute), addr)
+		}
+	}()
+
+	// Unreachable upstreams are skipped.
+	server := newTestServer("127.0.0.1:1", upstream.LocalAddr().String())
+
+	reply, err := server.Handle(context.Background(), newQuery(7, "unikraft.org", TypeA))
+	if err != nil {
+		t.Fatal("Failed to handle query:", err)
+	}
+
+	rcode, ips := parseReply(t, reply)
+	if rcode != RCodeSuccess || len(ips) != 1 || ips[0] != "10.0.0.1" {
+		t.Errorf("Unexpected forwarded reply: %d %v", rcode, ips)
+	}
+}
+
+func TestUpstreams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(path, []byte(strings.Join([]string{
+		"# Generated",
+		"nameserver 1.1.1.1",
+		"search example.com",
+		"nameserver 2606:4700:4700::1111",
+		"nameserver invalid",
+	}, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	upstreams, err := Upstreams(path)
+	if err != nil {
+		t.Fatal("Failed to read upstreams:", err)
+	}
+
+	if expect, got := "1.1.1.1:53,[2606:4700:4700::1111]:53", strings.Join(upstreams, ","); expect != got {
+		t.Errorf("Unexpected upstreams. Expected %s, got %s", expect, got)
+	}
+}
@@ -0,0 +1,623 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package ipam implements a persistent IP address manager for networks whose
+// addresses are allocated by KraftKit.  Allocations and static reservations are
+// stored per network in an embedded Badger database.  Since Badger holds an
+// exclusive lock on its directory whilst it is open, every operation is
+// performed within a single transaction whilst the database is open such that
+// several KraftKit processes can safely allocate addresses at the same time.
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/internal/retrytimeout"
+)
+
+const (
+	// StoreDir is the name of the directory within KraftKit's runtime directory
+	// which holds the IPAM database.
+	StoreDir = "ipamv1alpha1"
+
+	// DefaultTimeout is the default duration to wait for the database to become
+	// available when it is in use by another process.
+	DefaultTimeout = 5 * time.Second
+
+	leasePrefix       = "lease"
+	reservationPrefix = "reservation"
+)
+
+// Lease represents an address which has been allocated to an interface.
+type Lease struct {
+	// IP is the allocated address.
+	IP string
+
+	// ID uniquely identifies the interface which holds the lease.
+	ID string
+
+	// MAC is the hardware address of the interface.
+	MAC string
+
+	// Name is the name of the machine which the interface belongs to.
+	Name string
+
+	// Created is the time at which the address was allocated.
+	Created time.Time
+
+	// Expires is the time at which the address is freed again unless the lease
+	// is renewed.  Leases without expiry are held until they are released.
+	Expires time.Time
+}
+
+// expired returns whether the lease has expired at the provided time.
+func (l Lease) expired(now time.Time) bool {
+	return !l.Expires.IsZero() && now.After(l.Expires)
+}
+
+// Reservation represents an address which is statically reserved for an
+// interface with the given hardware address or for the machine with the given
+// name.
+type Reservation struct {
+	// IP is the reserved address.
+	IP string
+
+	// MAC is the hardware address which the address is reserved for.
+	MAC string
+
+	// Name is the name of the machine which the address is reserved for.
+	Name string
+}
+
+// matches returns whether the reservation applies to the provided request.
+func (r Reservation) matches(req Request) bool {
+	return (r.MAC != "" && strings.EqualFold(r.MAC, req.MAC)) ||
+		(r.Name != "" && r.Name == req.Name)
+}
+
+// Request contains the details of an interface which requests an address.
+type Request struct {
+	// ID uniquely identifies the interface.  Requesting an address for an ID
+	// which already holds a lease returns the same address.
+	ID string
+
+	// MAC is the hardware address of the interface, used to match static
+	// reservations.
+	MAC string
+
+	// Name is the name of the machine which the interface belongs to, used to
+	// match static reservations.
+	Name string
+
+	// IP is a specific address which is requested.  When empty, the reserved
+	// address or the next free address in the subnet is allocated.
+	IP string
+
+	// TTL is the duration after which the allocated address is freed again
+	// unless it is requested anew, which renews the lease.  A zero TTL allocates
+	// the address until it is released.
+	TTL time.Duration
+
+	// InUse is consulted before a free address is allocated and may be used to
+	// skip addresses which are in use by hosts which are unknown to the IPAM.
+	InUse func(net.IP) bool
+}
+
+// IPAM manages the allocation of addresses of networks.
+type IPAM struct {
+	path    string
+	bopts   badger.Options
+	timeout time.Duration
+}
+
+// IPAMOption represents an option-method handler for the IPAM.
+type IPAMOption func(*IPAM)
+
+// WithTimeout sets the duration to wait for the database to become available
+// when it is in use by another process.
+func WithTimeout(timeout time.Duration) IPAMOption {
+	return func(ipam *IPAM) {
+		ipam.timeout = timeout
+	}
+}
+
+// NewIPAM returns an IPAM whose database is located at the provided path.
+func NewIPAM(path string, opts ...IPAMOption) (*IPAM, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot create IPAM without path")
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create IPAM directory: %v", err)
+	}
+
+	ipam := IPAM{
+		path:    path,
+		bopts:   badger.DefaultOptions(path),
+		timeout: DefaultTimeout,
+	}
+
+	// Badger's informational messages are too noisy, see machine/store.
+	ipam.bopts.Logger = nil
+
+	for _, opt := range opts {
+		opt(&ipam)
+	}
+
+	return &ipam, nil
+}
+
+// NewDefaultIPAM returns an IPAM whose database is located in KraftKit's
+// runtime directory.
+func NewDefaultIPAM(ctx context.Context, opts ...IPAMOption) (*IPAM, error) {
+	return NewIPAM(filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, StoreDir), opts...)
+}
+
+// update opens the database and performs the provided function within a
+// single read-write transaction.  Whilst the database is open, no other
+// process is able to access it.
+func (ipam *IPAM) update(fn func(txn *badger.Txn) error) error {
+	db, err := badger.Open(ipam.bopts)
+	if err != nil && strings.Contains(err.Error(), "permission denied") {
+		// Retrying cannot grant the permissions.
+		return fmt.Errorf("could not open IPAM store: %v", err)
+	} else if err != nil {
+		if err := retrytimeout.RetryTimeout(ipam.timeout, func() error {
+			var err error
+			db, err = badger.Open(ipam.bopts)
+			if err != nil {
+				// The directory is locked by another process, back off briefly.
+				time.Sleep(10 * time.Millisecond)
+				return err
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("could not open IPAM store: %v", err)
+		}
+	}
+
+	defer db.Close()
+
+	return db.Update(fn)
+}
+
+func key(network, prefix, ip string) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s", network, prefix, ip))
+}
+
+func encode(v any) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// list decodes all entries of the network with the given prefix.
+func list[T any](txn *badger.Txn, network, prefix string) ([]T, error) {
+	var entries []T
+
+	itr := txn.NewIterator(badger.IteratorOptions{
+		Prefix: []byte(fmt.Sprintf("%s/%s/", network, prefix)),
+	})
+	defer itr.Close()
+
+	for itr.Rewind(); itr.Valid(); itr.Next() {
+		val, err := itr.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var entry T
+		if err := gob.NewDecoder(bytes.NewReader(val)).Decode(&entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Allocate returns an address within the provided subnet of the network for
+// the requested interface and persists the allocation.  Addresses in the
+// exclude list, e.g. the gateway, are never allocated.
+func (ipam *IPAM) Allocate(ctx context.Context, network string, subnet *net.IPNet, exclude []net.IP, req Request) (net.IP, error) {
+	if req.ID == "" {
+		return nil, fmt.Errorf("cannot allocate address without interface ID")
+	}
+
+	var allocated net.IP
+
+	err := ipam.update(func(txn *badger.Txn) error {
+		leases, err := list[Lease](txn, network, leasePrefix)
+		if err != nil {
+			return err
+		}
+
+		reservations, err := list[Reservation](txn, network, reservationPrefix)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		expires := func() time.Time {
+			if req.TTL == 0 {
+				return time.Time{}
+			}
+			return now.Add(req.TTL)
+		}
+
+		leased := make(map[string]Lease, len(leases))
+		for _, lease := range leases {
+			// Free the addresses of expired leases.
+			if lease.expired(now) {
+				if err := txn.Delete(key(network, leasePrefix, lease.IP)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// Return, and renew, the existing lease of the interface within the
+			// subnet.  Dual-stack interfaces hold one lease per address family.
+			if lease.ID == req.ID && subnet.Contains(net.ParseIP(lease.IP)) {
+				allocated = net.ParseIP(lease.IP)
+
+				if lease.Expires.IsZero() {
+					return nil
+				}
+
+				lease.Expires = expires()
+				val, err := encode(lease)
+				if err != nil {
+					return err
+				}
+
+				return txn.Set(key(network, leasePrefix, lease.IP), val)
+			}
+
+			leased[lease.IP] = lease
+		}
+
+		reserved := make(map[string]Reservation, len(reservations))
+		for _, reservation := range reservations {
+			reserved[reservation.IP] = reservation
+		}
+
+		excluded := func(ip net.IP) bool {
+			for _, ex := range exclude {
+				if ip.Equal(ex) {
+					return true
+				}
+			}
+			return false
+		}
+
+		switch {
+		case req.IP != "":
+			ip := net.ParseIP(req.IP)
+			if ip == nil || !subnet.Contains(ip) {
+				return fmt.Errorf("address %s is not within %s", req.IP, subnet)
+			}
+			if excluded(ip) {
+				return fmt.Errorf("address %s is reserved for the network", ip)
+			}
+			if reservation, ok := reserved[ip.String()]; ok && !reservation.matches(req) {
+				return fmt.Errorf("address %s is statically reserved", ip)
+			}
+
+			allocated = ip
+
+		default:
+			// Prefer the address which is statically reserved for the interface.
+			for _, reservation := range reservations {
+				if reservation.matches(req) && subnet.Contains(net.ParseIP(reservation.IP)) {
+					allocated = net.ParseIP(reservation.IP)
+					break
+				}
+			}
+
+			if allocated != nil {
+				break
+			}
+
+			for ip := nextIP(subnet.IP); subnet.Contains(ip); ip = nextIP(ip) {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				default:
+				}
+
+				if excluded(ip) || !isUnicast(ip, subnet.Mask) {
+					continue
+				}
+				if _, ok := leased[ip.String()]; ok {
+					continue
+				}
+				if _, ok := reserved[ip.String()]; ok {
+					continue
+				}
+				if req.InUse != nil && req.InUse(ip) {
+					continue
+				}
+
+				allocated = ip
+				break
+			}
+
+			if allocated == nil {
+				return fmt.Errorf("could not allocate address in %s: no free addresses", subnet)
+			}
+		}
+
+		if lease, ok := leased[allocated.String()]; ok {
+			return fmt.Errorf("address %s is already allocated to %s", allocated, lease.ID)
+		}
+
+		val, err := encode(Lease{
+			IP:      allocated.String(),
+			ID:      req.ID,
+			MAC:     req.MAC,
+			Name:    req.Name,
+			Created: now,
+			Expires: expires(),
+		})
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(key(network, leasePrefix, allocated.String()), val)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allocated, nil
+}
+
+// Release releases the addresses which are allocated to the interface with the
+// provided ID.  Releasing an interface which does not hold a lease is not an
+// error.
+func (ipam *IPAM) Release(ctx context.Context, network, id string) error {
+	return ipam.update(func(txn *badger.Txn) error {
+		leases, err := list[Lease](txn, network, leasePrefix)
+		if err != nil {
+			return err
+		}
+
+		for _, lease := range leases {
+			if lease.ID != id {
+				continue
+			}
+
+			if err := txn.Delete(key(network, leasePrefix, lease.IP)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Reserve statically reserves the provided address for the interface with the
+// given hardware address or for the machine with the given name.
+func (ipam *IPAM) Reserve(ctx context.Context, network string, reservation Reservation) error {
+	ip := net.ParseIP(reservation.IP)
+	if ip == nil {
+		return fmt.Errorf("invalid address: %q", reservation.IP)
+	}
+	if reservation.MAC == "" && reservation.Name == "" {
+		return fmt.Errorf("cannot reserve address without hardware address or name")
+	}
+	if reservation.MAC != "" {
+		if _, err := net.ParseMAC(reservation.MAC); err != nil {
+			return err
+		}
+	}
+
+	reservation.IP = ip.String()
+
+	return ipam.update(func(txn *badger.Txn) error {
+		reservations, err := list[Reservation](txn, network, reservationPrefix)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range reservations {
+			if existing.IP == reservation.IP {
+				return fmt.Errorf("address %s is already reserved", reservation.IP)
+			}
+			if existing.matches(Request{MAC: reservation.MAC, Name: reservation.Name}) {
+				return fmt.Errorf("%s already has reserved address %s", reservationOwner(reservation), existing.IP)
+			}
+		}
+
+		// Refuse reserving an address which is allocated to somebody else.
+		item, err := txn.Get(key(network, leasePrefix, reservation.IP))
+		if err == nil {
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			var lease Lease
+			if err := gob.NewDecoder(bytes.NewReader(val)).Decode(&lease); err != nil {
+				return err
+			}
+
+			if !reservation.matches(Request{MAC: lease.MAC, Name: lease.Name}) {
+				return fmt.Errorf("address %s is already allocated to %s", reservation.IP, lease.ID)
+			}
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		val, err := encode(reservation)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(key(network, reservationPrefix, reservation.IP), val)
+	})
+}
+
+// reservationOwner returns a human-readable representation of whom the
+// reservation is for.
+func reservationOwner(reservation Reservation) string {
+	if reservation.Name != "" {
+		return reservation.Name
+	}
+
+	return reservation.MAC
+}
+
+// Unreserve removes the static reservation of the provided address.
+func (ipam *IPAM) Unreserve(ctx context.Context, network, ip string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Errorf("invalid address: %q", ip)
+	}
+
+	return ipam.update(func(txn *badger.Txn) error {
+		k := key(network, reservationPrefix, parsed.String())
+		if _, err := txn.Get(k); errors.Is(err, badger.ErrKeyNotFound) {
+			return fmt.Errorf("address %s is not reserved", parsed)
+		} else if err != nil {
+			return err
+		}
+
+		return txn.Delete(k)
+	})
+}
+
+// Leases returns all addresses allocated within the network which have not
+// expired.
+func (ipam *IPAM) Leases(ctx context.Context, network string) ([]Lease, error) {
+	var leases []Lease
+
+	err := ipam.update(func(txn *badger.Txn) error {
+		all, err := list[Lease](txn, network, leasePrefix)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, lease := range all {
+			if !lease.expired(now) {
+				leases = append(leases, lease)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(leases, func(i, j int) bool {
+		return compareIP(leases[i].IP, leases[j].IP)
+	})
+
+	return leases, err
+}
+
+// Reservations returns all static reservations within the network.
+func (ipam *IPAM) Reservations(ctx context.Context, network string) ([]Reservation, error) {
+	var reservations []Reservation
+
+	err := ipam.update(func(txn *badger.Txn) error {
+		var err error
+		reservations, err = list[Reservation](txn, network, reservationPrefix)
+		return err
+	})
+
+	sort.Slice(reservations, func(i, j int) bool {
+		return compareIP(reservations[i].IP, reservations[j].IP)
+	})
+
+	return reservations, err
+}
+
+// Purge removes all allocations and reservations of the network.
+func (ipam *IPAM) Purge(ctx context.Context, network string) error {
+	return ipam.update(func(txn *badger.Txn) error {
+		itr := txn.NewIterator(badger.IteratorOptions{
+			Prefix: []byte(network + "/"),
+		})
+
+		var keys [][]byte
+		for itr.Rewind(); itr.Valid(); itr.Next() {
+			keys = append(keys, itr.Item().KeyCopy(nil))
+		}
+
+		itr.Close()
+
+		for _, k := range keys {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// nextIP returns the address following the provided one.
+func nextIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	next := new(big.Int).SetBytes(ip)
+	next.Add(next, big.NewInt(1))
+
+	b := next.Bytes()
+	if len(b) > len(ip) {
+		// Overflow, return an address which is outside of any subnet.
+		return net.IP{}
+	}
+
+	out := make(net.IP, len(ip))
+	copy(out[len(out)-len(b):], b)
+
+	return out
+}
+
+// isUnicast returns whether the provided address is a unicast address within a
+// subnet with the given mask, i.e. it is not the broadcast address.
+func isUnicast(ip net.IP, mask net.IPMask) bool {
+	if ip4 := ip.To4(); ip4 != nil && len(mask) == net.IPv4len {
+		if binary.BigEndian.Uint32(ip4)&^binary.BigEndian.Uint32(mask) == ^binary.BigEndian.Uint32(mask) {
+			return false
+		}
+	}
+
+	return ip.IsGlobalUnicast()
+}
+
+// compareIP returns whether the address a sorts before b.
+func compareIP(a, b string) bool {
+	ipa, ipb := net.ParseIP(a), net.ParseIP(b)
+	if ipa == nil || ipb == nil {
+		return a < b
+	}
+
+	return bytes.Compare(ipa.To16(), ipb.To16()) < 0
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	testGateway      = net.ParseIP("172.100.0.1")
+	_, testSubnet, _ = net.ParseCIDR("172.100.0.0/29")
+)
+
+func newIPAM(t *testing.T) *IPAM {
+	t.Helper()
+
+	ipam, err := NewIPAM(t.TempDir(), WithTimeout(30*time.Second))
+	if err != nil {
+		t.Fatal("Failed to create IPAM:", err)
+	}
+
+	return ipam
+}
+
+func allocate(t *testing.T, ipam *IPAM, req Request) string {
+	t.Helper()
+
+	ip, err := ipam.Allocate(context.Background(), "kraft0", testSubnet, []net.IP{testGateway}, req)
+	if err != nil {
+		t.Fatalf("Failed to allocate address for %s: %v", req.ID, err)
+	}
+
+	return ip.String()
+}
+
+func TestAllocate(t *testing.T) {
+	ipam := newIPAM(t)
+
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "a"}); expect != got {
+		t.Errorf("Unexpected address. Expected %s, got %s", expect, got)
+	}
+	if expect, got := "172.100.0.3", allocate(t, ipam, Request{ID: "b"}); expect != got {
+		t.Errorf("Unexpected address. Expected %s, got %s", expect, got)
+	}
+
+	// Allocating for the same interface again returns the same address.
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "a"}); expect != got {
+		t.Errorf("Unexpected address of existing lease. Expected %s, got %s", expect, got)
+	}
+
+	// Addresses which are in use by unknown hosts are skipped.
+	if expect, got := "172.100.0.5", allocate(t, ipam, Request{
+		ID:    "c",
+		InUse: func(ip net.IP) bool { return ip.String() == "172.100.0.4" },
+	}); expect != got {
+		t.Errorf("Unexpected address when skipping used address. Expected %s, got %s", expect, got)
+	}
+
+	if err := ipam.Release(context.Background(), "kraft0", "a"); err != nil {
+		t.Fatal("Failed to release address:", err)
+	}
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "d"}); expect != got {
+		t.Errorf("Expected released address to be re-used. Expected %s, got %s", expect, got)
+	}
+
+	// Exhaust the subnet, the broadcast address is never allocated.
+	allocate(t, ipam, Request{ID: "e"})
+	allocate(t, ipam, Request{ID: "f"})
+	if _, err := ipam.Allocate(context.Background(), "kraft0", testSubnet, []net.IP{testGateway}, Request{ID: "g"}); err == nil {
+		t.Error("Expected an error when the subnet is exhausted")
+	}
+}
+
+func TestAllocateRequested(t *testing.T) {
+	ipam := newIPAM(t)
+
+	if expect, got := "172.100.0.6", allocate(t, ipam, Request{ID: "a", IP: "172.100.0.6"}); expect != got {
+		t.Errorf("Unexpected address. Expected %s, got %s", expect, got)
+	}
+
+	for _, ip := range []string{"172.100.0.6", "172.100.0.1", "10.0.0.2"} {
+		if _, err := ipam.Allocate(context.Background(), "kraft0", testSubnet, []net.IP{testGateway}, Request{ID: "b", IP: ip}); err == nil {
+			t.Errorf("Expected an error when requesting %s", ip)
+		}
+	}
+}
+
+func TestAllocateDualStack(t *testing.T) {
+	ctx := context.Background()
+	ipam := newIPAM(t)
+
+	_, subnet6, _ := net.ParseCIDR("fd00:100::/64")
+	gateway6 := net.ParseIP("fd00:100::1")
+
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "a"}); expect != got {
+		t.Errorf("Unexpected address. Expected %s, got %s", expect, got)
+	}
+
+	// The same interface holds a separate lease in the IPv6 subnet.
+	for i := 0; i < 2; i++ {
+		ip, err := ipam.Allocate(ctx, "kraft0", subnet6, []net.IP{gateway6}, Request{ID: "a"})
+		if err != nil {
+			t.Fatal("Failed to allocate IPv6 address:", err)
+		}
+		if expect, got := "fd00:100::2", ip.String(); expect != got {
+			t.Errorf("Unexpected IPv6 address. Expected %s, got %s", expect, got)
+		}
+	}
+
+	if err := ipam.Release(ctx, "kraft0", "a"); err != nil {
+		t.Fatal("Failed to release addresses:", err)
+	}
+	if leases, err := ipam.Leases(ctx, "kraft0"); err != nil || len(leases) != 0 {
+		t.Errorf("Expected all leases of the interface to be released, got %v (%v)", leases, err)
+	}
+}
+
+func TestAllocateExpires(t *testing.T) {
+	ipam := newIPAM(t)
+	ctx := context.Background()
+
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "a", TTL: time.Second}); expect != got {
+		t.Errorf("Unexpected address. Expected %s, got %s", expect, got)
+	}
+
+	// Requesting the address anew renews the lease beyond its initial expiry.
+	time.Sleep(500 * time.Millisecond)
+	allocate(t, ipam, Request{ID: "a", TTL: time.Minute})
+	time.Sleep(time.Second)
+
+	if leases, err := ipam.Leases(ctx, "kraft0"); err != nil || len(leases) != 1 {
+		t.Errorf("Expected renewed lease to be held, got %v (%v)", leases, err)
+	}
+
+	// The address of the expired lease is allocated to another interface.
+	allocate(t, ipam, Request{ID: "a", TTL: time.Nanosecond})
+	time.Sleep(time.Millisecond)
+
+	if leases, err := ipam.Leases(ctx, "kraft0"); err != nil || len(leases) != 0 {
+		t.Errorf("Expected expired lease to be omitted, got %v (%v)", leases, err)
+	}
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "b"}); expect != got {
+		t.Errorf("Unexpected address after expiry. Expected %s, got %s", expect, got)
+	}
+}
+
+func TestReservations(t *testing.T) {
+	ctx := context.Background()
+	ipam := newIPAM(t)
+
+	if err := ipam.Reserve(ctx, "kraft0", Reservation{IP: "172.100.0.2", Name: "web"}); err != nil {
+		t.Fatal("Failed to reserve address:", err)
+	}
+	if err := ipam.Reserve(ctx, "kraft0", Reservation{IP: "172.100.0.3", MAC: "02:b0:b0:00:00:0a"}); err != nil {
+		t.Fatal("Failed to reserve address:", err)
+	}
+	if err := ipam.Reserve(ctx, "kraft0", Reservation{IP: "172.100.0.2", Name: "db"}); err == nil {
+		t.Error("Expected an error when reserving an address twice")
+	}
+	if err := ipam.Reserve(ctx, "kraft0", Reservation{IP: "172.100.0.4", Name: "web"}); err == nil {
+		t.Error("Expected an error when reserving two addresses for the same machine")
+	}
+
+	// Reserved addresses are skipped for other interfaces.
+	if expect, got := "172.100.0.4", allocate(t, ipam, Request{ID: "a", Name: "other"}); expect != got {
+		t.Errorf("Unexpected address. Expected %s, got %s", expect, got)
+	}
+	if _, err := ipam.Allocate(ctx, "kraft0", testSubnet, []net.IP{testGateway}, Request{ID: "b", IP: "172.100.0.2"}); err == nil {
+		t.Error("Expected an error when requesting an address reserved for another machine")
+	}
+
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "c", Name: "web"}); expect != got {
+		t.Errorf("Unexpected address reserved by name. Expected %s, got %s", expect, got)
+	}
+	if expect, got := "172.100.0.3", allocate(t, ipam, Request{ID: "d", MAC: "02:B0:B0:00:00:0A"}); expect != got {
+		t.Errorf("Unexpected address reserved by MAC. Expected %s, got %s", expect, got)
+	}
+
+	// Reservations outlive the leases of the machines they are reserved for.
+	if err := ipam.Release(ctx, "kraft0", "c"); err != nil {
+		t.Fatal("Failed to release address:", err)
+	}
+	if expect, got := "172.100.0.2", allocate(t, ipam, Request{ID: "e", Name: "web"}); expect != got {
+		t.Errorf("Unexpected address reserved by name after restart. Expected %s, got %s", expect, got)
+	}
+
+	if err := ipam.Unreserve(ctx, "kraft0", "172.100.0.3"); err != nil {
+		t.Fatal("Failed to remove reservation:", err)
+	}
+	if err := ipam.Unreserve(ctx, "kraft0", "172.100.0.3"); err == nil {
+		t.Error("Expected an error when removing a missing reservation")
+	}
+
+	reservations, err := ipam.Reservations(ctx, "kraft0")
+	if err != nil {
+		t.Fatal("Failed to list reservations:", err)
+	}
+	if len(reservations) != 1 || reservations[0].Name != "web" {
+		t.Errorf("Unexpected reservations: %v", reservations)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx := context.Background()
+	ipam := newIPAM(t)
+
+	allocate(t, ipam, Request{ID: "a"})
+	if _, err := ipam.Allocate(ctx, "kraft1", testSubnet, []net.IP{testGateway}, Request{ID: "b"}); err != nil {
+		t.Fatal("Failed to allocate address:", err)
+	}
+
+	if err := ipam.Purge(ctx, "kraft0"); err != nil {
+		t.Fatal("Failed to purge network:", err)
+	}
+
+	if leases, err := ipam.Leases(ctx, "kraft0"); err != nil || len(leases) != 0 {
+		t.Errorf("Expected no leases after purge, got %v (%v)", leases, err)
+	}
+	if leases, err := ipam.Leases(ctx, "kraft1"); err != nil || len(leases) != 1 {
+		t.Errorf("Expected leases of other networks to be kept, got %v (%v)", leases, err)
+	}
+}
+
+func TestAllocateConcurrently(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.100.0.0/24")
+	path := t.TempDir()
+
+	var wg sync.WaitGroup
+	ips := make([]string, 16)
+	errs := make([]error, len(ips))
+
+	for i := range ips {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Use a separate instance to mimic separate processes.
+			ipam, err := NewIPAM(path, WithTimeout(time.Minute))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			ip, err := ipam.Allocate(context.Background(), "kraft0", subnet, []net.IP{testGateway}, Request{ID: fmt.Sprint(i)})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+
+			ips[i] = ip.String()
+		}(i)
+	}
+
+	wg.Wait()
+
+	seen := make(map[string]int)
+	for i, ip := range ips {
+		if errs[i] != nil {
+			t.Fatalf("Failed to allocate address for %d: %v", i, errs[i])
+		}
+		if j, ok := seen[ip]; ok {
+			t.Errorf("Address %s allocated to both %d and %d", ip, j, i)
+		}
+		seen[ip] = i
+	}
+}
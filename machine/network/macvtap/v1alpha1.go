@@ -0,0 +1,417 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package macvtap implements a network strategy which attaches machines
+// directly to the network of a physical link of the host, without a Linux
+// bridge.  Each machine interface receives its own macvtap device on the
+// parent link, which is referenced by the interface name of the network.  The
+// addresses of the machines are managed by the physical network, e.g. via its
+// DHCP server, or can be set statically.
+package macvtap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/machine/network/macaddr"
+)
+
+// DriverName is the name of the macvtap network strategy.
+const DriverName = "macvtap"
+
+type v1alpha1Network struct{}
+
+func NewNetworkServiceV1alpha1(ctx context.Context, opts ...any) (networkv1alpha1.NetworkService, error) {
+	return &v1alpha1Network{}, nil
+}
+
+// interfaceAlias returns the unique combination of the network and the
+// interface which is used to reference the interface's macvtap link.
+func interfaceAlias(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec) string {
+	return fmt.Sprintf("%s:%s", network.ObjectMeta.UID, iface.ObjectMeta.UID)
+}
+
+// parentLink returns the physical link of the host which the machines of the
+// provided network are attached to.
+func parentLink(network *networkv1alpha1.Network) (netlink.Link, error) {
+	if network.Spec.IfName == "" {
+		return nil, fmt.Errorf("cannot use macvtap network %s without parent link", network.Name)
+	}
+
+	parent, err := netlink.LinkByName(network.Spec.IfName)
+	if err != nil {
+		return nil, fmt.Errorf("could not get parent link %s: %v", network.Spec.IfName, err)
+	}
+
+	return parent, nil
+}
+
+// subnets returns the IPv4 and IPv6 subnets of the provided network, which are
+// nil when the respective gateway is not set.
+func subnets(network *networkv1alpha1.Network) (*net.IPNet, *net.IPNet, error) {
+	var subnet4, subnet6 *net.IPNet
+
+	if network.Spec.Gateway != "" {
+		gateway := net.ParseIP(network.Spec.Gateway).To4()
+		netmask := net.ParseIP(network.Spec.Netmask).To4()
+		if gateway == nil || netmask == nil {
+			return nil, nil, fmt.Errorf("invalid gateway %q or netmask %q", network.Spec.Gateway, network.Spec.Netmask)
+		}
+
+		subnet4 = &net.IPNet{IP: gateway.Mask(net.IPMask(netmask)), Mask: net.IPMask(netmask)}
+	}
+
+	if network.Spec.Gateway6 != "" {
+		gateway := net.ParseIP(network.Spec.Gateway6)
+		netmask := net.ParseIP(network.Spec.Netmask6)
+		if gateway == nil || gateway.To4() != nil || netmask == nil {
+			return nil, nil, fmt.Errorf("invalid IPv6 gateway %q or netmask %q", network.Spec.Gateway6, network.Spec.Netmask6)
+		}
+
+		subnet6 = &net.IPNet{IP: gateway.Mask(net.IPMask(netmask)), Mask: net.IPMask(netmask)}
+	}
+
+	return subnet4, subnet6, nil
+}
+
+// validateInterface returns an error if the provided interface requests ports
+// to be published or addresses outside of the provided subnets of the network.
+// Addresses are not allocated since the network of the parent link is managed
+// elsewhere, only the requested ones are validated.
+func validateInterface(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec, subnet4, subnet6 *net.IPNet) error {
+	if len(iface.Spec.Ports) > 0 {
+		return fmt.Errorf("cannot publish ports of %s: machines on macvtap networks are directly reachable", iface.Spec.IfName)
+	}
+
+	if iface.Spec.IP != "" {
+		if ip := net.ParseIP(iface.Spec.IP); ip == nil || subnet4 == nil || !subnet4.Contains(ip) {
+			return fmt.Errorf("cannot assign address %s to %s: not within the IPv4 subnet of network %s", iface.Spec.IP, iface.Spec.IfName, network.Name)
+		}
+	}
+
+	if iface.Spec.IP6 != "" {
+		if ip := net.ParseIP(iface.Spec.IP6); ip == nil || subnet6 == nil || !subnet6.Contains(ip) {
+			return fmt.Errorf("cannot assign address %s to %s: not within the IPv6 subnet of network %s", iface.Spec.IP6, iface.Spec.IfName, network.Name)
+		}
+	}
+
+	return nil
+}
+
+// links returns the macvtap links of the provided network indexed by their
+// alias.
+func links(network *networkv1alpha1.Network) (map[string]*netlink.Macvtap, error) {
+	all, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("could not gather list of existing links: %v", err)
+	}
+
+	ret := make(map[string]*netlink.Macvtap)
+	for _, link := range all {
+		macvtap, ok := link.(*netlink.Macvtap)
+		if !ok {
+			continue
+		}
+
+		if !strings.HasPrefix(macvtap.Alias, string(network.ObjectMeta.UID)+":") {
+			continue
+		}
+
+		ret[macvtap.Alias] = macvtap
+	}
+
+	return ret, nil
+}
+
+// Create implements kraftkit.sh/api/network/v1alpha1.Create
+func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	if network.Name == "" {
+		return nil, fmt.Errorf("cannot create network without name")
+	}
+
+	if network.Spec.DHCP {
+		return network, fmt.Errorf("DHCP is not supported by macvtap networks: addresses are managed by the network of the parent link")
+	}
+
+	if _, _, err := subnets(network); err != nil {
+		return network, err
+	}
+
+	if _, err := parentLink(network); err != nil {
+		return network, err
+	}
+
+	if network.ObjectMeta.UID == "" {
+		network.ObjectMeta.UID = uuid.NewUUID()
+	}
+
+	if network.ObjectMeta.CreationTimestamp == *new(metav1.Time) {
+		network.ObjectMeta.CreationTimestamp = metav1.Now()
+	}
+
+	network.Spec.Driver = DriverName
+
+	return service.Update(ctx, network)
+}
+
+// Start implements kraftkit.sh/api/network/v1alpha1.Start
+func (service *v1alpha1Network) Start(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	parent, err := parentLink(network)
+	if err != nil {
+		return network, err
+	}
+
+	if err := netlink.LinkSetUp(parent); err != nil {
+		return network, fmt.Errorf("could not bring %s link up: %v", parent.Attrs().Name, err)
+	}
+
+	return service.Get(ctx, network)
+}
+
+// Stop implements kraftkit.sh/api/network/v1alpha1.Stop.  The parent link is
+// shared with the host and is therefore left untouched, only the macvtap links
+// of the machines are brought down.
+func (service *v1alpha1Network) Stop(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	existing, err := links(network)
+	if err != nil {
+		return network, err
+	}
+
+	for _, link := range existing {
+		if err := netlink.LinkSetDown(link); err != nil {
+			return network, fmt.Errorf("could not bring %s link down: %v", link.Name, err)
+		}
+	}
+
+	network.Status.State = networkv1alpha1.NetworkStateDown
+
+	return network, nil
+}
+
+// Update implements kraftkit.sh/api/network/v1alpha1.Update
+func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	parent, err := parentLink(network)
+	if err != nil {
+		return network, err
+	}
+
+	subnet4, subnet6, err := subnets(network)
+	if err != nil {
+		return network, err
+	}
+
+	existing, err := links(network)
+	if err != nil {
+		return network, err
+	}
+
+	// Start MAC addresses iteratively.
+	startMac, err := macaddr.GenerateMacAddress(true)
+	if err != nil {
+		return network, fmt.Errorf("could not prepare MAC address generator: %v", err)
+	}
+
+	inuse := make(map[string]bool)
+
+	for i, iface := range network.Spec.Interfaces {
+		if iface.ObjectMeta.UID == "" {
+			iface.ObjectMeta.UID = uuid.NewUUID()
+		}
+
+		if iface.ObjectMeta.CreationTimestamp == *new(metav1.Time) {
+			iface.ObjectMeta.CreationTimestamp = metav1.Now()
+		}
+
+		if err := validateInterface(network, iface, subnet4, subnet6); err != nil {
+			return network, err
+		}
+
+		alias := interfaceAlias(network, iface)
+		inuse[alias] = true
+
+		if link, ok := existing[alias]; ok {
+			iface.Spec.IfName = link.Name
+			iface.Spec.MacAddress = link.HardwareAddr.String()
+			network.Spec.Interfaces[i] = iface
+			continue
+		}
+
+		if iface.Spec.IfName == "" {
+			j := 0
+			for {
+				ifname := fmt.Sprintf("%s@if%d", network.Name, j)
+				if _, err := netlink.LinkByName(ifname); err != nil {
+					iface.Spec.IfName = ifname
+					break
+				}
+				j++
+			}
+		}
+
+		// The macvtap link only receives the frames which are addressed to its
+		// own hardware address, so it must match the one of the machine.
+		var mac net.HardwareAddr
+		if iface.Spec.MacAddress == "" {
+			startMac = macaddr.IncrementMacAddress(startMac)
+			mac = startMac
+			iface.Spec.MacAddress = mac.String()
+		} else if mac, err = net.ParseMAC(iface.Spec.MacAddress); err != nil {
+			return network, fmt.Errorf("invalid MAC address of %s: %v", iface.Spec.IfName, err)
+		}
+
+		attrs := netlink.NewLinkAttrs()
+		attrs.Name = iface.Spec.IfName
+		attrs.ParentIndex = parent.Attrs().Index
+		attrs.HardwareAddr = mac
+
+		link := &netlink.Macvtap{
+			Macvlan: netlink.Macvlan{
+				LinkAttrs: attrs,
+				Mode:      netlink.MACVLAN_MODE_BRIDGE,
+			},
+		}
+
+		if err := netlink.LinkAdd(link); err != nil {
+			return network, fmt.Errorf("could not create %s link: %v", iface.Spec.IfName, err)
+		}
+
+		if err := netlink.LinkSetAlias(link, alias); err != nil {
+			return network, fmt.Errorf("could not set link alias: %v", err)
+		}
+
+		if err := netlink.LinkSetUp(link); err != nil {
+			return network, fmt.Errorf("could not bring %s link up: %v", iface.Spec.IfName, err)
+		}
+
+		network.Spec.Interfaces[i] = iface
+	}
+
+	// Clean up any removed interfaces.
+	for alias, link := range existing {
+		if inuse[alias] {
+			continue
+		}
+
+		if err := netlink.LinkDel(link); err != nil {
+			return network, fmt.Errorf("could not remove %s: %v", link.Name, err)
+		}
+	}
+
+	return service.Get(ctx, network)
+}
+
+// Delete implements kraftkit.sh/api/network/v1alpha1.Delete
+func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	existing, err := links(network)
+	if err != nil {
+		return network, err
+	}
+
+	for _, link := range existing {
+		if err := netlink.LinkDel(link); err != nil {
+			return network, fmt.Errorf("could not remove %s: %v", link.Name, err)
+		}
+	}
+
+	return nil, nil
+}
+
+// Get implements kraftkit.sh/api/network/v1alpha1.Get
+func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	parent, err := parentLink(network)
+	if err != nil {
+		return network, err
+	}
+
+	network.Spec.Driver = DriverName
+
+	attrs := parent.Attrs()
+	if attrs.Flags&net.FlagUp != 0 {
+		network.Status.State = networkv1alpha1.NetworkStateUp
+	} else {
+		network.Status.State = networkv1alpha1.NetworkStateDown
+	}
+
+	if attrs.Statistics != nil {
+		network.Status.RxBytes = attrs.Statistics.RxBytes
+		network.Status.RxDropped = attrs.Statistics.RxDropped
+		network.Status.RxErrors = attrs.Statistics.RxErrors
+		network.Status.RxPackets = attrs.Statistics.RxPackets
+		network.Status.TxBytes = attrs.Statistics.TxBytes
+		network.Status.TxDropped = attrs.Statistics.TxDropped
+		network.Status.TxErrors = attrs.Statistics.TxErrors
+		network.Status.TxPackets = attrs.Statistics.TxPackets
+	}
+
+	existing, err := links(network)
+	if err != nil {
+		return network, err
+	}
+
+	network.Status.Interfaces = nil
+
+	for _, link := range existing {
+		status := networkv1alpha1.NetworkInterfaceStatus{
+			State:  networkv1alpha1.NetworkInterfaceStateDisconnected,
+			IfName: link.Name,
+		}
+
+		if link.OperState == netlink.OperUp {
+			status.State = networkv1alpha1.NetworkInterfaceStateConnected
+		}
+
+		if link.Statistics != nil {
+			status.RxBytes = link.Statistics.RxBytes
+			status.RxDropped = link.Statistics.RxDropped
+			status.RxErrors = link.Statistics.RxErrors
+			status.RxPackets = link.Statistics.RxPackets
+			status.TxBytes = link.Statistics.TxBytes
+			status.TxDropped = link.Statistics.TxDropped
+			status.TxErrors = link.Statistics.TxErrors
+			status.TxPackets = link.Statistics.TxPackets
+		}
+
+		network.Status.Interfaces = append(network.Status.Interfaces, status)
+	}
+
+	sort.Slice(network.Status.Interfaces, func(i, j int) bool {
+		return network.Status.Interfaces[i].IfName < network.Status.Interfaces[j].IfName
+	})
+
+	return network, nil
+}
+
+// List implements kraftkit.sh/api/network/v1alpha1.List.  Macvtap networks
+// cannot be discovered from the host, so only known networks are listed.
+func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alpha1.NetworkList) (*networkv1alpha1.NetworkList, error) {
+	for i, network := range networks.Items {
+		found, err := service.Get(ctx, &network)
+		if err != nil {
+			networks.Items[i].Status.State = networkv1alpha1.NetworkStateUnknown
+			continue
+		}
+
+		networks.Items[i] = *found
+	}
+
+	sort.SliceStable(networks.Items, func(i, j int) bool {
+		return networks.Items[i].Name < networks.Items[j].Name
+	})
+
+	return networks, nil
+}
+
+// Watch implements kraftkit.sh/api/network/v1alpha1.Watch
+func (service *v1alpha1Network) Watch(context.Context, *networkv1alpha1.Network) (chan *networkv1alpha1.Network, chan error, error) {
+	return nil, nil, fmt.Errorf("macvtap networks cannot be watched")
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package macvtap
+
+import (
+	"net"
+	"testing"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+// subnetString returns the CIDR notation of the provided subnet, if any.
+func subnetString(subnet *net.IPNet) string {
+	if subnet == nil {
+		return ""
+	}
+
+	return subnet.String()
+}
+
+func TestSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    networkv1alpha1.NetworkSpec
+		subnet4 string
+		subnet6 string
+		err     bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:    "ipv4",
+			spec:    networkv1alpha1.NetworkSpec{Gateway: "192.168.1.1", Netmask: "255.255.255.0"},
+			subnet4: "192.168.1.0/24",
+		},
+		{
+			name:    "ipv6",
+			spec:    networkv1alpha1.NetworkSpec{Gateway6: "fd00:1::1", Netmask6: "ffff:ffff:ffff:ffff::"},
+			subnet6: "fd00:1::/64",
+		},
+		{
+			name: "dual-stack",
+			spec: networkv1alpha1.NetworkSpec{
+				Gateway:  "10.0.0.1",
+				Netmask:  "255.0.0.0",
+				Gateway6: "fd00:1::1",
+				Netmask6: "ffff:ffff:ffff:ffff::",
+			},
+			subnet4: "10.0.0.0/8",
+			subnet6: "fd00:1::/64",
+		},
+		{
+			name: "missing netmask",
+			spec: networkv1alpha1.NetworkSpec{Gateway: "192.168.1.1"},
+			err:  true,
+		},
+		{
+			name: "invalid gateway",
+			spec: networkv1alpha1.NetworkSpec{Gateway: "192.168.1", Netmask: "255.255.255.0"},
+			err:  true,
+		},
+		{
+			name: "ipv4 gateway6",
+			spec: networkv1alpha1.NetworkSpec{Gateway6: "192.168.1.1", Netmask6: "ffff:ffff:ffff:ffff::"},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet4, subnet6, err := subnets(&networkv1alpha1.Network{Spec: tt.spec})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v and %v", subnet4, subnet6)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := subnetString(subnet4); got != tt.subnet4 {
+				t.Errorf("expected IPv4 subnet %q, got %q", tt.subnet4, got)
+			}
+
+			if got := subnetString(subnet6); got != tt.subnet6 {
+				t.Errorf("expected IPv6 subnet %q, got %q", tt.subnet6, got)
+			}
+		})
+	}
+}
+
+func TestValidateInterface(t *testing.T) {
+	network := &networkv1alpha1.Network{
+		Spec: networkv1alpha1.NetworkSpec{
+			Gateway:  "192.168.1.1",
+			Netmask:  "255.255.255.0",
+			Gateway6: "fd00:1::1",
+			Netmask6: "ffff:ffff:ffff:ffff::",
+		},
+	}
+	network.Name = "lan"
+
+	ipv4Only := &networkv1alpha1.Network{
+		Spec: networkv1alpha1.NetworkSpec{
+			Gateway: "192.168.1.1",
+			Netmask: "255.255.255.0",
+		},
+	}
+	ipv4Only.Name = "lan4"
+
+	tests := []struct {
+		name    string
+		network *networkv1alpha1.Network
+		spec    networkv1alpha1.NetworkInterfaceSpec
+		err     bool
+	}{
+		{
+			name:    "no addresses",
+			network: network,
+		},
+		{
+			name:    "addresses within subnets",
+			network: network,
+			spec:    networkv1alpha1.NetworkInterfaceSpec{IP: "192.168.1.10", IP6: "fd00:1::10"},
+		},
+		{
+			name:    "ipv4 outside subnet",
+			network: network,
+			spec:    networkv1alpha1.NetworkInterfaceSpec{IP: "192.168.2.10"},
+			err:     true,
+		},
+		{
+			name:    "ipv6 outside subnet",
+			network: network,
+			spec:    networkv1alpha1.NetworkInterfaceSpec{IP6: "fd00:2::10"},
+			err:     true,
+		},
+		{
+			name:    "invalid address",
+			network: network,
+			spec:    networkv1alpha1.NetworkInterfaceSpec{IP: "192.168.1"},
+			err:     true,
+		},
+		{
+			name:    "ipv6 without subnet",
+			network: ipv4Only,
+			spec:    networkv1alpha1.NetworkInterfaceSpec{IP6: "fd00:1::10"},
+			err:     true,
+		},
+		{
+			name:    "ports",
+			network: network,
+			spec: networkv1alpha1.NetworkInterfaceSpec{
+				Ports: []networkv1alpha1.NetworkInterfacePort{{HostPort: 8080, InterfacePort: 80}},
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet4, subnet6, err := subnets(tt.network)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = validateInterface(tt.network, networkv1alpha1.NetworkInterfaceTemplateSpec{Spec: tt.spec}, subnet4, subnet6)
+			if tt.err && err == nil {
+				t.Error("expected an error")
+			} else if !tt.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 
 	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/machine/network/user"
 )
 
 // hostSupportedStrategies returns the map of known supported drivers for the
@@ -20,5 +21,15 @@ func hostSupportedStrategies() map[string]*Strategy {
 				return nil, errors.New("network service is not supported on MacOS")
 			},
 		},
+		user.DriverName: {
+			NewNetworkV1alpha1: func(ctx context.Context, opts ...any) (networkv1alpha1.NetworkService, error) {
+				service, err := user.NewNetworkServiceV1alpha1(ctx, opts...)
+				if err != nil {
+					return nil, err
+				}
+
+				return newNetworkServiceWithStore(ctx, "usernetworkv1alpha1", service)
+			},
+		},
 	}
 }
@@ -6,14 +6,11 @@ package network
 
 import (
 	"context"
-	"path/filepath"
-
-	zip "api.zip"
 
 	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
-	"kraftkit.sh/config"
 	"kraftkit.sh/machine/network/bridge"
-	"kraftkit.sh/machine/store"
+	"kraftkit.sh/machine/network/macvtap"
+	"kraftkit.sh/machine/network/user"
 )
 
 // hostSupportedStrategies returns the map of known supported drivers for the
@@ -27,21 +24,27 @@ func hostSupportedStrategies() map[string]*Strategy {
 					return nil, err
 				}
 
-				embeddedStore, err := store.NewEmbeddedStore[networkv1alpha1.NetworkSpec, networkv1alpha1.NetworkStatus](
-					filepath.Join(
-						config.G[config.KraftKit](ctx).RuntimeDir,
-						"networkv1alpha1",
-					),
-				)
+				return newNetworkServiceWithStore(ctx, "networkv1alpha1", service)
+			},
+		},
+		macvtap.DriverName: {
+			NewNetworkV1alpha1: func(ctx context.Context, opts ...any) (networkv1alpha1.NetworkService, error) {
+				service, err := macvtap.NewNetworkServiceV1alpha1(ctx, opts...)
+				if err != nil {
+					return nil, err
+				}
+
+				return newNetworkServiceWithStore(ctx, "macvtapnetworkv1alpha1", service)
+			},
+		},
+		user.DriverName: {
+			NewNetworkV1alpha1: func(ctx context.Context, opts ...any) (networkv1alpha1.NetworkService, error) {
+				service, err := user.NewNetworkServiceV1alpha1(ctx, opts...)
 				if err != nil {
 					return nil, err
 				}
 
-				return networkv1alpha1.NewNetworkServiceHandler(
-					ctx,
-					service,
-					zip.WithStore[networkv1alpha1.NetworkSpec, networkv1alpha1.NetworkStatus](embeddedStore, zip.StoreRehydrationSpecNil),
-				)
+				return newNetworkServiceWithStore(ctx, "usernetworkv1alpha1", service)
 			},
 		},
 	}
@@ -6,8 +6,13 @@ package network
 
 import (
 	"context"
+	"path/filepath"
+
+	zip "api.zip"
 
 	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/config"
+	"kraftkit.sh/machine/store"
 )
 
 // NewStrategyConstructor is a prototype for the instantiation function of a
@@ -46,3 +51,24 @@ func DriverNames() []string {
 
 	return ret
 }
+
+// newNetworkServiceWithStore wraps the provided strategy's network service
+// with an embedded store, located at the provided directory within the runtime
+// directory, such that networks are persisted between invocations.
+func newNetworkServiceWithStore(ctx context.Context, dir string, service networkv1alpha1.NetworkService) (networkv1alpha1.NetworkService, error) {
+	embeddedStore, err := store.NewEmbeddedStore[networkv1alpha1.NetworkSpec, networkv1alpha1.NetworkStatus](
+		filepath.Join(
+			config.G[config.KraftKit](ctx).RuntimeDir,
+			dir,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return networkv1alpha1.NewNetworkServiceHandler(
+		ctx,
+		service,
+		zip.WithStore[networkv1alpha1.NetworkSpec, networkv1alpha1.NetworkStatus](embeddedStore, zip.StoreRehydrationSpecNil),
+	)
+}
@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package user implements a user-mode network strategy.  User-mode networks
+// are emulated entirely by the hypervisor process (e.g. QEMU's SLIRP stack) and
+// therefore do not require any privileges on the host.  Each machine attached
+// to a user-mode network receives its own isolated instance of the network,
+// ports are published by the hypervisor via host forwarding rules.
+package user
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/machine/network/macaddr"
+)
+
+const (
+	// DriverName is the name of the user-mode network strategy.
+	DriverName = "user"
+
+	// DefaultGateway is the address of the host within a user-mode network,
+	// which matches the default of QEMU's SLIRP stack.
+	DefaultGateway = "10.0.2.2"
+
+	// DefaultNetmask is the network mask of a user-mode network, which matches
+	// the default of QEMU's SLIRP stack.
+	DefaultNetmask = "255.255.255.0"
+
+	// guestHostID is the host part of the address assigned to interfaces which
+	// do not request a specific address.  This matches the first address handed
+	// out by the DHCP server of QEMU's SLIRP stack.
+	guestHostID = 15
+)
+
+type v1alpha1Network struct{}
+
+func NewNetworkServiceV1alpha1(ctx context.Context, opts ...any) (networkv1alpha1.NetworkService, error) {
+	return &v1alpha1Network{}, nil
+}
+
+// subnet returns the subnet of the provided network.
+func subnet(network *networkv1alpha1.Network) (*net.IPNet, error) {
+	if network.Spec.Gateway6 != "" {
+		return nil, fmt.Errorf("IPv6 is not supported by user-mode networks")
+	}
+
+	gateway := net.ParseIP(network.Spec.Gateway).To4()
+	if gateway == nil {
+		return nil, fmt.Errorf("invalid gateway address: %q", network.Spec.Gateway)
+	}
+
+	netmask := net.ParseIP(network.Spec.Netmask).To4()
+	if netmask == nil {
+		return nil, fmt.Errorf("invalid netmask: %q", network.Spec.Netmask)
+	}
+
+	mask := net.IPMask(netmask)
+	if ones, bits := mask.Size(); bits == 0 || ones > 24 {
+		return nil, fmt.Errorf("netmask of user-mode network must be /24 or larger: %s", network.Spec.Netmask)
+	}
+
+	return &net.IPNet{IP: gateway.Mask(mask), Mask: mask}, nil
+}
+
+// setDefaults populates the unset attributes of the network with the defaults
+// of a user-mode network.
+func setDefaults(network *networkv1alpha1.Network) {
+	if network.ObjectMeta.UID == "" {
+		network.ObjectMeta.UID = uuid.NewUUID()
+	}
+
+	if network.ObjectMeta.CreationTimestamp == *new(metav1.Time) {
+		network.ObjectMeta.CreationTimestamp = metav1.Now()
+	}
+
+	if network.Spec.IfName == "" {
+		network.Spec.IfName = network.Name
+	}
+
+	if network.Spec.Gateway == "" {
+		network.Spec.Gateway = DefaultGateway
+	}
+
+	if network.Spec.Netmask == "" {
+		network.Spec.Netmask = DefaultNetmask
+	}
+
+	network.Spec.Driver = DriverName
+}
+
+// Create implements kraftkit.sh/api/network/v1alpha1.Create
+func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	if network.Name == "" {
+		return nil, fmt.Errorf("cannot create network without name")
+	}
+
+	setDefaults(network)
+
+	if _, err := subnet(network); err != nil {
+		return network, err
+	}
+
+	network.Status.State = networkv1alpha1.NetworkStateUp
+
+	return service.Update(ctx, network)
+}
+
+// Start implements kraftkit.sh/api/network/v1alpha1.Start
+func (service *v1alpha1Network) Start(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	network.Status.State = networkv1alpha1.NetworkStateUp
+	return network, nil
+}
+
+// Stop implements kraftkit.sh/api/network/v1alpha1.Stop
+func (service *v1alpha1Network) Stop(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	network.Status.State = networkv1alpha1.NetworkStateDown
+	return network, nil
+}
+
+// Update implements kraftkit.sh/api/network/v1alpha1.Update.  Since each
+// machine receives its own instance of the network, interfaces only require a
+// hardware address and an address within the subnet of the network.
+func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	setDefaults(network)
+
+	ipnet, err := subnet(network)
+	if err != nil {
+		return network, err
+	}
+
+	gateway := net.ParseIP(network.Spec.Gateway)
+
+	guest := make(net.IP, len(ipnet.IP))
+	copy(guest, ipnet.IP)
+	guest[len(guest)-1] |= guestHostID
+	if guest.Equal(gateway) {
+		guest[len(guest)-1]++
+	}
+
+	// Start MAC addresses iteratively.
+	startMac, err := macaddr.GenerateMacAddress(true)
+	if err != nil {
+		return network, fmt.Errorf("could not prepare MAC address generator: %v", err)
+	}
+
+	for i, iface := range network.Spec.Interfaces {
+		if iface.ObjectMeta.UID == "" {
+			iface.ObjectMeta.UID = uuid.NewUUID()
+		}
+
+		if iface.ObjectMeta.CreationTimestamp == *new(metav1.Time) {
+			iface.ObjectMeta.CreationTimestamp = metav1.Now()
+		}
+
+		if iface.Spec.IfName == "" {
+			iface.Spec.IfName = fmt.Sprintf("%s@if%d", network.Name, i)
+		}
+
+		if iface.Spec.MacAddress == "" {
+			startMac = macaddr.IncrementMacAddress(startMac)
+			iface.Spec.MacAddress = startMac.String()
+		}
+
+		if iface.Spec.IP6 != "" {
+			return network, fmt.Errorf("IPv6 is not supported by user-mode networks")
+		}
+
+		if iface.Spec.IP == "" {
+			iface.Spec.IP = guest.String()
+		} else if ip := net.ParseIP(iface.Spec.IP); ip == nil || !ipnet.Contains(ip) {
+			return network, fmt.Errorf("interface address %s is not within network %s", iface.Spec.IP, ipnet)
+		} else if ip.Equal(gateway) {
+			return network, fmt.Errorf("interface address %s is reserved for the host", iface.Spec.IP)
+		}
+
+		network.Spec.Interfaces[i] = iface
+	}
+
+	return network, nil
+}
+
+// Delete implements kraftkit.sh/api/network/v1alpha1.Delete
+func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	if len(network.Spec.Interfaces) > 0 {
+		return network, fmt.Errorf("network %s still has %d interface(s) attached", network.Name, len(network.Spec.Interfaces))
+	}
+
+	return nil, nil
+}
+
+// Get implements kraftkit.sh/api/network/v1alpha1.Get.  User-mode networks do
+// not have any state on the host, so any network which has not previously been
+// created is returned with the default configuration.
+func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha1.Network) (*networkv1alpha1.Network, error) {
+	if network.Name == "" {
+		return network, fmt.Errorf("cannot get network without name")
+	}
+
+	setDefaults(network)
+
+	if network.Status.State == "" || network.Status.State == networkv1alpha1.NetworkStateUnknown {
+		network.Status.State = networkv1alpha1.NetworkStateUp
+	}
+
+	return network, nil
+}
+
+// List implements kraftkit.sh/api/network/v1alpha1.List
+func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alpha1.NetworkList) (*networkv1alpha1.NetworkList, error) {
+	for i, network := range networks.Items {
+		found, err := service.Get(ctx, &network)
+		if err != nil {
+			continue
+		}
+
+		networks.Items[i] = *found
+	}
+
+	sort.SliceStable(networks.Items, func(i, j int) bool {
+		return networks.Items[i].Name < networks.Items[j].Name
+	})
+
+	return networks, nil
+}
+
+// Watch implements kraftkit.sh/api/network/v1alpha1.Watch
+func (service *v1alpha1Network) Watch(context.Context, *networkv1alpha1.Network) (chan *networkv1alpha1.Network, chan error, error) {
+	return nil, nil, fmt.Errorf("user-mode networks cannot be watched")
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package user
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+func TestCreateDefaults(t *testing.T) {
+	service, _ := NewNetworkServiceV1alpha1(context.Background())
+
+	network, err := service.Create(context.Background(), &networkv1alpha1.Network{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+	})
+	if err != nil {
+		t.Fatal("Failed to create network:", err)
+	}
+
+	if expect, got := DriverName, network.Spec.Driver; expect != got {
+		t.Errorf("Unexpected driver. Expected %q, got %q", expect, got)
+	}
+	if expect, got := DefaultGateway, network.Spec.Gateway; expect != got {
+		t.Errorf("Unexpected gateway. Expected %q, got %q", expect, got)
+	}
+	if expect, got := DefaultNetmask, network.Spec.Netmask; expect != got {
+		t.Errorf("Unexpected netmask. Expected %q, got %q", expect, got)
+	}
+	if expect, got := networkv1alpha1.NetworkStateUp, network.Status.State; expect != got {
+		t.Errorf("Unexpected state. Expected %q, got %q", expect, got)
+	}
+}
+
+func TestUpdateInterfaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway string
+		netmask string
+		ip      string
+		expect  string
+		err     bool
+	}{
+		{
+			name:   "default address",
+			expect: "10.0.2.15",
+		},
+		{
+			name:   "requested address",
+			ip:     "10.0.2.42",
+			expect: "10.0.2.42",
+		},
+		{
+			name:    "custom subnet",
+			gateway: "192.168.76.1",
+			netmask: "255.255.255.0",
+			expect:  "192.168.76.15",
+		},
+		{
+			name:    "gateway clashes with default address",
+			gateway: "192.168.76.15",
+			netmask: "255.255.255.0",
+			expect:  "192.168.76.16",
+		},
+		{
+			name: "address outside of subnet",
+			ip:   "10.0.3.15",
+			err:  true,
+		},
+		{
+			name: "address of host",
+			ip:   DefaultGateway,
+			err:  true,
+		},
+		{
+			name:    "subnet too small",
+			netmask: "255.255.255.252",
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := NewNetworkServiceV1alpha1(context.Background())
+
+			network, err := service.Update(context.Background(), &networkv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Spec: networkv1alpha1.NetworkSpec{
+					Gateway: tt.gateway,
+					Netmask: tt.netmask,
+					Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{
+						{Spec: networkv1alpha1.NetworkInterfaceSpec{IP: tt.ip}},
+					},
+				},
+			})
+			if tt.err {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal("Unexpected error:", err)
+			}
+
+			iface := network.Spec.Interfaces[0]
+			if expect, got := tt.expect, iface.Spec.IP; expect != got {
+				t.Errorf("Unexpected interface address. Expected %q, got %q", expect, got)
+			}
+			if iface.Spec.MacAddress == "" {
+				t.Error("Expected a hardware address to be assigned")
+			}
+			if iface.ObjectMeta.UID == "" {
+				t.Error("Expected a UID to be assigned")
+			}
+		})
+	}
+}
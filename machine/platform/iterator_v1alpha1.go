@@ -124,6 +124,23 @@ func (iterator *machineV1alpha1ServiceIterator) Update(ctx context.Context, mach
 	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
 }
 
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (iterator *machineV1alpha1ServiceIterator) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	var errs []error
+
+	for _, strategy := range iterator.strategies {
+		ret, err := strategy.Snapshot(ctx, machine)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return ret, nil
+	}
+
+	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
+}
+
 // Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (iterator *machineV1alpha1ServiceIterator) Delete(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	var errs []error
@@ -17,11 +17,6 @@ import (
 )
 
 var qemuV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
-	service, err := qemu.NewMachineV1alpha1Service(ctx, opts...)
-	if err != nil {
-		return nil, err
-	}
-
 	embeddedStore, err := store.NewEmbeddedStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](
 		filepath.Join(
 			config.G[config.KraftKit](ctx).RuntimeDir,
@@ -32,6 +27,11 @@ var qemuV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1
 		return nil, err
 	}
 
+	service, err := qemu.NewMachineV1alpha1Service(ctx, append(opts, qemu.WithStore(embeddedStore))...)
+	if err != nil {
+		return nil, err
+	}
+
 	return machinev1alpha1.NewMachineServiceHandler(
 		ctx,
 		service,
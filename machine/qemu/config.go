@@ -9,14 +9,18 @@ import "strings"
 type QemuConfig struct {
 	// Command-line arguments for qemu-system-*
 	Accel      QemuMachineAccelerator `flag:"-accel"       json:"accel,omitempty"`
+	Action     QemuAction             `flag:"-action"      json:"action,omitempty"`
 	Append     string                 `flag:"-append"      json:"append,omitempty"`
 	CharDevs   []QemuCharDev          `flag:"-chardev"     json:"chardev,omitempty"`
 	CPU        QemuCPU                `flag:"-cpu"         json:"cpu,omitempty"`
 	Daemonize  bool                   `flag:"-daemonize"   json:"daemonize,omitempty"`
 	Devices    []QemuDevice           `flag:"-device"      json:"device,omitempty"`
 	Display    QemuDisplay            `flag:"-display"     json:"display,omitempty"`
+	Drives     []QemuDrive            `flag:"-drive"       json:"drive,omitempty"`
 	EnableKVM  bool                   `flag:"-enable-kvm"  json:"enable_kvm,omitempty"`
 	FsDevs     []QemuFsDev            `flag:"-fsdev"       json:"fsdev,omitempty"`
+	GDB        QemuHostCharDev        `flag:"-gdb"         json:"gdb,omitempty"`
+	Incoming   string                 `flag:"-incoming"    json:"incoming,omitempty"`
 	InitRd     string                 `flag:"-initrd"      json:"initrd,omitempty"`
 	Kernel     string                 `flag:"-kernel"      json:"kernel,omitempty"`
 	Machine    QemuMachine            `flag:"-machine"     json:"machine,omitempty"`
@@ -30,6 +34,7 @@ type QemuConfig struct {
 	NoReboot   bool                   `flag:"-no-reboot"   json:"no_reboot,omitempty"`
 	NoShutdown bool                   `flag:"-no-shutdown" json:"no_shutdown,omitempty"`
 	NoStart    bool                   `flag:"-S"           json:"no_start,omitempty"`
+	Objects    []QemuObject           `flag:"-object"      json:"object,omitempty"`
 	Parallel   QemuHostCharDev        `flag:"-parallel"    json:"parallel,omitempty"`
 	PidFile    string                 `flag:"-pidfile"     json:"pidfile,omitempty"`
 	QMP        []QemuHostCharDev      `flag:"-qmp"         json:"qmp,omitempty"`
@@ -65,6 +70,13 @@ func WithAccel(accel QemuMachineAccelerator) QemuOption {
 	}
 }
 
+func WithAction(action QemuAction) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.Action = action
+		return nil
+	}
+}
+
 func WithAppend(append ...string) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.Append = strings.Join(append, " ")
@@ -117,6 +129,18 @@ func WithDisplay(display QemuDisplay) QemuOption {
 	}
 }
 
+func WithDrive(drive QemuDrive) QemuOption {
+	return func(qc *QemuConfig) error {
+		if qc.Drives == nil {
+			qc.Drives = make([]QemuDrive, 0)
+		}
+
+		qc.Drives = append(qc.Drives, drive)
+
+		return nil
+	}
+}
+
 func WithEnableKVM(enableKVM bool) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.EnableKVM = enableKVM
@@ -136,6 +160,20 @@ func WithFsDevice(fsdev QemuFsDev) QemuOption {
 	}
 }
 
+func WithGDB(chardev QemuHostCharDev) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.GDB = chardev
+		return nil
+	}
+}
+
+func WithIncoming(uri string) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.Incoming = uri
+		return nil
+	}
+}
+
 func WithInitRd(initrd string) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.InitRd = initrd
@@ -232,6 +270,18 @@ func WithNoStart(noStart bool) QemuOption {
 	}
 }
 
+func WithObject(object QemuObject) QemuOption {
+	return func(qc *QemuConfig) error {
+		if qc.Objects == nil {
+			qc.Objects = make([]QemuObject, 0)
+		}
+
+		qc.Objects = append(qc.Objects, object)
+
+		return nil
+	}
+}
+
 func WithParallel(chardev QemuHostCharDev) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.Parallel = chardev
@@ -19,7 +19,7 @@ func init() {
 	// Character devices
 	// gob.Register(QemuCharDevNull{})
 	// gob.Register(QemuCharDevSocketTCP{})
-	// gob.Register(QemuCharDevSocketUnix{})
+	gob.Register(QemuCharDevSocketUnix{})
 	// gob.Register(QemuCharDevUdp{})
 	// gob.Register(QemuCharDevVirtualConsole{})
 	// gob.Register(QemuCharDevRingBuf{})
@@ -39,13 +39,13 @@ func init() {
 	// gob.Register(QemuHostCharDevPty{})
 	gob.Register(QemuHostCharDevNone{})
 	// gob.Register(QemuHostCharDevNull{})
-	// gob.Register(QemuHostCharDevNamed{})
+	gob.Register(QemuHostCharDevNamed{})
 	// gob.Register(QemuHostCharDevTty{})
 	gob.Register(QemuHostCharDevFile{})
 	// gob.Register(QemuHostCharDevStdio{})
 	// gob.Register(QemuHostCharDevPipe{})
 	// gob.Register(QemuHostCharDevUDP{})
-	// gob.Register(QemuHostCharDevTCP{})
+	gob.Register(QemuHostCharDevTCP{})
 	// gob.Register(QemuHostCharDevTelnet{})
 	// gob.Register(QemuHostCharDevWebsocket{})
 	gob.Register(QemuHostCharDevUnix{})
@@ -280,7 +280,7 @@ func init() {
 	// gob.Register(QemuDeviceVhostVsockPci{})
 	// gob.Register(QemuDeviceVhostVsockPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtioBalloonDevice{})
-	// gob.Register(QemuDeviceVirtioBalloonPci{})
+	gob.Register(QemuDeviceVirtioBalloonPci{})
 	// gob.Register(QemuDeviceVirtioBalloonPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtioBalloonPciTransitional{})
 	// gob.Register(QemuDeviceVirtioCryptoDevice{})
@@ -388,7 +388,7 @@ func init() {
 	// gob.Register(QemuDeviceVhostUserBlkPciNonTransitional{})
 	// gob.Register(QemuDeviceVhostUserBlkPciTransitional{})
 	// gob.Register(QemuDeviceVhostUserFsDevice{})
-	// gob.Register(QemuDeviceVhostUserFsPci{})
+	gob.Register(QemuDeviceVhostUserFsPci{})
 	// gob.Register(QemuDeviceVhostUserScsi{})
 	// gob.Register(QemuDeviceVhostUserScsiPci{})
 	// gob.Register(QemuDeviceVhostUserScsiPciNonTransitional{})
@@ -398,7 +398,7 @@ func init() {
 	// gob.Register(QemuDeviceVirtio9pPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtio9pPciTransitional{})
 	// gob.Register(QemuDeviceVirtioBlkDevice{})
-	// gob.Register(QemuDeviceVirtioBlkPci{})
+	gob.Register(QemuDeviceVirtioBlkPci{})
 	// gob.Register(QemuDeviceVirtioBlkPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtioBlkPciTransitional{})
 	// gob.Register(QemuDeviceVirtioScsiDevice{})
@@ -472,6 +472,9 @@ func init() {
 	// gob.Register(QemuFsDevSynth{})
 	gob.Register(QemuFsDevLocalSecurityModelPassthrough)
 
+	// Objects
+	gob.Register(QemuObjectMemoryBackendMemfd{})
+
 	// CLI configuration
 	gob.Register(QemuConfig{})
 
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import "strings"
+
+type QemuActionReboot string
+
+const (
+	QemuActionRebootReset    = QemuActionReboot("reset")
+	QemuActionRebootShutdown = QemuActionReboot("shutdown")
+)
+
+type QemuActionShutdown string
+
+const (
+	QemuActionShutdownPoweroff = QemuActionShutdown("poweroff")
+	QemuActionShutdownPause    = QemuActionShutdown("pause")
+)
+
+type QemuActionPanic string
+
+const (
+	QemuActionPanicPause       = QemuActionPanic("pause")
+	QemuActionPanicShutdown    = QemuActionPanic("shutdown")
+	QemuActionPanicExitFailure = QemuActionPanic("exit-failure")
+	QemuActionPanicNone        = QemuActionPanic("none")
+)
+
+// QemuAction represents the actions QEMU takes in response to specific guest
+// events.
+type QemuAction struct {
+	Reboot   QemuActionReboot   `json:"reboot,omitempty"`
+	Shutdown QemuActionShutdown `json:"shutdown,omitempty"`
+	Panic    QemuActionPanic    `json:"panic,omitempty"`
+}
+
+func (qa QemuAction) String() string {
+	var ret []string
+
+	if qa.Reboot != "" {
+		ret = append(ret, "reboot="+string(qa.Reboot))
+	}
+	if qa.Shutdown != "" {
+		ret = append(ret, "shutdown="+string(qa.Shutdown))
+	}
+	if qa.Panic != "" {
+		ret = append(ret, "panic="+string(qa.Panic))
+	}
+
+	return strings.Join(ret, ",")
+}
@@ -242,15 +242,16 @@ func (cd QemuCharDevSocketUnix) String() string {
 			ret.WriteString(",logappend=off")
 		}
 	}
+	// The abstract and tight options are only available on Linux hosts, so only
+	// set them when an abstract socket has been requested.
 	if cd.Abstract {
 		ret.WriteString(",abstract=on")
-	} else {
-		ret.WriteString(",abstract=off")
-	}
-	if cd.Tight {
-		ret.WriteString(",tight=on")
-	} else {
-		ret.WriteString(",tight=off")
+
+		if cd.Tight {
+			ret.WriteString(",tight=on")
+		} else {
+			ret.WriteString(",tight=off")
+		}
 	}
 
 	return ret.String()
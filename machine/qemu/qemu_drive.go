@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import "strings"
+
+type QemuDriveInterface string
+
+const (
+	QemuDriveInterfaceNone   = QemuDriveInterface("none")
+	QemuDriveInterfaceVirtio = QemuDriveInterface("virtio")
+)
+
+type QemuDriveFormat string
+
+const (
+	QemuDriveFormatRaw   = QemuDriveFormat("raw")
+	QemuDriveFormatQcow2 = QemuDriveFormat("qcow2")
+)
+
+// QemuDrive represents a drive which is backed by a disk image on the host and
+// which is attached to a device of the guest via its id.
+type QemuDrive struct {
+	Id       string             `json:"id,omitempty"`
+	File     string             `json:"file,omitempty"`
+	Format   QemuDriveFormat    `json:"format,omitempty"`
+	If       QemuDriveInterface `json:"if,omitempty"`
+	ReadOnly bool               `json:"readonly,omitempty"`
+}
+
+// String returns a QEMU command-line compatible drive string with the format:
+// file=file,id=id[,format=format][,if=if][,readonly=on]
+func (qd QemuDrive) String() string {
+	if len(qd.File) == 0 {
+		// Cannot stringify drive without file
+		return ""
+	}
+
+	var ret strings.Builder
+
+	// Commas in the path of the file are escaped by doubling them.
+	ret.WriteString("file=")
+	ret.WriteString(strings.ReplaceAll(qd.File, ",", ",,"))
+
+	if len(qd.Id) > 0 {
+		ret.WriteString(",id=")
+		ret.WriteString(qd.Id)
+	}
+	if len(qd.Format) > 0 {
+		ret.WriteString(",format=")
+		ret.WriteString(string(qd.Format))
+	}
+	if len(qd.If) > 0 {
+		ret.WriteString(",if=")
+		ret.WriteString(string(qd.If))
+	}
+	if qd.ReadOnly {
+		ret.WriteString(",readonly=on")
+	}
+
+	return ret.String()
+}
@@ -119,6 +119,7 @@ type QemuMachine struct {
 	SupressVMDesc bool                     `json:"suppress_vmdesc,omitempty"`
 	NVDIMM        bool                     `json:"nvdimm,omitempty"`
 	HMAT          bool                     `json:"hmat,omitempty"`
+	MemoryBackend string                   `json:"memory_backend,omitempty"`
 
 	// Added in QEMU 8.0.0
 	Graphics bool `json:"graphics,omitempty"`
@@ -176,6 +177,10 @@ func (qm QemuMachine) String() string {
 	if qm.HMAT {
 		ret.WriteString(",hmat=on")
 	}
+	if len(qm.MemoryBackend) > 0 {
+		ret.WriteString(",memory-backend=")
+		ret.WriteString(qm.MemoryBackend)
+	}
 
 	// Added in QEMU 8.0.0
 	if qm.HMAT {
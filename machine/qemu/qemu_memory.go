@@ -27,6 +27,21 @@ const (
 	QemuMemoryDefault = 64
 )
 
+// Bytes returns the size of the memory in bytes.
+func (qm QemuMemory) Bytes() uint64 {
+	size := qm.Size
+	if size == 0 {
+		size = QemuMemoryDefault
+	}
+
+	switch qm.Unit {
+	case QemuMemoryUnitGB:
+		return size << 30
+	default:
+		return size << 20
+	}
+}
+
 func (qm QemuMemory) String() string {
 	if qm.Size == 0 && len(qm.Unit) == 0 {
 		return ""
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type QemuObject interface {
+	fmt.Stringer
+}
+
+type QemuObjectType string
+
+const (
+	QemuObjectTypeMemoryBackendMemfd = QemuObjectType("memory-backend-memfd")
+)
+
+// QemuObjectMemoryBackendMemfd represents guest memory which is backed by an
+// anonymous file.  Shared memory is required by vhost-user devices, such that
+// the backend process can access the memory of the guest.
+type QemuObjectMemoryBackendMemfd struct {
+	Id    string `json:"id,omitempty"`
+	Size  uint64 `json:"size,omitempty"`
+	Share bool   `json:"share,omitempty"`
+}
+
+// String returns a QEMU command-line compatible object string with the format:
+// memory-backend-memfd,id=id,size=size[,share=on]
+func (obj QemuObjectMemoryBackendMemfd) String() string {
+	if len(obj.Id) == 0 {
+		// Cannot stringify object without id
+		return ""
+	}
+
+	var ret strings.Builder
+
+	ret.WriteString(string(QemuObjectTypeMemoryBackendMemfd))
+	ret.WriteString(",id=")
+	ret.WriteString(obj.Id)
+	ret.WriteString(",size=")
+	ret.WriteString(strconv.FormatUint(obj.Size, 10))
+
+	if obj.Share {
+		ret.WriteString(",share=on")
+	}
+
+	return ret.String()
+}
@@ -27,3 +27,69 @@ type SystemPowerdownRequest struct {
 type SystemWakeupRequest struct {
 	Execute string `json:"execute" default:"system_Wakeup"`
 }
+
+// List of properties to be used for hotplugging a CPU instance, it should be
+// passed by management with device_add command when a CPU is being hotplugged.
+//
+// Since: 2.7
+type CpuInstanceProperties struct {
+	// NUMA node ID the CPU belongs to
+	NodeId int64 `json:"node-id,omitempty"`
+	// socket number within node/board the CPU belongs to
+	SocketId int64 `json:"socket-id"`
+	// die number within socket the CPU belongs to (since 4.1)
+	DieId int64 `json:"die-id,omitempty"`
+	// core number within die the CPU belongs to
+	CoreId int64 `json:"core-id"`
+	// thread number within core the CPU belongs to
+	ThreadId int64 `json:"thread-id"`
+}
+
+type QueryCpusFastRequest struct {
+	Execute string `json:"execute" default:"query-cpus-fast"`
+}
+
+// Information about a virtual CPU
+//
+// Since: 2.12
+type CpuInfoFast struct {
+	// index of the virtual CPU
+	CpuIndex int64 `json:"cpu-index"`
+	// path to the CPU object in the QOM tree
+	QomPath string `json:"qom-path"`
+	// ID of the underlying host thread
+	ThreadId int64 `json:"thread-id"`
+	// properties describing to which node/socket/core/thread virtual CPU
+	// belongs to, provided if supported by board
+	Props CpuInstanceProperties `json:"props"`
+	// the QEMU system emulation target, which determines which additional
+	// fields will be listed (since 3.0)
+	Target string `json:"target"`
+}
+
+type QueryCpusFastResponse struct {
+	Return []CpuInfoFast `json:"return"`
+	Error  ErrorResponse `json:"error"`
+}
+
+type QueryHotpluggableCpusRequest struct {
+	Execute string `json:"execute" default:"query-hotpluggable-cpus"`
+}
+
+// Since: 2.7
+type HotpluggableCPU struct {
+	// CPU object type for usage with device_add command
+	Type string `json:"type"`
+	// number of logical VCPU threads HotpluggableCPU provides
+	VcpusCount int64 `json:"vcpus-count"`
+	// list of properties to be used for hotplugging CPU
+	Props CpuInstanceProperties `json:"props"`
+	// link to existing CPU object if CPU is present or omitted if CPU is not
+	// present.
+	QomPath string `json:"qom-path"`
+}
+
+type QueryHotpluggableCpusResponse struct {
+	Return []HotpluggableCPU `json:"return"`
+	Error  ErrorResponse     `json:"error"`
+}
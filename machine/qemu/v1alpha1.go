@@ -5,6 +5,7 @@
 package qemu
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
@@ -14,8 +15,10 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	zip "api.zip"
@@ -26,15 +29,21 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apiserver/pkg/storage"
 
 	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
 	"kraftkit.sh/config"
 	"kraftkit.sh/exec"
 	"kraftkit.sh/internal/logtail"
 	"kraftkit.sh/internal/retrytimeout"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/health"
 	"kraftkit.sh/machine/network/macaddr"
 	"kraftkit.sh/machine/qemu/qmp"
 	qmpapi "kraftkit.sh/machine/qemu/qmp/v7alpha2"
+	"kraftkit.sh/machine/volume/blockdev"
+	"kraftkit.sh/machine/volume/virtiofs"
 	"kraftkit.sh/unikraft/export/v0/ukargparse"
 	"kraftkit.sh/unikraft/export/v0/uknetdev"
 	"kraftkit.sh/unikraft/export/v0/vfscore"
@@ -42,12 +51,16 @@ import (
 
 // machineV1alpha1Service ...
 type machineV1alpha1Service struct {
-	eopts []exec.ExecOption
+	eopts         []exec.ExecOption
+	crashLogLines int
+	store         zip.Store
 }
 
 // NewMachineV1alpha1Service implements kraftkit.sh/machine/platform.NewStrategyConstructor
 func NewMachineV1alpha1Service(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
-	service := machineV1alpha1Service{}
+	service := machineV1alpha1Service{
+		crashLogLines: DefaultCrashLogLines,
+	}
 
 	for _, opt := range opts {
 		qopt, ok := opt.(MachineServiceV1alpha1Option)
@@ -73,17 +86,9 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("supplied kernel path does not exist: %s", machine.Status.KernelPath)
 	}
 
-	var bin string
-
-	switch machine.Spec.Architecture {
-	case "x86_64", "amd64":
-		bin = QemuSystemX86
-	case "arm":
-		bin = QemuSystemArm
-	case "arm64":
-		bin = QemuSystemAarch64
-	default:
-		return nil, fmt.Errorf("unsupported architecture: %s", machine.Spec.Architecture)
+	bin, err := qemuBinary(machine.Spec.Architecture)
+	if err != nil {
+		return nil, err
 	}
 
 	// Determine the version of QEMU so as to both determine whether it is a
@@ -148,6 +153,8 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		machine.Status.LogFile = filepath.Join(machine.Status.StateDir, "machine.log")
 	}
 
+	machine.Status.ConsoleSocket = filepath.Join(machine.Status.StateDir, "qemu_serial.sock")
+
 	if machine.Spec.Resources.Requests == nil {
 		machine.Spec.Resources.Requests = make(corev1.ResourceList, 2)
 	}
@@ -172,6 +179,27 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		machine.Spec.Resources.Requests[corev1.ResourceCPU] = quantity
 	}
 
+	// The amount of memory the machine is booted with represents the upper bound
+	// of memory which can later be made available to the guest via the balloon
+	// device, so prefer the limit if it has been provided.
+	memory := machine.Spec.Resources.Requests.Memory().Value()
+	if limit := machine.Spec.Resources.Limits.Memory().Value(); limit > memory {
+		memory = limit
+	}
+
+	// Similarly, any CPU limit represents the maximum number of vCPUs which can
+	// later be hotplugged.
+	var maxCPUs uint64
+	if limit := machine.Spec.Resources.Limits.Cpu().Value(); limit > machine.Spec.Resources.Requests.Cpu().Value() {
+		maxCPUs = uint64(limit)
+	}
+
+	qmem := QemuMemory{
+		// The value returned from Memory() is in bytes
+		Size: uint64(memory / 1000000),
+		Unit: QemuMemoryUnitMB,
+	}
+
 	qopts := []QemuOption{
 		WithDaemonize(true),
 		WithNoGraphic(true),
@@ -181,11 +209,10 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		WithName(string(machine.ObjectMeta.UID)),
 		WithKernel(machine.Status.KernelPath),
 		WithVGA(QemuVGANone),
-		WithMemory(QemuMemory{
-			// The value returned from Memory() is in bytes
-			Size: uint64(machine.Spec.Resources.Requests.Memory().Value() / 1000000),
-			Unit: QemuMemoryUnitMB,
-		}),
+		WithMemory(qmem),
+		// Attach a balloon device such that the memory of the guest can be
+		// adjusted at runtime.
+		WithDevice(QemuDeviceVirtioBalloonPci{}),
 		// Create a QMP connection solely for manipulating the machine
 		WithQMP(QemuHostCharDevUnix{
 			SocketDir: machine.Status.StateDir,
@@ -200,9 +227,18 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 			NoWait:    true,
 			Server:    true,
 		}),
-		WithSerial(QemuHostCharDevFile{
-			Monitor:  false,
-			Filename: machine.Status.LogFile,
+		// Connect the serial console to a socket such that it can be attached to,
+		// whilst also recording all output to the log file.
+		WithCharDevice(QemuCharDevSocketUnix{
+			Id:        "qemu_serial",
+			Path:      machine.Status.ConsoleSocket,
+			Server:    true,
+			NoWait:    true,
+			LogFile:   machine.Status.LogFile,
+			LogAppend: true,
+		}),
+		WithSerial(QemuHostCharDevNamed{
+			Id: "qemu_serial",
 		}),
 		WithMonitor(QemuHostCharDevUnix{
 			SocketDir: machine.Status.StateDir,
@@ -212,6 +248,7 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		}),
 		WithSMP(QemuSMP{
 			CPUs:    uint64(machine.Spec.Resources.Requests.Cpu().Value()),
+			MaxCPUs: maxCPUs,
 			Threads: 1,
 			Sockets: 1,
 		}),
@@ -223,6 +260,14 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		WithParallel(QemuHostCharDevNone{}),
 	}
 
+	// Keep the guest around when it panics such that its memory can be dumped
+	// before it is shutdown.  The action is only supported since QEMU 6.0.
+	if !qemuVersion.LessThan(QemuVersion6_0_0) {
+		qopts = append(qopts, WithAction(QemuAction{
+			Panic: QemuActionPanicPause,
+		}))
+	}
+
 	// TODO: Parse Rootfs types
 	if len(machine.Status.InitrdPath) > 0 {
 		qopts = append(qopts,
@@ -230,7 +275,45 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		)
 	}
 
-	if len(machine.Spec.Ports) > 0 {
+	// Expose a GDB stub.  Since the machine is not started, a debugger is able
+	// to connect before the first instruction is executed.
+	if len(machine.Spec.GDBStub) > 0 {
+		if port, err := strconv.Atoi(machine.Spec.GDBStub); err == nil {
+			qopts = append(qopts,
+				WithGDB(QemuHostCharDevTCP{
+					Host:   "localhost",
+					Port:   port,
+					Server: true,
+					NoWait: true,
+				}),
+			)
+			machine.Status.GDBStub = fmt.Sprintf("localhost:%d", port)
+		} else {
+			path, err := filepath.Abs(machine.Spec.GDBStub)
+			if err != nil {
+				return machine, fmt.Errorf("could not determine GDB stub socket path: %w", err)
+			}
+
+			qopts = append(qopts,
+				WithGDB(QemuHostCharDevUnix{
+					Path:   path,
+					Server: true,
+					NoWait: true,
+				}),
+			)
+			machine.Status.GDBStub = "unix::" + path
+		}
+	}
+
+	if incoming, err := incomingOption(machine); err != nil {
+		return machine, err
+	} else if incoming != nil {
+		qopts = append(qopts, incoming)
+	}
+
+	// Ports of machines which are attached to a network are published by the
+	// network itself.
+	if len(machine.Spec.Ports) > 0 && len(machine.Spec.Networks) == 0 {
 		// Start MAC addresses iteratively.
 		startMac, err := macaddr.GenerateMacAddress(true)
 		if err != nil {
@@ -252,7 +335,7 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 				}),
 				WithNetDevice(QemuNetDevUser{
 					Id:      hostnetid,
-					Hostfwd: fmt.Sprintf("%s::%d-:%d", port.Protocol, port.HostPort, port.MachinePort),
+					Hostfwd: []string{fmt.Sprintf("%s::%d-:%d", port.Protocol, port.HostPort, port.MachinePort)},
 				}),
 			)
 		}
@@ -263,67 +346,21 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, err
 	}
 
-	if len(machine.Spec.Networks) > 0 {
-		// Start MAC addresses iteratively.  Each interface will have the last
-		// hexdecimal byte increase by 1 starting at 1, allowing for easy-to-spot
-		// interface IDs from the MAC address.  The return value below returns `:00`
-		// as the last byte.
-		startMac, err := macaddr.GenerateMacAddress(true)
-		if err != nil {
-			return machine, err
-		}
-
-		i := 0 // host network ID.
+	netopts, kernelArgs, err := networkOptions(machine, kernelArgs)
+	if err != nil {
+		return machine, err
+	}
 
-		// Iterate over each interface of each network interface associated with
-		// this machine and attach it as a device.
-		for _, network := range machine.Spec.Networks {
-			for _, iface := range network.Interfaces {
-				mac := iface.Spec.MacAddress
-				if mac == "" {
-					// Increase the MAC address value by 1 such that we are able to
-					// identify interface IDs.
-					startMac = macaddr.IncrementMacAddress(startMac)
-					mac = startMac.String()
-				}
+	qopts = append(qopts, netopts...)
 
-				hostnetid := fmt.Sprintf("hostnet%d", i)
-				qopts = append(qopts,
-					// TODO(nderjung): The network device should be customizable based on
-					// the network spec or machine spec.  Additional insight can be provided
-					// by inspecting the KConfig options.  Potentially the MachineSpec is
-					// updated to reflect different systems or provide access to the
-					// KConfig values.
-					WithDevice(QemuDeviceVirtioNetPci{
-						Netdev: hostnetid,
-						Mac:    mac,
-					}),
-					WithNetDevice(QemuNetDevTap{
-						Id:         hostnetid,
-						Ifname:     iface.Spec.IfName,
-						Br:         network.IfName,
-						Script:     "no", // Disable execution
-						Downscript: "no", // Disable execution
-					}),
-				)
+	var fstab []string
 
-				// Assign the first interface statically via command-line arguments, also
-				// checking if the built-in arguments for
-				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && i == 0 {
-					kernelArgs = append(kernelArgs,
-						uknetdev.ParamIpv4Addr.WithValue(iface.Spec.IP),
-						uknetdev.ParamIpv4GwAddr.WithValue(network.Gateway),
-						uknetdev.ParamIpv4SubnetMask.WithValue(network.Netmask),
-					)
-				}
+	// The identifier of the shared memory backend of the guest, which is only
+	// set when a vhost-user device requires access to the memory of the guest.
+	var memoryBackend string
 
-				// Increment the host network ID for additional interfaces.
-				i++
-			}
-		}
-	}
-
-	var fstab []string
+	// The number of block devices which have been attached so far.
+	blkdevs := 0
 
 	for i, vol := range machine.Spec.Volumes {
 		switch vol.Spec.Driver {
@@ -352,6 +389,65 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 				"",
 			).String())
 
+		case blockdev.DriverName:
+			driveid := fmt.Sprintf("hblk%d", blkdevs)
+			qopts = append(qopts,
+				WithDrive(QemuDrive{
+					Id:       driveid,
+					File:     vol.Spec.Source,
+					Format:   QemuDriveFormat(vol.Spec.Format),
+					If:       QemuDriveInterfaceNone,
+					ReadOnly: vol.Spec.ReadOnly,
+				}),
+				WithDevice(QemuDeviceVirtioBlkPci{
+					Drive:  driveid,
+					Serial: blockdev.DeviceName(blkdevs),
+				}),
+			)
+
+			if entry, ok := blockdev.FstabEntry(blkdevs, vol); ok {
+				fstab = append(fstab, entry)
+			} else {
+				log.G(ctx).Warnf("attaching %s without mounting it: unknown filesystem", vol.Spec.Source)
+			}
+
+			blkdevs++
+
+		case virtiofs.DriverName:
+			chardevid := fmt.Sprintf("hvirtiofs%d", i+1)
+			mounttag := fmt.Sprintf("fs%d", i+1)
+
+			// virtiofsd is started with each launch of QEMU, see launch.
+			qopts = append(qopts,
+				WithCharDevice(QemuCharDevSocketUnix{
+					Id:   chardevid,
+					Path: virtiofs.SocketPath(machine.Status.StateDir, mounttag),
+				}),
+				WithDevice(QemuDeviceVhostUserFsPci{
+					Chardev: chardevid,
+					Tag:     mounttag,
+				}),
+			)
+
+			if memoryBackend == "" {
+				memoryBackend = "mem"
+				qopts = append(qopts,
+					WithObject(QemuObjectMemoryBackendMemfd{
+						Id:    memoryBackend,
+						Size:  qmem.Bytes(),
+						Share: true,
+					}),
+				)
+			}
+
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				mounttag,
+				vol.Spec.Destination,
+				vol.Spec.Driver,
+				"",
+				"",
+			).String())
+
 		default:
 			return machine, fmt.Errorf("unsupported QEMU volume driver: %v", vol.Spec.Driver)
 		}
@@ -381,7 +477,8 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		if machine.Spec.Emulation {
 			qopts = append(qopts,
 				WithMachine(QemuMachine{
-					Type: QemuMachineTypePC,
+					Type:          QemuMachineTypePC,
+					MemoryBackend: memoryBackend,
 				}),
 				WithCPU(QemuCPU{
 					CPU: QemuCPUX86Qemu64,
@@ -393,8 +490,9 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 			qopts = append(qopts,
 				WithEnableKVM(true),
 				WithMachine(QemuMachine{
-					Type:         QemuMachineTypePC,
-					Accelerators: []QemuMachineAccelerator{QemuMachineAccelKVM},
+					Type:          QemuMachineTypePC,
+					Accelerators:  []QemuMachineAccelerator{QemuMachineAccelKVM},
+					MemoryBackend: memoryBackend,
 				}),
 				WithCPU(QemuCPU{
 					CPU: QemuCPUX86Host,
@@ -403,82 +501,647 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 				}),
 			)
 		}
-		if qemuVersion.LessThan(QemuVersion8_0_0) {
-			qopts = append(qopts,
-				WithDevice(QemuDeviceSga{}),
-			)
+		if qemuVersion.LessThan(QemuVersion8_0_0) {
+			qopts = append(qopts,
+				WithDevice(QemuDeviceSga{}),
+			)
+		}
+	case "arm", "arm64":
+		qopts = append(qopts,
+			WithMachine(QemuMachine{
+				Type:          QemuMachineTypeVirt,
+				MemoryBackend: memoryBackend,
+			}),
+			WithCPU(QemuCPU{
+				CPU: QemuCPUArmCortexA53,
+			}),
+		)
+
+	default:
+		return nil, fmt.Errorf("unsupported architecture: %s", machine.Spec.Architecture)
+	}
+
+	qcfg, err := NewQemuConfig(qopts...)
+	if err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return machine, fmt.Errorf("could not generate QEMU config: %v", err)
+	}
+
+	machine.Status.PlatformConfig = *qcfg
+	machine.CreationTimestamp = metav1.Now()
+
+	if err := service.launch(ctx, machine, bin, qcfg); err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return machine, err
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateCreated
+
+	return machine, nil
+}
+
+// qemuBinary returns the name of the QEMU system emulator binary for the
+// provided architecture.
+func qemuBinary(arch string) (string, error) {
+	switch arch {
+	case "x86_64", "amd64":
+		return QemuSystemX86, nil
+	case "arm":
+		return QemuSystemArm, nil
+	case "arm64":
+		return QemuSystemAarch64, nil
+	default:
+		return "", fmt.Errorf("unsupported architecture: %s", arch)
+	}
+}
+
+// userNetDev returns the user-mode (SLIRP) network device for the provided
+// interface of a user-mode network.  The ports of the interface are published
+// on the host via QEMU's host forwarding rules.
+func userNetDev(id string, network networkv1alpha1.NetworkSpec, iface networkv1alpha1.NetworkInterfaceTemplateSpec) (QemuNetDevUser, error) {
+	netdev := QemuNetDevUser{
+		Id:   id,
+		Host: network.Gateway,
+	}
+
+	gateway := net.ParseIP(network.Gateway)
+	netmask := net.ParseIP(network.Netmask).To4()
+	if gateway == nil || netmask == nil {
+		return netdev, fmt.Errorf("invalid user-mode network %s: %s/%s", network.IfName, network.Gateway, network.Netmask)
+	}
+
+	ones, _ := net.IPMask(netmask).Size()
+	netdev.Net = fmt.Sprintf("%s/%d", gateway.Mask(net.IPMask(netmask)), ones)
+
+	for _, port := range iface.Spec.Ports {
+		protocol := strings.ToLower(string(port.Protocol))
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		netdev.Hostfwd = append(netdev.Hostfwd, fmt.Sprintf("%s:%s:%d-%s:%d",
+			protocol,
+			port.HostIP,
+			port.HostPort,
+			iface.Spec.IP,
+			port.InterfacePort,
+		))
+	}
+
+	return netdev, nil
+}
+
+// networkOptions returns the options which attach the interfaces of the
+// networks of the provided machine, along with the provided kernel arguments
+// extended by the static configuration of its first interface.  The tap
+// devices of macvtap interfaces are referenced by the file descriptors which
+// they are passed as by launch, see macvtapFiles.
+func networkOptions(machine *machinev1alpha1.Machine, kernelArgs ukargparse.Params) ([]QemuOption, ukargparse.Params, error) {
+	var qopts []QemuOption
+
+	if len(machine.Spec.Networks) == 0 {
+		return qopts, kernelArgs, nil
+	}
+
+	// Start MAC addresses iteratively.  Each interface will have the last
+	// hexdecimal byte increase by 1 starting at 1, allowing for easy-to-spot
+	// interface IDs from the MAC address.  The return value below returns `:00`
+	// as the last byte.
+	startMac, err := macaddr.GenerateMacAddress(true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	i := 0 // host network ID.
+	fd := macvtapFirstFd
+
+	// Iterate over each interface of each network interface associated with
+	// this machine and attach it as a device.
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			mac := iface.Spec.MacAddress
+			if mac == "" {
+				// Increase the MAC address value by 1 such that we are able to
+				// identify interface IDs.
+				startMac = macaddr.IncrementMacAddress(startMac)
+				mac = startMac.String()
+			}
+
+			hostnetid := fmt.Sprintf("hostnet%d", i)
+			qopts = append(qopts,
+				// TODO(nderjung): The network device should be customizable based on
+				// the network spec or machine spec.  Additional insight can be provided
+				// by inspecting the KConfig options.  Potentially the MachineSpec is
+				// updated to reflect different systems or provide access to the
+				// KConfig values.
+				WithDevice(QemuDeviceVirtioNetPci{
+					Netdev: hostnetid,
+					Mac:    mac,
+				}),
+			)
+
+			switch network.Driver {
+			case "user":
+				netdev, err := userNetDev(hostnetid, network, iface)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				qopts = append(qopts, WithNetDevice(netdev))
+
+			case "macvtap":
+				// The tap device of the macvtap link is opened when QEMU is launched
+				// and passed in the same order as the interfaces are iterated here.
+				qopts = append(qopts,
+					WithNetDevice(QemuNetDevTap{
+						Id: hostnetid,
+						Fd: fd,
+					}),
+				)
+				fd++
+
+			default:
+				qopts = append(qopts,
+					WithNetDevice(QemuNetDevTap{
+						Id:         hostnetid,
+						Ifname:     iface.Spec.IfName,
+						Br:         network.IfName,
+						Script:     "no", // Disable execution
+						Downscript: "no", // Disable execution
+					}),
+				)
+			}
+
+			// Assign the first interface statically via command-line arguments, also
+			// checking if the built-in arguments for
+			if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && i == 0 && iface.Spec.IP != "" {
+				kernelArgs = append(kernelArgs,
+					uknetdev.ParamIpv4Addr.WithValue(iface.Spec.IP),
+					uknetdev.ParamIpv4GwAddr.WithValue(network.Gateway),
+					uknetdev.ParamIpv4SubnetMask.WithValue(network.Netmask),
+				)
+			}
+
+			// Point the first interface at the embedded DNS server of the network.
+			if !kernelArgs.Contains(uknetdev.ParamIpv4Dns0) && i == 0 && network.DNS && network.Gateway != "" {
+				kernelArgs = append(kernelArgs,
+					uknetdev.ParamIpv4Dns0.WithValue(network.Gateway),
+				)
+			}
+
+			// Increment the host network ID for additional interfaces.
+			i++
+		}
+	}
+
+	return qopts, kernelArgs, nil
+}
+
+// macvtapFirstFd is the file descriptor of the first tap device which is
+// passed to QEMU, following stdin, stdout and stderr.
+const macvtapFirstFd = 3
+
+// macvtapInterfaces returns the names of the macvtap links of the interfaces
+// of the provided machine in the order in which they are attached.
+func macvtapInterfaces(machine *machinev1alpha1.Machine) []string {
+	var ifnames []string
+
+	for _, network := range machine.Spec.Networks {
+		if network.Driver != "macvtap" {
+			continue
+		}
+
+		for _, iface := range network.Interfaces {
+			ifnames = append(ifnames, iface.Spec.IfName)
+		}
+	}
+
+	return ifnames
+}
+
+// macvtapFiles opens the tap devices of the macvtap interfaces of the provided
+// machine in the order in which they are attached, such that the first device
+// is inherited by QEMU as macvtapFirstFd, the second as macvtapFirstFd+1, etc.
+func macvtapFiles(machine *machinev1alpha1.Machine) ([]*os.File, error) {
+	var files []*os.File
+
+	for _, ifname := range macvtapInterfaces(machine) {
+		link, err := net.InterfaceByName(ifname)
+		if err != nil {
+			closeFiles(files)
+			return nil, fmt.Errorf("could not get macvtap link %s: %v", ifname, err)
+		}
+
+		// The character device of a macvtap link is named after its index.
+		f, err := os.OpenFile(fmt.Sprintf("/dev/tap%d", link.Index), os.O_RDWR, 0)
+		if err != nil {
+			closeFiles(files)
+			return nil, fmt.Errorf("could not open tap device of %s: %v", ifname, err)
+		}
+
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// closeFiles closes all of the provided files.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// startVirtiofsDaemons starts the virtiofsd instances which serve the virtiofs
+// volumes of the provided machine, in the same order as the volumes are
+// iterated in Create.
+func startVirtiofsDaemons(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	for i, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver != virtiofs.DriverName {
+			continue
+		}
+
+		socket := virtiofs.SocketPath(machine.Status.StateDir, fmt.Sprintf("fs%d", i+1))
+		if err := virtiofs.StartDaemon(ctx, vol.Spec.Source, socket); err != nil {
+			stopVirtiofsDaemons(ctx, machine)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopVirtiofsDaemons stops any virtiofsd instances of the provided machine
+// which are still running.
+func stopVirtiofsDaemons(ctx context.Context, machine *machinev1alpha1.Machine) {
+	for i, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver != virtiofs.DriverName {
+			continue
+		}
+
+		socket := virtiofs.SocketPath(machine.Status.StateDir, fmt.Sprintf("fs%d", i+1))
+		if err := virtiofs.StopDaemon(socket); err != nil {
+			log.G(ctx).Warnf("could not stop virtiofsd of %s: %v", vol.Spec.Source, err)
+		}
+	}
+}
+
+// VirtiofsCheckInterval is the interval at which the virtiofsd instances of a
+// watched machine are checked.
+const VirtiofsCheckInterval = time.Second
+
+// exitedVirtiofsDaemons returns the host paths of the virtiofs volumes of the
+// provided machine whose virtiofsd instance is no longer running.
+func exitedVirtiofsDaemons(machine *machinev1alpha1.Machine) []string {
+	var exited []string
+
+	for i, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver != virtiofs.DriverName {
+			continue
+		}
+
+		if !virtiofs.IsRunning(virtiofs.SocketPath(machine.Status.StateDir, fmt.Sprintf("fs%d", i+1))) {
+			exited = append(exited, vol.Spec.Source)
+		}
+	}
+
+	return exited
+}
+
+// superviseVirtiofsDaemons periodically checks the virtiofsd instances of the
+// provided machine until the context is cancelled.  Once any of them has
+// exited, the VMM is quit, since the volume can no longer be served to the
+// guest, and onExit is called beforehand such that the resulting shutdown can
+// be told apart from a regular one.
+func (service *machineV1alpha1Service) superviseVirtiofsDaemons(ctx context.Context, machine *machinev1alpha1.Machine, onExit func()) {
+	ticker := time.NewTicker(VirtiofsCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		exited := exitedVirtiofsDaemons(machine)
+		if len(exited) == 0 {
+			continue
+		}
+
+		log.G(ctx).
+			WithField("machine", machine.Name).
+			Warnf("virtiofsd of %s exited", strings.Join(exited, ", "))
+
+		onExit()
+
+		if err := service.quit(ctx, machine); err != nil {
+			log.G(ctx).Warnf("could not quit %s: %v", machine.Name, err)
+		}
+
+		return
+	}
+}
+
+func (service *machineV1alpha1Service) launch(ctx context.Context, machine *machinev1alpha1.Machine, bin string, qcfg *QemuConfig) error {
+	// Create a log file just for the QEMU process which can be used to debug
+	// issues when starting the VMM.
+	qemuLogFile := filepath.Join(machine.Status.StateDir, "qemu.log")
+	fi, err := os.Create(qemuLogFile)
+	if err != nil {
+		return err
+	}
+
+	defer fi.Close()
+
+	if err := startVirtiofsDaemons(ctx, machine); err != nil {
+		return err
+	}
+
+	files, err := macvtapFiles(machine)
+	if err != nil {
+		return err
+	}
+
+	// QEMU holds its own references to the devices once it has been launched.
+	defer closeFiles(files)
+
+	eopts := append(service.eopts,
+		exec.WithStdout(fi),
+		exec.WithExtraFiles(files...),
+	)
+
+	e, err := exec.NewExecutable(bin, *qcfg)
+	if err != nil {
+		return fmt.Errorf("could not prepare QEMU executable: %v", err)
+	}
+
+	process, err := exec.NewProcessFromExecutable(e, eopts...)
+	if err != nil {
+		return fmt.Errorf("could not prepare QEMU process: %v", err)
+	}
+
+	// Start and also wait for the process to be released, this ensures the
+	// program is actively being executed.
+	if err := process.StartAndWait(ctx); err != nil {
+		stopVirtiofsDaemons(ctx, machine)
+
+		// Propagate the contents of the QEMU log file as an error
+		if errLog, err2 := os.ReadFile(qemuLogFile); err2 == nil {
+			err = errors.Join(fmt.Errorf(strings.TrimSpace(string(errLog))), err)
+		}
+
+		return fmt.Errorf("could not start and wait for QEMU process: %v", err)
+	}
+
+	return nil
+}
+
+// Update implements kraftkit.sh/api/machine/v1alpha1.MachineService.  Only
+// the resource requests of a running or paused machine can be updated: memory
+// is adjusted via the balloon device and is bounded by the memory the machine
+// was created with; vCPUs are hotplugged and bounded by the CPU limit the
+// machine was created with.
+func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	switch machine.Status.State {
+	case machinev1alpha1.MachineStateRunning, machinev1alpha1.MachineStatePaused:
+	default:
+		return machine, fmt.Errorf("cannot update machine in %s state", machine.Status.State)
+	}
+
+	// Any change other than to the resource requests requires re-creating the
+	// machine, which can only be determined against the stored machine.
+	if service.store == nil {
+		return machine, fmt.Errorf("cannot update machine: no machine store provided")
+	}
+
+	stored, err := service.storedMachine(ctx, machine)
+	if err != nil {
+		return machine, err
+	}
+
+	var errs []error
+	for _, field := range specChanges(stored.Spec, machine.Spec) {
+		errs = append(errs, fmt.Errorf("cannot change the %s of an existing machine: please re-create it instead", field))
+	}
+
+	if len(errs) > 0 {
+		return machine, errors.Join(errs...)
+	}
+
+	qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not update qemu instance: %v", err)
+	}
+
+	defer qmpClient.Close()
+
+	if err := updateMemory(qmpClient, qcfg, machine.Spec.Resources.Requests.Memory().Value()); err != nil {
+		return machine, fmt.Errorf("could not update memory: %w", err)
+	}
+
+	if err := updateCPUs(qmpClient, qcfg, machine.Spec.Resources.Requests.Cpu().Value()); err != nil {
+		return machine, fmt.Errorf("could not update vCPUs: %w", err)
+	}
+
+	return machine, nil
+}
+
+// storedMachine returns the machine with the UID of the provided one as it
+// was last saved in the store.
+func (service *machineV1alpha1Service) storedMachine(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	var machines machinev1alpha1.MachineList
+	if err := service.store.GetList(ctx, "", storage.ListOptions{}, &machines); err != nil {
+		return nil, fmt.Errorf("could not list machines: %w", err)
+	}
+
+	for i := range machines.Items {
+		if machines.Items[i].UID == machine.UID {
+			return &machines.Items[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not find machine %s", machine.Name)
+}
+
+// specChanges returns the names of the fields of the provided specifications
+// which differ.  Resources are omitted since the requests are what is updated
+// and they are bounded by the limits of the platform configuration instead.
+func specChanges(before, after machinev1alpha1.MachineSpec) []string {
+	var changes []string
+
+	b := reflect.ValueOf(before)
+	a := reflect.ValueOf(after)
+
+	for i := 0; i < b.NumField(); i++ {
+		field := b.Type().Field(i)
+		if field.Name == "Resources" {
+			continue
 		}
-	case "arm", "arm64":
-		qopts = append(qopts,
-			WithMachine(QemuMachine{
-				Type: QemuMachineTypeVirt,
-			}),
-			WithCPU(QemuCPU{
-				CPU: QemuCPUArmCortexA53,
-			}),
-		)
 
-	default:
-		return nil, fmt.Errorf("unsupported architecture: %s", machine.Spec.Architecture)
+		// Empty and unset values are equivalent, since the store does not
+		// distinguish between them.
+		if isEmptyValue(b.Field(i)) && isEmptyValue(a.Field(i)) {
+			continue
+		}
+
+		if reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			continue
+		}
+
+		changes = append(changes, strings.Split(field.Tag.Get("json"), ",")[0])
 	}
 
-	// Create a log file just for the QEMU process which can be used to debug
-	// issues when starting the VMM.
-	qemuLogFile := filepath.Join(machine.Status.StateDir, "qemu.log")
-	fi, err := os.Create(qemuLogFile)
-	if err != nil {
-		return machine, err
+	return changes
+}
+
+// isEmptyValue returns whether the provided value is the zero value or an
+// empty slice or map.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
 	}
 
-	defer fi.Close()
+	return v.IsZero()
+}
 
-	service.eopts = append(service.eopts,
-		exec.WithStdout(fi),
-	)
+// updateMemory requests the balloon device of the guest to adjust the logical
+// size of the machine's memory to the provided number of bytes.
+func updateMemory(qmpClient *qmpapi.QEMUMachineProtocolClient, qcfg *QemuConfig, request int64) error {
+	if request <= 0 {
+		return nil
+	}
 
-	qcfg, err := NewQemuConfig(qopts...)
+	// Use the same conversion which is used when creating the machine.
+	target := (request / 1000000) << 20
+	if max := int64(qcfg.Memory.Bytes()); target > max {
+		return fmt.Errorf("cannot increase memory beyond the %d%s the machine was created with", qcfg.Memory.Size, qcfg.Memory.Unit)
+	}
+
+	balloon, err := qmpClient.QueryBalloon(qmpapi.QueryBalloonRequest{})
 	if err != nil {
-		machine.Status.State = machinev1alpha1.MachineStateFailed
-		return machine, fmt.Errorf("could not generate QEMU config: %v", err)
+		return err
+	} else if balloon.Error.Class != "" {
+		return fmt.Errorf("could not query balloon device: %s", balloon.Error.Cescription)
 	}
 
-	machine.Status.PlatformConfig = *qcfg
+	if balloon.Return.Actual == target {
+		return nil
+	}
 
-	e, err := exec.NewExecutable(bin, *qcfg)
+	res, err := qmpClient.Balloon(qmpapi.BalloonRequest{
+		Arguments: qmpapi.BalloonRequestArguments{
+			Value: target,
+		},
+	})
 	if err != nil {
-		machine.Status.State = machinev1alpha1.MachineStateFailed
-		return machine, fmt.Errorf("could not prepare QEMU executable: %v", err)
+		return err
+	} else if res.Error.Class != "" {
+		return fmt.Errorf("could not resize balloon device: %s", res.Error.Cescription)
 	}
 
-	process, err := exec.NewProcessFromExecutable(e, service.eopts...)
-	if err != nil {
-		machine.Status.State = machinev1alpha1.MachineStateFailed
-		return machine, fmt.Errorf("could not prepare QEMU process: %v", err)
+	return nil
+}
+
+// updateCPUs hotplugs or unplugs vCPUs until the machine has the requested
+// number of vCPUs.  Only vCPUs which have previously been hotplugged can be
+// removed.
+func updateCPUs(qmpClient *qmpapi.QEMUMachineProtocolClient, qcfg *QemuConfig, request int64) error {
+	if request <= 0 {
+		return nil
 	}
 
-	machine.CreationTimestamp = metav1.Now()
+	if qcfg.SMP.MaxCPUs == 0 {
+		if request != int64(qcfg.SMP.CPUs) {
+			return fmt.Errorf("cannot change the number of vCPUs of a machine created without a CPU limit")
+		}
 
-	// Start and also wait for the process to be released, this ensures the
-	// program is actively being executed.
-	if err := process.StartAndWait(ctx); err != nil {
-		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return nil
+	} else if request > int64(qcfg.SMP.MaxCPUs) {
+		return fmt.Errorf("cannot increase vCPUs beyond the limit of %d the machine was created with", qcfg.SMP.MaxCPUs)
+	}
 
-		// Propagate the contents of the QEMU log file as an error
-		if errLog, err2 := os.ReadFile(qemuLogFile); err2 == nil {
-			err = errors.Join(fmt.Errorf(strings.TrimSpace(string(errLog))), err)
+	cpus, err := qmpClient.QueryHotpluggableCpus(qmpapi.QueryHotpluggableCpusRequest{})
+	if err != nil {
+		return err
+	} else if cpus.Error.Class != "" {
+		return fmt.Errorf("could not query hotpluggable vCPUs: %s", cpus.Error.Cescription)
+	}
+
+	var present, absent []qmpapi.HotpluggableCPU
+	for _, cpu := range cpus.Return {
+		if cpu.QomPath != "" {
+			present = append(present, cpu)
+		} else {
+			absent = append(absent, cpu)
 		}
+	}
+
+	// Always add and remove vCPUs in a predictable order.
+	sort.SliceStable(absent, func(i, j int) bool {
+		return cpuOrdinal(absent[i].Props) < cpuOrdinal(absent[j].Props)
+	})
+	sort.SliceStable(present, func(i, j int) bool {
+		return cpuOrdinal(present[i].Props) > cpuOrdinal(present[j].Props)
+	})
 
-		return machine, fmt.Errorf("could not start and wait for QEMU process: %v", err)
+	if missing := request - int64(len(present)); missing > int64(len(absent)) {
+		return fmt.Errorf("cannot add %d vCPUs: only %d hotpluggable slots are available", missing, len(absent))
+	}
+
+	for i := int64(len(present)); i < request; i++ {
+		cpu := absent[i-int64(len(present))]
+		res, err := qmpClient.DeviceAdd(qmpapi.DeviceAddRequest{
+			Arguments: qmpapi.DeviceAddRequestArguments{
+				Driver:   cpu.Type,
+				Id:       fmt.Sprintf("cpu-%d-%d-%d", cpu.Props.SocketId, cpu.Props.CoreId, cpu.Props.ThreadId),
+				NodeId:   cpu.Props.NodeId,
+				SocketId: cpu.Props.SocketId,
+				DieId:    cpu.Props.DieId,
+				CoreId:   cpu.Props.CoreId,
+				ThreadId: cpu.Props.ThreadId,
+			},
+		})
+		if err != nil {
+			return err
+		} else if res.Error.Class != "" {
+			return fmt.Errorf("could not hotplug vCPU: %s", res.Error.Cescription)
+		}
 	}
 
-	machine.Status.State = machinev1alpha1.MachineStateCreated
+	for i := int64(len(present)); i > request; i-- {
+		cpu := present[int64(len(present))-i]
 
-	return machine, nil
+		// vCPUs which were present when the machine booted are not located in
+		// the peripheral tree and cannot be unplugged.
+		if !strings.HasPrefix(cpu.QomPath, "/machine/peripheral/") {
+			return fmt.Errorf("cannot remove vCPUs which the machine was created with")
+		}
+
+		res, err := qmpClient.DeviceDel(qmpapi.DeviceDelRequest{
+			Arguments: qmpapi.DeviceDelRequestArguments{
+				Id: cpu.QomPath,
+			},
+		})
+		if err != nil {
+			return err
+		} else if res.Error.Class != "" {
+			return fmt.Errorf("could not unplug vCPU: %s", res.Error.Cescription)
+		}
+	}
+
+	return nil
 }
 
-// Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
-func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	panic("not implemented: kraftkit.sh/machine/qemu.machineV1alpha1Service.Update")
+// cpuOrdinal returns a comparable position of the vCPU based on its topology.
+func cpuOrdinal(props qmpapi.CpuInstanceProperties) int64 {
+	return props.SocketId<<32 | props.DieId<<24 | props.CoreId<<8 | props.ThreadId
 }
 
 // getQEMUConfigFromPlatformConfig converts the provided platformConfig
@@ -611,7 +1274,19 @@ func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machi
 	// machine, so that it can be immediately acted upon.
 	firstCall := true
 
+	// failed is set once the machine has failed, e.g. when its VMM is quit
+	// because one of its virtiofsd instances has exited, such that the resulting
+	// shutdown is not mistaken for a request to stop the machine.
+	var failed atomic.Bool
+
+	supervisorCtx, cancelSupervisor := context.WithCancel(ctx)
+	go service.superviseVirtiofsDaemons(supervisorCtx, machine, func() {
+		failed.Store(true)
+	})
+
 	go func() {
+		defer cancelSupervisor()
+
 	accept:
 		for {
 			// First check if the context has been cancelled
@@ -628,6 +1303,10 @@ func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machi
 				continue
 			}
 
+			if machine.Status.State == machinev1alpha1.MachineStateFailed {
+				failed.Store(true)
+			}
+
 			// Initialize with the current state
 			if firstCall {
 				events <- machine
@@ -657,16 +1336,42 @@ func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machi
 
 			case qmpapi.EVENT_SHUTDOWN:
 				machine.Status.State = machinev1alpha1.MachineStateExited
+				machine.Status.ExitCode = 0
+
+				// A shutdown which was not initiated by the guest, e.g. via QMP or a
+				// signal, is an explicit request to stop the machine, unless the
+				// machine has failed.
+				if failed.Load() {
+					machine.Status.State = machinev1alpha1.MachineStateFailed
+					machine.Status.ExitCode = 1
+				} else if data, ok := event.Data.(map[string]interface{}); ok {
+					if reason, ok := data["reason"].(string); ok && strings.HasPrefix(reason, "host-") {
+						machine.Status.ManuallyStopped = true
+					}
+				}
+
 				events <- machine
 
 				if !qcfg.NoShutdown {
 					break accept
 				}
+			case qmpapi.EVENT_BALLOON_CHANGE, qmpapi.EVENT_DEVICE_DELETED:
+				// Changes to the resources of the machine do not change its state.
+
 			case qmpapi.EVENT_GUEST_PANICKED:
+				service.captureCrash(ctx, machine)
+
 				machine.Status.State = machinev1alpha1.MachineStateErrored
+				machine.Status.ExitCode = 1
 				events <- machine
 
 				if !qcfg.NoShutdown {
+					// The guest is paused on panic in order to dump its memory, so quit
+					// explicitly now that this is complete.
+					if err := service.quit(ctx, machine); err != nil {
+						errs <- err
+					}
+
 					break accept
 				}
 			default:
@@ -678,8 +1383,14 @@ func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machi
 	return events, errs, nil
 }
 
-// Start implements kraftkit.sh/api/machine/v1alpha1.MachineService
+// Start implements kraftkit.sh/api/machine/v1alpha1.MachineService.  A machine
+// whose QEMU process has since exited is started by re-launching the process
+// with the configuration it was created with.
 func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if err := service.relaunch(ctx, machine); err != nil {
+		return machine, fmt.Errorf("could not restart qemu instance: %v", err)
+	}
+
 	qmpClient, err := service.QMPClient(ctx, machine)
 	if err != nil {
 		return machine, fmt.Errorf("could not start qemu instance: %v", err)
@@ -701,13 +1412,53 @@ func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machi
 		return machine, err
 	}
 
+	// If the machine was booted with a memory limit which exceeds the requested
+	// memory, inflate the balloon so only the requested memory is available.
+	if err := updateMemory(qmpClient, &qcfg, machine.Spec.Resources.Requests.Memory().Value()); err != nil {
+		log.G(ctx).Warnf("could not set initial memory: %v", err)
+	}
+
 	machine.Status.Pid = process.Pid
 	machine.Status.State = machinev1alpha1.MachineStateRunning
 	machine.Status.StartedAt = time.Now()
+	machine.Status.ManuallyStopped = false
 
 	return machine, nil
 }
 
+// relaunch re-executes the QEMU process of the machine if it is no longer
+// running.
+func (service *machineV1alpha1Service) relaunch(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return err
+	}
+
+	if process, err := processFromPidFile(qcfg.PidFile); err == nil {
+		if running, err := process.IsRunning(); err == nil && running {
+			return nil
+		}
+	}
+
+	bin, err := qemuBinary(machine.Spec.Architecture)
+	if err != nil {
+		return err
+	}
+
+	log.G(ctx).
+		WithField("machine", machine.Name).
+		Debug("re-launching qemu process")
+
+	if err := service.launch(ctx, machine, bin, qcfg); err != nil {
+		return err
+	}
+
+	machine.Status.ExitedAt = time.Time{}
+	machine.Status.ExitCode = 0
+
+	return nil
+}
+
 // Pause implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	qmpClient, err := service.QMPClient(ctx, machine)
@@ -767,6 +1518,18 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		return machine, fmt.Errorf("cannot read QEMU platform configuration from machine status")
 	}
 
+	machine.Status.Health = health.Load(machine)
+
+	// Record any artefacts of a previous crash of the machine.
+	crashed := false
+	if fi, err := os.Stat(crashDumpPath(machine)); err == nil {
+		machine.Status.CrashDumpPath = crashDumpPath(machine)
+		crashed = fi.ModTime().After(machine.Status.StartedAt)
+	}
+	if _, err := os.Stat(crashLogPath(machine)); err == nil {
+		machine.Status.CrashLogPath = crashLogPath(machine)
+	}
+
 	// Check if the process is alive, which ultimately indicates to us whether we
 	// able to speak to the exposed QMP socket
 	activeProcess := false
@@ -809,6 +1572,10 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		if savedState == machinev1alpha1.MachineStateRunning {
 			exitCode = 1
 		}
+		if crashed {
+			state = machinev1alpha1.MachineStateErrored
+			exitCode = 1
+		}
 		return machine, nil
 	}
 
@@ -846,6 +1613,12 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		state = machinev1alpha1.MachineStatePaused
 		exitCode = -1
 
+	case qmpapi.RUN_STATE_PRELAUNCH:
+		// The guest has not been started yet, e.g. since it waits for a debugger
+		// to resume it via the GDB stub or for the machine to be started.
+		state = machinev1alpha1.MachineStateCreated
+		exitCode = -1
+
 	case qmpapi.RUN_STATE_RUNNING:
 		state = machinev1alpha1.MachineStateRunning
 		exitCode = -1
@@ -854,19 +1627,61 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		state = machinev1alpha1.MachineStateExited
 		exitCode = 0
 
-	case qmpapi.RUN_STATE_SUSPENDED:
+	case qmpapi.RUN_STATE_SUSPENDED,
+		qmpapi.RUN_STATE_SAVE_VM,
+		qmpapi.RUN_STATE_FINISH_MIGRATE,
+		qmpapi.RUN_STATE_POSTMIGRATE:
 		state = machinev1alpha1.MachineStateSuspended
 		exitCode = -1
 
 	default:
-		// qmpapi.RUN_STATE_SAVE_VM,
-		// qmpapi.RUN_STATE_PRELAUNCH,
 		// qmpapi.RUN_STATE_RESTORE_VM,
 		// qmpapi.RUN_STATE_WATCHDOG,
 		state = machinev1alpha1.MachineStateUnknown
 		exitCode = -1
 	}
 
+	// The volumes of a machine whose virtiofsd has exited can no longer be
+	// served to the guest, so quit it such that it can be restarted.
+	if state == machinev1alpha1.MachineStateRunning ||
+		state == machinev1alpha1.MachineStatePaused ||
+		state == machinev1alpha1.MachineStateSuspended {
+		if exited := exitedVirtiofsDaemons(machine); len(exited) > 0 {
+			log.G(ctx).
+				WithField("machine", machine.Name).
+				Warnf("virtiofsd of %s exited", strings.Join(exited, ", "))
+
+			state = machinev1alpha1.MachineStateFailed
+			exitCode = 1
+
+			qmpClient.Close()
+
+			if !qcfg.NoShutdown {
+				if err := service.quit(ctx, machine); err != nil {
+					return machine, err
+				}
+			}
+
+			return machine, nil
+		}
+	}
+
+	// The guest is paused on panic in order to dump its memory.  Do so here too
+	// such that machines which nobody watches, e.g. those started in the
+	// background, do not remain paused forever.
+	if status.Return.Status == qmpapi.RUN_STATE_GUEST_PANICKED {
+		// Only a single client can be attached to the QMP socket at a time.
+		qmpClient.Close()
+
+		service.captureCrash(ctx, machine)
+
+		if !qcfg.NoShutdown {
+			if err := service.quit(ctx, machine); err != nil {
+				return machine, err
+			}
+		}
+	}
+
 	return machine, nil
 }
 
@@ -896,6 +1711,18 @@ func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machin
 		return machine, fmt.Errorf("could not stop qemu instance: %v", err)
 	}
 
+	// Capture the crash of a panicked machine before it is terminated.
+	if status, err := qmpClient.QueryStatus(qmpapi.QueryStatusRequest{}); err == nil && status.Return.Status == qmpapi.RUN_STATE_GUEST_PANICKED {
+		qmpClient.Close()
+
+		service.captureCrash(ctx, machine)
+
+		qmpClient, err = service.QMPClient(ctx, machine)
+		if err != nil {
+			return machine, fmt.Errorf("could not stop qemu instance: %v", err)
+		}
+	}
+
 	defer qmpClient.Close()
 	_, err = qmpClient.Quit(qmpapi.QuitRequest{})
 	if err != nil {
@@ -908,6 +1735,7 @@ func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machin
 	}
 
 	machine.Status.State = machinev1alpha1.MachineStateExited
+	machine.Status.ManuallyStopped = true
 
 	if err := retrytimeout.RetryTimeout(5*time.Second, func() error {
 		if _, err := os.ReadFile(qcfg.PidFile); !os.IsNotExist(err) {
@@ -919,9 +1747,220 @@ func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machin
 		return machine, err
 	}
 
+	stopVirtiofsDaemons(ctx, machine)
+
 	return machine, nil
 }
 
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService.  The
+// machine is paused and its complete state is saved to a file within its state
+// directory, after which the machine remains suspended.  The resulting file
+// can be used to restore new machines via Create.
+func (service *machineV1alpha1Service) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	switch machine.Status.State {
+	case machinev1alpha1.MachineStateRunning, machinev1alpha1.MachineStatePaused:
+	default:
+		return machine, fmt.Errorf("cannot snapshot machine in %s state", machine.Status.State)
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not snapshot qemu instance: %v", err)
+	}
+
+	defer qmpClient.Close()
+
+	// Pause the machine first such that the state is consistent with the moment
+	// the snapshot was requested.
+	if machine.Status.State == machinev1alpha1.MachineStateRunning {
+		if _, err := qmpClient.Stop(qmpapi.StopRequest{}); err != nil {
+			return machine, err
+		}
+
+		machine.Status.State = machinev1alpha1.MachineStatePaused
+	}
+
+	snapshotPath := filepath.Join(machine.Status.StateDir, "machine.snapshot")
+	if err := os.Remove(snapshotPath); err != nil && !os.IsNotExist(err) {
+		return machine, fmt.Errorf("could not remove previous snapshot: %w", err)
+	}
+
+	res, err := qmpClient.Migrate(qmpapi.MigrateRequest{
+		Arguments: qmpapi.MigrateRequestArguments{
+			Uri: "exec:cat > " + shellQuote(snapshotPath),
+		},
+	})
+	if err != nil {
+		return machine, err
+	} else if res.Error.Class != "" {
+		return machine, fmt.Errorf("could not start snapshot: %s", res.Error.Cescription)
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			_, _ = qmpClient.MigrateCancel(qmpapi.MigrateCancelRequest{})
+			return machine, ctx.Err()
+		case <-ticker.C:
+		}
+
+		info, err := qmpClient.QueryMigrate(qmpapi.QueryMigrateRequest{})
+		if err != nil {
+			return machine, err
+		} else if info.Error.Class != "" {
+			return machine, fmt.Errorf("could not query snapshot progress: %s", info.Error.Cescription)
+		}
+
+		switch info.Return.Status {
+		case qmpapi.MIGRATION_STATUS_COMPLETED:
+			machine.Status.SnapshotPath = snapshotPath
+			machine.Status.State = machinev1alpha1.MachineStateSuspended
+			return machine, nil
+
+		case qmpapi.MIGRATION_STATUS_FAILED, qmpapi.MIGRATION_STATUS_CANCELLED:
+			return machine, fmt.Errorf("could not snapshot machine: %s", info.Return.ErrorDesc)
+		}
+	}
+}
+
+// crashDumpPath returns the in-host path of the memory dump of a machine which
+// has panicked.
+func crashDumpPath(machine *machinev1alpha1.Machine) string {
+	return filepath.Join(machine.Status.StateDir, "crash.core")
+}
+
+// crashLogPath returns the in-host path of the serial output of a machine
+// which has panicked.
+func crashLogPath(machine *machinev1alpha1.Machine) string {
+	return filepath.Join(machine.Status.StateDir, "crash.log")
+}
+
+// dumpCrash captures the memory of a panicked machine as an ELF core file in
+// its state directory and retains the last lines of its serial output next to
+// it.
+func (service *machineV1alpha1Service) dumpCrash(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	var errs merr.Errors
+
+	if err := tailFile(machine.Status.LogFile, crashLogPath(machine), service.crashLogLines); err != nil {
+		errs = append(errs, fmt.Errorf("could not save serial output: %w", err))
+	} else {
+		machine.Status.CrashLogPath = crashLogPath(machine)
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not attach to QMP client: %w", err))
+		return errs.Err()
+	}
+
+	defer qmpClient.Close()
+
+	log.G(ctx).
+		WithField("path", crashDumpPath(machine)).
+		Info("machine panicked, dumping guest memory")
+
+	res, err := qmpClient.DumpGuestMemory(qmpapi.DumpGuestMemoryRequest{
+		Arguments: qmpapi.DumpGuestMemoryRequestArguments{
+			Paging:   false,
+			Protocol: "file:" + crashDumpPath(machine),
+			Format:   qmpapi.DUMP_GUEST_MEMORY_FORMAT_ELF,
+		},
+	})
+	if err != nil {
+		errs = append(errs, fmt.Errorf("could not dump guest memory: %w", err))
+	} else if res.Error.Class != "" {
+		errs = append(errs, fmt.Errorf("could not dump guest memory: %s", res.Error.Cescription))
+	} else {
+		machine.Status.CrashDumpPath = crashDumpPath(machine)
+	}
+
+	return errs.Err()
+}
+
+// captureCrash dumps the panicked machine, unless a crash dump has already
+// been captured since the machine was started, e.g. by a concurrent watcher.
+func (service *machineV1alpha1Service) captureCrash(ctx context.Context, machine *machinev1alpha1.Machine) {
+	if fi, err := os.Stat(crashDumpPath(machine)); err == nil && fi.ModTime().After(machine.Status.StartedAt) {
+		return
+	}
+
+	if err := service.dumpCrash(ctx, machine); err != nil {
+		log.G(ctx).Warnf("could not capture crash dump: %v", err)
+	}
+}
+
+// tailFile writes the last n lines of the file at src to the file at dst.
+func tailFile(src, dst string, n int) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close()
+
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+
+	return os.WriteFile(dst, []byte(out.String()), 0o644)
+}
+
+// quit terminates the VMM of the machine.
+func (service *machineV1alpha1Service) quit(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return fmt.Errorf("could not attach to QMP client: %w", err)
+	}
+
+	defer qmpClient.Close()
+
+	if _, err := qmpClient.Quit(qmpapi.QuitRequest{}); err != nil {
+		return fmt.Errorf("could not quit qemu instance: %w", err)
+	}
+
+	return nil
+}
+
+// incomingOption returns the option which restores the state of the provided
+// machine from its previously saved snapshot, if any.  Since the machine is
+// not started, QEMU will remain paused after the snapshot has been loaded
+// until the machine is started.
+func incomingOption(machine *machinev1alpha1.Machine) (QemuOption, error) {
+	if len(machine.Spec.Snapshot) == 0 {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(machine.Spec.Snapshot); err != nil {
+		return nil, fmt.Errorf("could not access snapshot: %w", err)
+	}
+
+	return WithIncoming("exec:cat " + shellQuote(machine.Spec.Snapshot)), nil
+}
+
+// shellQuote quotes the provided string such that it can be safely used as a
+// single argument of the commands which QEMU evaluates for `exec:` URIs.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
 func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	qcfg, ok := machine.Status.PlatformConfig.(QemuConfig)
@@ -931,6 +1970,8 @@ func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *mach
 
 	var errs merr.Errors
 
+	stopVirtiofsDaemons(ctx, machine)
+
 	err := os.RemoveAll(machine.Status.StateDir)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("error deleting QEMU's state directory %s: %w", machine.Status.StateDir, err))
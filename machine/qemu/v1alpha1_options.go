@@ -4,7 +4,15 @@
 // You may not use this file except in compliance with the License.
 package qemu
 
-import "kraftkit.sh/exec"
+import (
+	zip "api.zip"
+
+	"kraftkit.sh/exec"
+)
+
+// DefaultCrashLogLines is the default number of lines of serial output which
+// are retained next to the memory dump of a machine which has panicked.
+const DefaultCrashLogLines = 100
 
 // MachineServiceV1alpha1Option represents an option-method handler for the
 // machinev1alpha1 service.
@@ -18,3 +26,21 @@ func WithExecOptions(eopts ...exec.ExecOption) MachineServiceV1alpha1Option {
 		return nil
 	}
 }
+
+// WithCrashLogLines sets the number of lines of serial output which are
+// retained next to the memory dump of a machine which has panicked.
+func WithCrashLogLines(lines int) MachineServiceV1alpha1Option {
+	return func(service *machineV1alpha1Service) error {
+		service.crashLogLines = lines
+		return nil
+	}
+}
+
+// WithStore sets the store of machines, which is used to determine how the
+// specification of a machine is changed when it is updated.
+func WithStore(store zip.Store) MachineServiceV1alpha1Option {
+	return func(service *machineV1alpha1Service) error {
+		service.store = store
+		return nil
+	}
+}
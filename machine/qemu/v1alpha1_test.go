@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/machine/store"
+)
+
+func TestUpdateRejectsSpecChanges(t *testing.T) {
+	ctx := context.Background()
+
+	embeddedStore, err := store.NewEmbeddedStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := &machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			Kernel:     "kernel",
+			KernelArgs: []string{"console=ttyS0"},
+		},
+		Status: machinev1alpha1.MachineStatus{
+			State: machinev1alpha1.MachineStateRunning,
+		},
+	}
+	stored.Name = "test"
+	stored.UID = "0123"
+
+	if err := embeddedStore.Create(ctx, string(stored.UID), nil, stored, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewMachineV1alpha1Service(ctx, WithStore(embeddedStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		update   func(*machinev1alpha1.Machine)
+		expected []string
+	}{
+		{
+			name: "kernel",
+			update: func(machine *machinev1alpha1.Machine) {
+				machine.Spec.Kernel = "other"
+			},
+			expected: []string{"kernel"},
+		},
+		{
+			name: "multiple",
+			update: func(machine *machinev1alpha1.Machine) {
+				machine.Spec.KernelArgs = nil
+				machine.Spec.ApplicationArgs = []string{"-v"}
+				machine.Spec.Emulation = true
+			},
+			expected: []string{"kernelArgs", "args", "emulation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := *stored
+			machine.Spec.KernelArgs = append([]string{}, stored.Spec.KernelArgs...)
+			tt.update(&machine)
+
+			_, err := service.Update(ctx, &machine)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+
+			for _, field := range tt.expected {
+				if !strings.Contains(err.Error(), "cannot change the "+field+" of") {
+					t.Errorf("expected error for %s, got: %v", field, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateRequiresStore(t *testing.T) {
+	ctx := context.Background()
+
+	service, err := NewMachineV1alpha1Service(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	machine := &machinev1alpha1.Machine{
+		Status: machinev1alpha1.MachineStatus{
+			State: machinev1alpha1.MachineStateRunning,
+		},
+	}
+
+	if _, err := service.Update(ctx, machine); err == nil {
+		t.Error("expected error without store, got nil")
+	}
+}
+
+func TestSpecChanges(t *testing.T) {
+	before := machinev1alpha1.MachineSpec{
+		Kernel: "kernel",
+	}
+
+	after := before
+	after.KernelArgs = []string{}
+
+	if changes := specChanges(before, after); len(changes) > 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+
+	after.Rootfs = "initramfs.cpio"
+
+	if changes := specChanges(before, after); len(changes) != 1 || changes[0] != "rootfs" {
+		t.Errorf("expected changes [rootfs], got %v", changes)
+	}
+}
+
+func TestNetworkOptionsMacvtapFds(t *testing.T) {
+	ifaces := func(ifnames ...string) []networkv1alpha1.NetworkInterfaceTemplateSpec {
+		var ret []networkv1alpha1.NetworkInterfaceTemplateSpec
+		for _, ifname := range ifnames {
+			ret = append(ret, networkv1alpha1.NetworkInterfaceTemplateSpec{
+				Spec: networkv1alpha1.NetworkInterfaceSpec{IfName: ifname},
+			})
+		}
+
+		return ret
+	}
+
+	machine := &machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			Networks: []networkv1alpha1.NetworkSpec{
+				{Driver: "macvtap", IfName: "eth0", Interfaces: ifaces("lan@if0", "lan@if1")},
+				{Driver: "bridge", IfName: "kraft0", Interfaces: ifaces("kraft0@if0")},
+				{Driver: "macvtap", IfName: "eth1", Interfaces: ifaces("wan@if0")},
+			},
+		},
+	}
+
+	qopts, _, err := networkOptions(machine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qcfg, err := NewQemuConfig(qopts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fds := map[string]int{}
+	for _, netdev := range qcfg.NetDevs {
+		if tap, ok := netdev.(QemuNetDevTap); ok && tap.Fd > 0 {
+			fds[tap.Id] = tap.Fd
+		}
+	}
+
+	// The tap devices are opened in the same order as they are referenced, i.e.
+	// the n-th macvtap interface is passed as macvtapFirstFd+n.
+	ifnames := macvtapInterfaces(machine)
+	if expected := []string{"lan@if0", "lan@if1", "wan@if0"}; !reflect.DeepEqual(ifnames, expected) {
+		t.Fatalf("expected macvtap interfaces %v, got %v", expected, ifnames)
+	}
+
+	expected := map[string]int{
+		"hostnet0": macvtapFirstFd,
+		"hostnet1": macvtapFirstFd + 1,
+		"hostnet3": macvtapFirstFd + 2,
+	}
+
+	if !reflect.DeepEqual(fds, expected) {
+		t.Errorf("expected tap fds %v, got %v", expected, fds)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+	}{
+		{"", `''`},
+		{"/tmp/snapshot", `'/tmp/snapshot'`},
+		{"/tmp/my snapshot", `'/tmp/my snapshot'`},
+		{"/tmp/it's", `'/tmp/it'\''s'`},
+		{"$(reboot); `id`", "'$(reboot); `id`'"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.expected {
+			t.Errorf("shellQuote(%q): expected %s, got %s", tt.in, tt.expected, got)
+		}
+	}
+}
+
+func TestIncomingOption(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "it's.snapshot")
+	if err := os.WriteFile(snapshot, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("snapshot", func(t *testing.T) {
+		machine := &machinev1alpha1.Machine{}
+		machine.Spec.Snapshot = snapshot
+
+		incoming, err := incomingOption(machine)
+		if err != nil {
+			t.Fatal(err)
+		} else if incoming == nil {
+			t.Fatal("expected an incoming option")
+		}
+
+		qcfg, err := NewQemuConfig(incoming)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := "exec:cat " + shellQuote(snapshot); qcfg.Incoming != expected {
+			t.Errorf("expected incoming %s, got %s", expected, qcfg.Incoming)
+		}
+	})
+
+	t.Run("no snapshot", func(t *testing.T) {
+		incoming, err := incomingOption(&machinev1alpha1.Machine{})
+		if err != nil {
+			t.Fatal(err)
+		} else if incoming != nil {
+			t.Error("expected no incoming option")
+		}
+	})
+
+	t.Run("missing snapshot", func(t *testing.T) {
+		machine := &machinev1alpha1.Machine{}
+		machine.Spec.Snapshot = snapshot + ".missing"
+
+		if _, err := incomingOption(machine); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
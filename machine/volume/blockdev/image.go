@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package blockdev
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const (
+	// FormatRaw is the format of disk images which contain the contents of the
+	// block device as is.
+	FormatRaw = "raw"
+
+	// FormatQcow2 is the format of QEMU copy-on-write disk images.
+	FormatQcow2 = "qcow2"
+)
+
+// qcow2Magic is the magic at the start of qcow2 disk images.
+var qcow2Magic = []byte{'Q', 'F', 'I', 0xfb}
+
+// DetectFormat returns the format of the disk image at the provided path.
+func DetectFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	magic := make([]byte, len(qcow2Magic))
+	if _, err := io.ReadFull(f, magic); err == nil && bytes.Equal(magic, qcow2Magic) {
+		return FormatQcow2, nil
+	}
+
+	return FormatRaw, nil
+}
+
+// DetectFsType returns the filesystem of the raw disk image at the provided
+// path as it is named by Unikraft's vfscore, or an empty string if it is not
+// known.
+func DetectFsType(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	// The boot sector of FAT filesystems and the superblock of ext2/3/4
+	// filesystems are within the first 2 KiB of the image.
+	b := make([]byte, 2048)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		if err == io.EOF {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	b = b[:n]
+
+	switch {
+	case len(b) >= 1082 && binary.LittleEndian.Uint16(b[1080:1082]) == 0xef53:
+		return "ext2", nil
+	case len(b) >= 90 && bytes.Equal(b[82:87], []byte("FAT32")):
+		return "fat", nil
+	case len(b) >= 62 && bytes.Equal(b[54:57], []byte("FAT")):
+		return "fat", nil
+	}
+
+	return "", nil
+}
+
+// CreateImage creates an empty disk image of the provided format and size at
+// the provided path.  Raw images are sparse, such that they only occupy the
+// space on the host which is written to.
+func CreateImage(path, format string, size int64) error {
+	if size <= 0 {
+		return fmt.Errorf("cannot create disk image without size")
+	}
+
+	switch format {
+	case FormatRaw, "":
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		return f.Truncate(size)
+
+	case FormatQcow2:
+		bin, err := exec.LookPath("qemu-img")
+		if err != nil {
+			return fmt.Errorf("could not find qemu-img to create qcow2 disk image: %w", err)
+		}
+
+		out, err := exec.Command(bin, "create", "-q", "-f", FormatQcow2, path, strconv.FormatInt(size, 10)).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("could not create qcow2 disk image: %s: %w", bytes.TrimSpace(out), err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported disk image format: %s", format)
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package blockdev
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateImageRaw(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+
+	if err := CreateImage(path, FormatRaw, 1<<20); err != nil {
+		t.Fatalf("CreateImage: %v", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != 1<<20 {
+		t.Errorf("size = %d, want %d", fi.Size(), 1<<20)
+	}
+
+	if format, err := DetectFormat(path); err != nil || format != FormatRaw {
+		t.Errorf("DetectFormat = %q, %v, want %q", format, err, FormatRaw)
+	}
+
+	if err := CreateImage(path, FormatRaw, 1<<20); err == nil {
+		t.Errorf("CreateImage overwrote an existing image")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	ext2 := make([]byte, 2048)
+	binary.LittleEndian.PutUint16(ext2[1080:1082], 0xef53)
+
+	fat32 := make([]byte, 512)
+	copy(fat32[82:], "FAT32   ")
+
+	fat16 := make([]byte, 512)
+	copy(fat16[54:], "FAT16   ")
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+		fsType string
+	}{
+		{"empty", nil, FormatRaw, ""},
+		{"zeroes", make([]byte, 4096), FormatRaw, ""},
+		{"ext2", ext2, FormatRaw, "ext2"},
+		{"fat32", fat32, FormatRaw, "fat"},
+		{"fat16", fat16, FormatRaw, "fat"},
+		{"qcow2", []byte{'Q', 'F', 'I', 0xfb, 0, 0, 0, 3}, FormatQcow2, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "disk.img")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if format, err := DetectFormat(path); err != nil || format != tt.format {
+				t.Errorf("DetectFormat = %q, %v, want %q", format, err, tt.format)
+			}
+
+			if tt.format != FormatRaw {
+				return
+			}
+
+			if fsType, err := DetectFsType(path); err != nil || fsType != tt.fsType {
+				t.Errorf("DetectFsType = %q, %v, want %q", fsType, err, tt.fsType)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package blockdev implements volumes which are attached to machines as
+// virtio-blk devices that are backed by raw or qcow2 disk images on the host.
+package blockdev
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/config"
+	"kraftkit.sh/kconfig"
+	"kraftkit.sh/unikraft/export/v0/vfscore"
+)
+
+const (
+	// DriverName is the name of the block device volume driver.
+	DriverName = "blockdev"
+
+	// KConfigOption is the Unikraft option which enables virtio-blk support in
+	// the kernel of a machine.
+	KConfigOption = "CONFIG_VIRTIO_BLK"
+)
+
+// IsCompatible returns whether the provided source is a disk image and, if the
+// KConfig of the kernel of a machine is provided, whether it includes
+// virtio-blk support.
+func IsCompatible(source string, kvm kconfig.KeyValueMap) (bool, error) {
+	fi, err := os.Stat(source)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false, nil
+	}
+
+	if kvm == nil {
+		return true, nil
+	}
+
+	opt, ok := kvm[KConfigOption]
+	return ok && opt.Value == kconfig.Yes, nil
+}
+
+// DeviceName returns the name of the block device of the machine at the
+// provided index amongst its block device volumes, which is used as the source
+// of its fstab entry.
+func DeviceName(index int) string {
+	return fmt.Sprintf("blk%d", index)
+}
+
+// FstabEntry returns the vfscore fstab entry which mounts the provided volume
+// which is attached as the block device at the provided index, or false if the
+// filesystem of the volume is not known.
+func FstabEntry(index int, volume volumev1alpha1.Volume) (string, bool) {
+	if len(volume.Spec.FsType) == 0 {
+		return "", false
+	}
+
+	var flags string
+	if volume.Spec.ReadOnly {
+		flags = "ro"
+	}
+
+	return vfscore.NewFstabEntry(
+		DeviceName(index),
+		volume.Spec.Destination,
+		volume.Spec.FsType,
+		flags,
+		"",
+	).String(), true
+}
+
+type v1alpha1Volume struct{}
+
+func NewVolumeServiceV1alpha1(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
+	return &v1alpha1Volume{}, nil
+}
+
+// Create implements kraftkit.sh/api/volume/v1alpha1.Create.  Volumes without a
+// host path are named volumes, whose empty disk image of the requested size is
+// created within the runtime directory and is removed along with the volume.
+func (*v1alpha1Volume) Create(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Spec.Driver) == 0 {
+		volume.Spec.Driver = DriverName
+	} else if volume.Spec.Driver != DriverName {
+		return volume, fmt.Errorf("cannot use blockdev driver when driver set to %s", volume.Spec.Driver)
+	}
+
+	if len(volume.Spec.Source) == 0 {
+		if len(volume.Spec.Format) == 0 {
+			volume.Spec.Format = FormatRaw
+		}
+
+		dir := filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, "volumes")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return volume, fmt.Errorf("could not create volume directory: %w", err)
+		}
+
+		volume.Spec.Source = filepath.Join(dir, volume.Name+"."+volume.Spec.Format)
+
+		if err := CreateImage(volume.Spec.Source, volume.Spec.Format, volume.Spec.Size); err != nil {
+			return volume, fmt.Errorf("could not create volume %s: %w", volume.Name, err)
+		}
+
+		volume.Status.Managed = true
+	}
+
+	fi, err := os.Stat(volume.Spec.Source)
+	if err != nil {
+		return volume, fmt.Errorf("cannot stat disk image: %w", err)
+	} else if fi.IsDir() {
+		return volume, fmt.Errorf("cannot attach %s as block device: is a directory", volume.Spec.Source)
+	}
+
+	volume.Spec.Source, err = filepath.Abs(volume.Spec.Source)
+	if err != nil {
+		return volume, err
+	}
+
+	if len(volume.Spec.Format) == 0 {
+		if volume.Spec.Format, err = DetectFormat(volume.Spec.Source); err != nil {
+			return volume, fmt.Errorf("could not detect format of disk image: %w", err)
+		}
+	} else if volume.Spec.Format != FormatRaw && volume.Spec.Format != FormatQcow2 {
+		return volume, fmt.Errorf("unsupported disk image format: %s", volume.Spec.Format)
+	}
+
+	// The size and filesystem can only be determined from raw disk images.
+	if volume.Spec.Format == FormatRaw {
+		volume.Spec.Size = fi.Size()
+	}
+
+	// Detect the filesystem unless it was provided.
+	if len(volume.Spec.FsType) == 0 && volume.Spec.Format == FormatRaw {
+		if volume.Spec.FsType, err = DetectFsType(volume.Spec.Source); err != nil {
+			return volume, fmt.Errorf("could not detect filesystem of disk image: %w", err)
+		}
+	}
+
+	if volume.ObjectMeta.UID == "" {
+		volume.ObjectMeta.UID = uuid.NewUUID()
+	}
+
+	if volume.ObjectMeta.CreationTimestamp == *new(metav1.Time) {
+		volume.ObjectMeta.CreationTimestamp = metav1.Now()
+	}
+
+	volume.Status.State = volumev1alpha1.VolumeStateBound
+
+	return volume, nil
+}
+
+// Update implements kraftkit.sh/api/volume/v1alpha1.Update
+func (service *v1alpha1Volume) Update(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	return service.Get(ctx, volume)
+}
+
+// Delete implements kraftkit.sh/api/volume/v1alpha1.Delete
+func (*v1alpha1Volume) Delete(_ context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Status.Machines) > 0 {
+		return volume, fmt.Errorf("volume %s is attached to %s", volume.Name, strings.Join(volume.Status.Machines, ", "))
+	}
+
+	if volume.Status.Managed && len(volume.Spec.Source) > 0 {
+		if err := os.Remove(volume.Spec.Source); err != nil && !os.IsNotExist(err) {
+			return volume, fmt.Errorf("could not remove disk image: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// Get implements kraftkit.sh/api/volume/v1alpha1.Get
+func (*v1alpha1Volume) Get(_ context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Spec.Source) == 0 {
+		return volume, fmt.Errorf("volume %s not found", volume.Name)
+	}
+
+	if _, err := os.Stat(volume.Spec.Source); err != nil {
+		volume.Status.State = volumev1alpha1.VolumeStateLost
+	} else {
+		volume.Status.State = volumev1alpha1.VolumeStateBound
+	}
+
+	return volume, nil
+}
+
+// List implements kraftkit.sh/api/volume/v1alpha1.List
+func (service *v1alpha1Volume) List(ctx context.Context, volumes *volumev1alpha1.VolumeList) (*volumev1alpha1.VolumeList, error) {
+	for i, volume := range volumes.Items {
+		found, err := service.Get(ctx, &volume)
+		if err != nil {
+			continue
+		}
+
+		volumes.Items[i] = *found
+	}
+
+	return volumes, nil
+}
+
+// Watch implements kraftkit.sh/api/volume/v1alpha1.Watch
+func (*v1alpha1Volume) Watch(context.Context, *volumev1alpha1.Volume) (chan *volumev1alpha1.Volume, chan error, error) {
+	return nil, nil, fmt.Errorf("blockdev volumes cannot be watched")
+}
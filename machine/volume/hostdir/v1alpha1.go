@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package hostdir implements the volume service which is shared by the drivers
+// of volumes that are backed by a directory on the host, such as 9pfs and
+// virtiofs.
+package hostdir
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/config"
+)
+
+// Service implements kraftkit.sh/api/volume/v1alpha1.VolumeService for volumes
+// of the driver with the provided name.
+type Service struct {
+	Driver string
+}
+
+// Create implements kraftkit.sh/api/volume/v1alpha1.Create.  Volumes without a
+// host path are named volumes, whose host directory is created within the
+// runtime directory and is removed along with the volume.
+func (service *Service) Create(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Spec.Driver) == 0 {
+		volume.Spec.Driver = service.Driver
+	} else if volume.Spec.Driver != service.Driver {
+		return volume, fmt.Errorf("cannot use %s driver when driver set to %s", service.Driver, volume.Spec.Driver)
+	}
+
+	if len(volume.Spec.Source) == 0 {
+		volume.Spec.Source = filepath.Join(
+			config.G[config.KraftKit](ctx).RuntimeDir,
+			"volumes",
+			volume.Name,
+		)
+
+		if err := os.MkdirAll(volume.Spec.Source, 0o755); err != nil {
+			return volume, fmt.Errorf("could not create volume directory: %w", err)
+		}
+
+		volume.Status.Managed = true
+	}
+
+	if _, err := os.Stat(volume.Spec.Source); err != nil {
+		return volume, fmt.Errorf("cannot stat host path volume: %w", err)
+	}
+
+	if volume.ObjectMeta.UID == "" {
+		volume.ObjectMeta.UID = uuid.NewUUID()
+	}
+
+	if volume.ObjectMeta.CreationTimestamp == *new(metav1.Time) {
+		volume.ObjectMeta.CreationTimestamp = metav1.Now()
+	}
+
+	volume.Status.State = volumev1alpha1.VolumeStateBound
+
+	return volume, nil
+}
+
+// Update implements kraftkit.sh/api/volume/v1alpha1.Update
+func (service *Service) Update(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	return service.Get(ctx, volume)
+}
+
+// Delete implements kraftkit.sh/api/volume/v1alpha1.Delete
+func (*Service) Delete(_ context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Status.Machines) > 0 {
+		return volume, fmt.Errorf("volume %s is attached to %s", volume.Name, strings.Join(volume.Status.Machines, ", "))
+	}
+
+	if volume.Status.Managed && len(volume.Spec.Source) > 0 {
+		if err := os.RemoveAll(volume.Spec.Source); err != nil {
+			return volume, fmt.Errorf("could not remove volume directory: %w", err)
+		}
+	}
+
+	return nil, nil
+}
+
+// Get implements kraftkit.sh/api/volume/v1alpha1.Get
+func (*Service) Get(_ context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Spec.Source) == 0 {
+		return volume, fmt.Errorf("volume %s not found", volume.Name)
+	}
+
+	if _, err := os.Stat(volume.Spec.Source); err != nil {
+		volume.Status.State = volumev1alpha1.VolumeStateLost
+	} else {
+		volume.Status.State = volumev1alpha1.VolumeStateBound
+	}
+
+	return volume, nil
+}
+
+// List implements kraftkit.sh/api/volume/v1alpha1.List
+func (service *Service) List(ctx context.Context, volumes *volumev1alpha1.VolumeList) (*volumev1alpha1.VolumeList, error) {
+	for i, volume := range volumes.Items {
+		found, err := service.Get(ctx, &volume)
+		if err != nil {
+			continue
+		}
+
+		volumes.Items[i] = *found
+	}
+
+	return volumes, nil
+}
+
+// Watch implements kraftkit.sh/api/volume/v1alpha1.Watch
+func (service *Service) Watch(context.Context, *volumev1alpha1.Volume) (chan *volumev1alpha1.Volume, chan error, error) {
+	return nil, nil, fmt.Errorf("%s volumes cannot be watched", service.Driver)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package volume
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+)
+
+// DefaultDriver is the fallback driver of volumes, which is used when no other
+// driver is compatible with the kernel of a machine.
+const DefaultDriver = "9pfs"
+
+// NamePattern matches the valid names of named volumes.
+var NamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateName returns an error if the provided name is not a valid name of a
+// named volume.
+func ValidateName(name string) error {
+	if name == "." || name == ".." || !NamePattern.MatchString(name) {
+		return fmt.Errorf("invalid volume name %q: only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+
+	return nil
+}
+
+// IsNamed returns whether the provided source of a volume refers to a named
+// volume rather than a path on the host.  Similar to Docker, names must not
+// contain path separators, such that relative paths are expressed as, e.g.,
+// "./data".  Existing paths on the host take precedence over named volumes,
+// such that sources which were previously bound from the host still are.
+func IsNamed(source string) bool {
+	if ValidateName(source) != nil {
+		return false
+	}
+
+	_, err := os.Lstat(source)
+	return os.IsNotExist(err)
+}
+
+// Lookup returns the named volume with the provided name and the service of
+// its driver.
+func Lookup(ctx context.Context, name string) (*volumev1alpha1.Volume, volumev1alpha1.VolumeService, error) {
+	// Iterate over the drivers in a stable order.
+	var drivers []string
+	for driver := range Strategies() {
+		drivers = append(drivers, driver)
+	}
+
+	sort.Strings(drivers)
+
+	for _, driver := range drivers {
+		service, err := Strategies()[driver].NewVolumeV1alpha1(ctx)
+		if err != nil {
+			continue
+		}
+
+		found, err := service.Get(ctx, &volumev1alpha1.Volume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		})
+		if err != nil || found == nil || len(found.Spec.Source) == 0 {
+			continue
+		}
+
+		return found, service, nil
+	}
+
+	return nil, nil, fmt.Errorf("volume %s not found", name)
+}
+
+// Attach records the provided machine as a user of each of its named volumes.
+func Attach(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	return updateMachines(ctx, machine, func(machines []string) []string {
+		for _, name := range machines {
+			if name == machine.Name {
+				return machines
+			}
+		}
+
+		return append(machines, machine.Name)
+	})
+}
+
+// Detach removes the provided machine from the users of each of its named
+// volumes.
+func Detach(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	return updateMachines(ctx, machine, func(machines []string) []string {
+		ret := []string{}
+		for _, name := range machines {
+			if name != machine.Name {
+				ret = append(ret, name)
+			}
+		}
+
+		return ret
+	})
+}
+
+// updateMachines updates the users of each of the named volumes of the
+// provided machine with the provided method.
+func updateMachines(ctx context.Context, machine *machinev1alpha1.Machine, update func([]string) []string) error {
+	for _, vol := range machine.Spec.Volumes {
+		if !vol.Status.Managed {
+			continue
+		}
+
+		found, service, err := Lookup(ctx, vol.Name)
+		if err != nil {
+			return err
+		}
+
+		found.Status.Machines = update(found.Status.Machines)
+
+		if _, err := service.Update(ctx, found); err != nil {
+			return fmt.Errorf("could not update volume %s: %w", vol.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package volume
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIsNamed(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	// Existing paths on the host are bound rather than used as named volumes.
+	if err := os.Mkdir("data", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		source string
+		named  bool
+	}{
+		{"myvol", true},
+		{"my-vol_1.0", true},
+		{"data", false},
+		{"./myvol", false},
+		{"/data", false},
+		{"path/to/dir", false},
+		{".", false},
+		{"..", false},
+		{"-vol", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsNamed(tt.source); got != tt.named {
+			t.Errorf("IsNamed(%q) = %v, want %v", tt.source, got, tt.named)
+		}
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"myvol":   true,
+		"data":    true,
+		"./myvol": false,
+		"..":      false,
+		"-vol":    false,
+	} {
+		if err := ValidateName(name); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
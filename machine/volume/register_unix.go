@@ -6,15 +6,12 @@ package volume
 
 import (
 	"context"
-	"path/filepath"
-
-	zip "api.zip"
 
 	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
-	"kraftkit.sh/config"
 	"kraftkit.sh/kconfig"
-	"kraftkit.sh/machine/store"
 	ninepfs "kraftkit.sh/machine/volume/9pfs"
+	"kraftkit.sh/machine/volume/blockdev"
+	"kraftkit.sh/machine/volume/virtiofs"
 )
 
 // hostSupportedStrategies returns the map of known supported drivers for the
@@ -24,7 +21,7 @@ func hostSupportedStrategies() map[string]*Strategy {
 		"9pfs": {
 			IsCompatible: func(source string, _ kconfig.KeyValueMap) (bool, error) {
 				// TODO(nderjung): For now, it is OK to return true because this is the
-				// only supported driver.  In the future, we should a). check if the
+				// fallback driver.  In the future, we should a). check if the
 				// provided source is a readable directory and b). check if the supplied
 				// KConfig of the machine indicates that 9pfs is indeed part of the
 				// build configuration.
@@ -36,21 +33,29 @@ func hostSupportedStrategies() map[string]*Strategy {
 					return nil, err
 				}
 
-				embeddedStore, err := store.NewEmbeddedStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](
-					filepath.Join(
-						config.G[config.KraftKit](ctx).RuntimeDir,
-						"volumev1alpha1",
-					),
-				)
+				return newVolumeServiceWithStore(ctx, "volumev1alpha1", service)
+			},
+		},
+		blockdev.DriverName: {
+			IsCompatible: blockdev.IsCompatible,
+			NewVolumeV1alpha1: func(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
+				service, err := blockdev.NewVolumeServiceV1alpha1(ctx, opts...)
+				if err != nil {
+					return nil, err
+				}
+
+				return newVolumeServiceWithStore(ctx, "blockdevvolumev1alpha1", service)
+			},
+		},
+		virtiofs.DriverName: {
+			IsCompatible: virtiofs.IsCompatible,
+			NewVolumeV1alpha1: func(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
+				service, err := virtiofs.NewVolumeServiceV1alpha1(ctx, opts...)
 				if err != nil {
 					return nil, err
 				}
 
-				return volumev1alpha1.NewVolumeServiceHandler(
-					ctx,
-					service,
-					zip.WithStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](embeddedStore, zip.StoreRehydrationSpecNil),
-				)
+				return newVolumeServiceWithStore(ctx, "virtiofsvolumev1alpha1", service)
 			},
 		},
 	}
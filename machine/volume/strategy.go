@@ -6,9 +6,16 @@ package volume
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	zip "api.zip"
 
 	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/config"
 	"kraftkit.sh/kconfig"
+	"kraftkit.sh/machine/store"
 )
 
 // NewStrategyConstructor is a prototype for the instantiation function of a
@@ -47,3 +54,104 @@ func DriverNames() []string {
 
 	return ret
 }
+
+// newVolumeServiceWithStore wraps the provided strategy's volume service with
+// an embedded store, located at the provided directory within the runtime
+// directory, such that volumes are persisted between invocations.  Volumes
+// which are bound from a host path are not persisted, see unstoredVolumes.
+func newVolumeServiceWithStore(ctx context.Context, dir string, service volumev1alpha1.VolumeService) (volumev1alpha1.VolumeService, error) {
+	embeddedStore, err := store.NewEmbeddedStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](
+		filepath.Join(
+			config.G[config.KraftKit](ctx).RuntimeDir,
+			dir,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := volumev1alpha1.NewVolumeServiceHandler(
+		ctx,
+		namedVolumeService{service},
+		zip.WithStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](embeddedStore, zip.StoreRehydrationSpecNil),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return unstoredVolumes{
+		VolumeService: stored,
+		driver:        service,
+	}, nil
+}
+
+// CompatibleDriver returns the name of the driver which is used for a volume
+// with the provided source and a machine with the provided KConfig.  Drivers
+// which are compatible are preferred over DefaultDriver, which serves as the
+// fallback.
+func CompatibleDriver(source string, kvm kconfig.KeyValueMap) (string, error) {
+	var drivers []string
+	for driver := range Strategies() {
+		drivers = append(drivers, driver)
+	}
+
+	sort.Strings(drivers)
+
+	fallback := false
+
+	for _, driver := range drivers {
+		ok, err := Strategies()[driver].IsCompatible(source, kvm)
+		if err != nil || !ok {
+			continue
+		}
+
+		if driver == DefaultDriver {
+			fallback = true
+			continue
+		}
+
+		return driver, nil
+	}
+
+	if fallback {
+		return DefaultDriver, nil
+	}
+
+	return "", fmt.Errorf("could not find compatible volume driver for %s", source)
+}
+
+// namedVolumeService validates the names of named volumes, i.e. those without
+// a host path, before they are created by the wrapped volume service.
+type namedVolumeService struct {
+	volumev1alpha1.VolumeService
+}
+
+// Create implements kraftkit.sh/api/volume/v1alpha1.Create
+func (service namedVolumeService) Create(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Spec.Source) == 0 {
+		if err := ValidateName(volume.Name); err != nil {
+			return volume, err
+		}
+	}
+
+	return service.VolumeService.Create(ctx, volume)
+}
+
+// unstoredVolumes creates the volumes which are bound from a host path, e.g.
+// via `kraft run --volume PATH:DEST`, directly through the driver rather than
+// persisting them.  Such volumes are not managed by KraftKit and only exist as
+// part of the machine which they are attached to, whereas named volumes are
+// created through the wrapped, store-backed volume service.
+type unstoredVolumes struct {
+	volumev1alpha1.VolumeService
+	driver volumev1alpha1.VolumeService
+}
+
+// Create implements kraftkit.sh/api/volume/v1alpha1.Create
+func (service unstoredVolumes) Create(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	if len(volume.Spec.Source) > 0 {
+		return service.driver.Create(ctx, volume)
+	}
+
+	return service.VolumeService.Create(ctx, volume)
+}
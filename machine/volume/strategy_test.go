@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package volume
+
+import (
+	"context"
+	"testing"
+
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+)
+
+// countingVolumes records the names of the volumes which it creates.
+type countingVolumes struct {
+	volumev1alpha1.VolumeService
+	created *[]string
+}
+
+func (service countingVolumes) Create(_ context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	*service.created = append(*service.created, volume.Name)
+	return volume, nil
+}
+
+func TestUnstoredVolumes(t *testing.T) {
+	var stored, driver []string
+
+	service := unstoredVolumes{
+		VolumeService: countingVolumes{created: &stored},
+		driver:        countingVolumes{created: &driver},
+	}
+
+	bound := &volumev1alpha1.Volume{Spec: volumev1alpha1.VolumeSpec{Source: "/data"}}
+	bound.Name = "/data"
+
+	named := &volumev1alpha1.Volume{}
+	named.Name = "named"
+
+	for _, volume := range []*volumev1alpha1.Volume{bound, named} {
+		if _, err := service.Create(context.Background(), volume); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(stored) != 1 || stored[0] != "named" {
+		t.Errorf("expected only the named volume to be stored, got %v", stored)
+	}
+
+	if len(driver) != 1 || driver[0] != "/data" {
+		t.Errorf("expected the host path volume to bypass the store, got %v", driver)
+	}
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package virtiofs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"kraftkit.sh/internal/retrytimeout"
+	"kraftkit.sh/log"
+)
+
+// DaemonStartTimeout is the duration to wait for virtiofsd to listen on its
+// socket after it has been started.
+const DaemonStartTimeout = 5 * time.Second
+
+// daemonPaths are the well-known locations of virtiofsd which are searched when
+// it cannot be found in the PATH, since distributions commonly install it
+// outside of it.
+var daemonPaths = []string{
+	"/usr/libexec/virtiofsd",
+	"/usr/lib/qemu/virtiofsd",
+	"/usr/lib/virtiofsd",
+}
+
+// Daemon returns the path to the virtiofsd binary.
+func Daemon() (string, error) {
+	if bin, err := exec.LookPath("virtiofsd"); err == nil {
+		return bin, nil
+	}
+
+	for _, bin := range daemonPaths {
+		if fi, err := os.Stat(bin); err == nil && !fi.IsDir() {
+			return bin, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find virtiofsd: is it installed?")
+}
+
+// SocketPath returns the path of the vhost-user socket of the virtiofsd
+// instance which serves the volume with the provided tag to a machine whose
+// runtime files are stored in the provided directory.
+func SocketPath(stateDir, tag string) string {
+	return filepath.Join(stateDir, "virtiofsd_"+tag+".sock")
+}
+
+// daemonPid returns the PID of the running virtiofsd instance which listens on
+// the provided socket, or 0 if it is not running.
+func daemonPid(socket string) int {
+	b, err := os.ReadFile(socket + ".pid")
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0
+	}
+
+	if err := syscall.Kill(pid, 0); err != nil {
+		return 0
+	}
+
+	return pid
+}
+
+// IsRunning returns whether the virtiofsd instance which listens on the
+// provided socket is running.
+func IsRunning(socket string) bool {
+	return daemonPid(socket) > 0
+}
+
+// StartDaemon starts a virtiofsd instance in the background which shares the
+// provided directory via the provided socket, unless it is already running,
+// and waits until it accepts connections.  virtiofsd exits by itself once the
+// VMM disconnects, such that it is re-started with each launch of the VMM.
+// It is not restarted should it exit whilst the VMM is still running, since
+// the VMM cannot reconnect to it; the VMM driver considers the machine to have
+// failed instead.
+func StartDaemon(ctx context.Context, source, socket string) error {
+	if daemonPid(socket) > 0 {
+		return nil
+	}
+
+	bin, err := Daemon()
+	if err != nil {
+		return err
+	}
+
+	// Remove the socket of a previous instance, which is otherwise mistaken for
+	// the socket of the new instance.
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	logFile, err := os.Create(socket + ".log")
+	if err != nil {
+		return err
+	}
+
+	defer logFile.Close()
+
+	cmd := exec.Command(bin,
+		"--socket-path="+socket,
+		"--shared-dir="+source,
+		"--cache=auto",
+		"--sandbox=none",
+	)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+
+	log.G(ctx).
+		WithField("source", source).
+		WithField("socket", socket).
+		Debug("starting virtiofsd")
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start virtiofsd for %s: %v", source, err)
+	}
+
+	if err := os.WriteFile(socket+".pid", []byte(strconv.Itoa(cmd.Process.Pid)), 0o644); err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("could not write pid file of virtiofsd: %v", err)
+	}
+
+	// Reap the process when it exits whilst it is still a child of this
+	// process, e.g. when it fails to start.
+	var waitErr error
+	exited := make(chan struct{})
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+
+	if err := retrytimeout.RetryTimeout(DaemonStartTimeout, func() error {
+		select {
+		case <-exited:
+			return fmt.Errorf("virtiofsd exited: %v", waitErr)
+		default:
+		}
+
+		if _, err := os.Stat(socket); err != nil {
+			return fmt.Errorf("virtiofsd is not listening on %s", socket)
+		}
+
+		return nil
+	}); err != nil {
+		_ = StopDaemon(socket)
+
+		if errLog, err2 := os.ReadFile(socket + ".log"); err2 == nil && len(errLog) > 0 {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(errLog)))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// StopDaemon stops the virtiofsd instance which listens on the provided socket
+// if it is running.
+func StopDaemon(socket string) error {
+	if pid := daemonPid(socket); pid > 0 {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+			return fmt.Errorf("could not stop virtiofsd: %v", err)
+		}
+	}
+
+	for _, file := range []string{socket, socket + ".pid"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
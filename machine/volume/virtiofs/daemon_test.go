@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package virtiofs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDaemon(t *testing.T) {
+	inPath := t.TempDir()
+	outsidePath := filepath.Join(t.TempDir(), "virtiofsd")
+
+	for _, bin := range []string{filepath.Join(inPath, "virtiofsd"), outsidePath} {
+		if err := os.WriteFile(bin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := daemonPaths
+	t.Cleanup(func() { daemonPaths = saved })
+
+	tests := []struct {
+		name  string
+		path  string
+		paths []string
+		want  string
+	}{
+		{"path", inPath, []string{outsidePath}, filepath.Join(inPath, "virtiofsd")},
+		{"well-known", t.TempDir(), []string{filepath.Dir(outsidePath), outsidePath}, outsidePath},
+		{"missing", t.TempDir(), []string{filepath.Join(t.TempDir(), "virtiofsd")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", tt.path)
+			daemonPaths = tt.paths
+
+			got, err := Daemon()
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDaemonPid(t *testing.T) {
+	// A process which has exited and been reaped.
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"missing", "", 0},
+		{"running", strconv.Itoa(os.Getpid()) + "\n", os.Getpid()},
+		{"exited", strconv.Itoa(exited.Process.Pid), 0},
+		{"garbage", "virtiofsd", 0},
+		{"negative", "-1", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socket := SocketPath(t.TempDir(), "fs1")
+
+			if tt.content != "" {
+				if err := os.WriteFile(socket+".pid", []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := daemonPid(socket); got != tt.want {
+				t.Errorf("expected pid %d, got %d", tt.want, got)
+			}
+
+			if got := IsRunning(socket); got != (tt.want > 0) {
+				t.Errorf("expected running to be %t, got %t", tt.want > 0, got)
+			}
+		})
+	}
+}
+
+func TestStopDaemon(t *testing.T) {
+	socket := SocketPath(t.TempDir(), "fs1")
+
+	// Leftovers of an instance which has since exited.
+	for _, file := range []string{socket, socket + ".pid"} {
+		if err := os.WriteFile(file, []byte("0"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := StopDaemon(socket); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{socket, socket + ".pid"} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", file)
+		}
+	}
+}
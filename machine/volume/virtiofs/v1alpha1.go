@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package virtiofs implements volumes which are shared with machines via
+// virtio-fs.  Each volume is served by a virtiofsd process on the host, to
+// which the VMM connects via a vhost-user socket.
+package virtiofs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/kconfig"
+	"kraftkit.sh/machine/volume/hostdir"
+)
+
+const (
+	// DriverName is the name of the virtio-fs volume driver.
+	DriverName = "virtiofs"
+
+	// KConfigOption is the Unikraft option which enables virtio-fs support in
+	// the kernel of a machine.
+	KConfigOption = "CONFIG_LIBVIRTIOFS"
+)
+
+// IsCompatible returns whether the provided KConfig of the kernel of a machine
+// includes virtio-fs support.  Without a KConfig, virtio-fs support cannot be
+// determined and is therefore not assumed.  Only directories can be shared.
+func IsCompatible(source string, kvm kconfig.KeyValueMap) (bool, error) {
+	if kvm == nil {
+		return false, nil
+	}
+
+	if fi, err := os.Stat(source); err == nil && !fi.IsDir() {
+		return false, nil
+	}
+
+	opt, ok := kvm[KConfigOption]
+	return ok && opt.Value == kconfig.Yes, nil
+}
+
+type v1alpha1Volume struct {
+	hostdir.Service
+}
+
+func NewVolumeServiceV1alpha1(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
+	return &v1alpha1Volume{hostdir.Service{Driver: DriverName}}, nil
+}
+
+// Create implements kraftkit.sh/api/volume/v1alpha1.Create.  Like 9pfs
+// volumes, volumes without a host path are named volumes whose host directory
+// is created within the runtime directory.
+func (service *v1alpha1Volume) Create(ctx context.Context, volume *volumev1alpha1.Volume) (*volumev1alpha1.Volume, error) {
+	volume, err := service.Service.Create(ctx, volume)
+	if err != nil {
+		return volume, err
+	}
+
+	if fi, err := os.Stat(volume.Spec.Source); err != nil {
+		return volume, fmt.Errorf("cannot stat host path volume: %w", err)
+	} else if !fi.IsDir() {
+		return volume, fmt.Errorf("cannot share %s via virtiofs: not a directory", volume.Spec.Source)
+	}
+
+	// virtiofsd requires an absolute path to the shared directory.
+	volume.Spec.Source, err = filepath.Abs(volume.Spec.Source)
+	if err != nil {
+		return volume, err
+	}
+
+	return volume, nil
+}
@@ -26,7 +26,6 @@ import (
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 
-	"kraftkit.sh/archive"
 	"kraftkit.sh/log"
 )
 
@@ -571,7 +570,7 @@ func (handle *ContainerdHandler) UnpackImage(ctx context.Context, ref string, de
 			return err
 		}
 
-		if err := archive.Untar(content.NewReader(ra), dest); err != nil {
+		if err := ApplyLayer(ctx, content.NewReader(ra), dest); err != nil {
 			return err
 		}
 
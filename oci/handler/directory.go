@@ -5,7 +5,6 @@
 package handler
 
 import (
-	"archive/tar"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -190,12 +189,12 @@ func (handle *DirectoryHandler) SaveDigest(ctx context.Context, ref string, desc
 	return nil
 }
 
-// ResolveImage implements ImageResolver.
-func (handle *DirectoryHandler) ResolveImage(ctx context.Context, fullref string) (imgspec ocispec.Image, err error) {
-	// Find the manifest of this image
+// resolveManifest returns the manifest of the image with the provided
+// reference.
+func (handle *DirectoryHandler) resolveManifest(fullref string) (ocispec.Manifest, error) {
 	ref, err := name.ParseReference(fullref)
 	if err != nil {
-		return ocispec.Image{}, err
+		return ocispec.Manifest{}, err
 	}
 
 	var jsonPath string
@@ -213,23 +212,34 @@ func (handle *DirectoryHandler) ResolveImage(ctx context.Context, fullref string
 
 	// Check whether the manifest exists
 	if _, err := os.Stat(manifestPath); err != nil {
-		return ocispec.Image{}, fmt.Errorf("manifest for %s does not exist: %s", ref.Name(), manifestPath)
+		return ocispec.Manifest{}, fmt.Errorf("manifest for %s does not exist: %s", ref.Name(), manifestPath)
 	}
 
 	// Read the manifest
-	reader, err := os.Open(manifestPath)
+	manifestRaw, err := os.ReadFile(manifestPath)
 	if err != nil {
-		return ocispec.Image{}, err
+		return ocispec.Manifest{}, err
 	}
 
-	manifestRaw, err := io.ReadAll(reader)
+	// Unmarshal the manifest
+	manifest := ocispec.Manifest{}
+	if err = json.Unmarshal(manifestRaw, &manifest); err != nil {
+		return ocispec.Manifest{}, err
+	}
+
+	return manifest, nil
+}
+
+// ResolveImage implements ImageResolver.
+func (handle *DirectoryHandler) ResolveImage(ctx context.Context, fullref string) (imgspec ocispec.Image, err error) {
+	ref, err := name.ParseReference(fullref)
 	if err != nil {
 		return ocispec.Image{}, err
 	}
 
-	// Unmarshal the manifest
-	manifest := ocispec.Manifest{}
-	if err = json.Unmarshal(manifestRaw, &manifest); err != nil {
+	// Find the manifest of this image
+	manifest, err := handle.resolveManifest(fullref)
+	if err != nil {
 		return ocispec.Image{}, err
 	}
 
@@ -253,11 +263,13 @@ func (handle *DirectoryHandler) ResolveImage(ctx context.Context, fullref string
 	}
 
 	// Read the config
-	reader, err = os.Open(configDir)
+	reader, err := os.Open(configDir)
 	if err != nil {
 		return ocispec.Image{}, err
 	}
 
+	defer reader.Close()
+
 	configRaw, err := io.ReadAll(reader)
 	if err != nil {
 		return ocispec.Image{}, err
@@ -463,72 +475,30 @@ func (handle *DirectoryHandler) PushImage(ctx context.Context, fullref string, t
 
 // UnpackImage implements ImageUnpacker.
 func (handle *DirectoryHandler) UnpackImage(ctx context.Context, ref string, dest string) (err error) {
-	img, err := handle.ResolveImage(ctx, ref)
+	manifest, err := handle.resolveManifest(ref)
 	if err != nil {
 		return err
 	}
 
-	// Iterate over the layers
-	for _, layer := range img.RootFS.DiffIDs {
-		// Get the digest
-		digest, err := v1.NewHash(layer.String())
-		if err != nil {
-			return err
-		}
-
-		// Get the layer path
+	// Apply the layers in order, which are stored by the digest of their
+	// possibly compressed contents.
+	for _, layer := range manifest.Layers {
 		layerPath := filepath.Join(
 			handle.path,
 			DirectoryHandlerLayersDir,
-			digest.Algorithm,
-			digest.Hex,
+			layer.Digest.Algorithm().String(),
+			layer.Digest.Encoded(),
 		)
 
-		// Layer path is a tarball, so we need to extract it
 		reader, err := os.Open(layerPath)
 		if err != nil {
 			return err
 		}
 
-		defer reader.Close()
-
-		tr := tar.NewReader(reader)
-
-		for {
-			hdr, err := tr.Next()
-			if err != nil {
-				break
-			}
-
-			// Write the file to the destination
-			path := filepath.Join(dest, hdr.Name)
-
-			// If the file is a directory, create it
-			if hdr.Typeflag == tar.TypeDir {
-				if err := os.MkdirAll(path, 0o775); err != nil {
-					return err
-				}
-				continue
-			}
-
-			// If the directory in the path doesn't exist, create it
-			if _, err := os.Stat(path[:strings.LastIndex(path, "/")]); os.IsNotExist(err) {
-				if err := os.MkdirAll(path[:strings.LastIndex(path, "/")], 0o775); err != nil {
-					return err
-				}
-			}
-
-			// Otherwise, create the file
-			writer, err := os.Create(path)
-			if err != nil {
-				return err
-			}
-
-			defer writer.Close()
-
-			if _, err = io.Copy(writer, tr); err != nil {
-				return err
-			}
+		err = ApplyLayer(ctx, reader, dest)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("could not apply layer %s: %w", layer.Digest.String(), err)
 		}
 	}
 
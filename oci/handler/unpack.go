@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"kraftkit.sh/internal/unpack"
+	"kraftkit.sh/log"
+)
+
+const (
+	// WhiteoutPrefix is the prefix of the name of entries of a layer which mark
+	// the removal of the entry with the remainder of the name from the lower
+	// layers.
+	WhiteoutPrefix = ".wh."
+
+	// WhiteoutOpaque is the name of the entry of a layer which marks that the
+	// contents of its directory in the lower layers are hidden.
+	WhiteoutOpaque = WhiteoutPrefix + ".wh..opq"
+)
+
+// FileModes are the modes of the entries of the layers of an image as stored
+// in the layers, keyed by the absolute path of the entry within the image.
+type FileModes map[string]fs.FileMode
+
+type fileModesKey struct{}
+
+// WithFileModes returns a context with which ApplyLayer records the modes of
+// the entries it extracts in the provided map.  Extracted entries always remain
+// accessible by their owner on the host, such that e.g. a 0000 /etc/shadow can
+// still be read, and the recorded modes allow restoring the modes of the image
+// when it is serialized, e.g. into an initramfs.
+func WithFileModes(ctx context.Context, modes FileModes) context.Context {
+	return context.WithValue(ctx, fileModesKey{}, modes)
+}
+
+// fileModes returns the map in which the modes of extracted entries are
+// recorded, if any.
+func fileModes(ctx context.Context) FileModes {
+	modes, _ := ctx.Value(fileModesKey{}).(FileModes)
+	return modes
+}
+
+// ApplyLayer extracts the provided layer of an image on top of the filesystem
+// at the provided destination, which contains the lower layers of the image.
+// Whiteouts of the layer remove the respective entries of the lower layers.
+// Device files cannot be created without privileges and are skipped.  Files
+// are made readable and writable, and directories additionally searchable, by
+// their owner; see WithFileModes for retaining the modes of the layer.
+func ApplyLayer(ctx context.Context, layer io.Reader, dest string) error {
+	r, err := unpack.Decompress(layer)
+	if err != nil {
+		return fmt.Errorf("could not decompress layer: %w", err)
+	}
+
+	defer r.Close()
+
+	modes := fileModes(ctx)
+
+	// The entries of this layer, including their parent directories, which are
+	// retained by opaque whiteouts.
+	extracted := map[string]bool{}
+
+	// The modes of directories are only set after extraction such that
+	// read-only directories can be populated.
+	var dirs unpack.Dirs
+
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		// Entries are confined to the destination.
+		rel := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if rel == "" {
+			continue
+		}
+
+		dir, base := path.Split(rel)
+		if err := unpack.CheckParents(dest, dir); err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, filepath.FromSlash(rel))
+
+		if base == WhiteoutOpaque {
+			if err := removeOpaque(filepath.Join(dest, filepath.FromSlash(dir)), path.Clean(dir), extracted); err != nil {
+				return err
+			}
+
+			continue
+		} else if strings.HasPrefix(base, WhiteoutPrefix) {
+			if err := os.RemoveAll(filepath.Join(dest, filepath.FromSlash(dir), strings.TrimPrefix(base, WhiteoutPrefix))); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		for p := rel; p != "."; p = path.Dir(p) {
+			extracted[p] = true
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		// Entries of the lower layers are replaced, except for directories which
+		// are merged.
+		if fi, err := os.Lstat(target); err == nil && !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+
+		mode := hdr.FileInfo().Mode()
+
+		if modes != nil && hdr.Typeflag != tar.TypeSymlink {
+			modes["/"+rel] = mode
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+
+			dirs.Add(target, mode|0o700, hdr.ModTime)
+
+			continue
+
+		case tar.TypeReg, tar.TypeRegA:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+
+			continue
+
+		case tar.TypeLink:
+			source := strings.TrimPrefix(path.Clean("/"+hdr.Linkname), "/")
+			if err := unpack.CheckParents(dest, path.Dir(source)); err != nil {
+				return err
+			}
+
+			if err := os.Link(filepath.Join(dest, filepath.FromSlash(source)), target); err != nil {
+				return err
+			}
+
+			continue
+
+		default:
+			log.G(ctx).
+				WithField("path", hdr.Name).
+				Debugf("skipping unsupported tar entry type %c", hdr.Typeflag)
+
+			continue
+		}
+
+		if err := unpack.SetAttributes(target, mode|0o600, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	return dirs.Restore()
+}
+
+// removeOpaque removes the contents of the provided directory which were not
+// extracted from the current layer.
+func removeOpaque(dir, rel string, extracted map[string]bool) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		child := path.Join(rel, entry.Name())
+
+		if !extracted[child] {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		} else if entry.IsDir() {
+			if err := removeOpaque(filepath.Join(dir, entry.Name()), child, extracted); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
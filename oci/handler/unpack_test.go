@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package handler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// tarEntry is an entry of a layer, which is a directory if it has no contents
+// and its name ends with a slash.
+type tarEntry struct {
+	name     string
+	contents string
+}
+
+func buildLayer(t *testing.T, entries []tarEntry, compress bool) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var gw *gzip.Writer
+
+	if compress {
+		gw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(gw)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Name:     entry.name,
+			Mode:     0o644,
+			Typeflag: tar.TypeReg,
+			Size:     int64(len(entry.contents)),
+		}
+
+		if entry.name[len(entry.name)-1] == '/' {
+			hdr.Mode = 0o755
+			hdr.Typeflag = tar.TypeDir
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(entry.contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if gw != nil {
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &buf
+}
+
+func TestApplyLayer(t *testing.T) {
+	lower := []tarEntry{
+		{name: "etc/"},
+		{name: "etc/hostname", contents: "lower"},
+		{name: "etc/passwd", contents: "root"},
+		{name: "var/"},
+		{name: "var/cache/"},
+		{name: "var/cache/a", contents: "a"},
+		{name: "var/cache/b", contents: "b"},
+	}
+
+	tests := []struct {
+		name     string
+		upper    []tarEntry
+		expected []string
+	}{
+		{
+			name: "replaces files",
+			upper: []tarEntry{
+				{name: "etc/hostname", contents: "upper"},
+			},
+			expected: []string{"etc", "etc/hostname=upper", "etc/passwd=root", "var", "var/cache", "var/cache/a=a", "var/cache/b=b"},
+		},
+		{
+			name: "removes whiteouts",
+			upper: []tarEntry{
+				{name: "etc/.wh.passwd"},
+				{name: "var/.wh.cache"},
+			},
+			expected: []string{"etc", "etc/hostname=lower", "var"},
+		},
+		{
+			name: "hides opaque directories",
+			upper: []tarEntry{
+				{name: "var/cache/"},
+				{name: "var/cache/c", contents: "c"},
+				{name: "var/cache/.wh..wh..opq"},
+			},
+			expected: []string{"etc", "etc/hostname=lower", "etc/passwd=root", "var", "var/cache", "var/cache/c=c"},
+		},
+		{
+			name: "confines entries",
+			upper: []tarEntry{
+				{name: "../../escaped", contents: "escaped"},
+			},
+			expected: []string{"escaped=escaped", "etc", "etc/hostname=lower", "etc/passwd=root", "var", "var/cache", "var/cache/a=a", "var/cache/b=b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := t.TempDir()
+
+			if err := ApplyLayer(context.Background(), buildLayer(t, lower, true), dest); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ApplyLayer(context.Background(), buildLayer(t, tt.upper, false), dest); err != nil {
+				t.Fatal(err)
+			}
+
+			var found []string
+			err := filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+				if err != nil || path == dest {
+					return err
+				}
+
+				rel, _ := filepath.Rel(dest, path)
+				rel = filepath.ToSlash(rel)
+
+				if !d.IsDir() {
+					b, err := os.ReadFile(path)
+					if err != nil {
+						return err
+					}
+
+					rel += "=" + string(b)
+				}
+
+				found = append(found, rel)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sort.Strings(found)
+
+			if !reflect.DeepEqual(found, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, found)
+			}
+		})
+	}
+}
+
+func TestApplyLayerModes(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+
+	for _, hdr := range []*tar.Header{
+		{Name: "etc/", Mode: 0o555, Typeflag: tar.TypeDir},
+		{Name: "etc/shadow", Mode: 0o000, Typeflag: tar.TypeReg, Size: 6},
+		{Name: "usr/", Mode: 0o755, Typeflag: tar.TypeDir},
+		{Name: "usr/bin/su", Mode: 0o4755, Typeflag: tar.TypeReg, Size: 6},
+		{Name: "usr/bin/sudo", Linkname: "usr/bin/su", Mode: 0o4755, Typeflag: tar.TypeLink},
+		{Name: "usr/sbin", Linkname: "bin", Mode: 0o777, Typeflag: tar.TypeSymlink},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write(make([]byte, hdr.Size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	modes := FileModes{}
+
+	if err := ApplyLayer(WithFileModes(context.Background(), modes), &buf, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	// The modes of the layer are recorded, except for those of symbolic links
+	// which have no modes of their own.
+	expected := FileModes{
+		"/etc":          fs.ModeDir | 0o555,
+		"/etc/shadow":   0o000,
+		"/usr":          fs.ModeDir | 0o755,
+		"/usr/bin/su":   fs.ModeSetuid | 0o755,
+		"/usr/bin/sudo": fs.ModeSetuid | 0o755,
+	}
+
+	if !reflect.DeepEqual(modes, expected) {
+		t.Errorf("expected modes %v, got %v", expected, modes)
+	}
+
+	// Whilst the extracted entries remain accessible by their owner.
+	for path, mode := range map[string]fs.FileMode{
+		"etc":        fs.ModeDir | 0o755,
+		"etc/shadow": 0o600,
+		"usr/bin/su": fs.ModeSetuid | 0o755,
+	} {
+		fi, err := os.Lstat(filepath.Join(dest, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fi.Mode() != mode {
+			t.Errorf("expected %s to have mode %v, got %v", path, mode, fi.Mode())
+		}
+	}
+
+	if _, err := os.ReadFile(filepath.Join(dest, "etc", "shadow")); err != nil {
+		t.Errorf("could not read extracted file: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dest, "etc", "hostname"), []byte("kraft"), 0o644); err != nil {
+		t.Errorf("could not write into extracted directory: %v", err)
+	}
+}
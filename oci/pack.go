@@ -46,6 +46,7 @@ type ociPackage struct {
 	kconfig    kconfig.KeyValueMap
 	kernel     string
 	initrd     initrd.Initrd
+	rootfs     string
 	entrypoint string
 	command    []string
 }
@@ -195,6 +196,24 @@ func NewPackageFromTarget(ctx context.Context, targ target.Target, opts ...packm
 		}
 	}
 
+	if popts.RootfsImage() != "" {
+		log.G(ctx).Debug("oci: including rootfs image")
+		layer, err := NewLayerFromFile(ctx,
+			ocispec.MediaTypeImageLayer,
+			popts.RootfsImage(),
+			WellKnownRootfsImagePath,
+			WithLayerAnnotation(fmt.Sprintf(AnnotationDiskIndexPathPattern, 0), WellKnownRootfsImagePath),
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(layer.tmp)
+
+		if _, err := image.AddLayer(ctx, layer); err != nil {
+			return nil, err
+		}
+	}
+
 	// TODO(nderjung): See below.
 
 	// if popts.PackKernelLibraryObjects() {
@@ -473,6 +492,12 @@ unpack:
 				return err
 			}
 		}
+
+		// Set the rootfs image if available
+		rootfsPath := filepath.Join(popts.Workdir(), WellKnownRootfsImagePath)
+		if f, err := os.Stat(rootfsPath); err == nil && f.Size() > 0 {
+			ocipack.rootfs = rootfsPath
+		}
 	}
 
 	return nil
@@ -533,6 +558,12 @@ func (ocipack *ociPackage) Initrd() initrd.Initrd {
 	return ocipack.initrd
 }
 
+// RootfsImage returns the path of the filesystem image of the rootfs which is
+// shipped with the package, if any.
+func (ocipack *ociPackage) RootfsImage() string {
+	return ocipack.rootfs
+}
+
 // Entrypoint implements unikraft.target.Target
 func (ocipack *ociPackage) Entrypoint() string {
 	return ocipack.entrypoint
@@ -7,6 +7,7 @@ package oci
 const (
 	WellKnownKernelPath      = "/unikraft/bin/kernel"
 	WellKnownInitrdPath      = "/unikraft/bin/initrd"
+	WellKnownRootfsImagePath = "/unikraft/bin/rootfs.img"
 	WellKnownConfigPath      = "/unikraft/bin/config"
 	WellKnownKernelSourceDir = "/unikraft/src"
 	WellKnownAppSourceDir    = "/unikraft/app"
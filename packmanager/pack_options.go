@@ -17,6 +17,7 @@ type PackOptions struct {
 	kernelVersion                    string
 	name                             string
 	output                           string
+	rootfsImage                      string
 }
 
 // PackAppSourceFiles returns whether the application source files should be
@@ -71,6 +72,12 @@ func (popts *PackOptions) Output() string {
 	return popts.output
 }
 
+// RootfsImage returns the path of the filesystem image of the rootfs that
+// should be packaged.
+func (popts *PackOptions) RootfsImage() string {
+	return popts.rootfsImage
+}
+
 // PackOption is an option function which is used to modify PackOptions.
 type PackOption func(*PackOptions)
 
@@ -102,6 +109,14 @@ func PackInitrd(initrd string) PackOption {
 	}
 }
 
+// PackRootfsImage includes the provided path to a filesystem image of the rootfs
+// in the package, which is attached to the machine as a block device.
+func PackRootfsImage(image string) PackOption {
+	return func(popts *PackOptions) {
+		popts.rootfsImage = image
+	}
+}
+
 // PackKernelLibraryIntermediateObjects marks to include intermediate library
 // object files, e.g. libnolibc/errno.o
 func PackKernelLibraryIntermediateObjects(pack bool) PackOption {
@@ -41,6 +41,7 @@ func (opts *GithubAction) packAndPush(ctx context.Context) error {
 
 	if opts.Rootfs != "" {
 		ramfs, err := initrd.New(ctx, opts.Rootfs,
+			initrd.WithOwner(0, 0),
 			initrd.WithCacheDir(filepath.Join(opts.Workdir, unikraft.BuildDir, "rootfs")),
 		)
 		if err != nil {
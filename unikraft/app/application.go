@@ -68,6 +68,10 @@ type Application interface {
 	// Command is the list of arguments passed to the application's runtime.
 	Command() []string
 
+	// HealthCheck is the desired probe which determines whether the application
+	// is able to serve.
+	HealthCheck() *HealthCheck
+
 	// Extensions returns the application's extensions
 	Extensions() component.Extensions
 
@@ -156,6 +160,7 @@ type application struct {
 	entrypoint    string
 	command       []string
 	rootfs        string
+	healthCheck   *HealthCheck
 	kraftfile     *Kraftfile
 	configuration kconfig.KeyValueMap
 	extensions    component.Extensions
@@ -232,6 +237,10 @@ func (app application) Command() []string {
 	return app.command
 }
 
+func (app application) HealthCheck() *HealthCheck {
+	return app.healthCheck
+}
+
 func (app application) Extensions() component.Extensions {
 	return app.extensions
 }
@@ -786,6 +795,10 @@ func (app application) MarshalYAML() (interface{}, error) {
 		ret["libraries"] = app.libraries
 	}
 
+	if app.healthCheck != nil {
+		ret["healthcheck"] = app.healthCheck
+	}
+
 	return ret, nil
 }
 
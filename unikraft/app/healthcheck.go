@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package app
+
+import "time"
+
+// HealthCheck is the health check of an application as it is specified in its
+// Kraftfile, e.g.:
+//
+//	healthcheck:
+//	  http: http://localhost:8080/
+//	  interval: 5s
+//	  retries: 3
+type HealthCheck struct {
+	TCP         string        `yaml:"tcp,omitempty"`
+	HTTP        string        `yaml:"http,omitempty"`
+	Serial      string        `yaml:"serial,omitempty"`
+	Interval    time.Duration `yaml:"interval,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty"`
+	StartPeriod time.Duration `yaml:"start_period,omitempty" mapstructure:"start_period"`
+	Retries     int           `yaml:"retries,omitempty"`
+}
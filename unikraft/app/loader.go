@@ -85,6 +85,10 @@ func NewApplicationFromInterface(ctx context.Context, iface map[string]interface
 		app.outDir = popts.RelativePath(outdir)
 	}
 
+	if err := Transform(ctx, getSection(iface, "healthcheck"), &app.healthCheck); err != nil {
+		return nil, err
+	}
+
 	if err := Transform(ctx, getSection(iface, "unikraft"), &app.unikraft); err != nil {
 		return nil, err
 	}
@@ -12,11 +12,15 @@ var (
 	ParamIpv4Addr       = ukargparse.ParamStr("netdev", "ipv4_addr", nil)
 	ParamIpv4SubnetMask = ukargparse.ParamStr("netdev", "ipv4_subnet_mask", nil)
 	ParamIpv4GwAddr     = ukargparse.ParamStr("netdev", "ipv4_gw_addr", nil)
+	ParamIpv4Dns0       = ukargparse.ParamStr("netdev", "ipv4_dns0", nil)
 )
 
 // ExportedParams returns the parameters available by this exported library.
 func ExportedParams() []ukargparse.Param {
 	return []ukargparse.Param{
 		ParamIpv4Addr,
+		ParamIpv4SubnetMask,
+		ParamIpv4GwAddr,
+		ParamIpv4Dns0,
 	}
 }